@@ -61,12 +61,13 @@ package bgql
 
 import (
 	"github.com/ubugeeei/bgql/bindings/go/bgql/client"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/internal/version"
 	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
 	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
 )
 
 // Version returns the bgql version.
-const Version = "0.1.0"
+const Version = version.Version
 
 // Re-export client types
 type (
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// tokenFetch is a call of Config.TokenSource.
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// authorized calls send with a context adding the TokenSource token to
+// the request. If rejected reports that the server refused it and retry
+// is set, the token is refreshed and send called once more. Requests
+// given an Authorization header of their own are sent as they are.
+func authorized[T any](c *Client, ctx context.Context, retry bool, send func(context.Context) (T, error), rejected func(T, error) bool) (T, error) {
+	if c.config.TokenSource == nil {
+		return send(ctx)
+	}
+	if added, ok := ctx.Value(requestHeadersKey{}).(http.Header); ok && added.Get("Authorization") != "" {
+		return send(ctx)
+	}
+
+	token, err := c.currentToken(ctx, "")
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	result, err := send(withRequestHeader(ctx, "Authorization", "Bearer "+token))
+	if !retry || !rejected(result, err) {
+		return result, err
+	}
+	if token, err = c.currentToken(ctx, token); err != nil {
+		var zero T
+		return zero, err
+	}
+	return send(withRequestHeader(ctx, "Authorization", "Bearer "+token))
+}
+
+// currentToken returns the token to send, calling TokenSource when there
+// is none yet or it is stale, the one the server rejected. Callers wait
+// for the call in flight rather than making their own.
+func (c *Client) currentToken(ctx context.Context, stale string) (string, error) {
+	c.tokenMu.Lock()
+	if c.token != "" && c.token != stale {
+		token := c.token
+		c.tokenMu.Unlock()
+		return token, nil
+	}
+	f := c.tokenFetch
+	if f == nil {
+		f = &tokenFetch{done: make(chan struct{})}
+		c.tokenFetch = f
+		// The call outlives callers that give up on it.
+		go func(ctx context.Context) {
+			token, err := c.config.TokenSource(ctx)
+			c.tokenMu.Lock()
+			f.token, f.err = token, err
+			if err == nil {
+				c.token = token
+			}
+			c.tokenFetch = nil
+			c.tokenMu.Unlock()
+			close(f.done)
+		}(context.WithoutCancel(ctx))
+	}
+	c.tokenMu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return "", fmt.Errorf("failed to get token: %w", f.err)
+		}
+		return f.token, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// unauthenticated reports whether the server rejected a request's
+// credentials, with a 401 status or an UNAUTHENTICATED error.
+func unauthenticated(resp *Response, err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode == http.StatusUnauthorized {
+			return true
+		}
+		resp = httpErr.Response
+	}
+	if resp == nil {
+		return false
+	}
+	for _, gqlErr := range resp.Errors {
+		if gqlErr.Extensions["code"] == "UNAUTHENTICATED" {
+			return true
+		}
+	}
+	return false
+}
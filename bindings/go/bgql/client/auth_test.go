@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// tokenServer accepts requests bearing the token in valid. It rejects
+// others with a 401, or an UNAUTHENTICATED error when graphqlErrors is
+// set, and counts requests.
+func tokenServer(t *testing.T, valid *atomic.Value, graphqlErrors bool, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") == "Bearer "+valid.Load().(string) {
+			body, _ := io.ReadAll(r.Body)
+			if bytes.HasPrefix(body, []byte("[")) {
+				w.Write([]byte(`[{"data":{"a":1}},{"data":{"a":2}}]`))
+				return
+			}
+			w.Write([]byte(`{"data":{"a":1}}`))
+			return
+		}
+		if graphqlErrors {
+			w.Write([]byte(`{"data":null,"errors":[{"message":"Invalid token.","extensions":{"code":"UNAUTHENTICATED"}}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"message":"Invalid token."}]}`))
+	}))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func TestTokenSource(t *testing.T) {
+	tests := []struct {
+		name          string
+		tokens        []string
+		valid         string
+		graphqlErrors bool
+		wantErr       string
+		wantCalls     int32
+		wantRequests  int32
+	}{
+		{"token sent", []string{"t1"}, "t1", false, "", 1, 2},
+		{"refreshed on 401", []string{"old", "t2"}, "t2", false, "", 2, 3},
+		{"refreshed on UNAUTHENTICATED", []string{"old", "t2"}, "t2", true, "", 2, 3},
+		{"retried once", []string{"old", "older", "t3"}, "t3", false, "HTTP 401", 2, 2},
+		{"source fails", nil, "t1", false, "failed to get token: no token", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var valid atomic.Value
+			valid.Store(tt.valid)
+			var requests, calls atomic.Int32
+			hs := tokenServer(t, &valid, tt.graphqlErrors, &requests)
+			c := NewWithConfig(Config{URL: hs.URL, TokenSource: func(ctx context.Context) (string, error) {
+				n := int(calls.Add(1))
+				if n > len(tt.tokens) {
+					return "", errors.New("no token")
+				}
+				return tt.tokens[n-1], nil
+			}})
+
+			// The second request reuses the token the first ended with.
+			for i := 0; i < 2; i++ {
+				res := c.Query(context.Background(), "{ a }", nil)
+				if tt.wantErr != "" {
+					if res.IsOk() || !strings.Contains(res.Error().Error(), tt.wantErr) {
+						t.Fatalf("result = %v, want error %q", res, tt.wantErr)
+					}
+					break
+				}
+				if res.IsErr() {
+					t.Fatalf("request %d: %v", i, res.Error())
+				}
+			}
+			if calls.Load() != tt.wantCalls || requests.Load() != tt.wantRequests {
+				t.Errorf("TokenSource calls = %d, requests = %d; want %d and %d", calls.Load(), requests.Load(), tt.wantCalls, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestTokenSourceSharesRefresh(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("t0")
+	var requests, calls atomic.Int32
+	hs := tokenServer(t, &valid, false, &requests)
+	c := NewWithConfig(Config{URL: hs.URL, TokenSource: func(ctx context.Context) (string, error) {
+		n := calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return fmt.Sprintf("t%d", n-1), nil
+	}})
+	if res := c.Query(context.Background(), "{ a }", nil); res.IsErr() {
+		t.Fatal(res.Error())
+	}
+
+	// The token expires; concurrent requests share one refresh.
+	valid.Store("t1")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := c.Query(context.Background(), "{ a }", nil); res.IsErr() {
+				t.Error(res.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 2 {
+		t.Errorf("TokenSource calls = %d, want 2", n)
+	}
+}
+
+func TestTokenSourceYieldsToRequestHeaders(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("user")
+	var requests atomic.Int32
+	hs := tokenServer(t, &valid, false, &requests)
+	var calls atomic.Int32
+	c := NewWithConfig(Config{URL: hs.URL, TokenSource: func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return "service", nil
+	}}).Use(ForwardHeadersMiddleware("Authorization"))
+
+	ctx := sdk.RequestHeaders.Set(context.Background(), http.Header{"Authorization": {"Bearer user"}})
+	if res := c.Query(ctx, "{ a }", nil); res.IsErr() || calls.Load() != 0 {
+		t.Errorf("forwarded request: %v, TokenSource calls = %d", res, calls.Load())
+	}
+	if res := c.Query(context.Background(), "{ a }", nil); res.IsOk() {
+		t.Errorf("service token accepted for the user")
+	}
+}
+
+func TestTokenSourceBatches(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("t2")
+	var requests, calls atomic.Int32
+	hs := tokenServer(t, &valid, false, &requests)
+	c := NewWithConfig(Config{URL: hs.URL, TokenSource: func(ctx context.Context) (string, error) {
+		return fmt.Sprintf("t%d", calls.Add(1)), nil
+	}})
+	resps, err := c.doBatch(context.Background(), []*Request{{Query: "{ a }"}, {Query: "{ a }"}})
+	if err != nil || len(resps) != 2 || string(resps[1].Data) != `{"a":2}` {
+		t.Fatalf("batch = %v, %v", resps, err)
+	}
+	if calls.Load() != 2 || requests.Load() != 2 {
+		t.Errorf("TokenSource calls = %d, requests = %d; want a refresh and one retry", calls.Load(), requests.Load())
+	}
+}
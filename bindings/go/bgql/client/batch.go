@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// =============================================================================
+// Batching
+// =============================================================================
+
+// BatchOption configures a Batcher.
+type BatchOption func(*Batcher)
+
+// BatchInterval sets how long the first request of a batch waits for more
+// to join it. Defaults to 10ms.
+func BatchInterval(d time.Duration) BatchOption {
+	return func(b *Batcher) { b.interval = d }
+}
+
+// MaxBatchSize sets how many requests a batch holds before it is sent
+// without waiting out the interval. Defaults to 10.
+func MaxBatchSize(n int) BatchOption {
+	return func(b *Batcher) { b.maxSize = n }
+}
+
+// BatchMutations batches mutations as well as queries. Servers may
+// execute the requests of a batch concurrently, so mutations sent
+// together are not applied in a guaranteed order.
+func BatchMutations() BatchOption {
+	return func(b *Batcher) { b.mutations = true }
+}
+
+// Batcher sends queries made close together as one request. See
+// WithBatching.
+type Batcher struct {
+	client    *Client
+	interval  time.Duration
+	maxSize   int
+	mutations bool
+
+	mu      sync.Mutex
+	pending []*batchedRequest
+	timer   *time.Timer
+}
+
+type batchedRequest struct {
+	ctx  context.Context
+	req  *Request
+	next func(context.Context, *Request) (*Response, error)
+	done chan batchResult
+}
+
+type batchResult struct {
+	resp *Response
+	err  error
+}
+
+// WithBatching installs a middleware that collects the queries executed
+// within an interval, up to a maximum count, and posts them as a JSON
+// array, handing each caller its element of the response array.
+// Mutations, subscriptions, uploads, GET requests, and requests using
+// @defer or @stream are sent on their own.
+//
+// Middleware installed before WithBatching sees batched requests
+// individually; middleware installed after it only sees unbatched ones.
+func (c *Client) WithBatching(opts ...BatchOption) *Batcher {
+	b := &Batcher{
+		client:   c,
+		interval: 10 * time.Millisecond,
+		maxSize:  10,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	c.Use(b.middleware)
+	return b
+}
+
+func (b *Batcher) middleware(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+	if !b.batchable(req) {
+		return next(ctx, req)
+	}
+
+	r := &batchedRequest{ctx: ctx, req: req, next: next, done: make(chan batchResult, 1)}
+	b.add(r)
+	select {
+	case res := <-r.done:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Batcher) batchable(req *Request) bool {
+	if req.Method == http.MethodGet || wantsIncremental(req.Query) || findUploads(req) != nil {
+		return false
+	}
+	switch operationType(req) {
+	case language.Query:
+		return true
+	case language.Mutation:
+		return b.mutations
+	}
+	return false
+}
+
+// add queues r, sending the batch if it is full.
+func (b *Batcher) add(r *batchedRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, r)
+	if len(b.pending) >= b.maxSize {
+		go b.send(b.take())
+		return
+	}
+	if len(b.pending) == 1 {
+		b.timer = time.AfterFunc(b.interval, b.flush)
+	}
+}
+
+// take removes the pending batch. b.mu must be held.
+func (b *Batcher) take() []*batchedRequest {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// flush sends the pending batch.
+func (b *Batcher) flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+}
+
+// send sends a batch and hands out its responses. A batch of one is sent
+// as a plain request.
+func (b *Batcher) send(batch []*batchedRequest) {
+	if len(batch) == 1 {
+		r := batch[0]
+		resp, err := r.next(r.ctx, r.req)
+		r.done <- batchResult{resp, err}
+		return
+	}
+
+	reqs := make([]*Request, len(batch))
+	for i, r := range batch {
+		reqs[i] = r.req
+	}
+	// The batch outlives callers that give up on it.
+	resps, err := b.client.doBatch(context.WithoutCancel(batch[0].ctx), reqs)
+	for i, r := range batch {
+		if err != nil {
+			r.done <- batchResult{nil, err}
+			continue
+		}
+		r.done <- batchResult{resps[i], nil}
+	}
+}
+
+// doBatch posts reqs as a JSON array and returns their responses, in
+// order.
+func (c *Client) doBatch(ctx context.Context, reqs []*Request) ([]*Response, error) {
+	return authorized(c, ctx, true, func(ctx context.Context) ([]*Response, error) {
+		return c.batchRoundTrip(ctx, reqs)
+	}, func(resps []*Response, err error) bool {
+		if err != nil {
+			return unauthenticated(nil, err)
+		}
+		return slices.ContainsFunc(resps, func(resp *Response) bool { return unauthenticated(resp, nil) })
+	})
+}
+
+func (c *Client) batchRoundTrip(ctx context.Context, reqs []*Request) ([]*Response, error) {
+	sent := make([]*Request, len(reqs))
+	for i, req := range reqs {
+		sent[i] = c.wire(req)
+	}
+	body, err := json.Marshal(sent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	reqBody, contentEncoding := c.requestBody(body)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if contentEncoding != "" {
+		httpReq.Header.Set("Content-Encoding", contentEncoding)
+	}
+	c.setClientHeaders(httpReq.Header)
+	c.setRequestHeaders(ctx, httpReq.Header)
+
+	httpResp, err := send(c.httpClient, httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if httpResp.StatusCode >= 400 {
+		return nil, newHTTPError(httpResp, respBody)
+	}
+
+	var resps []*Response
+	if err := json.Unmarshal(respBody, &resps); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(resps) != len(reqs) {
+		return nil, fmt.Errorf("batch of %d requests got %d responses", len(reqs), len(resps))
+	}
+	for i, resp := range resps {
+		if resp == nil {
+			return nil, fmt.Errorf("batch response %d is null", i)
+		}
+		resp.StatusCode, resp.Header = httpResp.StatusCode, httpResp.Header
+	}
+	return resps, nil
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+)
+
+// newEchoServer serves echo(s) and a mutation set(s), recording the size
+// of each HTTP request: 0 for a single request, else its batch size.
+func newEchoServer(t *testing.T, sizes *[]int, mu *sync.Mutex) *httptest.Server {
+	t.Helper()
+	s := server.NewBuilder().
+		Schema(`type Query { echo(s: String!): String fail: Int } type Mutation { set(s: String!): String }`).
+		Resolver("Query", "echo", func(ctx *server.Context, p any, args map[string]any) (any, error) { return args["s"], nil }).
+		Resolver("Query", "fail", func(ctx *server.Context, p any, args map[string]any) (any, error) { return nil, errors.New("boom") }).
+		Resolver("Mutation", "set", func(ctx *server.Context, p any, args map[string]any) (any, error) { return args["s"], nil }).
+		Build().Unwrap()
+	handler := s.Handler()
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		size := 0
+		if bytes.HasPrefix(body, []byte("[")) {
+			size = bytes.Count(body, []byte(`"query"`))
+		}
+		mu.Lock()
+		*sizes = append(*sizes, size)
+		mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func TestBatching(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []BatchOption
+		queries   []string
+		wantSizes []int
+	}{
+		{"one batch", []BatchOption{BatchInterval(50 * time.Millisecond)}, []string{"a", "b", "c", "d"}, []int{4}},
+		{"full batches", []BatchOption{BatchInterval(time.Minute), MaxBatchSize(2)}, []string{"a", "b", "c", "d"}, []int{2, 2}},
+		{"single", []BatchOption{BatchInterval(time.Millisecond)}, []string{"a"}, []int{0}},
+		{"error in one element", []BatchOption{BatchInterval(50 * time.Millisecond)}, []string{"a", "!fail", "c"}, []int{3}},
+		{"mutations bypass", []BatchOption{BatchInterval(50 * time.Millisecond)}, []string{"a", "b", "=x", "=y"}, []int{0, 0, 2}},
+		{"mutations batched", []BatchOption{BatchInterval(50 * time.Millisecond), BatchMutations()}, []string{"a", "=x"}, []int{2}},
+		{"defer bypasses", []BatchOption{BatchInterval(50 * time.Millisecond)}, []string{"a", "b", "@defer"}, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var sizes []int
+			hs := newEchoServer(t, &sizes, &mu)
+			c := New(hs.URL + "/graphql")
+			var logged atomic.Int32
+			c.Use(func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+				logged.Add(1)
+				return next(ctx, req)
+			})
+			c.WithBatching(tt.opts...)
+
+			var wg sync.WaitGroup
+			for _, q := range tt.queries {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					query, want := fmt.Sprintf(`{ echo(s: %q) }`, q), fmt.Sprintf(`{"echo":%q}`, q)
+					switch q[0] {
+					case '!':
+						query = "{ fail }"
+					case '=':
+						query, want = fmt.Sprintf(`mutation { set(s: %q) }`, q), fmt.Sprintf(`{"set":%q}`, q)
+					case '@':
+						query = fmt.Sprintf(`{ ... @defer { echo(s: %q) } }`, q)
+					}
+					res := c.Execute(context.Background(), &Request{Query: query})
+					if q[0] == '!' {
+						if res.IsErr() || res.Unwrap().FirstError().Message != "boom" {
+							t.Errorf("%s: result = %v, want partial data with boom", q, res)
+						}
+						return
+					}
+					if res.IsErr() || string(res.Unwrap().Data) != want {
+						t.Errorf("%s: result = %v, want %s", q, res, want)
+					} else if resp := res.Unwrap(); resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") == "" {
+						t.Errorf("%s: status %d, header %v", q, resp.StatusCode, resp.Header)
+					}
+				}()
+				// Mutations go out first for a deterministic request order.
+				if q[0] == '=' || q[0] == '@' {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			wg.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			slices.Sort(sizes)
+			if !slices.Equal(sizes, tt.wantSizes) {
+				t.Errorf("HTTP requests = %v, want %v", sizes, tt.wantSizes)
+			}
+			if int(logged.Load()) != len(tt.queries) {
+				t.Errorf("middleware saw %d requests, want %d", logged.Load(), len(tt.queries))
+			}
+		})
+	}
+}
+
+func TestBatchingCallerCancels(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	hs := newEchoServer(t, &sizes, &mu)
+	c := New(hs.URL + "/graphql")
+	c.WithBatching(BatchInterval(50 * time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- c.Query(ctx, `{ echo(s: "a") }`, nil).Error()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	kept := make(chan string, 1)
+	go func() {
+		res := c.Query(context.Background(), `{ echo(s: "b") }`, nil)
+		if res.IsErr() {
+			kept <- res.Error().Error()
+			return
+		}
+		kept <- string(res.Unwrap().Data)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	if got := <-kept; got != `{"echo":"b"}` {
+		t.Errorf("other caller got %s", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(sizes) != "[2]" {
+		t.Errorf("HTTP requests = %v, want one batch of 2", sizes)
+	}
+}
+
+func TestBatchingHTTPError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"server error", http.StatusServiceUnavailable, `down`, "HTTP 503: down"},
+		{"short array", http.StatusOK, `[{"data":{"a":1}}]`, "batch of 2 requests got 1 responses"},
+		{"null element", http.StatusOK, `[{"data":{"a":1}},null]`, "batch response 1 is null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer hs.Close()
+			c := New(hs.URL)
+			c.WithBatching(BatchInterval(time.Minute), MaxBatchSize(2))
+
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() { errs <- c.Query(context.Background(), "{ a }", nil).Error() }()
+			}
+			for i := 0; i < 2; i++ {
+				if err := <-errs; err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -3,14 +3,40 @@ package client
 
 import (
 	"bytes"
+	"container/list"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"math/rand/v2"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/ubugeeei/bgql/bindings/go/bgql/internal/version"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/manifest"
 	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// DefaultUserAgent is the User-Agent sent when Config.UserAgent is empty.
+const DefaultUserAgent = "bgql-go/" + version.Version
+
+// Client identification headers understood by Apollo-compatible gateways.
+const (
+	ClientNameHeader    = "apollographql-client-name"
+	ClientVersionHeader = "apollographql-client-version"
 )
 
 // Config holds client configuration.
@@ -21,6 +47,74 @@ type Config struct {
 	MaxRetries    int
 	RetryInterval time.Duration
 	HTTPClient    *http.Client
+
+	// ErrorPolicy is how Execute treats responses with GraphQL errors.
+	// HTTP and network failures always fail.
+	ErrorPolicy ErrorPolicy
+
+	// UserAgent overrides DefaultUserAgent.
+	UserAgent string
+	// ClientName and ClientVersion identify the calling application.
+	// They are sent as the apollographql-client-name/-version headers when set.
+	ClientName    string
+	ClientVersion string
+
+	// WebSocketURL is the endpoint of subscriptions. Defaults to URL with
+	// the ws or wss scheme.
+	WebSocketURL string
+	// InitPayload is sent with the connection_init message of WebSocket
+	// connections, typically to authenticate them.
+	InitPayload map[string]any
+	// MaxReconnectAttempts is how many times in a row a dropped WebSocket
+	// connection, or SSE subscription stream, is reopened. Zero retries
+	// forever; a negative value never reconnects.
+	MaxReconnectAttempts int
+	// ReconnectInterval is the wait before the first reconnection
+	// attempt, doubled after each failure up to MaxReconnectInterval and
+	// jittered. Defaults to a second, and MaxReconnectInterval to 30
+	// seconds.
+	ReconnectInterval    time.Duration
+	MaxReconnectInterval time.Duration
+
+	// UseGETForQueries sends queries as GET requests, with the query,
+	// operationName, and variables as URL parameters, so CDNs can cache
+	// them. Mutations, requests with uploads, and requests whose URL would
+	// be longer than MaxGETURLLength are still POSTed. Request.Method
+	// overrides it for a request.
+	UseGETForQueries bool
+	// MaxGETURLLength defaults to 2048.
+	MaxGETURLLength int
+	// MinifyQueries strips comments and insignificant whitespace and
+	// commas from queries before they are sent. See MinifyQuery.
+	MinifyQueries bool
+	// CompressRequests gzips the bodies of POST requests of at least
+	// CompressMinSize bytes, which defaults to 1024. Requests with uploads
+	// are not compressed. Responses are always accepted gzipped.
+	CompressRequests bool
+	CompressMinSize  int
+	// PersistedManifest, when set, sends the hash of each query in place
+	// of its text, as a persisted query. Execute fails with
+	// ErrNotPersisted, without sending it, a request whose query is not
+	// in the manifest.
+	PersistedManifest *manifest.Manifest
+	// TokenSource, when set, returns the bearer token of HTTP requests.
+	// It is called for the first request, and again when the server
+	// rejects the token with a 401 status or an UNAUTHENTICATED error, in
+	// which case the request is retried once with the new token.
+	// Concurrent requests share one call. To use an oauth2.TokenSource:
+	//
+	//	TokenSource: func(ctx context.Context) (string, error) {
+	//		t, err := ts.Token()
+	//		if err != nil {
+	//			return "", err
+	//		}
+	//		return t.AccessToken, nil
+	//	}
+	TokenSource func(ctx context.Context) (string, error)
+
+	// OnConnectionStateChange, when set, is called as the WebSocket
+	// connection changes state, with the error that caused the change.
+	OnConnectionStateChange func(state ConnState, err error)
 }
 
 // DefaultConfig returns default client configuration.
@@ -36,17 +130,134 @@ func DefaultConfig(url string) Config {
 
 // Request represents a GraphQL request.
 type Request struct {
-	Query         string         `json:"query"`
+	Query         string         `json:"query,omitempty"`
 	Variables     map[string]any `json:"variables,omitempty"`
 	OperationName string         `json:"operationName,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+	// Method, when GET or POST, overrides Config.UseGETForQueries. Execute
+	// sets it to the method the request is sent with before middleware
+	// sees the request.
+	Method string `json:"-"`
 }
 
 // Response represents a GraphQL response.
 type Response struct {
-	Data   json.RawMessage `json:"data,omitempty"`
-	Errors []GraphQLError  `json:"errors,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Errors     []GraphQLError  `json:"errors,omitempty"`
+	Extensions map[string]any  `json:"extensions,omitempty"`
+
+	// Stream is set when the server streams the response, as it may for
+	// @defer and @stream: Data, Errors, and Extensions are then those of
+	// the initial payload, and Stream has the ones after it. Read it to
+	// the end or close it; Config.Timeout covers the whole stream.
+	Stream *IncrementalStream `json:"-"`
+
+	// StatusCode and Header are those of the HTTP response. The responses
+	// of a batch share them.
+	StatusCode int         `json:"-"`
+	Header     http.Header `json:"-"`
+}
+
+// HasErrors reports whether the response has GraphQL errors.
+func (r *Response) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// FirstError returns the first GraphQL error of the response, or nil.
+func (r *Response) FirstError() *GraphQLError {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return &r.Errors[0]
+}
+
+// Clone returns a deep copy of the response, which middleware may change
+// without affecting other holders of r, such as a cache. The Stream of a
+// streamed response is shared.
+func (r *Response) Clone() *Response {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.Data = slices.Clone(r.Data)
+	c.Extensions = cloneMap(r.Extensions)
+	c.Header = r.Header.Clone()
+	if r.Errors != nil {
+		c.Errors = make([]GraphQLError, len(r.Errors))
+		for i, e := range r.Errors {
+			e.Path = cloneValue(e.Path).([]any)
+			e.Locations = slices.Clone(e.Locations)
+			e.Extensions = cloneMap(e.Extensions)
+			c.Errors[i] = e
+		}
+	}
+	return &c
+}
+
+// SetDataJSON replaces the data of the response with v encoded as JSON.
+func (r *Response) SetDataJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	r.Data = data
+	return nil
+}
+
+// cloneMap returns a deep copy of a JSON object.
+func cloneMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	return cloneValue(m).(map[string]any)
+}
+
+// cloneValue returns a deep copy of a decoded JSON value.
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = cloneValue(e)
+		}
+		return c
+	case []any:
+		if v == nil {
+			return v
+		}
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = cloneValue(e)
+		}
+		return c
+	}
+	return v
+}
+
+// hasData reports whether the response has data: partial data when it
+// also has errors.
+func (r *Response) hasData() bool {
+	data := bytes.TrimSpace(r.Data)
+	return len(data) > 0 && string(data) != "null"
 }
 
+// ErrorPolicy is how Execute treats a response with GraphQL errors.
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyAll returns partial data with its errors, failing only
+	// when the data is null. The default.
+	ErrorPolicyAll ErrorPolicy = iota
+	// ErrorPolicyNone fails on any GraphQL error, discarding the data.
+	ErrorPolicyNone
+	// ErrorPolicyIgnore drops GraphQL errors and returns the data, even
+	// when it is null.
+	ErrorPolicyIgnore
+)
+
 // GraphQLError represents a GraphQL error.
 type GraphQLError struct {
 	Message    string         `json:"message"`
@@ -59,6 +270,78 @@ func (e GraphQLError) Error() string {
 	return e.Message
 }
 
+// GraphQLErrors are the GraphQL errors of a response, returned by Execute
+// when they fail it. errors.As finds each as a *GraphQLError.
+type GraphQLErrors []GraphQLError
+
+// Error joins the messages of the errors.
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, gqlErr := range e {
+		messages[i] = gqlErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns each error.
+func (e GraphQLErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = &e[i]
+	}
+	return errs
+}
+
+// HTTPError is returned when the server responds with a 4xx or 5xx status.
+// When the body is a GraphQL response, Response holds it so the structured
+// errors are not lost.
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Response   *Response
+}
+
+// newHTTPError returns the error of an HTTP response with body.
+func newHTTPError(httpResp *http.Response, body []byte) *HTTPError {
+	httpErr := &HTTPError{StatusCode: httpResp.StatusCode, Header: httpResp.Header, Body: body}
+	if isJSON(httpResp.Header.Get("Content-Type")) {
+		var resp Response
+		if err := json.Unmarshal(body, &resp); err == nil && (len(resp.Errors) > 0 || resp.Data != nil) {
+			resp.StatusCode, resp.Header = httpResp.StatusCode, httpResp.Header
+			httpErr.Response = &resp
+		}
+	}
+	return httpErr
+}
+
+func (e *HTTPError) Error() string {
+	if e.Response != nil && len(e.Response.Errors) > 0 {
+		return "HTTP " + strconv.Itoa(e.StatusCode) + ": " + GraphQLErrors(e.Response.Errors).Error()
+	}
+	return "HTTP " + strconv.Itoa(e.StatusCode) + ": " + string(e.Body)
+}
+
+// Unwrap returns the GraphQL errors of the response body, if any, so they
+// can be matched with errors.As.
+func (e *HTTPError) Unwrap() []error {
+	if e.Response == nil {
+		return nil
+	}
+	return GraphQLErrors(e.Response.Errors).Unwrap()
+}
+
+// Retryable reports whether the status indicates a transient failure: a
+// 429, 502, 503, or 504. Other 5xx statuses, such as a 500 from a failing
+// resolver, are likely to fail again.
+func (e *HTTPError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
 // Location represents a location in a GraphQL document.
 type Location struct {
 	Line   int `json:"line"`
@@ -67,12 +350,37 @@ type Location struct {
 
 // Client is the GraphQL client.
 type Client struct {
-	config      Config
-	httpClient  *http.Client
+	config     Config
+	httpClient *http.Client
+	// persisted maps the documents of Config.PersistedManifest to their
+	// hashes.
+	persisted map[string]string
+
+	// headersMu guards config.Headers, which SetHeader and SetAuthToken
+	// change.
+	headersMu sync.RWMutex
+
+	// mu guards middlewares and validator, the queryValidator of
+	// WithSchema.
+	mu          sync.RWMutex
 	middlewares []Middleware
+	validator   *queryValidator
+
+	// tokenMu guards token, the last token of TokenSource, and
+	// tokenFetch, the call of TokenSource in flight.
+	tokenMu    sync.Mutex
+	token      string
+	tokenFetch *tokenFetch
+
+	// wsMu guards ws, the connection shared by subscriptions.
+	wsMu sync.Mutex
+	ws   *wsSession
 }
 
-// Middleware is a function that wraps request execution.
+// Middleware is a function that wraps request execution. It may change the
+// request it passes on, and the response next returns, which is its own;
+// or it may return a response without calling next, as FixtureMiddleware
+// does.
 type Middleware func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error)
 
 // New creates a new GraphQL client.
@@ -81,7 +389,12 @@ func New(url string) *Client {
 }
 
 // NewWithConfig creates a new GraphQL client with custom configuration.
+// The client keeps its own copy of config.Headers.
 func NewWithConfig(config Config) *Client {
+	config.Headers = maps.Clone(config.Headers)
+	if config.Headers == nil {
+		config.Headers = make(map[string]string)
+	}
 	httpClient := config.HTTPClient
 	if httpClient == nil {
 		httpClient = &http.Client{
@@ -89,26 +402,50 @@ func NewWithConfig(config Config) *Client {
 		}
 	}
 
-	return &Client{
+	c := &Client{
 		config:     config,
 		httpClient: httpClient,
 	}
+	if config.PersistedManifest != nil {
+		c.persisted = make(map[string]string)
+		for hash, document := range config.PersistedManifest.Documents() {
+			c.persisted[document] = hash
+		}
+	}
+	return c
 }
 
-// Use adds middleware to the client.
+// Use adds middleware to the client. It is safe to call while requests
+// are in flight; requests already executing keep the chain they started with.
 func (c *Client) Use(middleware Middleware) *Client {
-	c.middlewares = append(c.middlewares, middleware)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Copy on write so snapshots taken by Execute are never mutated.
+	c.middlewares = append(c.middlewares[:len(c.middlewares):len(c.middlewares)], middleware)
 	return c
 }
 
-// SetHeader sets a default header.
+// chain returns a snapshot of the middleware chain.
+func (c *Client) chain() []Middleware {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.middlewares
+}
+
+// SetHeader sets a default header. It is safe to call while requests are
+// in flight.
 func (c *Client) SetHeader(key, value string) *Client {
+	c.headersMu.Lock()
+	defer c.headersMu.Unlock()
 	c.config.Headers[key] = value
 	return c
 }
 
-// SetAuthToken sets the Authorization header with a Bearer token.
+// SetAuthToken sets the Authorization header with a Bearer token, or
+// removes it if token is empty.
 func (c *Client) SetAuthToken(token string) *Client {
+	c.headersMu.Lock()
+	defer c.headersMu.Unlock()
 	if token != "" {
 		c.config.Headers["Authorization"] = "Bearer " + token
 	} else {
@@ -135,11 +472,23 @@ func (c *Client) Mutate(ctx context.Context, mutation string, variables map[stri
 
 // Execute executes a GraphQL request.
 func (c *Client) Execute(ctx context.Context, req *Request) result.Result[*Response] {
+	if err := c.validate(req.Query); err != nil {
+		return result.Err[*Response](err)
+	}
+	if c.persisted != nil {
+		if _, ok := c.persisted[req.Query]; !ok {
+			return result.Err[*Response](fmt.Errorf("%w: %s", ErrNotPersisted, operationLabel(req)))
+		}
+	}
+	req = c.withMethod(c.minified(req))
+	ctx = context.WithValue(ctx, clientKey{}, c)
+
 	// Build middleware chain
 	handler := c.doRequest
 
-	for i := len(c.middlewares) - 1; i >= 0; i-- {
-		middleware := c.middlewares[i]
+	middlewares := c.chain()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		middleware := middlewares[i]
 		next := handler
 		handler = func(ctx context.Context, req *Request) (*Response, error) {
 			return middleware(ctx, req, next)
@@ -151,12 +500,22 @@ func (c *Client) Execute(ctx context.Context, req *Request) result.Result[*Respo
 		return result.Err[*Response](err)
 	}
 
-	// Check for GraphQL errors
-	if len(resp.Errors) > 0 {
-		return result.Err[*Response](&resp.Errors[0])
+	if !resp.HasErrors() {
+		return result.Ok(resp)
 	}
-
-	return result.Ok(resp)
+	switch c.config.ErrorPolicy {
+	case ErrorPolicyIgnore:
+		resp.Errors = nil
+		return result.Ok(resp)
+	case ErrorPolicyAll:
+		if resp.hasData() {
+			return result.Ok(resp)
+		}
+	}
+	if resp.Stream != nil {
+		resp.Stream.Close()
+	}
+	return result.Err[*Response](GraphQLErrors(resp.Errors))
 }
 
 // ExecuteInto executes a request and unmarshals the data into the target.
@@ -175,27 +534,83 @@ func ExecuteInto[T any](c *Client, ctx context.Context, req *Request) result.Res
 }
 
 func (c *Client) doRequest(ctx context.Context, req *Request) (*Response, error) {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	// Uploads cannot be sent twice, as their files have been read.
+	return authorized(c, ctx, findUploads(req) == nil, func(ctx context.Context) (*Response, error) {
+		return c.roundTrip(ctx, req)
+	}, unauthenticated)
+}
+
+// roundTrip sends req and reads its response.
+func (c *Client) roundTrip(ctx context.Context, req *Request) (*Response, error) {
+	var body io.Reader
+	var contentEncoding string
+	method, target, contentType := http.MethodPost, c.config.URL, "application/json"
+	if req.Method == http.MethodGet {
+		method, target, contentType = http.MethodGet, c.getURL(req), ""
+	} else if uploads := findUploads(req); uploads != nil {
+		// Stream the files instead of holding them in memory.
+		pr, pw := io.Pipe()
+		w := multipart.NewWriter(pw)
+		go func() { pw.CloseWithError(uploads.writeMultipart(w, c.wire(req))) }()
+		defer pr.Close()
+		body, contentType = pr, w.FormDataContentType()
+	} else {
+		encoded, err := json.Marshal(c.wire(req))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body, contentEncoding = c.requestBody(encoded)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.config.URL, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "application/json")
-
-	for k, v := range c.config.Headers {
-		httpReq.Header.Set(k, v)
+	if contentType == "application/json" {
+		httpReq.Header.Set("Content-Type", contentType)
+	} else {
+		// Servers preventing CSRF take GET and multipart requests with
+		// this header.
+		if contentType != "" {
+			httpReq.Header.Set("Content-Type", contentType)
+		}
+		httpReq.Header.Set("Apollo-Require-Preflight", "true")
+	}
+	if contentEncoding != "" {
+		httpReq.Header.Set("Content-Encoding", contentEncoding)
 	}
+	if wantsIncremental(req.Query) {
+		httpReq.Header.Set("Accept", incrementalAccept)
+	}
+
+	c.setClientHeaders(httpReq.Header)
+	c.setRequestHeaders(ctx, httpReq.Header)
 
-	httpResp, err := c.httpClient.Do(httpReq)
+	httpResp, err := send(c.httpClient, httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	if httpResp.StatusCode < 400 {
+		if stream := newIncrementalStream(httpResp); stream != nil {
+			payload, err := stream.Next()
+			if err != nil {
+				return nil, err
+			}
+			resp := &Response{
+				Data:       payload.Data,
+				Errors:     payload.Errors,
+				Extensions: payload.Extensions,
+				StatusCode: httpResp.StatusCode,
+				Header:     httpResp.Header,
+			}
+			if payload.HasNext {
+				resp.Stream = stream
+			}
+			return resp, nil
+		}
+	}
 	defer httpResp.Body.Close()
 
 	respBody, err := io.ReadAll(httpResp.Body)
@@ -204,17 +619,170 @@ func (c *Client) doRequest(ctx context.Context, req *Request) (*Response, error)
 	}
 
 	if httpResp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d: %s", httpResp.StatusCode, string(respBody))
+		httpErr := newHTTPError(httpResp, respBody)
+		return httpErr.Response, httpErr
 	}
 
 	var resp Response
 	if err := json.Unmarshal(respBody, &resp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	resp.StatusCode, resp.Header = httpResp.StatusCode, httpResp.Header
 
 	return &resp, nil
 }
 
+// defaultMaxGETURLLength is the default of Config.MaxGETURLLength.
+const defaultMaxGETURLLength = 2048
+
+// withMethod returns req with Method set to the method it is sent with:
+// GET if it is wanted and req is a query that fits in a URL, or else POST.
+func (c *Client) withMethod(req *Request) *Request {
+	method := http.MethodPost
+	wantGET := req.Method == http.MethodGet || (req.Method != http.MethodPost && c.config.UseGETForQueries)
+	if wantGET && isQuery(req) && findUploads(req) == nil {
+		maxLength := c.config.MaxGETURLLength
+		if maxLength <= 0 {
+			maxLength = defaultMaxGETURLLength
+		}
+		if u := c.getURL(req); u != "" && len(u) <= maxLength {
+			method = http.MethodGet
+		}
+	}
+	if req.Method == method {
+		return req
+	}
+	sent := *req
+	sent.Method = method
+	return &sent
+}
+
+// getURL returns the URL of req sent as a GET request, or "" if its
+// variables cannot be encoded.
+func (c *Client) getURL(req *Request) string {
+	u, err := url.Parse(c.config.URL)
+	if err != nil {
+		return ""
+	}
+	req = c.wire(req)
+	params := u.Query()
+	if req.Query != "" {
+		params.Set("query", req.Query)
+	}
+	if req.OperationName != "" {
+		params.Set("operationName", req.OperationName)
+	}
+	if len(req.Variables) > 0 {
+		variables, err := json.Marshal(req.Variables)
+		if err != nil {
+			return ""
+		}
+		params.Set("variables", string(variables))
+	}
+	if len(req.Extensions) > 0 {
+		extensions, err := json.Marshal(req.Extensions)
+		if err != nil {
+			return ""
+		}
+		params.Set("extensions", string(extensions))
+	}
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+type requestHeadersKey struct{}
+
+// clientKey is the context key of the client executing a request.
+type clientKey struct{}
+
+// outgoingHeader returns the configured headers and those added with
+// withRequestHeader of a request executed with ctx.
+func outgoingHeader(ctx context.Context) http.Header {
+	h := make(http.Header)
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok {
+		c.setRequestHeaders(ctx, h)
+	} else if added, ok := ctx.Value(requestHeadersKey{}).(http.Header); ok {
+		for k, v := range added {
+			h[k] = v
+		}
+	}
+	return h
+}
+
+// WithHeaders returns a context that adds h to the requests executed with
+// it, over the configured headers.
+func WithHeaders(ctx context.Context, h http.Header) context.Context {
+	added := make(http.Header, len(h))
+	for k, v := range h {
+		added[http.CanonicalHeaderKey(k)] = v
+	}
+	return withRequestHeaders(ctx, added)
+}
+
+// withRequestHeader returns a context that adds a header to the request
+// sent with it, on top of the configured headers.
+func withRequestHeader(ctx context.Context, key, value string) context.Context {
+	return withRequestHeaders(ctx, http.Header{http.CanonicalHeaderKey(key): {value}})
+}
+
+// withRequestHeaders is withRequestHeader for several headers.
+func withRequestHeaders(ctx context.Context, added http.Header) context.Context {
+	h := make(http.Header)
+	if existing, ok := ctx.Value(requestHeadersKey{}).(http.Header); ok {
+		h = existing.Clone()
+	}
+	for k, v := range added {
+		h[k] = v
+	}
+	return context.WithValue(ctx, requestHeadersKey{}, h)
+}
+
+// isJSON reports whether a Content-Type is JSON, including the
+// application/graphql-response+json media type.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// setClientHeaders sets the User-Agent and client identification headers.
+func (c *Client) setClientHeaders(h http.Header) {
+	userAgent := c.config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	h.Set("User-Agent", userAgent)
+
+	if c.config.ClientName != "" {
+		h.Set(ClientNameHeader, c.config.ClientName)
+	}
+	if c.config.ClientVersion != "" {
+		h.Set(ClientVersionHeader, c.config.ClientVersion)
+	}
+}
+
+// setConfigHeaders sets the configured headers.
+func (c *Client) setConfigHeaders(h http.Header) {
+	c.headersMu.RLock()
+	defer c.headersMu.RUnlock()
+	for k, v := range c.config.Headers {
+		h.Set(k, v)
+	}
+}
+
+// setRequestHeaders sets the configured headers, and those added to ctx
+// with withRequestHeader.
+func (c *Client) setRequestHeaders(ctx context.Context, h http.Header) {
+	c.setConfigHeaders(h)
+	if added, ok := ctx.Value(requestHeadersKey{}).(http.Header); ok {
+		for k, v := range added {
+			h[k] = v
+		}
+	}
+}
+
 // =============================================================================
 // Middleware Helpers
 // =============================================================================
@@ -246,92 +814,387 @@ func LoggingMiddleware(logger func(format string, args ...any)) Middleware {
 	}
 }
 
-// RetryMiddleware retries failed requests.
+// FixtureMiddleware answers requests with copies of fixtures instead of
+// sending them, looking them up by operation name, then by query. Other
+// requests are sent as usual.
+func FixtureMiddleware(fixtures map[string]*Response) Middleware {
+	return func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+		if fixture, ok := fixtures[operationName(req)]; ok && fixture != nil {
+			return fixture.Clone(), nil
+		}
+		if fixture, ok := fixtures[req.Query]; ok && fixture != nil {
+			return fixture.Clone(), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// ForwardHeadersMiddleware copies the named headers of the inbound request
+// in the context, under sdk.RequestHeaders as bgql servers store it for
+// resolvers, onto outgoing requests, such as Authorization and
+// traceparent in calls between services. Forwarded headers replace
+// configured ones; headers the inbound request lacks are not sent.
+func ForwardHeadersMiddleware(keys ...string) Middleware {
+	return func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+		inbound, ok := sdk.RequestHeaders.Get(ctx)
+		if !ok {
+			return next(ctx, req)
+		}
+		forwarded := make(http.Header)
+		for _, key := range keys {
+			if values := inbound.Values(key); len(values) > 0 {
+				forwarded[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+			}
+		}
+		if len(forwarded) == 0 {
+			return next(ctx, req)
+		}
+		return next(withRequestHeaders(ctx, forwarded), req)
+	}
+}
+
+// RetryConfig configures RetryMiddlewareWithConfig.
+type RetryConfig struct {
+	// MaxRetries is how many times a request is retried.
+	MaxRetries int
+	// InitialInterval bounds the first wait, and MaxInterval every wait:
+	// the bound doubles after each attempt, and each wait is random below
+	// it. They default to a second and 30 seconds.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// MaxElapsed caps the time spent on a request and its retries: a
+	// retry that would start after it is not made. Defaults to a minute.
+	MaxElapsed time.Duration
+	// RetryMutations retries mutations too. A mutation that failed with a
+	// network error may have been applied, so only set it when mutations
+	// are idempotent.
+	RetryMutations bool
+}
+
+// RetryMiddleware retries queries that fail transiently, up to maxRetries
+// times, backing off from interval. See RetryMiddlewareWithConfig.
 func RetryMiddleware(maxRetries int, interval time.Duration) Middleware {
+	return RetryMiddlewareWithConfig(RetryConfig{MaxRetries: maxRetries, InitialInterval: interval})
+}
+
+// RetryMiddlewareWithConfig retries requests that fail transiently: with
+// a network error, a 429, 502, 503, or 504 status, or a RATE_LIMITED
+// error. Waits back off exponentially with full jitter, unless the server
+// gives one in a Retry-After header or a retryAfter error extension.
+// Mutations are not retried unless configured, nor are requests with
+// uploads, as their files have been read.
+func RetryMiddlewareWithConfig(config RetryConfig) Middleware {
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = time.Second
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = 30 * time.Second
+	}
+	if config.MaxElapsed <= 0 {
+		config.MaxElapsed = time.Minute
+	}
+
 	return func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
-		var lastErr error
+		if findUploads(req) != nil || (!config.RetryMutations && isMutation(req)) {
+			return next(ctx, req)
+		}
 
-		for attempt := 0; attempt <= maxRetries; attempt++ {
+		deadline := time.Now().Add(config.MaxElapsed)
+		bound := config.InitialInterval
+		for attempt := 0; ; attempt++ {
 			resp, err := next(ctx, req)
-			if err == nil {
-				return resp, nil
+			wait, transient := retryWait(resp, err)
+			if !transient || attempt >= config.MaxRetries || ctx.Err() != nil {
+				return resp, err
+			}
+			if wait <= 0 {
+				wait = rand.N(bound + 1)
+			}
+			bound = min(bound*2, config.MaxInterval)
+			if time.Now().Add(wait).After(deadline) {
+				return resp, err
 			}
 
-			lastErr = err
-
-			if attempt < maxRetries {
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(interval):
-				}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
 			}
 		}
+	}
+}
 
-		return nil, lastErr
+// retryWait reports whether a request that got resp and err failed
+// transiently, and how long the server asked to wait before retrying.
+func retryWait(resp *Response, err error) (time.Duration, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if !httpErr.Retryable() {
+			return 0, false
+		}
+		if wait := parseRetryAfter(httpErr.Header.Get("Retry-After")); wait > 0 {
+			return wait, true
+		}
+		if httpErr.Response != nil {
+			wait, _ := rateLimited(httpErr.Response)
+			return wait, true
+		}
+		return 0, true
 	}
+	if err != nil {
+		var netErr net.Error
+		return 0, errors.As(err, &netErr)
+	}
+	return rateLimited(resp)
+}
+
+// rateLimited reports whether resp was rejected by a rate limit, and the
+// wait its retryAfter extension asks for.
+func rateLimited(resp *Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	for _, gqlErr := range resp.Errors {
+		if gqlErr.Extensions["code"] == "RATE_LIMITED" {
+			ms, _ := gqlErr.Extensions["retryAfter"].(float64)
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	return 0, false
+}
+
+// parseRetryAfter returns the wait of a Retry-After header, given in
+// seconds or as a date, or 0.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// CacheConfig configures CachingMiddlewareWithConfig.
+type CacheConfig struct {
+	Cache Cache
+	TTL   time.Duration
+	// VaryHeaders are request headers whose values are part of the cache
+	// key, such as Authorization, so one user's data is not served to
+	// another.
+	VaryHeaders []string
 }
 
 // CachingMiddleware caches query responses.
 func CachingMiddleware(cache Cache, ttl time.Duration) Middleware {
+	return CachingMiddlewareWithConfig(CacheConfig{Cache: cache, TTL: ttl})
+}
+
+// CachingMiddlewareWithConfig caches the responses of queries without
+// errors, keyed by a hash of the method, operation name, query,
+// variables, and VaryHeaders. Mutations and streamed responses are not
+// cached.
+func CachingMiddlewareWithConfig(config CacheConfig) Middleware {
 	return func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
-		// Generate cache key
-		key := fmt.Sprintf("%s:%v", req.Query, req.Variables)
+		if isMutation(req) {
+			return next(ctx, req)
+		}
+		key, err := cacheKey(ctx, req, config.VaryHeaders)
+		if err != nil {
+			return next(ctx, req)
+		}
 
-		// Check cache
-		if cached, ok := cache.Get(key); ok {
-			return cached, nil
+		// The cache holds copies, so callers may change their responses.
+		if cached, ok := config.Cache.Get(key); ok {
+			return cached.Clone(), nil
 		}
 
-		// Execute request
 		resp, err := next(ctx, req)
 		if err != nil {
 			return nil, err
 		}
 
-		// Cache successful responses without errors
-		if len(resp.Errors) == 0 {
-			cache.Set(key, resp, ttl)
+		if len(resp.Errors) == 0 && resp.Stream == nil {
+			config.Cache.Set(key, resp.Clone(), config.TTL)
 		}
 
 		return resp, nil
 	}
 }
 
+// cacheKey returns the SHA-256 of req and the values of varyHeaders. The
+// variables are hashed as JSON, which sorts map keys, and each part is
+// length-prefixed so no two requests share a key.
+func cacheKey(ctx context.Context, req *Request, varyHeaders []string) (string, error) {
+	variables, err := json.Marshal(req.Variables)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	write := func(part string) {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	write(req.Method)
+	write(req.OperationName)
+	write(req.Query)
+	write(string(variables))
+	if len(varyHeaders) > 0 {
+		header := outgoingHeader(ctx)
+		for _, name := range varyHeaders {
+			values := header.Values(name)
+			write(strconv.Itoa(len(values)))
+			for _, value := range values {
+				write(value)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // Cache interface for caching middleware.
 type Cache interface {
 	Get(key string) (*Response, bool)
 	Set(key string, value *Response, ttl time.Duration)
 }
 
-// SimpleCache is a basic in-memory cache implementation.
+// SimpleCacheConfig configures NewSimpleCacheWithConfig.
+type SimpleCacheConfig struct {
+	// MaxEntries bounds the cache, evicting the least recently used entry
+	// when it is full. Zero leaves it unbounded.
+	MaxEntries int
+	// CleanupInterval is how often expired entries are swept as entries
+	// are set. Defaults to a minute.
+	CleanupInterval time.Duration
+}
+
+// CacheStats counts the work of a SimpleCache.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int
+}
+
+// SimpleCache is an in-memory cache, safe for concurrent use. Expired
+// entries are dropped when read and swept as entries are set, so the
+// cache needs no goroutine and nothing to stop.
 type SimpleCache struct {
-	data map[string]cacheEntry
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	max      int
+	interval time.Duration
+	swept    time.Time
+	stats    CacheStats
 }
 
 type cacheEntry struct {
+	key       string
 	response  *Response
 	expiresAt time.Time
 }
 
-// NewSimpleCache creates a new simple cache.
+// NewSimpleCache creates an unbounded cache.
 func NewSimpleCache() *SimpleCache {
+	return NewSimpleCacheWithConfig(SimpleCacheConfig{})
+}
+
+// NewSimpleCacheWithConfig creates a cache.
+func NewSimpleCacheWithConfig(config SimpleCacheConfig) *SimpleCache {
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Minute
+	}
 	return &SimpleCache{
-		data: make(map[string]cacheEntry),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		max:      config.MaxEntries,
+		interval: config.CleanupInterval,
+		swept:    time.Now(),
 	}
 }
 
+// Get returns the response cached for key, if it has not expired.
 func (c *SimpleCache) Get(key string) (*Response, bool) {
-	entry, ok := c.data[key]
-	if !ok || time.Now().After(entry.expiresAt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok && time.Now().After(el.Value.(*cacheEntry).expiresAt) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
 		return nil, false
 	}
-	return entry.response, true
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).response, true
 }
 
+// Set caches value for key for ttl.
 func (c *SimpleCache) Set(key string, value *Response, ttl time.Duration) {
-	c.data[key] = cacheEntry{
-		response:  value,
-		expiresAt: time.Now().Add(ttl),
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.swept) >= c.interval {
+		c.sweep(now)
+	}
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.response, entry.expiresAt = value, now.Add(ttl)
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, response: value, expiresAt: now.Add(ttl)})
+	if c.max > 0 && c.lru.Len() > c.max {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete removes the entry of key, as to invalidate it after a mutation.
+func (c *SimpleCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Flush removes every entry.
+func (c *SimpleCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// Stats returns the cache's counters.
+func (c *SimpleCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// remove removes an entry. c.mu must be held.
+func (c *SimpleCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// sweep removes the entries expired by now. c.mu must be held.
+func (c *SimpleCache) sweep(now time.Time) {
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if now.After(el.Value.(*cacheEntry).expiresAt) {
+			c.remove(el)
+		}
+		el = next
 	}
+	c.swept = now
 }
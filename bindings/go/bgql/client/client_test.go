@@ -0,0 +1,1056 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+func TestClientHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"User-Agent":        DefaultUserAgent,
+				ClientNameHeader:    "",
+				ClientVersionHeader: "",
+			},
+		},
+		{
+			name:   "overridden user agent",
+			config: Config{UserAgent: "my-app/2.0"},
+			want:   map[string]string{"User-Agent": "my-app/2.0"},
+		},
+		{
+			name:   "client identification",
+			config: Config{ClientName: "web", ClientVersion: "1.4.0"},
+			want: map[string]string{
+				"User-Agent":        DefaultUserAgent,
+				ClientNameHeader:    "web",
+				ClientVersionHeader: "1.4.0",
+			},
+		},
+		{
+			name:   "explicit header wins",
+			config: Config{Headers: map[string]string{"User-Agent": "custom"}},
+			want:   map[string]string{"User-Agent": "custom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(chan http.Header, 1)
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got <- r.Header.Clone()
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data":{"a":1}}`))
+			}))
+			defer hs.Close()
+
+			config := tt.config
+			config.URL = hs.URL
+			if res := NewWithConfig(config).Query(context.Background(), "{ a }", nil); res.IsErr() {
+				t.Fatalf("query failed: %v", res.Error())
+			}
+			header := <-got
+			for name, want := range tt.want {
+				if v := header.Get(name); v != want {
+					t.Errorf("%s = %q, want %q", name, v, want)
+				}
+			}
+		})
+	}
+}
+
+// orderKey is the context key of the middlewares a request went through.
+type orderKey struct{}
+
+func TestUseDuringRequests(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer hs.Close()
+	c := New(hs.URL)
+
+	const added = 20
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				var order []int
+				ctx := context.WithValue(context.Background(), orderKey{}, &order)
+				if res := c.Query(ctx, "{ a }", nil); res.IsErr() {
+					t.Errorf("query failed: %v", res.Error())
+					return
+				}
+				for j, n := range order {
+					if n != j {
+						t.Errorf("middlewares ran in order %v, want a prefix of 0..%d", order, added-1)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < added; i++ {
+		i := i
+		c.Use(func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+			order := ctx.Value(orderKey{}).(*[]int)
+			*order = append(*order, i)
+			return next(ctx, req)
+		})
+	}
+	wg.Wait()
+
+	var order []int
+	c.Query(context.WithValue(context.Background(), orderKey{}, &order), "{ a }", nil)
+	if len(order) != added {
+		t.Errorf("request went through %d middlewares, want %d", len(order), added)
+	}
+}
+
+func TestGETForQueries(t *testing.T) {
+	type sent struct {
+		method, query, operationName, variables, contentType string
+	}
+	got := make(chan sent, 1)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := sent{method: r.Method, contentType: r.Header.Get("Content-Type")}
+		if r.Method == http.MethodGet {
+			q := r.URL.Query()
+			s.query, s.operationName, s.variables = q.Get("query"), q.Get("operationName"), q.Get("variables")
+			if q.Get("v") != "1" {
+				t.Errorf("configured URL parameters dropped: %s", r.URL)
+			}
+		} else {
+			var req Request
+			json.NewDecoder(r.Body).Decode(&req)
+			s.query = req.Query
+		}
+		got <- s
+		w.Header().Set("Content-Type", "application/json")
+		if s.query == "{ fail }" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"no fail field"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer hs.Close()
+
+	long := "{ a " + strings.Repeat(" ", 3000) + "}"
+	tests := []struct {
+		name       string
+		useGET     bool
+		maxLength  int
+		req        Request
+		wantMethod string
+		wantErr    string
+	}{
+		{"query", true, 0, Request{Query: "query Q($n: Int) { a(n: $n) }", OperationName: "Q", Variables: map[string]any{"n": 1}}, http.MethodGet, ""},
+		{"shorthand", true, 0, Request{Query: "{ a }"}, http.MethodGet, ""},
+		{"disabled", false, 0, Request{Query: "{ a }"}, http.MethodPost, ""},
+		{"request opts in", false, 0, Request{Query: "{ a }", Method: http.MethodGet}, http.MethodGet, ""},
+		{"request opts out", true, 0, Request{Query: "{ a }", Method: http.MethodPost}, http.MethodPost, ""},
+		{"mutation", true, 0, Request{Query: "mutation { a }", Method: http.MethodGet}, http.MethodPost, ""},
+		{"too long", true, 0, Request{Query: long}, http.MethodPost, ""},
+		{"longer limit", true, 10000, Request{Query: long}, http.MethodGet, ""},
+		{"uploads", true, 0, Request{Query: "query($f: Upload) { a }", Variables: map[string]any{"f": Upload{Reader: strings.NewReader("x")}}}, http.MethodPost, ""},
+		{"error response", true, 0, Request{Query: "{ fail }"}, http.MethodGet, "no fail field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewWithConfig(Config{URL: hs.URL + "?v=1", UseGETForQueries: tt.useGET, MaxGETURLLength: tt.maxLength})
+			req := tt.req
+			res := c.Execute(context.Background(), &req)
+			s := <-got
+			if s.method != tt.wantMethod {
+				t.Fatalf("method = %s, want %s", s.method, tt.wantMethod)
+			}
+			if req.Method != tt.req.Method {
+				t.Errorf("Execute changed the caller's request")
+			}
+			if tt.wantErr != "" {
+				if res.IsOk() || !strings.Contains(res.Error().Error(), tt.wantErr) {
+					t.Fatalf("result = %v, want error %q", res, tt.wantErr)
+				}
+				return
+			}
+			if res.IsErr() || string(res.Unwrap().Data) != `{"a":1}` {
+				t.Fatalf("result = %v", res)
+			}
+			if s.method != http.MethodGet {
+				return
+			}
+			if s.contentType != "" || s.query != tt.req.Query || s.operationName != tt.req.OperationName {
+				t.Errorf("sent %+v for %+v", s, tt.req)
+			}
+			if wantVars, _ := json.Marshal(tt.req.Variables); len(tt.req.Variables) > 0 && s.variables != string(wantVars) {
+				t.Errorf("variables = %s, want %s", s.variables, wantVars)
+			}
+		})
+	}
+}
+
+func TestCachingMiddlewareKeysMethod(t *testing.T) {
+	var requests int
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"method":"` + r.Method + `"}}`))
+	}))
+	defer hs.Close()
+
+	c := NewWithConfig(Config{URL: hs.URL, UseGETForQueries: true}).Use(CachingMiddleware(NewSimpleCache(), time.Minute))
+	for _, tt := range []struct {
+		method, want string
+		requests     int
+	}{
+		{"", `{"method":"GET"}`, 1},
+		{http.MethodPost, `{"method":"POST"}`, 2},
+		{http.MethodGet, `{"method":"GET"}`, 2},
+		{http.MethodPost, `{"method":"POST"}`, 2},
+	} {
+		res := c.Execute(context.Background(), &Request{Query: "{ method }", Method: tt.method})
+		if data := string(res.Unwrap().Data); data != tt.want || requests != tt.requests {
+			t.Errorf("Method %q: data %s after %d requests, want %s after %d", tt.method, data, requests, tt.want, tt.requests)
+		}
+	}
+}
+
+func TestResponseHTTPMetadata(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-"+req.Query)
+		switch req.Query {
+		case "limited":
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`slow down`))
+		case "invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"bad"}],"extensions":{"cost":2}}`))
+		default:
+			w.Write([]byte(`{"data":{"a":1},"extensions":{"cost":1}}`))
+		}
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		query       string
+		wantStatus  int
+		wantID      string
+		wantCost    any
+		wantErr     bool
+		retryAfter  string
+		hasResponse bool
+	}{
+		{"ok", http.StatusOK, "req-ok", 1.0, false, "", true},
+		{"invalid", http.StatusBadRequest, "req-invalid", 2.0, true, "", true},
+		{"limited", http.StatusTooManyRequests, "req-limited", nil, true, "3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var seen *Response
+			c := New(hs.URL).Use(func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+				resp, err := next(ctx, req)
+				seen = resp
+				return resp, err
+			})
+			res := c.Query(context.Background(), tt.query, nil)
+			if res.IsErr() != tt.wantErr {
+				t.Fatalf("result = %v", res)
+			}
+			if !tt.hasResponse {
+				var httpErr *HTTPError
+				if seen != nil || !errors.As(res.Error(), &httpErr) {
+					t.Fatalf("middleware saw %v, error %v; want only an HTTPError", seen, res.Error())
+				}
+				if got := httpErr.Header.Get("Retry-After"); httpErr.StatusCode != tt.wantStatus || got != tt.retryAfter {
+					t.Errorf("HTTPError = %d, Retry-After %q", httpErr.StatusCode, got)
+				}
+				return
+			}
+			if seen == nil {
+				t.Fatal("middleware saw no response")
+			}
+			if seen.StatusCode != tt.wantStatus || seen.Header.Get("X-Request-Id") != tt.wantID || seen.Extensions["cost"] != tt.wantCost {
+				t.Errorf("response = %d, %q, %v", seen.StatusCode, seen.Header.Get("X-Request-Id"), seen.Extensions)
+			}
+		})
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	bodies := map[string]string{
+		"partial": `{"data":{"a":1,"b":null},"errors":[{"message":"b failed","path":["b"]}]}`,
+		"null":    `{"data":null,"errors":[{"message":"all failed"}]}`,
+		"missing": `{"errors":[{"message":"invalid"}]}`,
+		"clean":   `{"data":{"a":1}}`,
+	}
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(bodies[req.Query]))
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		policy     ErrorPolicy
+		query      string
+		wantErr    string
+		wantData   string
+		wantErrors int
+	}{
+		{ErrorPolicyAll, "partial", "", `{"a":1,"b":null}`, 1},
+		{ErrorPolicyAll, "null", "all failed", "", 0},
+		{ErrorPolicyAll, "missing", "invalid", "", 0},
+		{ErrorPolicyAll, "clean", "", `{"a":1}`, 0},
+		{ErrorPolicyNone, "partial", "b failed", "", 0},
+		{ErrorPolicyNone, "clean", "", `{"a":1}`, 0},
+		{ErrorPolicyIgnore, "partial", "", `{"a":1,"b":null}`, 0},
+		{ErrorPolicyIgnore, "null", "", "null", 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%s", tt.policy, tt.query), func(t *testing.T) {
+			res := NewWithConfig(Config{URL: hs.URL, ErrorPolicy: tt.policy}).Query(context.Background(), tt.query, nil)
+			if tt.wantErr != "" {
+				if res.IsOk() || !strings.Contains(res.Error().Error(), tt.wantErr) {
+					t.Fatalf("result = %v, want error %q", res, tt.wantErr)
+				}
+				return
+			}
+			if res.IsErr() {
+				t.Fatal(res.Error())
+			}
+			resp := res.Unwrap()
+			if string(resp.Data) != tt.wantData || len(resp.Errors) != tt.wantErrors || resp.HasErrors() != (tt.wantErrors > 0) {
+				t.Errorf("response = %s with errors %v", resp.Data, resp.Errors)
+			}
+			if tt.wantErrors > 0 && resp.FirstError().Path[0] != "b" {
+				t.Errorf("FirstError = %+v", resp.FirstError())
+			}
+		})
+	}
+
+	// ExecuteInto decodes partial data.
+	var got struct{ A int }
+	data := ExecuteInto[struct{ A int }](New(hs.URL), context.Background(), &Request{Query: "partial"})
+	if got = data.Unwrap(); got.A != 1 {
+		t.Errorf("ExecuteInto = %+v", got)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	const body = `{"data":null,"errors":[` +
+		`{"message":"a failed","path":["a"],"extensions":{"code":"A"}},` +
+		`{"message":"b failed","path":["b",0],"locations":[{"line":1,"column":5}],"extensions":{"code":"B"}}]}`
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		// wantHTTPError is whether the error is an HTTPError, and
+		// wantParsed whether it carries the GraphQL errors of the body.
+		wantHTTPError, wantParsed bool
+	}{
+		{"execution errors", http.StatusOK, "application/json", body, false, true},
+		{"request errors", http.StatusBadRequest, "application/json", body, true, true},
+		{"server errors", http.StatusInternalServerError, "application/json; charset=utf-8", body, true, true},
+		{"html error page", http.StatusBadRequest, "text/html", "<html><body>Bad Request</body></html>", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer hs.Close()
+
+			err := New(hs.URL).Query(context.Background(), "{ a b }", nil).Error()
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) != tt.wantHTTPError {
+				t.Fatalf("error %T %v, want HTTPError: %v", err, err, tt.wantHTTPError)
+			}
+			if httpErr != nil && httpErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", httpErr.StatusCode, tt.status)
+			}
+			if !tt.wantParsed {
+				if httpErr.Response != nil || string(httpErr.Body) != tt.body {
+					t.Errorf("HTTPError = %+v, want only the raw body", httpErr)
+				}
+				var gqlErr *GraphQLError
+				if errors.As(err, &gqlErr) {
+					t.Errorf("errors.As found GraphQL error %+v in an opaque body", gqlErr)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "a failed; b failed") {
+				t.Fatalf("error = %v, want both messages", err)
+			}
+			var first *GraphQLError
+			if !errors.As(err, &first) || first.Extensions["code"] != "A" {
+				t.Errorf("errors.As = %+v", first)
+			}
+			var all GraphQLErrors
+			if httpErr != nil {
+				if httpErr.Response == nil {
+					t.Fatal("HTTPError has no parsed Response")
+				}
+				all = httpErr.Response.Errors
+			} else if !errors.As(err, &all) {
+				t.Fatalf("error %T is not GraphQLErrors", err)
+			}
+			var got []string
+			for _, e := range all.Unwrap() {
+				gqlErr := e.(*GraphQLError)
+				got = append(got, fmt.Sprintf("%v %v %v", gqlErr.Path, gqlErr.Locations, gqlErr.Extensions["code"]))
+			}
+			if want := "[[a] [] A [b 0] [{1 5}] B]"; fmt.Sprint(got) != want {
+				t.Errorf("errors = %v, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusNotImplemented, false},
+	}
+	for _, tt := range tests {
+		if got := (&HTTPError{StatusCode: tt.status}).Retryable(); got != tt.want {
+			t.Errorf("Retryable() for %d = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	type reply struct {
+		status     int
+		retryAfter string
+		body       string
+	}
+	ok := reply{http.StatusOK, "", `{"data":{"a":1}}`}
+	unavailable := reply{http.StatusServiceUnavailable, "", `down`}
+	rateLimited := reply{http.StatusOK, "", `{"errors":[{"message":"Rate limit exceeded","extensions":{"code":"RATE_LIMITED","retryAfter":80}}]}`}
+	tests := []struct {
+		name         string
+		config       RetryConfig
+		query        string
+		replies      []reply
+		wantRequests int
+		wantErr      bool
+		minElapsed   time.Duration
+		maxElapsed   time.Duration
+	}{
+		{"recovers", RetryConfig{MaxRetries: 3}, "{ a }", []reply{unavailable, {http.StatusBadGateway, "", ""}, ok}, 3, false, 0, time.Second},
+		{"gives up", RetryConfig{MaxRetries: 2}, "{ a }", []reply{unavailable, unavailable, unavailable, ok}, 3, true, 0, time.Second},
+		{"internal error", RetryConfig{MaxRetries: 3}, "{ a }", []reply{{http.StatusInternalServerError, "", ""}, ok}, 1, true, 0, time.Second},
+		{"bad request", RetryConfig{MaxRetries: 3}, "{ a }", []reply{{http.StatusBadRequest, "", ""}, ok}, 1, true, 0, time.Second},
+		{"mutation", RetryConfig{MaxRetries: 3}, "mutation { a }", []reply{unavailable, ok}, 1, true, 0, time.Second},
+		{"mutation opted in", RetryConfig{MaxRetries: 3, RetryMutations: true}, "mutation { a }", []reply{unavailable, ok}, 2, false, 0, time.Second},
+		{"Retry-After", RetryConfig{MaxRetries: 3}, "{ a }", []reply{{http.StatusTooManyRequests, "1", ""}, ok}, 2, false, time.Second, 3 * time.Second},
+		{"rate limit extension", RetryConfig{MaxRetries: 3}, "{ a }", []reply{rateLimited, ok}, 2, false, 80 * time.Millisecond, time.Second},
+		{"wait past MaxElapsed", RetryConfig{MaxRetries: 3, MaxElapsed: 100 * time.Millisecond}, "{ a }", []reply{{http.StatusTooManyRequests, "60", ""}, ok}, 1, true, 0, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			var mu sync.Mutex
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				rep := tt.replies[min(requests, len(tt.replies)-1)]
+				requests++
+				mu.Unlock()
+				if rep.retryAfter != "" {
+					w.Header().Set("Retry-After", rep.retryAfter)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(rep.status)
+				w.Write([]byte(rep.body))
+			}))
+			defer hs.Close()
+
+			config := tt.config
+			config.InitialInterval = 10 * time.Millisecond
+			c := New(hs.URL).Use(RetryMiddlewareWithConfig(config))
+			start := time.Now()
+			res := c.Execute(context.Background(), &Request{Query: tt.query})
+			elapsed := time.Since(start)
+			if res.IsErr() != tt.wantErr {
+				t.Errorf("result = %v", res)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed || elapsed > tt.maxElapsed {
+				t.Errorf("took %v, want %v to %v", elapsed, tt.minElapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
+
+func TestRetryMiddlewareNetworkErrors(t *testing.T) {
+	var requests int
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer hs.Close()
+
+	c := New(hs.URL).Use(RetryMiddlewareWithConfig(RetryConfig{MaxRetries: 3, InitialInterval: 10 * time.Millisecond}))
+	if res := c.Query(context.Background(), "{ a }", nil); res.IsErr() || requests != 3 {
+		t.Errorf("result %v after %d requests, want success after 3", res, requests)
+	}
+
+	// A cancelled request is not retried.
+	requests = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res := c.Query(ctx, "{ a }", nil); !errors.Is(res.Error(), context.Canceled) || requests != 0 {
+		t.Errorf("cancelled request: %v after %d requests", res.Error(), requests)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"5", 5 * time.Second, 5 * time.Second},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v to %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestCachingMiddlewareKeys(t *testing.T) {
+	var requests int
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"data":{"n":%d}}`, requests)))
+	}))
+	defer hs.Close()
+
+	vars := func(kv ...any) map[string]any {
+		m := make(map[string]any)
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i].(string)] = kv[i+1]
+		}
+		return m
+	}
+	// Each step is executed in order on one client; wantN is the response
+	// it gets, which is the count of requests that reached the server.
+	tests := []struct {
+		name  string
+		req   Request
+		token string
+		wantN int
+	}{
+		{"first", Request{Query: "{ n }", Variables: vars("a", 1, "b", map[string]any{"x": 1, "y": 2})}, "", 1},
+		{"same variables in another order", Request{Query: "{ n }", Variables: vars("b", map[string]any{"y": 2, "x": 1}, "a", 1)}, "", 1},
+		{"other variables", Request{Query: "{ n }", Variables: vars("a", 2, "b", map[string]any{"x": 1, "y": 2})}, "", 2},
+		{"operation name", Request{Query: "{ n }", OperationName: "N", Variables: vars("a", 1, "b", map[string]any{"x": 1, "y": 2})}, "", 3},
+		{"query that looks like a key", Request{Query: "{ n }:map[a:1]"}, "", 4},
+		{"mutation", Request{Query: "mutation { n }"}, "", 5},
+		{"mutation again", Request{Query: "mutation { n }"}, "", 6},
+		{"other user", Request{Query: "{ n }", Variables: vars("a", 1, "b", map[string]any{"x": 1, "y": 2})}, "u2", 7},
+		{"other user again", Request{Query: "{ n }", Variables: vars("a", 1, "b", map[string]any{"x": 1, "y": 2})}, "u2", 7},
+	}
+	c := New(hs.URL).Use(CachingMiddlewareWithConfig(CacheConfig{Cache: NewSimpleCache(), TTL: time.Minute, VaryHeaders: []string{"Authorization"}}))
+	for _, tt := range tests {
+		c.SetAuthToken(tt.token)
+		req := tt.req
+		res := c.Execute(context.Background(), &req)
+		if want := fmt.Sprintf(`{"n":%d}`, tt.wantN); res.IsErr() || string(res.Unwrap().Data) != want {
+			t.Errorf("%s: result = %v, want %s", tt.name, res, want)
+		}
+	}
+
+	// Headers added for a request vary the key too.
+	ctx := withRequestHeader(context.Background(), "Authorization", "Bearer u3")
+	c.SetAuthToken("")
+	if res := c.Query(ctx, "{ n }", nil); string(res.Unwrap().Data) != `{"n":8}` {
+		t.Errorf("request header: data = %s", res.Unwrap().Data)
+	}
+	if res := c.Query(context.Background(), "{ n }", nil); string(res.Unwrap().Data) != `{"n":9}` {
+		t.Errorf("without the header: data = %s", res.Unwrap().Data)
+	}
+}
+
+func TestSimpleCache(t *testing.T) {
+	resp := func(n int) *Response { return &Response{Data: json.RawMessage(fmt.Sprint(n))} }
+	type step struct {
+		op   string // get, set, delete, flush, sleep
+		key  string
+		n    int // set: value, get: wanted value or 0 for a miss
+		ttl  time.Duration
+		wait time.Duration
+	}
+	tests := []struct {
+		name   string
+		config SimpleCacheConfig
+		steps  []step
+		want   CacheStats
+	}{
+		{
+			name: "hits and misses",
+			steps: []step{
+				{op: "get", key: "a"},
+				{op: "set", key: "a", n: 1, ttl: time.Minute},
+				{op: "get", key: "a", n: 1},
+				{op: "set", key: "a", n: 2, ttl: time.Minute},
+				{op: "get", key: "a", n: 2},
+			},
+			want: CacheStats{Hits: 2, Misses: 1, Entries: 1},
+		},
+		{
+			name: "expiry",
+			steps: []step{
+				{op: "set", key: "a", n: 1, ttl: time.Millisecond},
+				{op: "sleep", wait: 5 * time.Millisecond},
+				{op: "get", key: "a"},
+			},
+			want: CacheStats{Misses: 1},
+		},
+		{
+			name:   "sweep on set",
+			config: SimpleCacheConfig{CleanupInterval: time.Millisecond},
+			steps: []step{
+				{op: "set", key: "a", n: 1, ttl: time.Millisecond},
+				{op: "set", key: "b", n: 2, ttl: time.Millisecond},
+				{op: "sleep", wait: 5 * time.Millisecond},
+				{op: "set", key: "c", n: 3, ttl: time.Minute},
+			},
+			want: CacheStats{Entries: 1},
+		},
+		{
+			name:   "least recently used evicted",
+			config: SimpleCacheConfig{MaxEntries: 2},
+			steps: []step{
+				{op: "set", key: "a", n: 1, ttl: time.Minute},
+				{op: "set", key: "b", n: 2, ttl: time.Minute},
+				{op: "get", key: "a", n: 1},
+				{op: "set", key: "c", n: 3, ttl: time.Minute},
+				{op: "get", key: "b"},
+				{op: "get", key: "a", n: 1},
+				{op: "get", key: "c", n: 3},
+			},
+			want: CacheStats{Hits: 3, Misses: 1, Evictions: 1, Entries: 2},
+		},
+		{
+			name: "delete and flush",
+			steps: []step{
+				{op: "set", key: "a", n: 1, ttl: time.Minute},
+				{op: "set", key: "b", n: 2, ttl: time.Minute},
+				{op: "delete", key: "a"},
+				{op: "get", key: "a"},
+				{op: "get", key: "b", n: 2},
+				{op: "flush"},
+				{op: "get", key: "b"},
+			},
+			want: CacheStats{Hits: 1, Misses: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSimpleCacheWithConfig(tt.config)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "get":
+					got, ok := c.Get(s.key)
+					if ok != (s.n != 0) || (ok && string(got.Data) != fmt.Sprint(s.n)) {
+						t.Errorf("step %d: Get(%q) = %v, %v; want %d", i, s.key, got, ok, s.n)
+					}
+				case "set":
+					c.Set(s.key, resp(s.n), s.ttl)
+				case "delete":
+					c.Delete(s.key)
+				case "flush":
+					c.Flush()
+				case "sleep":
+					time.Sleep(s.wait)
+				}
+			}
+			if got := c.Stats(); got != tt.want {
+				t.Errorf("Stats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimpleCacheConcurrentAccess(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer hs.Close()
+
+	cache := NewSimpleCacheWithConfig(SimpleCacheConfig{MaxEntries: 5})
+	c := New(hs.URL).Use(CachingMiddleware(cache, time.Minute))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if res := c.Query(context.Background(), "{ a }", map[string]any{"i": i % 10}); res.IsErr() {
+					t.Error(res.Error())
+					return
+				}
+				if i%7 == 0 {
+					cache.Delete("missing")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := cache.Stats(); stats.Hits+stats.Misses != 160 || stats.Entries > 5 {
+		t.Errorf("Stats = %+v", stats)
+	}
+}
+
+func TestForwardHeadersMiddleware(t *testing.T) {
+	got := make(chan http.Header, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer downstream.Close()
+
+	inbound := http.Header{
+		"Authorization": {"Bearer user"},
+		"Traceparent":   {"00-abc-def-01"},
+		"X-Tenant":      {"t1", "t2"},
+		"Cookie":        {"secret"},
+	}
+	tests := []struct {
+		name    string
+		inbound http.Header
+		want    http.Header
+	}{
+		{
+			name:    "forwarded",
+			inbound: inbound,
+			want:    http.Header{"Authorization": {"Bearer user"}, "Traceparent": {"00-abc-def-01"}, "X-Tenant": {"t1", "t2"}, "Cookie": nil},
+		},
+		{
+			name:    "absent headers keep the configured ones",
+			inbound: http.Header{"Traceparent": {"00-abc-def-01"}},
+			want:    http.Header{"Authorization": {"Bearer service"}, "Traceparent": {"00-abc-def-01"}, "X-Tenant": nil},
+		},
+		{
+			name: "no inbound request",
+			want: http.Header{"Authorization": {"Bearer service"}, "Traceparent": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(downstream.URL).SetAuthToken("service").Use(ForwardHeadersMiddleware("authorization", "Traceparent", "X-Tenant"))
+			ctx := context.Background()
+			if tt.inbound != nil {
+				ctx = sdk.RequestHeaders.Set(ctx, tt.inbound)
+			}
+			if res := c.Query(ctx, "{ a }", nil); res.IsErr() {
+				t.Fatal(res.Error())
+			}
+			header := <-got
+			for name, want := range tt.want {
+				if values := header.Values(name); !slices.Equal(values, want) {
+					t.Errorf("%s = %q, want %q", name, values, want)
+				}
+			}
+		})
+	}
+}
+
+func TestForwardHeadersFromResolver(t *testing.T) {
+	got := make(chan string, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer downstream.Close()
+	c := New(downstream.URL).Use(ForwardHeadersMiddleware("Authorization"))
+
+	s := server.NewBuilder().
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *server.Context, p any, args map[string]any) (any, error) {
+			res := c.Query(ctx, "{ a }", nil)
+			if res.IsErr() {
+				return nil, res.Error()
+			}
+			return 1, nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(s.Handler())
+	defer hs.Close()
+
+	upstream := New(hs.URL + "/graphql").SetAuthToken("user")
+	if res := upstream.Query(context.Background(), "{ a }", nil); res.IsErr() {
+		t.Fatal(res.Error())
+	}
+	if auth := <-got; auth != "Bearer user" {
+		t.Errorf("downstream Authorization = %q", auth)
+	}
+}
+
+func TestConcurrentSetHeader(t *testing.T) {
+	var mu sync.Mutex
+	var got []http.Header
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"literal config", Config{URL: hs.URL}},
+		{"default config", DefaultConfig(hs.URL)},
+		{"caller's headers", Config{URL: hs.URL, Headers: map[string]string{"X-Static": "1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			got = nil
+			mu.Unlock()
+			c := NewWithConfig(tt.config)
+
+			var wg sync.WaitGroup
+			for i := range 20 {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					c.SetHeader("X-N", strconv.Itoa(i))
+					c.SetAuthToken("t" + strconv.Itoa(i))
+				}()
+				go func() {
+					defer wg.Done()
+					if res := c.Query(context.Background(), "{ a }", nil); res.IsErr() {
+						t.Error(res.Error())
+					}
+				}()
+			}
+			wg.Wait()
+
+			if _, ok := tt.config.Headers["X-N"]; ok {
+				t.Error("SetHeader changed the caller's map")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, h := range got {
+				if tt.config.Headers["X-Static"] != "" && h.Get("X-Static") != "1" {
+					t.Errorf("configured header missing: %v", h)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseClone(t *testing.T) {
+	orig := &Response{
+		Data:       json.RawMessage(`{"a":1}`),
+		Errors:     []GraphQLError{{Message: "m", Path: []any{"a", 0}, Locations: []Location{{1, 2}}, Extensions: map[string]any{"code": "X", "n": map[string]any{"k": []any{"v"}}}}},
+		Extensions: map[string]any{"cost": map[string]any{"total": 1}},
+		Header:     http.Header{"X": {"1"}},
+	}
+	c := orig.Clone()
+
+	tests := []struct {
+		name   string
+		mutate func(*Response)
+	}{
+		{"data", func(r *Response) { r.Data[2] = 'b' }},
+		{"error message", func(r *Response) { r.Errors[0].Message = "changed" }},
+		{"error path", func(r *Response) { r.Errors[0].Path[0] = "b" }},
+		{"error locations", func(r *Response) { r.Errors[0].Locations[0].Line = 9 }},
+		{"nested error extension", func(r *Response) { r.Errors[0].Extensions["n"].(map[string]any)["k"].([]any)[0] = "w" }},
+		{"extensions", func(r *Response) { r.Extensions["cost"].(map[string]any)["total"] = 2 }},
+		{"header", func(r *Response) { r.Header.Set("X", "2") }},
+		{"set data", func(r *Response) { r.SetDataJSON(map[string]int{"a": 2}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := fmt.Sprintf("%v %v %v %v", string(orig.Data), orig.Errors, orig.Extensions, orig.Header)
+			tt.mutate(c)
+			if got := fmt.Sprintf("%v %v %v %v", string(orig.Data), orig.Errors, orig.Extensions, orig.Header); got != want {
+				t.Errorf("changing the clone changed the original: %s, want %s", got, want)
+			}
+		})
+	}
+	if (*Response)(nil).Clone() != nil {
+		t.Error("Clone of nil is not nil")
+	}
+}
+
+func TestSetDataJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		want    string
+		wantErr bool
+	}{
+		{"struct", struct {
+			User string `json:"user"`
+		}{"u"}, `{"user":"u"}`, false},
+		{"null", nil, `null`, false},
+		{"unencodable", map[string]any{"f": func() {}}, `{"old":1}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &Response{Data: json.RawMessage(`{"old":1}`)}
+			err := resp.SetDataJSON(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(resp.Data) != tt.want {
+				t.Errorf("Data = %s, want %s", resp.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixtureMiddleware(t *testing.T) {
+	var requests atomic.Int32
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"from":"server"}}`))
+	}))
+	defer hs.Close()
+
+	fixtures := map[string]*Response{
+		"GetUser": {Data: json.RawMessage(`{"from":"name"}`)},
+		"{ me }":  {Data: json.RawMessage(`{"from":"query"}`)},
+	}
+	c := New(hs.URL).Use(FixtureMiddleware(fixtures))
+
+	tests := []struct {
+		name         string
+		req          Request
+		want         string
+		wantRequests int32
+	}{
+		{"by operation name", Request{Query: "query GetUser { user }"}, `{"from":"name"}`, 0},
+		{"by request operation name", Request{Query: "query GetUser { user } query Other { x }", OperationName: "GetUser"}, `{"from":"name"}`, 0},
+		{"by query", Request{Query: "{ me }"}, `{"from":"query"}`, 0},
+		{"not a fixture", Request{Query: "query Other { x }"}, `{"from":"server"}`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			res := c.Execute(context.Background(), &tt.req)
+			if res.IsErr() {
+				t.Fatal(res.Error())
+			}
+			resp := res.Unwrap()
+			if string(resp.Data) != tt.want {
+				t.Errorf("data = %s, want %s", resp.Data, tt.want)
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", n, tt.wantRequests)
+			}
+			resp.SetDataJSON("changed")
+		})
+	}
+	if string(fixtures["GetUser"].Data) != `{"from":"name"}` {
+		t.Errorf("changing a response changed its fixture: %s", fixtures["GetUser"].Data)
+	}
+}
+
+func TestTransformingMiddlewareOverCache(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"secret":"abc"}}`))
+	}))
+	defer hs.Close()
+
+	// reveal stands in for a middleware decrypting a field.
+	reveal := func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		var data struct{ Secret string }
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			return nil, err
+		}
+		return resp, resp.SetDataJSON(map[string]string{"secret": strings.ToUpper(data.Secret) + "!"})
+	}
+
+	tests := []struct {
+		name  string
+		cache Middleware
+	}{
+		{"caching", CachingMiddleware(NewSimpleCache(), time.Minute)},
+		{"dedupe", DedupeMiddleware()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(hs.URL).Use(reveal).Use(tt.cache)
+			for i := range 3 {
+				res := c.Query(context.Background(), "{ secret }", nil)
+				if res.IsErr() {
+					t.Fatal(res.Error())
+				}
+				if got := string(res.Unwrap().Data); got != `{"secret":"ABC!"}` {
+					t.Errorf("request %d: data = %s", i, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultCompressMinSize is the default of Config.CompressMinSize.
+const defaultCompressMinSize = 1024
+
+// requestBody returns the body of a request posting encoded, and its
+// Content-Encoding: gzip if CompressRequests is set and encoded is large
+// enough, or else none.
+func (c *Client) requestBody(encoded []byte) (io.Reader, string) {
+	minSize := c.config.CompressMinSize
+	if minSize <= 0 {
+		minSize = defaultCompressMinSize
+	}
+	if !c.config.CompressRequests || len(encoded) < minSize {
+		return bytes.NewReader(encoded), ""
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	// Writes to a bytes.Buffer do not fail.
+	zw.Write(encoded)
+	zw.Close()
+	return &buf, "gzip"
+}
+
+// send sends httpReq with hc, accepting a gzipped response and
+// decompressing it. http.Transport only does so itself when it sets
+// Accept-Encoding, which custom transports may not do.
+func send(hc *http.Client, httpReq *http.Request) (*http.Response, error) {
+	if httpReq.Header.Get("Accept-Encoding") == "" {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	}
+	httpResp, err := hc.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(httpResp.Header.Get("Content-Encoding"), "gzip") {
+		httpResp.Body = &gzipReader{body: httpResp.Body}
+		httpResp.Header.Del("Content-Encoding")
+		httpResp.Header.Del("Content-Length")
+		httpResp.ContentLength = -1
+		httpResp.Uncompressed = true
+	}
+	return httpResp, nil
+}
+
+// gzipReader decompresses a response body, reading the gzip header on the
+// first Read so that streamed responses are not held up.
+type gzipReader struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (r *gzipReader) Read(p []byte) (int, error) {
+	if r.zr == nil && r.err == nil {
+		r.zr, r.err = gzip.NewReader(r.body)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.zr.Read(p)
+}
+
+func (r *gzipReader) Close() error {
+	return r.body.Close()
+}
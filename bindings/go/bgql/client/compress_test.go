@@ -0,0 +1,104 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gzipServer echoes the variable "v" of requests, gzipping its responses
+// to clients that accept it, and records the request.
+func gzipServer(t *testing.T, status int, got *http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = *r
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("request body is not gzipped: %v", err)
+				return
+			}
+			body = zr
+		}
+		var req Request
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+		data, _ := json.Marshal(map[string]any{"data": map[string]any{"v": req.Variables["v"]}})
+
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.WriteHeader(status)
+			w.Write(data)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(status)
+		zw := gzip.NewWriter(w)
+		zw.Write(data)
+		zw.Close()
+	}))
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("x", 4096)
+	tests := []struct {
+		name         string
+		compress     bool
+		transport    http.RoundTripper
+		value        string
+		status       int
+		wantEncoding string
+	}{
+		{"small request", true, nil, "small", http.StatusOK, ""},
+		{"large request", true, nil, large, http.StatusOK, "gzip"},
+		{"compression off", false, nil, large, http.StatusOK, ""},
+		{"custom transport", true, &http.Transport{DisableCompression: true}, large, http.StatusOK, "gzip"},
+		{"gzipped error", false, nil, "small", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Request
+			hs := gzipServer(t, tt.status, &got)
+			defer hs.Close()
+
+			config := DefaultConfig(hs.URL)
+			config.CompressRequests = tt.compress
+			if tt.transport != nil {
+				config.HTTPClient = &http.Client{Transport: tt.transport}
+			}
+			res := NewWithConfig(config).Query(context.Background(), "query($v: String) { v }", map[string]any{"v": tt.value})
+
+			if enc := got.Header.Get("Content-Encoding"); enc != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", enc, tt.wantEncoding)
+			}
+			if enc := got.Header.Get("Accept-Encoding"); enc != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want gzip", enc)
+			}
+			var resp *Response
+			if tt.status >= 400 {
+				var httpErr *HTTPError
+				if !res.IsErr() || !errors.As(res.Error(), &httpErr) {
+					t.Fatalf("got %v, want an HTTP error", res)
+				}
+				resp = httpErr.Response
+			} else {
+				if res.IsErr() {
+					t.Fatal(res.Error())
+				}
+				resp = res.Unwrap()
+			}
+			var data struct{ V string }
+			if resp == nil || json.Unmarshal(resp.Data, &data) != nil || data.V != tt.value {
+				t.Errorf("response data was not decompressed: %v", resp)
+			}
+		})
+	}
+}
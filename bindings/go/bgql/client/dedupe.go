@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// dedupeCall is a request shared by identical requests.
+type dedupeCall struct {
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// DedupeMiddleware sends identical queries in flight at the same time as
+// one request, keyed like CachingMiddleware on the method, operation
+// name, query, variables, and headers added for the request. Each caller
+// gets its own copy of the response. A caller whose context ends stops
+// waiting, but the shared request goes on for the others.
+//
+// Mutations, uploads, and requests using @defer or @stream are always
+// sent on their own.
+func DedupeMiddleware() Middleware {
+	var mu sync.Mutex
+	inflight := make(map[string]*dedupeCall)
+
+	return func(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+		if isMutation(req) || wantsIncremental(req.Query) || findUploads(req) != nil {
+			return next(ctx, req)
+		}
+		var added []string
+		if h, ok := ctx.Value(requestHeadersKey{}).(http.Header); ok {
+			added = slices.Sorted(maps.Keys(h))
+		}
+		key, err := cacheKey(ctx, req, added)
+		if err != nil {
+			return next(ctx, req)
+		}
+
+		mu.Lock()
+		call, ok := inflight[key]
+		if !ok {
+			call = &dedupeCall{done: make(chan struct{})}
+			inflight[key] = call
+			go func(ctx context.Context) {
+				call.resp, call.err = next(ctx, req)
+				mu.Lock()
+				delete(inflight, key)
+				mu.Unlock()
+				close(call.done)
+			}(context.WithoutCancel(ctx))
+		}
+		mu.Unlock()
+
+		select {
+		case <-call.done:
+			return call.resp.Clone(), call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedupeMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"data":{"n":%d}}`, n)))
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		name     string
+		req      Request
+		header   string
+		ownGroup bool
+	}{
+		{"query", Request{Query: "{ n }", Variables: map[string]any{"a": 1, "b": 2}}, "", false},
+		{"same variables", Request{Query: "{ n }", Variables: map[string]any{"b": 2, "a": 1}}, "", false},
+		{"other variables", Request{Query: "{ n }", Variables: map[string]any{"a": 2, "b": 2}}, "", true},
+		{"other user", Request{Query: "{ n }", Variables: map[string]any{"a": 1, "b": 2}}, "Bearer u2", true},
+		{"mutation", Request{Query: "mutation { n }"}, "", true},
+		{"second mutation", Request{Query: "mutation { n }"}, "", true},
+	}
+	c := New(hs.URL).Use(DedupeMiddleware())
+	results := make([]*Response, len(tests))
+	var wg sync.WaitGroup
+	for i, tt := range tests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = withRequestHeader(ctx, "Authorization", tt.header)
+			}
+			req := tt.req
+			res := c.Execute(ctx, &req)
+			if res.IsErr() {
+				t.Errorf("%s: %v", tt.name, res.Error())
+				return
+			}
+			results[i] = res.Unwrap()
+		}()
+	}
+	// 1 shared request, and one for each of the others.
+	want := int32(1)
+	for _, tt := range tests {
+		if tt.ownGroup {
+			want++
+		}
+	}
+	for deadline := time.Now().Add(5 * time.Second); requests.Load() < want && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := requests.Load(); n != want {
+		t.Errorf("requests = %d, want %d", n, want)
+	}
+	if results[0] == nil || results[1] == nil || string(results[0].Data) != string(results[1].Data) {
+		t.Fatalf("deduplicated responses differ: %v, %v", results[0], results[1])
+	}
+	if results[0] == results[1] || &results[0].Data[0] == &results[1].Data[0] {
+		t.Errorf("callers share one response")
+	}
+}
+
+func TestDedupeMiddlewareCancelledWaiter(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer hs.Close()
+	c := New(hs.URL).Use(DedupeMiddleware())
+
+	// The first caller starts the request, then gives up on it.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() { first <- c.Query(ctx, "{ a }", nil).Error() }()
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan string, 1)
+	go func() {
+		res := c.Query(context.Background(), "{ a }", nil)
+		if res.IsErr() {
+			second <- res.Error().Error()
+			return
+		}
+		second <- string(res.Unwrap().Data)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	close(release)
+	if got := <-second; got != `{"a":1}` {
+		t.Errorf("other caller got %s", got)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
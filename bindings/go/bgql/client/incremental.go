@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// incrementalAccept is the Accept header of requests using @defer or
+// @stream, which servers may answer with a multipart/mixed stream.
+const incrementalAccept = "multipart/mixed;deferSpec=20220824, application/json"
+
+// IncrementalPayload is a payload of a streamed response: the initial
+// result, or a later one delivering @defer and @stream results.
+type IncrementalPayload struct {
+	Data        json.RawMessage     `json:"data,omitempty"`
+	Errors      []GraphQLError      `json:"errors,omitempty"`
+	Incremental []IncrementalResult `json:"incremental,omitempty"`
+	Extensions  map[string]any      `json:"extensions,omitempty"`
+	// HasNext is false on the last payload.
+	HasNext bool `json:"hasNext"`
+}
+
+// IncrementalResult is the result of a deferred fragment, in Data, or of
+// streamed list items, in Items, at Path.
+type IncrementalResult struct {
+	Data       json.RawMessage   `json:"data,omitempty"`
+	Items      []json.RawMessage `json:"items,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Label      string            `json:"label,omitempty"`
+	Errors     []GraphQLError    `json:"errors,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// IncrementalStream iterates the payloads of a streamed response. Next is
+// not safe for concurrent use, but Close may be called at any time to
+// abandon the stream.
+type IncrementalStream struct {
+	body io.Closer
+	read func() ([]byte, error)
+	done bool
+	once sync.Once
+}
+
+// newIncrementalStream returns the stream of payloads of resp if it is a
+// multipart/mixed or text/event-stream response, or else nil.
+func newIncrementalStream(resp *http.Response) *IncrementalStream {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	s := &IncrementalStream{body: resp.Body}
+	switch {
+	case mediaType == "multipart/mixed" && params["boundary"] != "":
+		parts := multipart.NewReader(resp.Body, params["boundary"])
+		s.read = func() ([]byte, error) {
+			part, err := parts.NextPart()
+			if err != nil {
+				return nil, err
+			}
+			defer part.Close()
+			return io.ReadAll(part)
+		}
+	case mediaType == "text/event-stream":
+		events := newSSEReader(resp.Body)
+		s.read = func() ([]byte, error) {
+			for {
+				ev, err := events.next()
+				if err != nil {
+					return nil, err
+				}
+				switch ev.typ {
+				case "next", "message":
+					return []byte(ev.data), nil
+				case "complete":
+					return nil, io.EOF
+				}
+			}
+		}
+	default:
+		return nil
+	}
+	return s
+}
+
+// Next returns the next payload. After the payload whose HasNext is
+// false, or when the stream ends early, it closes the stream and returns
+// io.EOF.
+func (s *IncrementalStream) Next() (*IncrementalPayload, error) {
+	for !s.done {
+		data, err := s.read()
+		if err != nil {
+			s.finish()
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		// Servers send empty parts and {} to keep idle streams open.
+		if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || string(trimmed) == "{}" {
+			continue
+		}
+		var payload IncrementalPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			s.finish()
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		if !payload.HasNext {
+			s.finish()
+		}
+		return &payload, nil
+	}
+	return nil, io.EOF
+}
+
+func (s *IncrementalStream) finish() {
+	s.done = true
+	s.Close()
+}
+
+// Close closes the response body, ending the stream.
+func (s *IncrementalStream) Close() error {
+	var err error
+	s.once.Do(func() { err = s.body.Close() })
+	return err
+}
+
+// wantsIncremental reports whether a query may be answered incrementally.
+func wantsIncremental(query string) bool {
+	return strings.Contains(query, "@defer") || strings.Contains(query, "@stream")
+}
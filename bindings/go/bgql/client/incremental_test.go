@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// streamingServer answers every request with body as contentType, and
+// records the Accept header it was sent.
+func streamingServer(t *testing.T, contentType, body string, accept *string) *httptest.Server {
+	t.Helper()
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func TestExecuteIncremental(t *testing.T) {
+	const multipartBody = "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+		`{"data":{"a":1},"hasNext":true}` +
+		"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n{}" +
+		"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+		`{"incremental":[{"data":{"b":2},"path":[],"label":"b"}],"hasNext":true}` +
+		"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" +
+		`{"incremental":[{"items":[3],"path":["c",0]}],"hasNext":false}` +
+		"\r\n-----\r\n"
+	const sseBody = "event: next\ndata: {\"data\":{\"a\":1},\"hasNext\":true}\n\n" +
+		":\n\n" +
+		"event: next\ndata: {\"incremental\":[{\"data\":{\"b\":2},\"path\":[],\"label\":\"b\"}],\"hasNext\":true}\n\n" +
+		"event: next\ndata: {\"incremental\":[{\"items\":[3],\"path\":[\"c\",0]}],\"hasNext\":false}\n\n" +
+		"event: complete\ndata:\n\n"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"multipart", `multipart/mixed; boundary="-"; deferSpec=20220824`, multipartBody},
+		{"event stream", "text/event-stream", sseBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accept string
+			hs := streamingServer(t, tt.contentType, tt.body, &accept)
+			c := New(hs.URL)
+
+			resp := c.Execute(context.Background(), &Request{Query: "{ a ... @defer(label: \"b\") { b } c @stream }"}).Unwrap()
+			if !strings.HasPrefix(accept, "multipart/mixed") {
+				t.Errorf("Accept = %q, want multipart/mixed first", accept)
+			}
+			if string(resp.Data) != `{"a":1}` || resp.Stream == nil {
+				t.Fatalf("initial response = %s, stream %v", resp.Data, resp.Stream)
+			}
+
+			var got []string
+			for {
+				payload, err := resp.Stream.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, inc := range payload.Incremental {
+					result := string(inc.Data)
+					if inc.Items != nil {
+						result = fmt.Sprintf("%s", inc.Items)
+					}
+					got = append(got, fmt.Sprintf("%s@%v:%s", result, inc.Path, inc.Label))
+				}
+				got = append(got, fmt.Sprint(payload.HasNext))
+			}
+			want := []string{`{"b":2}@[]:b`, "true", `[3]@[c 0]:`, "false"}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("payloads = %v, want %v", got, want)
+			}
+			if _, err := resp.Stream.Next(); err != io.EOF {
+				t.Errorf("Next after the last payload = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestExecuteIncrementalSinglePayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		wantAccept  string
+		wantErr     string
+	}{
+		{"plain query", "{ a }", "application/json", `{"data":{"a":1}}`, "application/json", ""},
+		{"defer not streamed", "{ ... @defer { a } }", "application/json", `{"data":{"a":1}}`, incrementalAccept, ""},
+		{"one part", "{ ... @defer { a } }", `multipart/mixed; boundary="-"`, "\r\n---\r\n\r\n{\"data\":{\"a\":1},\"hasNext\":false}\r\n-----\r\n", incrementalAccept, ""},
+		{"initial errors", "{ ... @defer { a } }", `multipart/mixed; boundary="-"`, "\r\n---\r\n\r\n{\"errors\":[{\"message\":\"boom\"}],\"hasNext\":true}\r\n---", incrementalAccept, "boom"},
+		{"empty stream", "{ ... @defer { a } }", "text/event-stream", "event: complete\ndata:\n\n", incrementalAccept, "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accept string
+			hs := streamingServer(t, tt.contentType, tt.body, &accept)
+			res := New(hs.URL).Execute(context.Background(), &Request{Query: tt.query})
+			if accept != tt.wantAccept {
+				t.Errorf("Accept = %q, want %q", accept, tt.wantAccept)
+			}
+			if tt.wantErr != "" {
+				if res.IsOk() || !strings.Contains(res.Error().Error(), tt.wantErr) {
+					t.Fatalf("result = %v, want error %q", res, tt.wantErr)
+				}
+				return
+			}
+			resp := res.Unwrap()
+			if string(resp.Data) != `{"a":1}` || resp.Stream != nil {
+				t.Errorf("response = %s, stream %v; want the data and no stream", resp.Data, resp.Stream)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// MinifyQuery returns query without comments, commas, or whitespace other
+// than the single spaces that separate names and numbers. Strings and
+// block strings are kept exactly as written. It fails if query has a
+// syntax error in its tokens.
+func MinifyQuery(query string) (string, error) {
+	var sb strings.Builder
+	lexer := language.NewLexer(query)
+	var prev language.Token
+	for first := true; ; first = false {
+		tok, err := lexer.Next()
+		if err != nil {
+			return "", err
+		}
+		if tok.Kind == language.EOF {
+			return sb.String(), nil
+		}
+		if !first && needsSpace(prev.Kind, tok.Kind) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(query[tok.Start:tok.End])
+		prev = tok
+	}
+}
+
+// needsSpace reports whether tokens of kinds a and b, in that order, must
+// be separated to be read back as two tokens.
+func needsSpace(a, b language.TokenKind) bool {
+	switch a {
+	case language.Name, language.Int, language.Float:
+		// A number cannot be followed by a name or "...", and "1.5" or
+		// "ab" would be read as one token.
+		return b == language.Name || b == language.Int || b == language.Float || b == language.Spread
+	case language.String, language.BlockString:
+		// "" followed by a string would start a block string.
+		return b == language.String || b == language.BlockString
+	}
+	return false
+}
+
+// minified returns req with its query minified if MinifyQueries is set.
+// Queries that do not minify are sent as written, for the server to
+// report their errors, and persisted queries are not sent at all.
+func (c *Client) minified(req *Request) *Request {
+	if !c.config.MinifyQueries || c.persisted != nil {
+		return req
+	}
+	query, err := MinifyQuery(req.Query)
+	if err != nil || query == req.Query {
+		return req
+	}
+	sent := *req
+	sent.Query = query
+	return &sent
+}
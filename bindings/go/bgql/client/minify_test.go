@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMinifyQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"whitespace", "query  Get {\n  user(id: 1) {\n    id\n    name\n  }\n}\n", "query Get{user(id:1){id name}}", false},
+		{"comments", "# leading\n{ a # trailing\n b }", "{a b}", false},
+		{"commas", "{ f(a: 1, b: [1, 2, 3]) { x, y } }", "{f(a:1 b:[1 2 3]){x y}}", false},
+		{"variables and directives", "query ($id: ID!, $n: Int = 10) @live { u(id: $id) @include(if: true) { ...F ... on User { id } } }",
+			"query($id:ID!$n:Int=10)@live{u(id:$id)@include(if:true){...F ...on User{id}}}", false},
+		{"string", `{ f(s: "a, b  # not a comment\t") }`, `{f(s:"a, b  # not a comment\t")}`, false},
+		{"escaped quote", `{ f(s: "say \"hi\" # there") }`, `{f(s:"say \"hi\" # there")}`, false},
+		{"block string", "{ f(s: \"\"\"\n  # kept\n   x,  y\n  \"\"\") }", "{f(s:\"\"\"\n  # kept\n   x,  y\n  \"\"\")}", false},
+		{"adjacent strings", `{ f(a: ["" "b"]) }`, `{f(a:["" "b"])}`, false},
+		{"numbers", "{ f(a: [1.5 -2 3e4], b: -1) }", "{f(a:[1.5 -2 3e4]b:-1)}", false},
+		{"empty", "  # nothing\n", "", false},
+		{"unterminated string", `{ f(s: "oops) }`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MinifyQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MinifyQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyQueries(t *testing.T) {
+	var sent string
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = req.Query
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		name   string
+		minify bool
+		query  string
+		want   string
+	}{
+		{"off", false, "{ a, b }", "{ a, b }"},
+		{"on", true, "{ a, b } # done", "{a b}"},
+		{"syntax error", true, "{ a ? }", "{ a ? }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig(hs.URL)
+			config.MinifyQueries = tt.minify
+			req := &Request{Query: tt.query}
+			NewWithConfig(config).Execute(context.Background(), req)
+			if sent != tt.want {
+				t.Errorf("sent %q, want %q", sent, tt.want)
+			}
+			if req.Query != tt.query {
+				t.Errorf("request was modified: %q", req.Query)
+			}
+		})
+	}
+}
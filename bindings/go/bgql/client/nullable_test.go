@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// newUpdateServer serves an updateUser mutation reporting, for each
+// nullable input field, whether it was absent, null, or set.
+func newUpdateServer(t *testing.T) string {
+	t.Helper()
+	s := server.NewBuilder().
+		Schema(`
+			type Query { a: Int }
+			input UserPatch { name: String nickname: String }
+			type Mutation { updateUser(id: ID!, patch: UserPatch!, bio: String): String! }
+		`).
+		Resolver("Mutation", "updateUser", func(ctx *server.Context, p any, args map[string]any) (any, error) {
+			state := func(fields map[string]any, name string) string {
+				v, ok := fields[name]
+				switch {
+				case !ok:
+					return "absent"
+				case v == nil:
+					return "null"
+				}
+				return fmt.Sprint(v)
+			}
+			patch := args["patch"].(map[string]any)
+			return fmt.Sprintf("%s name=%s nickname=%s bio=%s",
+				args["id"], state(patch, "name"), state(patch, "nickname"), state(args, "bio")), nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(s.Handler())
+	t.Cleanup(hs.Close)
+	return hs.URL + "/graphql"
+}
+
+const updateUserMutation = `mutation ($id: ID!, $patch: UserPatch!, $bio: String) {
+	updateUser(id: $id, patch: $patch, bio: $bio)
+}`
+
+// The bindings module is the one depending on both the server and the sdk,
+// so the round trip of sdk.Nullable is tested here too.
+func TestNullRoundTrip(t *testing.T) {
+	url := newUpdateServer(t)
+
+	type patch struct {
+		Name     Null[string] `json:"name"`
+		Nickname Null[string] `json:"nickname"`
+	}
+	type variables struct {
+		ID    string       `json:"id"`
+		Patch patch        `json:"patch"`
+		Bio   Null[string] `json:"bio"`
+	}
+	type sdkPatch struct {
+		Name     sdk.Nullable[string] `json:"name"`
+		Nickname sdk.Nullable[string] `json:"nickname"`
+	}
+	type sdkVariables struct {
+		ID    string               `json:"id"`
+		Patch sdkPatch             `json:"patch"`
+		Bio   sdk.Nullable[string] `json:"bio"`
+	}
+
+	tests := []struct {
+		name string
+		vars variables
+		sdk  sdkVariables
+		want string
+	}{
+		{
+			"absent",
+			variables{ID: "1"},
+			sdkVariables{ID: "1"},
+			"1 name=absent nickname=absent bio=absent",
+		},
+		{
+			"null",
+			variables{ID: "2", Patch: patch{Nickname: ExplicitNull[string]()}, Bio: ExplicitNull[string]()},
+			sdkVariables{ID: "2", Patch: sdkPatch{Nickname: sdk.ExplicitNull[string]()}, Bio: sdk.ExplicitNull[string]()},
+			"2 name=absent nickname=null bio=null",
+		},
+		{
+			"values",
+			variables{ID: "3", Patch: patch{Name: Value("Ada"), Nickname: Value("")}, Bio: Value("hi")},
+			sdkVariables{ID: "3", Patch: sdkPatch{Name: sdk.NullableOf("Ada"), Nickname: sdk.NullableOf("")}, Bio: sdk.NullableOf("hi")},
+			"3 name=Ada nickname= bio=hi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := MutateTyped(New(url), context.Background(), updateUserMutation, tt.vars)
+			if res.IsErr() {
+				t.Fatalf("client: %v", res.Error())
+			}
+			var data struct{ UpdateUser string }
+			if err := json.Unmarshal(res.Unwrap().Data, &data); err != nil || data.UpdateUser != tt.want {
+				t.Errorf("client: updateUser = %q (%v), want %q", data.UpdateUser, err, tt.want)
+			}
+
+			op := sdk.NewMutation[sdkVariables, struct{ UpdateUser string }]("", updateUserMutation)
+			got := sdk.Execute(sdk.NewClient(sdk.ClientConfig{URL: url}), context.Background(), op, tt.sdk)
+			if got.IsErr() || got.Unwrap().UpdateUser != tt.want {
+				t.Errorf("sdk: updateUser = %+v (%v), want %q", got.UnwrapOr(struct{ UpdateUser string }{}), got.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNullUnmarshal(t *testing.T) {
+	var v struct {
+		A Null[int] `json:"a"`
+		B Null[int] `json:"b"`
+		C Null[int] `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"b":null,"c":3}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !v.A.IsZero() || !v.B.IsNull() {
+		t.Errorf("a = %+v, b = %+v, want absent and null", v.A, v.B)
+	}
+	if c, ok := v.C.Get(); !ok || c != 3 {
+		t.Errorf("c = %d, %v, want 3", c, ok)
+	}
+}
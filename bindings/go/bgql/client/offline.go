@@ -0,0 +1,377 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// IdempotencyKeyHeader carries the key identifying a queued mutation, so
+// the server can detect a replay of a request it already applied.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ErrMutationsPending is the cause of a QueuedError for a mutation queued
+// because earlier mutations were still waiting to be replayed, which it
+// must not overtake.
+var ErrMutationsPending = errors.New("earlier mutations are waiting to be replayed")
+
+// QueuedMutation is a mutation waiting to be replayed.
+type QueuedMutation struct {
+	ID         string    `json:"id"`
+	Request    *Request  `json:"request"`
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+}
+
+// QueuedError is returned for a mutation that failed with a transient
+// error and was queued for replay.
+type QueuedError struct {
+	ID  string
+	Err error
+}
+
+func (e *QueuedError) Error() string {
+	return fmt.Sprintf("mutation queued for replay (%s): %v", e.ID, e.Err)
+}
+
+func (e *QueuedError) Unwrap() error {
+	return e.Err
+}
+
+// QueueStore persists queued mutations in FIFO order.
+type QueueStore interface {
+	Append(m QueuedMutation) error
+	List() ([]QueuedMutation, error)
+	Remove(id string) error
+}
+
+// =============================================================================
+// Offline Queue
+// =============================================================================
+
+// OfflineQueueOption configures an OfflineQueue.
+type OfflineQueueOption func(*OfflineQueue)
+
+// ReplayInterval sets how often the queue is retried while connectivity
+// is down, before backoff. Defaults to 5 seconds.
+func ReplayInterval(d time.Duration) OfflineQueueOption {
+	return func(q *OfflineQueue) { q.interval = d }
+}
+
+// MaxReplayBackoff caps the backoff between replay attempts. Defaults to 5 minutes.
+func MaxReplayBackoff(d time.Duration) OfflineQueueOption {
+	return func(q *OfflineQueue) { q.maxBackoff = d }
+}
+
+// OnReplaySuccess is called after a queued mutation is replayed successfully.
+func OnReplaySuccess(fn func(m QueuedMutation, resp *Response)) OfflineQueueOption {
+	return func(q *OfflineQueue) { q.onSuccess = fn }
+}
+
+// OnReplayConflict is called when the server rejects a replayed mutation
+// with a non-retryable or GraphQL error. The mutation is dropped from the queue.
+func OnReplayConflict(fn func(m QueuedMutation, err error)) OfflineQueueOption {
+	return func(q *OfflineQueue) { q.onConflict = fn }
+}
+
+// OfflineQueue queues mutations that fail with transient errors and
+// replays them in order once the server is reachable again. While any are
+// queued, new mutations are queued behind them rather than sent.
+type OfflineQueue struct {
+	client     *Client
+	store      QueueStore
+	interval   time.Duration
+	maxBackoff time.Duration
+	onSuccess  func(QueuedMutation, *Response)
+	onConflict func(QueuedMutation, error)
+
+	replayMu  sync.Mutex
+	wake      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// WithOfflineQueue installs an offline queue backed by store and starts
+// replaying anything already in it. Queries are never queued.
+func (c *Client) WithOfflineQueue(store QueueStore, opts ...OfflineQueueOption) *OfflineQueue {
+	q := &OfflineQueue{
+		client:     c,
+		store:      store,
+		interval:   5 * time.Second,
+		maxBackoff: 5 * time.Minute,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	c.Use(q.middleware)
+	go q.run()
+	q.notify()
+	return q
+}
+
+// Close stops the background replayer, waiting for a replay in progress.
+// Queued mutations stay in the store.
+func (q *OfflineQueue) Close() {
+	q.closeOnce.Do(func() { close(q.done) })
+	<-q.stopped
+}
+
+// Pending returns the mutations waiting to be replayed.
+func (q *OfflineQueue) Pending() ([]QueuedMutation, error) {
+	return q.store.List()
+}
+
+func (q *OfflineQueue) middleware(ctx context.Context, req *Request, next func(context.Context, *Request) (*Response, error)) (*Response, error) {
+	if replaying, _ := ctx.Value(replayKey{}).(bool); replaying || !isMutation(req) {
+		return next(ctx, req)
+	}
+
+	id := newIdempotencyKey()
+	pending, err := q.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mutation queue: %w", err)
+	}
+	if len(pending) > 0 {
+		err = ErrMutationsPending
+	} else {
+		var resp *Response
+		resp, err = next(withRequestHeader(ctx, IdempotencyKeyHeader, id), req)
+		if err == nil || !isTransient(err) {
+			return resp, err
+		}
+	}
+
+	if storeErr := q.store.Append(QueuedMutation{ID: id, Request: req, EnqueuedAt: time.Now()}); storeErr != nil {
+		return nil, fmt.Errorf("failed to queue mutation: %w (original error: %v)", storeErr, err)
+	}
+	q.notify()
+	return nil, &QueuedError{ID: id, Err: err}
+}
+
+type replayKey struct{}
+
+func (q *OfflineQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run replays the queue whenever woken, backing off while the server is
+// unreachable.
+func (q *OfflineQueue) run() {
+	defer close(q.stopped)
+	backoff := q.interval
+	var retry <-chan time.Time
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-q.wake:
+		case <-retry:
+		}
+
+		if err := q.Replay(context.Background()); err != nil && isTransient(err) {
+			retry = time.After(backoff)
+			backoff *= 2
+			if backoff > q.maxBackoff {
+				backoff = q.maxBackoff
+			}
+			continue
+		}
+		backoff = q.interval
+		retry = nil
+	}
+}
+
+// Replay sends queued mutations in FIFO order, stopping at the first
+// transient failure so later mutations never overtake earlier ones.
+func (q *OfflineQueue) Replay(ctx context.Context) error {
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+
+	pending, err := q.store.List()
+	if err != nil {
+		return err
+	}
+
+	// Replays run through the full middleware chain, so auth and other
+	// middleware still apply; replayKey keeps them from being queued again.
+	ctx = context.WithValue(ctx, replayKey{}, true)
+	for _, m := range pending {
+		res := q.client.Execute(withRequestHeader(ctx, IdempotencyKeyHeader, m.ID), m.Request)
+		if res.IsErr() && isTransient(res.Error()) {
+			return res.Error()
+		}
+
+		if res.IsErr() {
+			if q.onConflict != nil {
+				q.onConflict(m, res.Error())
+			}
+		} else if q.onSuccess != nil {
+			q.onSuccess(m, res.Unwrap())
+		}
+
+		if err := q.store.Remove(m.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMutation reports whether the operation req selects is a mutation.
+func isMutation(req *Request) bool {
+	return operationType(req) == language.Mutation
+}
+
+// isQuery reports whether req is a query, as opposed to a mutation, a
+// subscription, or an invalid document.
+func isQuery(req *Request) bool {
+	return operationType(req) == language.Query
+}
+
+func operationType(req *Request) language.OperationType {
+	doc, err := language.Parse(req.Query)
+	if err != nil {
+		return ""
+	}
+	op := doc.Operation(req.OperationName)
+	if op == nil {
+		return ""
+	}
+	return op.Operation
+}
+
+// isTransient reports whether err is a network failure or a retryable
+// HTTP status.
+func isTransient(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Retryable()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// =============================================================================
+// Queue Stores
+// =============================================================================
+
+// MemoryQueueStore keeps queued mutations in memory.
+type MemoryQueueStore struct {
+	mu    sync.Mutex
+	items []QueuedMutation
+}
+
+// NewMemoryQueueStore creates an empty in-memory queue store.
+func NewMemoryQueueStore() *MemoryQueueStore {
+	return &MemoryQueueStore{}
+}
+
+func (s *MemoryQueueStore) Append(m QueuedMutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, m)
+	return nil
+}
+
+func (s *MemoryQueueStore) List() ([]QueuedMutation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]QueuedMutation(nil), s.items...), nil
+}
+
+func (s *MemoryQueueStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = removeQueued(s.items, id)
+	return nil
+}
+
+// FileQueueStore keeps queued mutations in a JSON file so they survive
+// process restarts.
+type FileQueueStore struct {
+	path  string
+	mu    sync.Mutex
+	items []QueuedMutation
+}
+
+// NewFileQueueStore opens the queue stored at path, creating it on first write.
+func NewFileQueueStore(path string) (*FileQueueStore, error) {
+	s := &FileQueueStore{path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.items); err != nil {
+			return nil, fmt.Errorf("failed to read queue %s: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *FileQueueStore) Append(m QueuedMutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, m)
+	return s.save()
+}
+
+func (s *FileQueueStore) List() ([]QueuedMutation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]QueuedMutation(nil), s.items...), nil
+}
+
+func (s *FileQueueStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = removeQueued(s.items, id)
+	return s.save()
+}
+
+// save writes the queue atomically via a temporary file.
+func (s *FileQueueStore) save() error {
+	data, err := json.Marshal(s.items)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func removeQueued(items []QueuedMutation, id string) []QueuedMutation {
+	for i, m := range items {
+		if m.ID == id {
+			return append(items[:i:i], items[i+1:]...)
+		}
+	}
+	return items
+}
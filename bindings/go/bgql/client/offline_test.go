@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mutationServer records the mutations it receives and fails with 503
+// while down.
+type mutationServer struct {
+	*httptest.Server
+	down atomic.Bool
+
+	mu       sync.Mutex
+	received []string
+	keys     []string
+}
+
+func newMutationServer(t *testing.T) *mutationServer {
+	t.Helper()
+	ms := &mutationServer{}
+	ms.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ms.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		ms.mu.Lock()
+		ms.received = append(ms.received, req.Query)
+		ms.keys = append(ms.keys, r.Header.Get(IdempotencyKeyHeader))
+		ms.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"ok":true}}`))
+	}))
+	t.Cleanup(ms.Close)
+	return ms
+}
+
+func (ms *mutationServer) mutations() []string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]string(nil), ms.received...)
+}
+
+func mutation(n int) string {
+	return fmt.Sprintf("mutation { add(n: %d) }", n)
+}
+
+// waitReplayed waits until q has nothing pending.
+func waitReplayed(t *testing.T, q *OfflineQueue) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pending, _ := q.Pending()
+		if len(pending) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d mutations still pending", len(pending))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOfflineQueueReplaysInOrder(t *testing.T) {
+	ms := newMutationServer(t)
+	ms.down.Store(true)
+	c := New(ms.URL)
+	q := c.WithOfflineQueue(NewMemoryQueueStore(), ReplayInterval(10*time.Millisecond))
+	defer q.Close()
+
+	for n := 1; n <= 3; n++ {
+		res := c.Mutate(context.Background(), mutation(n), nil)
+		var queued *QueuedError
+		if !errors.As(res.Error(), &queued) {
+			t.Fatalf("mutation %d error = %v, want a QueuedError", n, res.Error())
+		}
+	}
+	if res := c.Query(context.Background(), "{ ok }", nil); res.IsErr() {
+		var queued *QueuedError
+		if errors.As(res.Error(), &queued) {
+			t.Fatalf("query was queued")
+		}
+	}
+
+	ms.down.Store(false)
+	// The server is back, but mutations still queued must go first.
+	res := c.Mutate(context.Background(), mutation(4), nil)
+	if !errors.Is(res.Error(), ErrMutationsPending) {
+		t.Fatalf("mutation 4 error = %v, want ErrMutationsPending", res.Error())
+	}
+	waitReplayed(t, q)
+
+	want := []string{mutation(1), mutation(2), mutation(3), mutation(4)}
+	if got := ms.mutations(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+	for i, key := range ms.keys {
+		if key == "" {
+			t.Errorf("mutation %d replayed without an idempotency key", i+1)
+		}
+	}
+
+	// With the queue drained, mutations are sent directly again.
+	if res := c.Mutate(context.Background(), mutation(5), nil); res.IsErr() {
+		t.Fatalf("mutation 5 failed: %v", res.Error())
+	}
+}
+
+func TestOfflineQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	ms := newMutationServer(t)
+	ms.down.Store(true)
+
+	store, err := NewFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(ms.URL)
+	q := c.WithOfflineQueue(store, ReplayInterval(time.Hour))
+	for n := 1; n <= 3; n++ {
+		c.Mutate(context.Background(), mutation(n), nil)
+	}
+	queued, _ := q.Pending()
+	q.Close()
+
+	// A new process reopens the store and replays it.
+	ms.down.Store(false)
+	store, err = NewFileQueueStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayed []string
+	var mu sync.Mutex
+	q = New(ms.URL).WithOfflineQueue(store, OnReplaySuccess(func(m QueuedMutation, resp *Response) {
+		mu.Lock()
+		replayed = append(replayed, m.ID)
+		mu.Unlock()
+	}))
+	defer q.Close()
+	waitReplayed(t, q)
+
+	want := []string{mutation(1), mutation(2), mutation(3)}
+	if got := ms.mutations(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, m := range queued {
+		if i >= len(replayed) || replayed[i] != m.ID || ms.keys[i] != m.ID {
+			t.Errorf("mutation %d: replayed %v with keys %v, want ID %s", i+1, replayed, ms.keys, m.ID)
+		}
+	}
+}
+
+func TestOfflineQueueConflict(t *testing.T) {
+	// A 500 is not transient: the mutation is likely to fail again.
+	for _, status := range []int{http.StatusBadRequest, http.StatusInternalServerError} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			}))
+			defer hs.Close()
+			store := NewMemoryQueueStore()
+			queued := QueuedMutation{ID: "m1", Request: &Request{Query: mutation(1)}, EnqueuedAt: time.Now()}
+			store.Append(queued)
+
+			conflicts := make(chan string, 1)
+			q := New(hs.URL).WithOfflineQueue(store, OnReplayConflict(func(m QueuedMutation, err error) {
+				conflicts <- m.ID
+			}))
+			defer q.Close()
+
+			select {
+			case id := <-conflicts:
+				if id != "m1" {
+					t.Errorf("conflict for %s, want m1", id)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no conflict reported")
+			}
+			waitReplayed(t, q)
+		})
+	}
+}
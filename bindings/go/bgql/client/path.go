@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+)
+
+// Errors of ExecuteIntoPath, wrapped with the part of the path that is
+// missing or null.
+var (
+	ErrPathNotFound = errors.New("path not found in response data")
+	ErrPathNull     = errors.New("path is null in response data")
+)
+
+// ExecuteIntoPath executes a request and unmarshals the value at path in
+// the data into T. Path is a dot-separated list of fields and array
+// indices, such as "viewer.repositories.nodes[0].name"; an empty path is
+// the whole data.
+//
+// A path that is missing fails with ErrPathNotFound. A null value fails
+// with ErrPathNull unless T is a pointer or interface type, which is set
+// to nil.
+func ExecuteIntoPath[T any](c *Client, ctx context.Context, req *Request, path string) result.Result[T] {
+	resp := c.Execute(ctx, req)
+	if resp.IsErr() {
+		return result.Err[T](resp.Error())
+	}
+
+	raw, err := extractPath(resp.Unwrap().Data, path)
+	if err != nil {
+		return result.Err[T](err)
+	}
+	var data T
+	if isNull(raw) {
+		if kind := reflect.TypeFor[T]().Kind(); kind != reflect.Pointer && kind != reflect.Interface {
+			return result.Err[T](fmt.Errorf("%w: %s", ErrPathNull, path))
+		}
+		return result.Ok(data)
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return result.Err[T](fmt.Errorf("failed to unmarshal %s: %w", path, err))
+	}
+	return result.Ok(data)
+}
+
+// extractPath returns the value at path in data.
+func extractPath(data json.RawMessage, path string) (json.RawMessage, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	current := data
+	if len(current) == 0 {
+		current = json.RawMessage("null")
+	}
+	for i, segment := range segments {
+		prefix := joinPath(segments[:i+1])
+		if isNull(current) {
+			return nil, fmt.Errorf("%w: %s", ErrPathNull, joinPath(segments[:i]))
+		}
+		var next json.RawMessage
+		var ok bool
+		switch trimmed := bytes.TrimSpace(current); {
+		case len(trimmed) > 0 && trimmed[0] == '{':
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(trimmed, &fields); err != nil {
+				return nil, err
+			}
+			next, ok = fields[segment]
+		case len(trimmed) > 0 && trimmed[0] == '[':
+			var items []json.RawMessage
+			if err := json.Unmarshal(trimmed, &items); err != nil {
+				return nil, err
+			}
+			if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(items) {
+				next, ok = items[index], true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPathNotFound, prefix)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// splitPath splits "a.b[0].c" into "a", "b", "0", "c".
+func splitPath(path string) ([]string, error) {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		if path == "" {
+			break
+		}
+		name, rest, _ := strings.Cut(part, "[")
+		if name == "" && rest == "" {
+			return nil, fmt.Errorf("invalid path %q: empty field", path)
+		}
+		if name != "" {
+			segments = append(segments, name)
+		}
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			if _, err := strconv.Atoi(index); !ok || err != nil {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", path, index)
+			}
+			segments = append(segments, index)
+			if after == "" {
+				break
+			}
+			if after[0] != '[' {
+				return nil, fmt.Errorf("invalid path %q: unexpected %q", path, after)
+			}
+			rest = after[1:]
+		}
+	}
+	return segments, nil
+}
+
+// joinPath formats the segments of a path as "a.b.0.c".
+func joinPath(segments []string) string {
+	return strings.Join(segments, ".")
+}
+
+func isNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
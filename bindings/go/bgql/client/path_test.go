@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+)
+
+func TestExecuteIntoPath(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"viewer":{"login":"u","bio":null,"repositories":{"nodes":[{"name":"a"},{"name":"b","tags":[["x","y"]]}]}}}}`))
+	}))
+	defer hs.Close()
+	c := New(hs.URL)
+	ctx := context.Background()
+	req := &Request{Query: "{ viewer { login } }"}
+
+	type repo struct{ Name string }
+	tests := []struct {
+		name    string
+		run     func() (any, error)
+		want    any
+		wantErr error
+	}{
+		{"nested list", func() (any, error) {
+			return resultValue(ExecuteIntoPath[[]repo](c, ctx, req, "viewer.repositories.nodes"))
+		}, []repo{{"a"}, {"b"}}, nil},
+		{"index", func() (any, error) {
+			return resultValue(ExecuteIntoPath[string](c, ctx, req, "viewer.repositories.nodes[1].name"))
+		}, "b", nil},
+		{"nested indices", func() (any, error) {
+			return resultValue(ExecuteIntoPath[string](c, ctx, req, "viewer.repositories.nodes[1].tags[0][1]"))
+		}, "y", nil},
+		{"whole data", func() (any, error) {
+			v, err := resultValue(ExecuteIntoPath[map[string]any](c, ctx, req, ""))
+			return len(v), err
+		}, 1, nil},
+		{"null into pointer", func() (any, error) {
+			return resultValue(ExecuteIntoPath[*string](c, ctx, req, "viewer.bio"))
+		}, (*string)(nil), nil},
+		{"value into pointer", func() (any, error) {
+			v, err := resultValue(ExecuteIntoPath[*string](c, ctx, req, "viewer.login"))
+			return *v, err
+		}, "u", nil},
+		{"null", func() (any, error) {
+			return resultValue(ExecuteIntoPath[string](c, ctx, req, "viewer.bio"))
+		}, "", ErrPathNull},
+		{"through null", func() (any, error) {
+			return resultValue(ExecuteIntoPath[string](c, ctx, req, "viewer.bio.text"))
+		}, "", ErrPathNull},
+		{"missing field", func() (any, error) {
+			return resultValue(ExecuteIntoPath[*string](c, ctx, req, "viewer.email"))
+		}, (*string)(nil), ErrPathNotFound},
+		{"index out of range", func() (any, error) {
+			return resultValue(ExecuteIntoPath[repo](c, ctx, req, "viewer.repositories.nodes[2]"))
+		}, repo{}, ErrPathNotFound},
+		{"field of a list", func() (any, error) {
+			return resultValue(ExecuteIntoPath[string](c, ctx, req, "viewer.repositories.nodes.name"))
+		}, "", ErrPathNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.run()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func resultValue[T any](r result.Result[T]) (T, error) {
+	if r.IsErr() {
+		var zero T
+		return zero, r.Error()
+	}
+	return r.Unwrap(), nil
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"a", []string{"a"}, false},
+		{"a.b[0].c", []string{"a", "b", "0", "c"}, false},
+		{"a[1][2]", []string{"a", "1", "2"}, false},
+		{"a.0", []string{"a", "0"}, false},
+		{"a..b", nil, true},
+		{"a[x]", nil, true},
+		{"a[1", nil, true},
+		{"a[1]b", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := splitPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// ErrNotPersisted is the cause of the error for a request whose query is
+// not in Config.PersistedManifest.
+var ErrNotPersisted = errors.New("operation is not in the persisted manifest")
+
+// wire returns req as it is sent: with a PersistedManifest, without its
+// query but with the persistedQuery extension carrying its hash.
+func (c *Client) wire(req *Request) *Request {
+	hash, ok := c.persisted[req.Query]
+	if !ok {
+		return req
+	}
+	sent := *req
+	sent.Query = ""
+	sent.Extensions = maps.Clone(req.Extensions)
+	if sent.Extensions == nil {
+		sent.Extensions = make(map[string]any)
+	}
+	sent.Extensions["persistedQuery"] = map[string]any{"version": 1, "sha256Hash": hash}
+	return &sent
+}
+
+// operationLabel names the operation of req in errors.
+func operationLabel(req *Request) string {
+	if name := operationName(req); name != "" {
+		return name
+	}
+	return "anonymous operation"
+}
+
+// operationName returns the name of the operation req selects, or "".
+func operationName(req *Request) string {
+	if req.OperationName != "" {
+		return req.OperationName
+	}
+	if doc, err := language.Parse(req.Query); err == nil {
+		if op := doc.Operation(""); op != nil {
+			return op.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/gen"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/manifest"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+)
+
+const persistedSchema = `type Query { echo(s: String!): String! }`
+
+// persistedManifest returns the manifest bgql-gen writes for an Echo
+// query, read back from its file format.
+func persistedManifest(t *testing.T) *manifest.Manifest {
+	t.Helper()
+	s, err := schema.Parse(persistedSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := gen.Manifest(gen.Config{Package: "api", Schema: s}, gen.Source{Name: "echo.graphql", Body: `query Echo($s: String!) { echo(s: $s) }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalIndent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err = manifest.Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestPersistedManifest(t *testing.T) {
+	m := persistedManifest(t)
+	echo := m.Operations["Echo"].Document
+
+	config := server.DefaultConfig()
+	config.RejectUnknownOperations = true
+	s := server.NewBuilder().
+		Config(config).
+		Schema(persistedSchema).
+		Resolver("Query", "echo", func(ctx *server.Context, p any, args map[string]any) (any, error) { return args["s"], nil }).
+		AllowedManifest(m).
+		Build().Unwrap()
+	handler := s.Handler()
+	var mu sync.Mutex
+	var sent []string
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		sent = append(sent, r.URL.RawQuery+string(body))
+		mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		name     string
+		batch    bool
+		query    string
+		wantErr  error
+		wantSent int
+	}{
+		{"post", false, echo, nil, 1},
+		{"batch", true, echo, nil, 1},
+		{"not in manifest", false, `query Other { echo(s: "x") }`, ErrNotPersisted, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			sent = nil
+			mu.Unlock()
+			cfg := DefaultConfig(hs.URL + "/graphql")
+			cfg.PersistedManifest = m
+			cfg.MinifyQueries = true
+			c := NewWithConfig(cfg)
+			if tt.batch {
+				c.WithBatching()
+			}
+
+			var wg sync.WaitGroup
+			results := make([]*Response, 2)
+			errs := make([]error, 2)
+			for i := range results {
+				if !tt.batch && i > 0 {
+					break
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res := c.Execute(context.Background(), &Request{Query: tt.query, Variables: map[string]any{"s": "hi"}})
+					if res.IsErr() {
+						errs[i] = res.Error()
+						return
+					}
+					results[i] = res.Unwrap()
+				}()
+			}
+			wg.Wait()
+
+			if !errors.Is(errs[0], tt.wantErr) {
+				t.Fatalf("err = %v, want %v", errs[0], tt.wantErr)
+			}
+			if tt.wantErr == nil && string(results[0].Data) != `{"echo":"hi"}` {
+				t.Errorf("data = %s", results[0].Data)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(sent) != tt.wantSent {
+				t.Fatalf("sent %d requests, want %d", len(sent), tt.wantSent)
+			}
+			for _, body := range sent {
+				if strings.Contains(body, "echo") || !strings.Contains(body, m.Operations["Echo"].Hash) {
+					t.Errorf("request does not send only the hash: %s", body)
+				}
+			}
+		})
+	}
+}
+
+func TestPersistedManifestGET(t *testing.T) {
+	m := persistedManifest(t)
+	config := DefaultConfig("http://example.com/graphql")
+	config.PersistedManifest = m
+	c := NewWithConfig(config)
+
+	op := m.Operations["Echo"]
+	u := c.getURL(&Request{Query: op.Document, Variables: map[string]any{"s": "hi"}})
+	if strings.Contains(u, "query=") || !strings.Contains(u, op.Hash) || !strings.Contains(u, "extensions=") {
+		t.Errorf("GET URL does not send only the hash: %s", u)
+	}
+}
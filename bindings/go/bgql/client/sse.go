@@ -0,0 +1,270 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseEvent is an event of a text/event-stream.
+type sseEvent struct {
+	typ  string
+	data string
+	id   string
+	// retry is the reconnection time the server asked for, if any.
+	retry time.Duration
+}
+
+// sseReader reads the events of a text/event-stream.
+type sseReader struct {
+	br *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{br: bufio.NewReader(r)}
+}
+
+// next returns the next event. It returns io.EOF when the stream ends
+// between events, and io.ErrUnexpectedEOF within one.
+func (r *sseReader) next() (sseEvent, error) {
+	var ev sseEvent
+	var data []string
+	empty := true
+	for {
+		line, err := r.br.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && (!empty || line != "") {
+				err = io.ErrUnexpectedEOF
+			}
+			return sseEvent{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if !empty {
+				ev.data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		empty = false
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.typ = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			ev.id = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				ev.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// isEventStream reports whether a Content-Type is text/event-stream.
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// =============================================================================
+// Subscriptions over SSE
+// =============================================================================
+
+// SubscribeSSE is Subscribe over Server-Sent Events, in the distinct
+// connections mode of the graphql-sse protocol, for servers that do not
+// take subscriptions over WebSocket. Each subscription is a request
+// whose response streams next events until a complete event.
+//
+// When the stream drops, it is requested again with the Last-Event-ID of
+// the last event that had an id, backing off and giving up as configured
+// by MaxReconnectAttempts. Events are not queued: the server's stream is
+// read as they are received.
+func (c *Client) SubscribeSSE(ctx context.Context, query string, variables map[string]any) (<-chan *Response, func(), error) {
+	if err := c.validate(query); err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(&Request{Query: query, Variables: variables})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.openEventStream(ctx, body, "")
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	sub := &sseSubscription{client: c, ctx: ctx, cancel: cancel, body: body, events: make(chan *Response)}
+	go sub.run(stream)
+	return sub.events, cancel, nil
+}
+
+// openEventStream posts a subscription for a text/event-stream response,
+// resuming after lastID if it is set, and returns the response body.
+func (c *Client) openEventStream(ctx context.Context, body []byte, lastID string) (io.ReadCloser, error) {
+	return authorized(c, ctx, true, func(ctx context.Context) (io.ReadCloser, error) {
+		return c.eventStreamRoundTrip(ctx, body, lastID)
+	}, func(_ io.ReadCloser, err error) bool { return unauthenticated(nil, err) })
+}
+
+func (c *Client) eventStreamRoundTrip(ctx context.Context, body []byte, lastID string) (io.ReadCloser, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	c.setClientHeaders(httpReq.Header)
+	c.setRequestHeaders(ctx, httpReq.Header)
+	if lastID != "" {
+		httpReq.Header.Set("Last-Event-ID", lastID)
+	}
+
+	// The stream outlives Config.Timeout; ctx ends it.
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	httpResp, err := send(&httpClient, httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if httpResp.StatusCode == http.StatusOK && isEventStream(httpResp.Header.Get("Content-Type")) {
+		return httpResp.Body, nil
+	}
+
+	defer httpResp.Body.Close()
+	respBody, _ := io.ReadAll(httpResp.Body)
+	return nil, newHTTPError(httpResp, respBody)
+}
+
+// sseSubscription is a subscription over SSE.
+type sseSubscription struct {
+	client *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	body   []byte
+	events chan *Response
+	// lastID and retry are the last event id and reconnection time the
+	// server sent.
+	lastID string
+	retry  time.Duration
+	// failures counts the attempts to resume since the last event, and
+	// backoff paces them.
+	failures int
+	backoff  *backoff
+}
+
+// run sends the events of the stream, and of the streams resuming it,
+// until the subscription completes, is cancelled, or cannot resume.
+func (s *sseSubscription) run(stream io.ReadCloser) {
+	defer s.cancel()
+	defer close(s.events)
+	for stream != nil {
+		err := s.read(stream)
+		stream.Close()
+		if err == nil || s.ctx.Err() != nil {
+			return
+		}
+		stream = s.resume(err)
+	}
+}
+
+// resume requests the stream again after it failed with err, waiting
+// with backoff between attempts. Streams that end before an event count
+// as failed attempts. When it gives up, it sends the error and returns
+// nil.
+func (s *sseSubscription) resume(err error) io.ReadCloser {
+	c := s.client
+	attempts := c.config.MaxReconnectAttempts
+	if s.backoff == nil {
+		s.backoff = c.newBackoff()
+		if s.retry > 0 {
+			s.backoff.next = s.retry
+		}
+	}
+	for s.failures++; attempts == 0 || s.failures <= attempts; s.failures++ {
+		select {
+		case <-time.After(s.backoff.wait()):
+		case <-s.ctx.Done():
+			return nil
+		}
+		stream, openErr := c.openEventStream(s.ctx, s.body, s.lastID)
+		if openErr == nil {
+			return stream
+		}
+		if s.ctx.Err() != nil {
+			return nil
+		}
+		err = openErr
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && !httpErr.Retryable() {
+			if httpErr.Response != nil {
+				s.send(httpErr.Response)
+				return nil
+			}
+			break
+		}
+	}
+	s.send(&Response{Errors: []GraphQLError{{Message: "subscription connection lost: " + err.Error()}}})
+	return nil
+}
+
+// read sends the events of stream until a complete event, when it
+// returns nil, or until the stream fails.
+func (s *sseSubscription) read(stream io.Reader) error {
+	r := newSSEReader(stream)
+	for {
+		ev, err := r.next()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if ev.id != "" {
+			s.lastID = ev.id
+		}
+		if ev.retry > 0 {
+			s.retry = ev.retry
+		}
+		switch ev.typ {
+		case "next":
+			s.failures, s.backoff = 0, nil
+			var resp Response
+			if err := json.Unmarshal([]byte(ev.data), &resp); err != nil {
+				return fmt.Errorf("failed to unmarshal event: %w", err)
+			}
+			if !s.send(&resp) {
+				return nil
+			}
+		case "complete":
+			return nil
+		}
+	}
+}
+
+// send sends resp to the subscriber, reporting false if the subscription
+// was cancelled instead.
+func (s *sseSubscription) send(resp *Response) bool {
+	select {
+	case s.events <- resp:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
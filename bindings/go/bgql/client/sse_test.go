@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSSEReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []sseEvent
+		wantErr error
+	}{
+		{"events", "event: next\ndata: {\"a\":1}\n\nevent: complete\ndata:\n\n", []sseEvent{{typ: "next", data: `{"a":1}`}, {typ: "complete"}}, io.EOF},
+		{"multiline data", "data: a\ndata: b\n\n", []sseEvent{{data: "a\nb"}}, io.EOF},
+		{"comments and CRLF", ":\r\n\r\nid: 7\r\nretry: 250\r\nevent: next\r\ndata: x\r\n\r\n", []sseEvent{{typ: "next", data: "x", id: "7", retry: 250 * time.Millisecond}}, io.EOF},
+		{"no space after colon", "event:next\ndata:x\n\n", []sseEvent{{typ: "next", data: "x"}}, io.EOF},
+		{"cut short", "event: next\ndata: x\n", nil, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newSSEReader(strings.NewReader(tt.stream))
+			var got []sseEvent
+			var err error
+			for {
+				var ev sseEvent
+				if ev, err = r.next(); err != nil {
+					break
+				}
+				got = append(got, ev)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+			if err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscribeSSE(t *testing.T) {
+	var conns atomic.Int32
+	var token atomic.Value
+	hs := newTickServer(t, &conns, &token)
+	c := New(hs.URL + "/graphql")
+
+	events, _, err := c.SubscribeSSE(context.Background(), "subscription($n: Int!) { tick(n: $n) }", map[string]any{"n": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(drain(events), " "); got != `{"tick":0} {"tick":1} {"tick":2}` {
+		t.Errorf("events = %s", got)
+	}
+
+	_, _, err = c.SubscribeSSE(context.Background(), "subscription { nope }", nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Response == nil || len(httpErr.Response.Errors) == 0 {
+		t.Errorf("invalid subscription: err = %v, want an HTTPError with GraphQL errors", err)
+	}
+	if conns.Load() != 0 {
+		t.Error("SSE subscription opened a WebSocket connection")
+	}
+}
+
+func TestSubscribeSSEUnsubscribe(t *testing.T) {
+	var conns atomic.Int32
+	var token atomic.Value
+	hs := newTickServer(t, &conns, &token)
+	c := New(hs.URL + "/graphql")
+
+	events, unsubscribe, err := c.SubscribeSSE(context.Background(), "subscription { tick(n: -1) }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := <-events; string(resp.Data) != `{"tick":0}` {
+		t.Fatalf("first event = %s", resp.Data)
+	}
+	unsubscribe()
+	unsubscribe()
+	done := make(chan struct{})
+	go func() {
+		drain(events)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("events not closed after unsubscribe")
+	}
+}
+
+// resumingHandler streams tick events with ids, dropping the stream
+// after each of drops, and resumes after the Last-Event-ID it is sent.
+func resumingHandler(drops []int, last int, lastIDs *[]string) http.HandlerFunc {
+	var requests atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1)) - 1
+		*lastIDs = append(*lastIDs, r.Header.Get("Last-Event-ID"))
+		start := 0
+		fmt.Sscan(r.Header.Get("Last-Event-ID"), &start)
+		if r.Header.Get("Last-Event-ID") != "" {
+			start++
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		end := last
+		if n < len(drops) {
+			end = drops[n]
+		}
+		fmt.Fprint(w, "retry: 5\n\n")
+		for i := start; i <= end; i++ {
+			fmt.Fprintf(w, "id: %d\nevent: next\ndata: {\"data\":{\"tick\":%d}}\n\n", i, i)
+		}
+		if n >= len(drops) {
+			fmt.Fprint(w, "event: complete\ndata:\n\n")
+		}
+	}
+}
+
+func TestSubscribeSSEResumes(t *testing.T) {
+	tests := []struct {
+		name        string
+		attempts    int
+		drops       []int
+		want        string
+		wantLastIDs []string
+		wantLost    bool
+	}{
+		{"no drops", 0, nil, "0 1 2", []string{""}, false},
+		{"resumes", 0, []int{0, 1}, "0 1 2", []string{"", "0", "1"}, false},
+		{"gives up", 1, []int{0, 0, 0}, "0", []string{"", "0"}, true},
+		{"never resumes", -1, []int{1}, "0 1", []string{""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lastIDs []string
+			hs := httptest.NewServer(resumingHandler(tt.drops, 2, &lastIDs))
+			defer hs.Close()
+			config := DefaultConfig(hs.URL)
+			config.ReconnectInterval = time.Hour // the server's retry wins
+			config.MaxReconnectAttempts = tt.attempts
+			c := NewWithConfig(config)
+
+			events, _, err := c.SubscribeSSE(context.Background(), "subscription { tick }", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ticks []string
+			lost := false
+			for resp := range events {
+				if len(resp.Errors) > 0 {
+					lost = strings.Contains(resp.Errors[0].Message, "connection lost")
+					continue
+				}
+				ticks = append(ticks, strings.TrimSuffix(strings.TrimPrefix(string(resp.Data), `{"tick":`), "}"))
+			}
+			if got := strings.Join(ticks, " "); got != tt.want {
+				t.Errorf("ticks = %s, want %s", got, tt.want)
+			}
+			if fmt.Sprint(lastIDs) != fmt.Sprint(tt.wantLastIDs) {
+				t.Errorf("Last-Event-IDs = %q, want %q", lastIDs, tt.wantLastIDs)
+			}
+			if lost != tt.wantLost {
+				t.Errorf("connection lost = %v, want %v", lost, tt.wantLost)
+			}
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// wsSubprotocol is the WebSocket subprotocol spoken for subscriptions.
+const wsSubprotocol = "graphql-transport-ws"
+
+// wsMessage is a graphql-transport-ws protocol message.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ConnState is the state of the WebSocket connection of subscriptions.
+type ConnState int
+
+const (
+	// ConnConnected is reported once the server acknowledges a
+	// connection, including after reconnecting.
+	ConnConnected ConnState = iota
+	// ConnReconnecting is reported when the connection drops with
+	// subscriptions open, and after each failed attempt to reopen it.
+	ConnReconnecting
+	// ConnDisconnected is reported when the connection is closed for good:
+	// with nil after its last subscription ends, or with the error that
+	// made reconnecting give up.
+	ConnDisconnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnConnected:
+		return "connected"
+	case ConnReconnecting:
+		return "reconnecting"
+	case ConnDisconnected:
+		return "disconnected"
+	}
+	return "ConnState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Subscribe starts a subscription over the client's WebSocket connection,
+// opening it with a connection_init carrying Config.InitPayload if none is
+// open. Concurrent subscriptions share the connection.
+//
+// Each event is sent on the returned channel, which is closed when the
+// server completes the subscription or, after a Response holding its
+// errors, when the subscription fails. The returned function unsubscribes,
+// as does cancelling ctx; the connection is closed with its last
+// subscription. Events wait in memory until they are received.
+//
+// When the connection drops, it is reopened with backoff as configured by
+// MaxReconnectAttempts, and the open subscriptions are started again;
+// subscriptions only fail with the connection once reconnecting gives up.
+func (c *Client) Subscribe(ctx context.Context, query string, variables map[string]any) (<-chan *Response, func(), error) {
+	return c.SubscribeRequest(ctx, &Request{Query: query, Variables: variables})
+}
+
+// SubscribeRequest is Subscribe for a request with an operation name.
+func (c *Client) SubscribeRequest(ctx context.Context, req *Request) (<-chan *Response, func(), error) {
+	if err := c.validate(req.Query); err != nil {
+		return nil, nil, err
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	c.wsMu.Lock()
+	session := c.ws
+	if session == nil || session.closed {
+		session, err = c.connectWebSocket(ctx)
+		if err != nil {
+			c.wsMu.Unlock()
+			return nil, nil, err
+		}
+		c.ws = session
+	}
+	session.nextID++
+	sub := newWSSubscription(strconv.Itoa(session.nextID), session, payload)
+	session.subs[sub.id] = sub
+	// While reconnecting, the subscription starts with the others.
+	conn := session.conn
+	c.wsMu.Unlock()
+
+	if conn != nil {
+		if err := conn.writeJSON(sub.message()); err != nil {
+			sub.unsubscribe()
+			return nil, nil, fmt.Errorf("failed to subscribe: %w", err)
+		}
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.unsubscribe()
+		case <-sub.exited:
+		}
+	}()
+	return sub.events, sub.unsubscribe, nil
+}
+
+// connectWebSocket opens a WebSocket connection for a new session. c.wsMu
+// must be held.
+func (c *Client) connectWebSocket(ctx context.Context) (*wsSession, error) {
+	conn, err := c.dialWebSocket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session := &wsSession{
+		client: c,
+		conn:   conn,
+		subs:   make(map[string]*wsSubscription),
+		done:   make(chan struct{}),
+	}
+	go session.run()
+	return session, nil
+}
+
+// dialWebSocket opens a WebSocket connection and waits for the server to
+// acknowledge its connection_init.
+func (c *Client) dialWebSocket(ctx context.Context) (*wsConn, error) {
+	rawURL, err := webSocketURL(c.config)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	c.setClientHeaders(header)
+	c.setConfigHeaders(header)
+
+	dialCtx := ctx
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+	conn, err := dialWebSocket(dialCtx, rawURL, header, wsSubprotocol)
+	if err != nil {
+		return nil, fmt.Errorf("websocket connection failed: %w", err)
+	}
+
+	init := wsMessage{Type: "connection_init"}
+	if c.config.InitPayload != nil {
+		if init.Payload, err = json.Marshal(c.config.InitPayload); err != nil {
+			conn.close(1000, "")
+			return nil, fmt.Errorf("failed to marshal init payload: %w", err)
+		}
+	}
+	if deadline, ok := dialCtx.Deadline(); ok {
+		conn.conn.SetDeadline(deadline)
+	}
+	err = conn.writeJSON(init)
+	for err == nil {
+		var data []byte
+		if data, err = conn.readMessage(); err != nil {
+			break
+		}
+		var msg wsMessage
+		if err = json.Unmarshal(data, &msg); err != nil {
+			break
+		}
+		if msg.Type == "connection_ack" {
+			break
+		}
+		if msg.Type == "ping" {
+			err = conn.writeJSON(wsMessage{Type: "pong"})
+		}
+	}
+	if err != nil {
+		conn.close(1000, "")
+		return nil, fmt.Errorf("websocket connection failed: %w", err)
+	}
+	conn.conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// setConnState reports a connection state change to the config's
+// callback.
+func (c *Client) setConnState(state ConnState, err error) {
+	if fn := c.config.OnConnectionStateChange; fn != nil {
+		fn(state, err)
+	}
+}
+
+// =============================================================================
+// Sessions
+// =============================================================================
+
+// wsSession is the WebSocket connection of subscriptions, reopened when it
+// drops, and its subscriptions. conn, which is nil while reconnecting,
+// subs, and closed are guarded by the client's wsMu.
+type wsSession struct {
+	client *Client
+	conn   *wsConn
+	subs   map[string]*wsSubscription
+	nextID int
+	closed bool
+	// done is closed with the session.
+	done chan struct{}
+}
+
+// run serves the session's connections until it closes or reconnecting
+// gives up, then fails the subscriptions still open. It reports every
+// state change, so they are reported in order.
+func (s *wsSession) run() {
+	c := s.client
+	c.setConnState(ConnConnected, nil)
+	conn := s.conn
+	var err error
+	for conn != nil {
+		conn, err = s.reconnect(s.read(conn))
+	}
+
+	c.wsMu.Lock()
+	clean := s.closed
+	s.close()
+	subs := s.subs
+	s.subs = make(map[string]*wsSubscription)
+	c.wsMu.Unlock()
+
+	for _, sub := range subs {
+		sub.push(&Response{Errors: []GraphQLError{{Message: "subscription connection lost: " + err.Error()}}})
+		sub.end()
+	}
+	if clean {
+		err = nil
+	}
+	c.setConnState(ConnDisconnected, err)
+}
+
+// read dispatches the messages of conn to their subscriptions until the
+// connection ends, and returns why it did.
+func (s *wsSession) read(conn *wsConn) error {
+	for {
+		data, err := conn.readMessage()
+		if err != nil {
+			return err
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			conn.close(4400, "Invalid message received")
+			return err
+		}
+
+		switch msg.Type {
+		case "ping":
+			conn.writeJSON(wsMessage{Type: "pong"})
+		case "next":
+			var resp Response
+			if json.Unmarshal(msg.Payload, &resp) == nil {
+				if sub := s.lookup(msg.ID); sub != nil {
+					sub.push(&resp)
+				}
+			}
+		case "error":
+			var errs []GraphQLError
+			json.Unmarshal(msg.Payload, &errs)
+			if sub, _, last := s.remove(msg.ID); sub != nil {
+				sub.push(&Response{Errors: errs})
+				sub.end()
+				if last {
+					conn.close(1000, "")
+				}
+			}
+		case "complete":
+			if sub, _, last := s.remove(msg.ID); sub != nil {
+				sub.end()
+				if last {
+					conn.close(1000, "")
+				}
+			}
+		}
+	}
+}
+
+// reconnect reopens the connection after it failed with err, waiting
+// with backoff between attempts, and starts the open subscriptions over
+// it. It returns a nil connection and the last error if the session
+// closed meanwhile or the attempts ran out.
+func (s *wsSession) reconnect(err error) (*wsConn, error) {
+	c := s.client
+	attempts := c.config.MaxReconnectAttempts
+	c.wsMu.Lock()
+	if s.closed || len(s.subs) == 0 || attempts < 0 || refused(err) {
+		c.wsMu.Unlock()
+		return nil, err
+	}
+	s.conn = nil
+	c.wsMu.Unlock()
+
+	backoff := c.newBackoff()
+	for attempt := 1; attempts == 0 || attempt <= attempts; attempt++ {
+		c.setConnState(ConnReconnecting, err)
+		select {
+		case <-time.After(backoff.wait()):
+		case <-s.done:
+			return nil, err
+		}
+
+		conn, dialErr := c.dialWebSocket(context.Background())
+		if dialErr != nil {
+			if err = dialErr; refused(err) {
+				return nil, err
+			}
+			continue
+		}
+		// Subscribing under the lock orders it before any unsubscribe,
+		// and skips subscriptions cancelled during the outage.
+		c.wsMu.Lock()
+		if s.closed {
+			c.wsMu.Unlock()
+			conn.close(1000, "")
+			return nil, err
+		}
+		s.conn = conn
+		for _, sub := range s.subs {
+			conn.writeJSON(sub.message())
+		}
+		c.wsMu.Unlock()
+		c.setConnState(ConnConnected, nil)
+		return conn, nil
+	}
+	return nil, err
+}
+
+// backoff yields the waits between reconnection attempts.
+type backoff struct {
+	next, max time.Duration
+}
+
+// newBackoff starts the waits configured by ReconnectInterval and
+// MaxReconnectInterval.
+func (c *Client) newBackoff() *backoff {
+	b := &backoff{next: c.config.ReconnectInterval, max: c.config.MaxReconnectInterval}
+	if b.next <= 0 {
+		b.next = time.Second
+	}
+	if b.max <= 0 {
+		b.max = 30 * time.Second
+	}
+	return b
+}
+
+// wait returns the next wait and doubles the one after it. Jitter spreads
+// out clients reconnecting to a restarted server.
+func (b *backoff) wait() time.Duration {
+	d := b.next/2 + rand.N(b.next/2+1)
+	b.next = min(b.next*2, b.max)
+	return d
+}
+
+// refused reports whether err is the server closing the connection with
+// a 4400-4499 code of the protocol, as when it rejects connection_init,
+// which reconnecting would not change.
+func refused(err error) bool {
+	var closeErr *wsCloseError
+	return errors.As(err, &closeErr) && closeErr.code >= 4400 && closeErr.code < 4500
+}
+
+func (s *wsSession) lookup(id string) *wsSubscription {
+	s.client.wsMu.Lock()
+	defer s.client.wsMu.Unlock()
+	return s.subs[id]
+}
+
+// remove forgets subscription id and returns it if it was open, the
+// current connection, and whether it was the last, in which case the
+// session is closed and the caller closes the connection.
+func (s *wsSession) remove(id string) (sub *wsSubscription, conn *wsConn, last bool) {
+	c := s.client
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	sub = s.subs[id]
+	delete(s.subs, id)
+	last = sub != nil && len(s.subs) == 0 && !s.closed
+	if last {
+		s.close()
+	}
+	return sub, s.conn, last
+}
+
+// close marks the session closed, so no new subscription uses it.
+// c.wsMu must be held.
+func (s *wsSession) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	if s.client.ws == s {
+		s.client.ws = nil
+	}
+}
+
+// =============================================================================
+// Subscriptions
+// =============================================================================
+
+// wsSubscription is one subscription of a session. Its events are queued
+// and sent on events by its own goroutine, so a subscriber that does not
+// read holds up no other subscription.
+type wsSubscription struct {
+	id      string
+	session *wsSession
+	payload json.RawMessage
+	events  chan *Response
+	// stopped is closed on unsubscribe, dropping queued events, and exited
+	// once events is closed.
+	stopped chan struct{}
+	exited  chan struct{}
+	wake    chan struct{}
+
+	mu    sync.Mutex
+	queue []*Response
+	ended bool
+	once  sync.Once
+}
+
+func newWSSubscription(id string, session *wsSession, payload json.RawMessage) *wsSubscription {
+	sub := &wsSubscription{
+		id:      id,
+		session: session,
+		payload: payload,
+		events:  make(chan *Response),
+		stopped: make(chan struct{}),
+		exited:  make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+	go sub.pump()
+	return sub
+}
+
+// message is the subscribe message that starts the subscription.
+func (s *wsSubscription) message() wsMessage {
+	return wsMessage{ID: s.id, Type: "subscribe", Payload: s.payload}
+}
+
+// push queues resp for the subscriber.
+func (s *wsSubscription) push(resp *Response) {
+	s.mu.Lock()
+	if !s.ended {
+		s.queue = append(s.queue, resp)
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// end closes events once the queued events are received.
+func (s *wsSubscription) end() {
+	s.mu.Lock()
+	s.ended = true
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *wsSubscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends the queued events until the subscription ends or stops.
+func (s *wsSubscription) pump() {
+	defer close(s.exited)
+	defer close(s.events)
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			resp := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			select {
+			case s.events <- resp:
+			case <-s.stopped:
+				return
+			}
+			continue
+		}
+		ended := s.ended
+		s.mu.Unlock()
+		if ended {
+			return
+		}
+		select {
+		case <-s.wake:
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+// unsubscribe tells the server to complete the subscription, unless it
+// already has, and closes events without sending what is queued.
+func (s *wsSubscription) unsubscribe() {
+	if sub, conn, last := s.session.remove(s.id); sub != nil && conn != nil {
+		conn.writeJSON(wsMessage{ID: s.id, Type: "complete"})
+		if last {
+			conn.close(1000, "")
+		}
+	}
+	s.once.Do(func() { close(s.stopped) })
+	s.end()
+}
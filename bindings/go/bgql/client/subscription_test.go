@@ -0,0 +1,499 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+)
+
+// newTickServer serves a subscription tick(n) emitting 0..n-1, or forever
+// when n is negative, and counts WebSocket connections.
+func newTickServer(t *testing.T, conns *atomic.Int32, token *atomic.Value) *httptest.Server {
+	t.Helper()
+	s := server.NewBuilder().
+		Schema(`type Query { a: Int } type Subscription { tick(n: Int!): Int }`).
+		Resolver("Query", "a", func(ctx *server.Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Subscription("tick", func(ctx *server.Context, args map[string]any) (<-chan any, error) {
+			n := args["n"].(int)
+			ch := make(chan any)
+			go func() {
+				defer close(ch)
+				for i := 0; n < 0 || i < n; i++ {
+					select {
+					case ch <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return ch, nil
+		}).
+		OnConnect(func(ctx *server.Context, payload map[string]any) error {
+			conns.Add(1)
+			if v, ok := payload["token"].(string); ok {
+				token.Store(v)
+			}
+			return nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(s.Handler())
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func drain(ch <-chan *Response) []string {
+	var out []string
+	for resp := range ch {
+		out = append(out, string(resp.Data))
+	}
+	return out
+}
+
+func TestSubscribeMultiplexesOneConnection(t *testing.T) {
+	var conns atomic.Int32
+	var token atomic.Value
+	hs := newTickServer(t, &conns, &token)
+
+	config := DefaultConfig(hs.URL + "/graphql")
+	config.InitPayload = map[string]any{"token": "secret"}
+	c := NewWithConfig(config)
+	ctx := context.Background()
+
+	// An unread subscription must not hold up the other one.
+	endless, unsubscribe, err := c.Subscribe(ctx, "subscription { tick(n: -1) }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	three, _, err := c.Subscribe(ctx, "subscription($n: Int!) { tick(n: $n) }", map[string]any{"n": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := drain(three)
+	want := []string{`{"tick":0}`, `{"tick":1}`, `{"tick":2}`}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if resp := <-endless; string(resp.Data) != `{"tick":0}` {
+		t.Fatalf("first event = %s", resp.Data)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	drain(endless)
+
+	if n := conns.Load(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+	if v := token.Load(); v != "secret" {
+		t.Errorf("init payload token = %v, want secret", v)
+	}
+	waitTornDown(t, c)
+}
+
+func TestSubscribeErrorClosesChannel(t *testing.T) {
+	var conns atomic.Int32
+	var token atomic.Value
+	hs := newTickServer(t, &conns, &token)
+	c := New(hs.URL + "/graphql")
+
+	events, _, err := c.Subscribe(context.Background(), "subscription { nope }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := <-events
+	if !ok || len(resp.Errors) == 0 {
+		t.Fatalf("got %+v, %v; want a response with errors", resp, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("channel still open after error")
+	}
+	waitTornDown(t, c)
+}
+
+func TestSubscribeContextCancelUnsubscribes(t *testing.T) {
+	var conns atomic.Int32
+	var token atomic.Value
+	hs := newTickServer(t, &conns, &token)
+	c := New(hs.URL + "/graphql")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, _, err := c.Subscribe(ctx, "subscription { tick(n: -1) }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-events
+	cancel()
+	drain(events)
+	waitTornDown(t, c)
+}
+
+// waitTornDown waits for the client to close its WebSocket connection.
+func waitTornDown(t *testing.T, c *Client) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.wsMu.Lock()
+		ws := c.ws
+		c.wsMu.Unlock()
+		if ws == nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("connection not closed after the last subscription")
+}
+
+// flakyProxy forwards TCP connections to a server, so tests can drop
+// them and refuse new ones as a failing network would.
+type flakyProxy struct {
+	ln     net.Listener
+	target string
+
+	mu    sync.Mutex
+	conns []net.Conn
+	down  bool
+}
+
+func newFlakyProxy(t *testing.T, hs *httptest.Server) *flakyProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &flakyProxy{ln: ln, target: hs.Listener.Addr().String()}
+	t.Cleanup(func() {
+		ln.Close()
+		p.drop(true)
+	})
+	go p.serve()
+	return p
+}
+
+func (p *flakyProxy) url() string { return "http://" + p.ln.Addr().String() + "/graphql" }
+
+func (p *flakyProxy) serve() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		down := p.down
+		p.mu.Unlock()
+		if down {
+			conn.Close()
+			continue
+		}
+		upstream, err := net.Dial("tcp", p.target)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, conn, upstream)
+		p.mu.Unlock()
+		go func() { io.Copy(upstream, conn); upstream.Close() }()
+		go func() { io.Copy(conn, upstream); conn.Close() }()
+	}
+}
+
+// drop closes the open connections and, if down, refuses new ones until
+// up is called.
+func (p *flakyProxy) drop(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+	p.down = down
+}
+
+func (p *flakyProxy) up() {
+	p.mu.Lock()
+	p.down = false
+	p.mu.Unlock()
+}
+
+// newSlowTickServer serves a subscription tick emitting 0, 1, 2, ... every
+// few milliseconds, and counts connections and started subscriptions.
+func newSlowTickServer(t *testing.T, conns, starts *atomic.Int32) *httptest.Server {
+	t.Helper()
+	s := server.NewBuilder().
+		Schema(`type Query { a: Int } type Subscription { tick: Int }`).
+		Resolver("Query", "a", func(ctx *server.Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Subscription("tick", func(ctx *server.Context, args map[string]any) (<-chan any, error) {
+			starts.Add(1)
+			ch := make(chan any)
+			go func() {
+				defer close(ch)
+				for i := 0; ; i++ {
+					select {
+					case ch <- i:
+					case <-ctx.Done():
+						return
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}()
+			return ch, nil
+		}).
+		OnConnect(func(ctx *server.Context, payload map[string]any) error {
+			conns.Add(1)
+			return nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(s.Handler())
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+// stateRecorder records connection state changes.
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []string
+	errs   []error
+	wake   chan struct{}
+}
+
+func newStateRecorder() *stateRecorder {
+	return &stateRecorder{wake: make(chan struct{}, 100)}
+}
+
+func (r *stateRecorder) record(state ConnState, err error) {
+	r.mu.Lock()
+	r.states = append(r.states, state.String())
+	r.errs = append(r.errs, err)
+	r.mu.Unlock()
+	r.wake <- struct{}{}
+}
+
+// waitFor waits until the last recorded state is state and returns all
+// the states.
+func (r *stateRecorder) waitFor(t *testing.T, state ConnState) []string {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		r.mu.Lock()
+		states := append([]string(nil), r.states...)
+		r.mu.Unlock()
+		if len(states) > 0 && states[len(states)-1] == state.String() {
+			return states
+		}
+		select {
+		case <-r.wake:
+		case <-deadline:
+			t.Fatalf("states = %v, waiting for %v", states, state)
+		}
+	}
+}
+
+// nextTick waits for the subscription to emit {"tick":want}, skipping
+// other events.
+func nextTick(t *testing.T, events <-chan *Response, want string) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case resp, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed waiting for tick %s", want)
+			}
+			if len(resp.Errors) > 0 {
+				t.Fatalf("errors waiting for tick %s: %v", want, resp.Errors)
+			}
+			if string(resp.Data) == `{"tick":`+want+`}` {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("no tick %s", want)
+		}
+	}
+}
+
+func reconnectConfig(url string, states *stateRecorder) Config {
+	config := DefaultConfig(url)
+	config.ReconnectInterval = 5 * time.Millisecond
+	config.MaxReconnectInterval = 20 * time.Millisecond
+	config.OnConnectionStateChange = states.record
+	return config
+}
+
+func TestSubscribeReconnects(t *testing.T) {
+	var conns, starts atomic.Int32
+	proxy := newFlakyProxy(t, newSlowTickServer(t, &conns, &starts))
+	states := newStateRecorder()
+	c := NewWithConfig(reconnectConfig(proxy.url(), states))
+
+	events, unsubscribe, err := c.Subscribe(context.Background(), "subscription { tick }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextTick(t, events, "1")
+
+	// The subscription starts over on the new connection, counting from 0.
+	proxy.drop(true)
+	states.waitFor(t, ConnReconnecting)
+	time.Sleep(30 * time.Millisecond)
+	proxy.up()
+	nextTick(t, events, "0")
+	nextTick(t, events, "1")
+
+	unsubscribe()
+	got := states.waitFor(t, ConnDisconnected)
+	if got[0] != "connected" || got[len(got)-2] != "connected" || !strings.Contains(strings.Join(got, " "), "reconnecting") {
+		t.Errorf("states = %v, want connected, reconnecting..., connected, disconnected", got)
+	}
+	if err := states.errs[len(states.errs)-1]; err != nil {
+		t.Errorf("disconnected with %v after the last unsubscribe, want nil", err)
+	}
+	if n := conns.Load(); n != 2 {
+		t.Errorf("connection_inits = %d, want 2", n)
+	}
+	if n := starts.Load(); n != 2 {
+		t.Errorf("subscription starts = %d, want 2", n)
+	}
+	waitTornDown(t, c)
+}
+
+func TestSubscribeReconnectGivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     []string
+	}{
+		{"attempts run out", 2, []string{"connected", "reconnecting", "reconnecting", "disconnected"}},
+		{"never reconnects", -1, []string{"connected", "disconnected"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conns, starts atomic.Int32
+			proxy := newFlakyProxy(t, newSlowTickServer(t, &conns, &starts))
+			states := newStateRecorder()
+			config := reconnectConfig(proxy.url(), states)
+			config.MaxReconnectAttempts = tt.attempts
+			c := NewWithConfig(config)
+
+			events, _, err := c.Subscribe(context.Background(), "subscription { tick }", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nextTick(t, events, "0")
+			proxy.drop(true)
+
+			var last *Response
+			for resp := range events {
+				last = resp
+			}
+			if last == nil || len(last.Errors) == 0 || !strings.Contains(last.Errors[0].Message, "connection lost") {
+				t.Fatalf("last event = %+v, want a connection lost error", last)
+			}
+			got := states.waitFor(t, ConnDisconnected)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("states = %v, want %v", got, tt.want)
+			}
+			if states.errs[len(states.errs)-1] == nil {
+				t.Error("disconnected without the error")
+			}
+			waitTornDown(t, c)
+		})
+	}
+}
+
+func TestSubscribeCancelledDuringOutage(t *testing.T) {
+	var conns, starts atomic.Int32
+	proxy := newFlakyProxy(t, newSlowTickServer(t, &conns, &starts))
+	states := newStateRecorder()
+	c := NewWithConfig(reconnectConfig(proxy.url(), states))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, _, err := c.Subscribe(ctx, "subscription { tick }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, unsubscribe, err := c.Subscribe(context.Background(), "subscription { tick }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextTick(t, cancelled, "0")
+	nextTick(t, kept, "0")
+
+	proxy.drop(true)
+	states.waitFor(t, ConnReconnecting)
+	cancel()
+	for range cancelled {
+	}
+	// A subscription started during the outage waits for the connection.
+	late, _, err := c.Subscribe(context.Background(), "subscription { tick }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.up()
+
+	nextTick(t, kept, "1")
+	nextTick(t, late, "1")
+	time.Sleep(50 * time.Millisecond)
+	if n := starts.Load(); n != 4 {
+		t.Errorf("subscription starts = %d, want 4: two, then kept and late", n)
+	}
+	unsubscribe()
+	if n := conns.Load(); n != 2 {
+		t.Errorf("connection_inits = %d, want 2", n)
+	}
+}
+
+func TestSubscribeNoReconnectWhenRefused(t *testing.T) {
+	var rejecting atomic.Bool
+	s := server.NewBuilder().
+		Schema(`type Query { a: Int } type Subscription { tick: Int }`).
+		Resolver("Query", "a", func(ctx *server.Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Subscription("tick", func(ctx *server.Context, args map[string]any) (<-chan any, error) {
+			ch := make(chan any, 1)
+			ch <- 0
+			return ch, nil
+		}).
+		OnConnect(func(ctx *server.Context, payload map[string]any) error {
+			if rejecting.Load() {
+				return server.ErrUnauthenticated
+			}
+			return nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(s.Handler())
+	defer hs.Close()
+	proxy := newFlakyProxy(t, hs)
+	states := newStateRecorder()
+	c := NewWithConfig(reconnectConfig(proxy.url(), states))
+
+	events, _, err := c.Subscribe(context.Background(), "subscription { tick }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextTick(t, events, "0")
+	rejecting.Store(true)
+	proxy.drop(false)
+
+	for range events {
+	}
+	got := states.waitFor(t, ConnDisconnected)
+	if strings.Join(got, " ") != "connected reconnecting disconnected" {
+		t.Errorf("states = %v, want one attempt", got)
+	}
+	if err := states.errs[len(states.errs)-1]; err == nil || !strings.Contains(err.Error(), "4401") {
+		t.Errorf("disconnected with %v, want the 4401 close", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package tracing creates OpenTelemetry spans for the requests of a bgql
+// client. It is a separate module so that clients without tracing do not
+// depend on OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/client"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// instrumentationName identifies the tracer.
+const instrumentationName = "github.com/ubugeeei/bgql/bindings/go/bgql/client/tracing"
+
+// Span attributes, named as by the server's tracing package.
+const (
+	OperationNameKey = attribute.Key("graphql.operation.name")
+	OperationTypeKey = attribute.Key("graphql.operation.type")
+	DocumentHashKey  = attribute.Key("graphql.document.hash")
+)
+
+// Option configures tracing.
+type Option func(*config)
+
+type config struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the tracer provider. The global provider is used
+// by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) { c.provider = provider }
+}
+
+// WithPropagator sets how the trace context is sent to the server, such
+// as in a traceparent header. The global propagator is used by default.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *config) { c.propagator = propagator }
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{provider: otel.GetTracerProvider(), propagator: otel.GetTextMapPropagator()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Instrument installs the tracing middleware on c.
+func Instrument(c *client.Client, opts ...Option) *client.Client {
+	return c.Use(Middleware(opts...))
+}
+
+// Middleware creates a client span per request, a child of the span in
+// the caller's context, and sends its trace context with the request.
+// GraphQL errors in the response are recorded on the span. Install it
+// after middleware that retries, so each attempt has its own span.
+func Middleware(opts ...Option) client.Middleware {
+	c := newConfig(opts)
+	tracer := c.provider.Tracer(instrumentationName)
+
+	return func(ctx context.Context, req *client.Request, next func(context.Context, *client.Request) (*client.Response, error)) (*client.Response, error) {
+		name, attrs := operation(req)
+		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		header := make(http.Header)
+		c.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+		resp, err := next(client.WithHeaders(ctx, header), req)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return resp, err
+		}
+		if len(resp.Errors) > 0 {
+			for _, gqlErr := range resp.Errors {
+				span.RecordError(gqlErr)
+			}
+			span.SetStatus(codes.Error, resp.Errors[0].Message)
+		}
+		return resp, nil
+	}
+}
+
+// operation returns the span name and attributes of req. Documents that
+// do not parse are named "graphql".
+func operation(req *client.Request) (string, []attribute.KeyValue) {
+	sum := sha256.Sum256([]byte(req.Query))
+	attrs := []attribute.KeyValue{DocumentHashKey.String(hex.EncodeToString(sum[:]))}
+
+	doc, err := language.Parse(req.Query)
+	if err != nil {
+		return "graphql", attrs
+	}
+	op := doc.Operation(req.OperationName)
+	if op == nil {
+		return "graphql", attrs
+	}
+	name := string(op.Operation)
+	attrs = append(attrs, OperationTypeKey.String(name))
+	if op.Name != "" {
+		attrs = append(attrs, OperationNameKey.String(op.Name))
+		name += " " + op.Name
+	}
+	return name, attrs
+}
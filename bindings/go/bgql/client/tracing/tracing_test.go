@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/client"
+)
+
+func TestMiddleware(t *testing.T) {
+	var traceparent string
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":null,"errors":[{"message":"boom"}]}`))
+	}))
+	defer hs.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c := Instrument(client.New(hs.URL), WithTracerProvider(provider), WithPropagator(propagation.TraceContext{}))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantName string
+		wantType string
+		wantOp   string
+	}{
+		{"named query", "query GetUser { user { id } }", "query GetUser", "query", "GetUser"},
+		{"anonymous mutation", "mutation { delete }", "mutation", "mutation", ""},
+		{"invalid document", "{", "graphql", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder = tracetest.NewSpanRecorder()
+			provider.RegisterSpanProcessor(recorder)
+			defer provider.UnregisterSpanProcessor(recorder)
+
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "caller")
+			c.Execute(ctx, &client.Request{Query: tt.query})
+			parent.End()
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("got %d spans, want 2", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != tt.wantName {
+				t.Errorf("name = %q, want %q", span.Name(), tt.wantName)
+			}
+			if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("span is not a child of the caller's span")
+			}
+			want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+			if traceparent != want {
+				t.Errorf("traceparent = %q, want %q", traceparent, want)
+			}
+
+			attrs := make(map[attribute.Key]string)
+			for _, kv := range span.Attributes() {
+				attrs[kv.Key] = kv.Value.AsString()
+			}
+			if attrs[OperationTypeKey] != tt.wantType || attrs[OperationNameKey] != tt.wantOp {
+				t.Errorf("attributes = %v", attrs)
+			}
+			if len(attrs[DocumentHashKey]) != 64 {
+				t.Errorf("document hash = %q", attrs[DocumentHashKey])
+			}
+			if span.Status().Code != codes.Error || span.Status().Description != "boom" {
+				t.Errorf("status = %v", span.Status())
+			}
+			if events := span.Events(); len(events) != 1 || events[0].Name != "exception" {
+				t.Errorf("events = %v", events)
+			}
+		})
+	}
+}
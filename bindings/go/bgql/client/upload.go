@@ -0,0 +1,167 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Upload is a file sent as a variable of the Upload scalar. Requests with
+// uploads in their variables, directly or in maps and slices, are sent as
+// multipart requests following the GraphQL multipart request spec, with
+// the files streamed from their readers.
+//
+// Readers are read once and not closed, so requests with uploads are not
+// retried. Pass the same *Upload to send one file for several variables.
+type Upload struct {
+	Filename string
+	// ContentType defaults to application/octet-stream.
+	ContentType string
+	io.Reader
+}
+
+// MarshalJSON writes the null that stands for an upload in the operations
+// of a multipart request.
+func (Upload) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+var uploadType = reflect.TypeOf(Upload{})
+
+// uploadFiles are the uploads of a request: each file, and the paths in
+// the operations where it is used.
+type uploadFiles struct {
+	files []Upload
+	paths [][]string
+	// seen indexes the files passed by pointer, so they are sent once.
+	seen map[*Upload]int
+}
+
+// findUploads returns the uploads in the variables of req, or nil if it
+// has none.
+func findUploads(req *Request) *uploadFiles {
+	u := &uploadFiles{}
+	for _, name := range sortedKeys(reflect.ValueOf(req.Variables)) {
+		u.find(reflect.ValueOf(req.Variables[name]), "variables."+name)
+	}
+	if len(u.files) == 0 {
+		return nil
+	}
+	return u
+}
+
+// find records the uploads in v, which is at path.
+func (u *uploadFiles) find(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			u.find(v.Elem(), path)
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if v.Type().Elem() == uploadType {
+			u.add(path, v.Interface().(*Upload))
+			return
+		}
+		u.find(v.Elem(), path)
+	case reflect.Struct:
+		if v.Type() == uploadType {
+			upload := v.Interface().(Upload)
+			u.add(path, &upload)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, key := range sortedKeys(v) {
+			u.find(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), path+"."+key)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			u.find(v.Index(i), path+"."+strconv.Itoa(i))
+		}
+	}
+}
+
+func (u *uploadFiles) add(path string, upload *Upload) {
+	if i, ok := u.seen[upload]; ok {
+		u.paths[i] = append(u.paths[i], path)
+		return
+	}
+	if u.seen == nil {
+		u.seen = make(map[*Upload]int)
+	}
+	u.seen[upload] = len(u.files)
+	u.files = append(u.files, *upload)
+	u.paths = append(u.paths, []string{path})
+}
+
+// sortedKeys returns the keys of a map with string keys, sorted.
+func sortedKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, key := range m.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeMultipart writes the multipart request of req and its uploads to
+// w: the operations, the map of files to paths, then the files.
+func (u *uploadFiles) writeMultipart(w *multipart.Writer, req *Request) error {
+	operations, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if err := w.WriteField("operations", string(operations)); err != nil {
+		return err
+	}
+	fileMap := make(map[string][]string, len(u.files))
+	for i, paths := range u.paths {
+		fileMap[strconv.Itoa(i)] = paths
+	}
+	mapField, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteField("map", string(mapField)); err != nil {
+		return err
+	}
+
+	for i, file := range u.files {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename="%s"`, i, escapeQuotes(file.Filename)))
+		header.Set("Content-Type", contentType)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if file.Reader != nil {
+			if _, err := io.Copy(part, file.Reader); err != nil {
+				return fmt.Errorf("failed to read upload %q: %w", file.Filename, err)
+			}
+		}
+	}
+	return w.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
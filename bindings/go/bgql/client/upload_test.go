@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+)
+
+// multipartRequest is what uploadServer received.
+type multipartRequest struct {
+	contentType string
+	preflight   string
+	operations  string
+	fileMap     map[string][]string
+	files       map[string]string
+}
+
+// uploadServer records the multipart requests it is sent. onFields, when
+// set, is called once the operations and map have been read.
+func uploadServer(t *testing.T, got chan<- multipartRequest, onFields func()) *httptest.Server {
+	t.Helper()
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := multipartRequest{contentType: r.Header.Get("Content-Type"), preflight: r.Header.Get("Apollo-Require-Preflight"), files: map[string]string{}}
+		if mediaType, _, _ := mime.ParseMediaType(req.contentType); mediaType == "multipart/form-data" {
+			mr, err := r.MultipartReader()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if part.FormName() == "map" && onFields != nil {
+					json.NewDecoder(part).Decode(&req.fileMap)
+					onFields()
+					continue
+				}
+				data, _ := io.ReadAll(part)
+				switch part.FormName() {
+				case "operations":
+					req.operations = string(data)
+				case "map":
+					json.Unmarshal(data, &req.fileMap)
+				default:
+					req.files[part.FormName()] = fmt.Sprintf("%s:%s:%s", part.FileName(), part.Header.Get("Content-Type"), data)
+				}
+			}
+		} else {
+			data, _ := io.ReadAll(r.Body)
+			req.operations = string(data)
+		}
+		got <- req
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"ok":true}}`))
+	}))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func TestUploads(t *testing.T) {
+	shared := &Upload{Filename: "shared.txt", Reader: strings.NewReader("s")}
+	tests := []struct {
+		name      string
+		variables map[string]any
+		wantOps   string
+		wantMap   map[string][]string
+		wantFiles map[string]string
+	}{
+		{
+			name:      "single file",
+			variables: map[string]any{"file": Upload{Filename: "a.txt", ContentType: "text/plain", Reader: strings.NewReader("hello")}, "n": 1},
+			wantOps:   `{"query":"mutation","variables":{"file":null,"n":1}}`,
+			wantMap:   map[string][]string{"0": {"variables.file"}},
+			wantFiles: map[string]string{"0": "a.txt:text/plain:hello"},
+		},
+		{
+			name: "list",
+			variables: map[string]any{"files": []*Upload{
+				{Filename: "a.bin", Reader: strings.NewReader("1")},
+				{Filename: "b.bin", Reader: strings.NewReader("2")},
+			}},
+			wantOps:   `{"query":"mutation","variables":{"files":[null,null]}}`,
+			wantMap:   map[string][]string{"0": {"variables.files.0"}, "1": {"variables.files.1"}},
+			wantFiles: map[string]string{"0": "a.bin:application/octet-stream:1", "1": "b.bin:application/octet-stream:2"},
+		},
+		{
+			name: "nested",
+			variables: map[string]any{"input": map[string]any{
+				"title": "t",
+				"docs":  []any{map[string]any{"file": &Upload{Filename: `say "hi".md`, Reader: strings.NewReader("#")}}},
+			}},
+			wantOps:   `{"query":"mutation","variables":{"input":{"docs":[{"file":null}],"title":"t"}}}`,
+			wantMap:   map[string][]string{"0": {"variables.input.docs.0.file"}},
+			wantFiles: map[string]string{"0": `say "hi".md:application/octet-stream:#`},
+		},
+		{
+			name:      "shared file",
+			variables: map[string]any{"a": shared, "b": []any{shared}},
+			wantOps:   `{"query":"mutation","variables":{"a":null,"b":[null]}}`,
+			wantMap:   map[string][]string{"0": {"variables.a", "variables.b.0"}},
+			wantFiles: map[string]string{"0": "shared.txt:application/octet-stream:s"},
+		},
+		{
+			name:      "no uploads",
+			variables: map[string]any{"data": []byte("raw"), "n": 1},
+			wantOps:   `{"query":"mutation","variables":{"data":"cmF3","n":1}}`,
+			wantFiles: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(chan multipartRequest, 1)
+			c := New(uploadServer(t, got, nil).URL)
+			if res := c.Mutate(context.Background(), "mutation", tt.variables); res.IsErr() {
+				t.Fatal(res.Error())
+			}
+			req := <-got
+			mediaType, _, _ := mime.ParseMediaType(req.contentType)
+			if wantType := map[bool]string{true: "multipart/form-data", false: "application/json"}[tt.wantMap != nil]; mediaType != wantType {
+				t.Errorf("Content-Type = %q, want %s", req.contentType, wantType)
+			}
+			if (req.preflight == "true") != (tt.wantMap != nil) {
+				t.Errorf("Apollo-Require-Preflight = %q", req.preflight)
+			}
+			if req.operations != tt.wantOps {
+				t.Errorf("operations = %s, want %s", req.operations, tt.wantOps)
+			}
+			if !reflect.DeepEqual(req.fileMap, tt.wantMap) {
+				t.Errorf("map = %v, want %v", req.fileMap, tt.wantMap)
+			}
+			if !reflect.DeepEqual(req.files, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", req.files, tt.wantFiles)
+			}
+		})
+	}
+}
+
+// gatedReader returns its data only once gate is closed.
+type gatedReader struct {
+	gate <-chan struct{}
+	data io.Reader
+}
+
+func (r *gatedReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.gate:
+		return r.data.Read(p)
+	case <-time.After(5 * time.Second):
+		return 0, fmt.Errorf("upload read before the request was sent")
+	}
+}
+
+func TestUploadStreamsFiles(t *testing.T) {
+	// The file is only readable once the server has the operations, so
+	// the request would fail if it were buffered first.
+	gate := make(chan struct{})
+	got := make(chan multipartRequest, 1)
+	hs := uploadServer(t, got, func() { close(gate) })
+	upload := &Upload{Filename: "big.bin", Reader: &gatedReader{gate: gate, data: strings.NewReader(strings.Repeat("x", 1<<20))}}
+
+	c := NewWithConfig(Config{URL: hs.URL, Headers: map[string]string{}})
+	c.Use(RetryMiddleware(3, time.Millisecond))
+	if res := c.Mutate(context.Background(), "mutation", map[string]any{"file": upload}); res.IsErr() {
+		t.Fatal(res.Error())
+	}
+	if req := <-got; len(req.files["0"]) != len("big.bin:application/octet-stream:")+1<<20 {
+		t.Errorf("file part has %d bytes", len(req.files["0"]))
+	}
+}
+
+func TestUploadNotRetried(t *testing.T) {
+	var requests int
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer hs.Close()
+
+	c := New(hs.URL).Use(RetryMiddleware(3, time.Millisecond))
+	res := c.Mutate(context.Background(), "mutation", map[string]any{"file": Upload{Filename: "a", Reader: strings.NewReader("a")}})
+	if res.IsOk() || requests != 1 {
+		t.Errorf("result %v after %d requests, want one failed request", res, requests)
+	}
+	requests = 0
+	if res := c.Query(context.Background(), "{ a }", nil); res.IsOk() || requests != 4 {
+		t.Errorf("result %v after %d requests, want 4 failed requests", res, requests)
+	}
+}
+
+func TestUploadToServer(t *testing.T) {
+	s := server.NewBuilder().
+		Schema(`scalar Upload type Query { a: Int } type Mutation { sizes(files: [Upload!]!, note: String): [String!]! }`).
+		Resolver("Mutation", "sizes", func(ctx *server.Context, p any, args map[string]any) (any, error) {
+			var out []string
+			for _, f := range args["files"].([]any) {
+				upload := f.(server.Upload)
+				data, err := io.ReadAll(upload.File)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, fmt.Sprintf("%s=%s", upload.Filename, data))
+			}
+			return out, nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(s.Handler())
+	defer hs.Close()
+
+	res := New(hs.URL+"/graphql").Mutate(context.Background(), `mutation($files: [Upload!]!) { sizes(files: $files) }`, map[string]any{
+		"files": []Upload{
+			{Filename: "a.txt", Reader: strings.NewReader("one")},
+			{Filename: "b.txt", Reader: strings.NewReader("two")},
+		},
+	})
+	if res.IsErr() {
+		t.Fatal(res.Error())
+	}
+	if got := string(res.Unwrap().Data); got != `{"sizes":["a.txt=one","b.txt=two"]}` {
+		t.Errorf("data = %s", got)
+	}
+}
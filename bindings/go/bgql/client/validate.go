@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// ErrValidationError is the cause of the error for a request whose query
+// does not validate against the schema given to WithSchema. The error also
+// wraps the language.ErrorList of the problems, with their locations.
+var ErrValidationError = errors.New("GraphQL validation failed")
+
+// maxValidated bounds the queries whose validation results are kept.
+const maxValidated = 1000
+
+// queryValidator validates queries against a schema, remembering the
+// result for each query text.
+type queryValidator struct {
+	schema *schema.Schema
+
+	mu      sync.Mutex
+	results map[string]error
+}
+
+// WithSchema makes the client validate every query it sends against the
+// schema sdl describes, failing requests that do not validate with
+// ErrValidationError before they reach the network. Results are cached per
+// query text. It returns an error if sdl does not build a schema.
+func (c *Client) WithSchema(sdl string) error {
+	s, err := schema.Parse(sdl)
+	if err != nil {
+		return fmt.Errorf("failed to parse schema: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validator = &queryValidator{schema: s, results: make(map[string]error)}
+	return nil
+}
+
+// validate returns the validation error for query, or nil if it is valid
+// or the client has no schema.
+func (c *Client) validate(query string) error {
+	c.mu.RLock()
+	v := c.validator
+	c.mu.RUnlock()
+	if v == nil {
+		return nil
+	}
+	return v.validate(query)
+}
+
+func (v *queryValidator) validate(query string) error {
+	v.mu.Lock()
+	err, ok := v.results[query]
+	v.mu.Unlock()
+	if ok {
+		return err
+	}
+
+	err = v.check(query)
+	v.mu.Lock()
+	if len(v.results) >= maxValidated {
+		clear(v.results)
+	}
+	v.results[query] = err
+	v.mu.Unlock()
+	return err
+}
+
+func (v *queryValidator) check(query string) error {
+	doc, err := language.Parse(query)
+	if err != nil {
+		var syntaxErr *language.Error
+		if errors.As(err, &syntaxErr) {
+			return fmt.Errorf("%w: %w", ErrValidationError, language.ErrorList{syntaxErr})
+		}
+		return fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+	if errs := schema.Validate(v.schema, doc); len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrValidationError, errs)
+	}
+	return nil
+}
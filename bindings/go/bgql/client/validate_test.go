@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+const validateSchema = `
+type Query {
+  user(id: ID!): User
+}
+
+type User {
+  id: ID!
+  name: String!
+}
+`
+
+func TestWithSchema(t *testing.T) {
+	var requests atomic.Int32
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"user":{"id":"1","name":"Ada"}}}`))
+	}))
+	defer hs.Close()
+
+	c := New(hs.URL)
+	if err := c.WithSchema(validateSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{"valid", `query A($id: ID!) { user(id: $id) { id name } }`, ""},
+		{"unknown field", `{ user(id: 1) { nmae } }`, `Cannot query field "nmae" on type "User". (1:17)`},
+		{"wrong argument type", `{ user(id: true) { id } }`, `Expected value of type "ID!", found true. (1:12)`},
+		{"undeclared variable", `{ user(id: $id) { id } }`, `Variable "$id" is not defined. (1:12)`},
+		{"syntax error", `{ user(id: 1) { id }`, `Syntax Error: Expected Name, found <EOF>. (1:21)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := requests.Load()
+			err := c.Execute(context.Background(), &Request{Query: tt.query, Variables: map[string]any{"id": "1"}}).Error()
+			sent := requests.Load() - before
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Execute() error = %v", err)
+				}
+				if sent != 1 {
+					t.Errorf("sent %d requests, want 1", sent)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidationError) {
+				t.Fatalf("Execute() error = %v, want ErrValidationError", err)
+			}
+			var errs language.ErrorList
+			if !errors.As(err, &errs) || errs.Error() != tt.wantErr {
+				t.Errorf("validation errors = %v, want %q", errs, tt.wantErr)
+			}
+			if sent != 0 {
+				t.Errorf("sent %d requests, want none", sent)
+			}
+		})
+	}
+
+	// The result is cached per query text.
+	first := c.Execute(context.Background(), &Request{Query: `{ user(id: 1) { nmae } }`}).Error()
+	second := c.Execute(context.Background(), &Request{Query: `{ user(id: 1) { nmae } }`}).Error()
+	if first != second {
+		t.Errorf("validation was repeated: %v, %v", first, second)
+	}
+
+	if _, _, err := c.Subscribe(context.Background(), `subscription { user }`, nil); !errors.Is(err, ErrValidationError) {
+		t.Errorf("Subscribe() error = %v, want ErrValidationError", err)
+	}
+	if err := New(hs.URL).WithSchema(`type Query { user: Missing }`); err == nil {
+		t.Error("WithSchema() accepted an invalid schema")
+	}
+}
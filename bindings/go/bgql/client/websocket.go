@@ -0,0 +1,298 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal RFC 6455 WebSocket client, enough to speak the subscription
+// protocol without third-party dependencies.
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsAcceptGUID is appended to the client key to compute the accept key.
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage is the largest message accepted from the server.
+const maxWebSocketMessage = 16 << 20
+
+// errWebSocketClosed is returned once the connection has been closed.
+var errWebSocketClosed = errors.New("websocket: connection closed")
+
+// wsCloseError is returned when the server closes the connection with a
+// status code.
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("%v: %d %s", errWebSocketClosed, e.code, e.reason)
+}
+
+func (e *wsCloseError) Unwrap() error { return errWebSocketClosed }
+
+// wsConn is a client-side WebSocket connection. Writes are safe for
+// concurrent use; reads are not.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// webSocketURL returns the WebSocket endpoint for the config: WebSocketURL,
+// or URL with its scheme changed to ws or wss.
+func webSocketURL(config Config) (string, error) {
+	if config.WebSocketURL != "" {
+		return config.WebSocketURL, nil
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	return u.String(), nil
+}
+
+// dialWebSocket opens a WebSocket connection to rawURL, sending header
+// with the opening handshake and agreeing on subprotocol.
+func dialWebSocket(ctx context.Context, rawURL string, header http.Header, subprotocol string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	case "wss":
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Header:     header.Clone(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", subprotocol)
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	switch {
+	case resp.StatusCode != http.StatusSwitchingProtocols:
+		conn.Close()
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header}
+	case resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]):
+		conn.Close()
+		return nil, errors.New("websocket: bad accept key")
+	case !strings.EqualFold(resp.Header.Get("Sec-WebSocket-Protocol"), subprotocol):
+		conn.Close()
+		return nil, fmt.Errorf("websocket: server does not speak %s", subprotocol)
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+// readMessage returns the next text or binary message, answering pings
+// and reassembling fragments. It returns errWebSocketClosed, or a
+// wsCloseError wrapping it, after a close frame.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.close(1000, "")
+			if len(payload) >= 2 {
+				return nil, &wsCloseError{code: int(binary.BigEndian.Uint16(payload)), reason: string(payload[2:])}
+			}
+			return nil, errWebSocketClosed
+		case wsText, wsBinary, wsContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				c.close(1009, "Message too big")
+				return nil, errors.New("websocket: message too big")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			c.close(1002, "Unknown opcode")
+			return nil, errors.New("websocket: unknown opcode")
+		}
+	}
+}
+
+// readFrame reads one frame.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if masked {
+		c.close(1002, "Server frames must not be masked")
+		return false, 0, nil, errors.New("websocket: masked server frame")
+	}
+	if length > maxWebSocketMessage {
+		c.close(1009, "Message too big")
+		return false, 0, nil, errors.New("websocket: message too big")
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unfragmented frame, masked as clients must.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 0x80|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	header = append(header, mask[:]...)
+	frame := append(header, payload...)
+	masked := frame[len(header):]
+	for i := range masked {
+		masked[i] ^= mask[i%4]
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeJSON sends v as a text message.
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+// close sends a close frame with code and reason, then closes the
+// connection. Later calls do nothing.
+func (c *wsConn) close(code int, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.writeFrame(wsClose, append(payload, reason...))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.conn.Close()
+	}
+}
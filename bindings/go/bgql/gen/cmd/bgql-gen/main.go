@@ -0,0 +1,131 @@
+// Command bgql-gen generates typed Go code for the GraphQL operations in
+// the given files, checked against a schema. Run it from go:generate:
+//
+//	//go:generate go run github.com/ubugeeei/bgql/bindings/go/bgql/gen/cmd/bgql-gen -schema schema.graphql -out operations.go -scalar DateTime=time.Time queries/*.graphql
+//
+// File arguments are globs, so they work without a shell. With -manifest,
+// it also writes the persisted operation manifest of the operations, for
+// the client's Config.PersistedManifest and the server's AllowedManifest.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/gen"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// listFlag is a flag that may be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	var schemas, scalars listFlag
+	flag.Var(&schemas, "schema", "schema `file` or glob; may be repeated")
+	flag.Var(&scalars, "scalar", "custom scalar mapping `Name=GoType`, such as DateTime=time.Time; may be repeated")
+	out := flag.String("out", "operations.go", "output `file`")
+	manifestOut := flag.String("manifest", "", "persisted operation manifest `file` to write, if any")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package `name` of the output; defaults to $GOPACKAGE")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bgql-gen -schema schema.graphql [flags] operations.graphql...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(schemas, scalars, flag.Args(), *out, *manifestOut, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "bgql-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schemaGlobs, scalars, operationGlobs []string, out, manifestOut, pkg string) error {
+	if len(schemaGlobs) == 0 || len(operationGlobs) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if pkg == "" {
+		return fmt.Errorf("no package name: pass -package or run from go:generate")
+	}
+
+	config := gen.Config{Package: pkg, Scalars: make(map[string]string)}
+	for _, mapping := range scalars {
+		name, goType, ok := strings.Cut(mapping, "=")
+		if !ok {
+			return fmt.Errorf("invalid -scalar %q: want Name=GoType", mapping)
+		}
+		config.Scalars[name] = goType
+	}
+
+	schemaSources, err := readSources(schemaGlobs)
+	if err != nil {
+		return err
+	}
+	merged := &language.Document{}
+	for _, src := range schemaSources {
+		doc, err := language.Parse(src.Body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Name, err)
+		}
+		merged.Definitions = append(merged.Definitions, doc.Definitions...)
+	}
+	if config.Schema, err = schema.Build(merged); err != nil {
+		return err
+	}
+
+	sources, err := readSources(operationGlobs)
+	if err != nil {
+		return err
+	}
+	src, err := gen.Generate(config, sources...)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		return err
+	}
+	if manifestOut == "" {
+		return nil
+	}
+	m, err := gen.Manifest(config, sources...)
+	if err != nil {
+		return err
+	}
+	data, err := m.MarshalIndent()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestOut, data, 0o644)
+}
+
+// readSources reads the files matching globs, in lexical order per glob.
+func readSources(globs []string) ([]gen.Source, error) {
+	var sources []gen.Source
+	for _, glob := range globs {
+		names, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no files match %q", glob)
+		}
+		for _, name := range names {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, gen.Source{Name: name, Body: string(data)})
+		}
+	}
+	return sources, nil
+}
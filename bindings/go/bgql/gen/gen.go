@@ -0,0 +1,627 @@
+// Package gen generates typed Go code for GraphQL operations: for each
+// query and mutation, a struct of its variables, structs of its response
+// data, and an sdk.Operation wiring the two together, all checked against
+// the schema. The bgql-gen command runs it from go:generate.
+//
+// Selections of fragments are merged into the structs of the selection
+// sets they are spread in. Fields selected under a type condition other
+// than the type of their selection set, or with @include or @skip, may be
+// absent from the response, so they are pointers like nullable fields.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/manifest"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// sdkImport is the import path of the package of generated operations.
+const sdkImport = "github.com/ubugeeei/bgql/sdk"
+
+// Config configures code generation.
+type Config struct {
+	// Package is the package name of the generated file.
+	Package string
+	// Schema is the schema operations are checked against.
+	Schema *schema.Schema
+	// Scalars maps custom scalars to Go types, either predeclared, such as
+	// "string", or qualified by their import path, such as "time.Time" or
+	// "github.com/shopspring/decimal.Decimal". Unmapped custom scalars are
+	// json.RawMessage.
+	Scalars map[string]string
+}
+
+// Source is a document of operations and fragments. Its name prefixes
+// the errors found in it.
+type Source struct {
+	Name string
+	Body string
+}
+
+// builtinScalars are the Go types of the specified scalars.
+var builtinScalars = map[string]string{
+	"Int":     "int",
+	"Float":   "float64",
+	"String":  "string",
+	"Boolean": "bool",
+	"ID":      "string",
+}
+
+// Generate returns the formatted Go source of the operations in sources.
+// Fragments may be spread across sources. Operations must be named
+// queries or mutations. All problems found are reported together as a
+// language.ErrorList.
+func Generate(config Config, sources ...Source) ([]byte, error) {
+	g, err := generate(config, sources)
+	if err != nil {
+		return nil, err
+	}
+	return g.file()
+}
+
+// Manifest returns the persisted operation manifest of the operations in
+// sources, holding the documents of the generated operations.
+func Manifest(config Config, sources ...Source) (*manifest.Manifest, error) {
+	g, err := generate(config, sources)
+	if err != nil {
+		return nil, err
+	}
+	return g.manifest, nil
+}
+
+func generate(config Config, sources []Source) (*generator, error) {
+	g := &generator{
+		config:          config,
+		fragments:       make(map[string]*language.FragmentDefinition),
+		fragmentSources: make(map[string]string),
+		imports:         map[string]bool{sdkImport: true},
+		named:           make(map[string]bool),
+		manifest:        manifest.New(),
+	}
+
+	type operation struct {
+		source string
+		def    *language.OperationDefinition
+	}
+	var ops []operation
+	names := make(map[string]bool)
+	for _, src := range sources {
+		doc, err := language.Parse(src.Body)
+		if err != nil {
+			g.errs = append(g.errs, sourceErrors(src.Name, err)...)
+			continue
+		}
+		g.source = src.Name
+		for _, def := range doc.Definitions {
+			switch def := def.(type) {
+			case *language.OperationDefinition:
+				switch {
+				case def.Name == "":
+					g.errorf(def.Loc, "Operations must be named.")
+				case names[def.Name]:
+					g.errorf(def.Loc, "There can be only one operation named %q.", def.Name)
+				default:
+					names[def.Name] = true
+					ops = append(ops, operation{src.Name, def})
+				}
+			case *language.FragmentDefinition:
+				if g.fragments[def.Name] != nil {
+					g.errorf(def.Loc, "There can be only one fragment named %q.", def.Name)
+					continue
+				}
+				g.fragments[def.Name] = def
+				g.fragmentSources[def.Name] = src.Name
+			default:
+				g.errorf(def.Position(), "Operation documents may only define operations and fragments.")
+			}
+		}
+	}
+	if len(g.errs) > 0 {
+		return nil, g.errs
+	}
+
+	for _, op := range ops {
+		g.source = op.source
+		g.operation(op.def)
+	}
+	g.namedTypes()
+	if len(g.errs) > 0 {
+		return nil, g.errs
+	}
+	return g, nil
+}
+
+type generator struct {
+	config    Config
+	fragments map[string]*language.FragmentDefinition
+	// fragmentSources are the names of the sources of fragments, for
+	// errors found in them.
+	fragmentSources map[string]string
+	// source is the name of the source being generated, for errors.
+	source string
+
+	body    bytes.Buffer
+	imports map[string]bool
+	// named holds the enums and input objects to declare.
+	named    map[string]bool
+	manifest *manifest.Manifest
+	errs     language.ErrorList
+}
+
+func (g *generator) errorf(loc language.Location, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if g.source != "" {
+		msg = g.source + ": " + msg
+	}
+	g.errs = append(g.errs, &language.Error{Message: msg, Locations: []language.Location{loc}})
+}
+
+// printf writes to the body of the file.
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// =============================================================================
+// Operations
+// =============================================================================
+
+func (g *generator) operation(op *language.OperationDefinition) {
+	var constructor string
+	switch op.Operation {
+	case language.Query:
+		constructor = "NewQuery"
+	case language.Mutation:
+		constructor = "NewMutation"
+	default:
+		g.errorf(op.Loc, "Operation %q: only queries and mutations are generated.", op.Name)
+		return
+	}
+	root := g.config.Schema.RootType(op.Operation)
+	if root == nil {
+		g.errorf(op.Loc, "The schema does not support %s operations.", op.Operation)
+		return
+	}
+
+	name := exportedName(op.Name)
+	variables, data, document := name+"Variables", name+"Data", unexportedName(op.Name)+"Document"
+
+	g.printf("// %sOperation is the %s %s.\n", name, op.Name, op.Operation)
+	g.printf("var %sOperation = sdk.%s[%s, %s](%q, %s)\n\n", name, constructor, variables, data, op.Name, document)
+	source := g.document(op)
+	g.manifest.Add(op.Name, source)
+	g.printf("const %s = %s\n\n", document, goString(source))
+
+	g.printf("// %s are the variables of the %s %s.\n", variables, op.Name, op.Operation)
+	g.printf("type %s struct {\n", variables)
+	for _, v := range op.VariableDefinitions {
+		t := g.config.Schema.Type(v.Type.Name())
+		if t == nil || !t.IsInputType() {
+			g.errorf(v.Loc, "Variable \"$%s\" cannot be of type %q.", v.Variable, v.Type)
+			continue
+		}
+		g.printf("\t%s %s `json:\"%s%s\"`\n", exportedName(v.Variable), g.inputType(v.Type), v.Variable, omitEmpty(v.Type))
+	}
+	g.printf("}\n\n")
+
+	g.printf("// %s is the data of the %s %s.\n", data, op.Name, op.Operation)
+	g.selectionStruct(data, root, []language.SelectionSet{op.SelectionSet})
+}
+
+// document returns the source of op and the fragments it spreads.
+func (g *generator) document(op *language.OperationDefinition) string {
+	doc := &language.Document{Definitions: []language.Definition{op}}
+	seen := make(map[string]bool)
+	var spread func(set language.SelectionSet)
+	spread = func(set language.SelectionSet) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *language.Field:
+				spread(sel.SelectionSet)
+			case *language.InlineFragment:
+				spread(sel.SelectionSet)
+			case *language.FragmentSpread:
+				if frag := g.fragments[sel.Name]; frag != nil && !seen[sel.Name] {
+					seen[sel.Name] = true
+					doc.Definitions = append(doc.Definitions, frag)
+					spread(frag.SelectionSet)
+				}
+			}
+		}
+	}
+	spread(op.SelectionSet)
+	return language.Print(doc)
+}
+
+// =============================================================================
+// Selection Sets
+// =============================================================================
+
+// selectedField is a field of a selection set, merging the selections
+// with its response key.
+type selectedField struct {
+	key string
+	def *schema.Field
+	// sets are the selection sets of the field.
+	sets []language.SelectionSet
+	// optional is set when the field may be absent from the response.
+	optional bool
+}
+
+// selectionStruct declares the struct named name of the fields selected
+// on parent by sets, followed by the structs of their selection sets.
+func (g *generator) selectionStruct(name string, parent *schema.Type, sets []language.SelectionSet) {
+	var fields []*selectedField
+	index := make(map[string]*selectedField)
+	for _, set := range sets {
+		g.collect(parent, set, false, &fields, index, make(map[string]bool))
+	}
+
+	type nested struct {
+		name string
+		t    *schema.Type
+		sets []language.SelectionSet
+	}
+	var children []nested
+	g.printf("type %s struct {\n", name)
+	for _, f := range fields {
+		t := g.config.Schema.Type(f.def.Type.Name())
+		goName := exportedName(f.key)
+		var elem string
+		if t.IsComposite() {
+			elem = name + goName
+			children = append(children, nested{elem, t, f.sets})
+		} else {
+			elem = g.leafType(t)
+		}
+		g.printf("\t%s %s `json:\"%s\"`\n", goName, outputType(f.def.Type, elem, f.optional), f.key)
+	}
+	g.printf("}\n\n")
+
+	for _, child := range children {
+		g.selectionStruct(child.name, child.t, child.sets)
+	}
+}
+
+// collect adds the fields selected on parent by set to fields, merging
+// them by response key. Fields under a type condition other than parent,
+// or with @include or @skip, are optional.
+func (g *generator) collect(parent *schema.Type, set language.SelectionSet, optional bool, fields *[]*selectedField, index map[string]*selectedField, visited map[string]bool) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			g.collectField(parent, sel, optional || conditional(sel.Directives), fields, index)
+
+		case *language.InlineFragment:
+			t := parent
+			if sel.TypeCondition != "" {
+				if t = g.typeCondition(sel.TypeCondition, sel.Loc); t == nil {
+					continue
+				}
+			}
+			g.collect(t, sel.SelectionSet, optional || t != parent || conditional(sel.Directives), fields, index, visited)
+
+		case *language.FragmentSpread:
+			frag := g.fragments[sel.Name]
+			if frag == nil {
+				g.errorf(sel.Loc, "Unknown fragment %q.", sel.Name)
+				continue
+			}
+			if visited[sel.Name] {
+				continue
+			}
+			visited[sel.Name] = true
+			source := g.source
+			g.source = g.fragmentSources[sel.Name]
+			if t := g.typeCondition(frag.TypeCondition, frag.Loc); t != nil {
+				g.collect(t, frag.SelectionSet, optional || t != parent || conditional(sel.Directives), fields, index, visited)
+			}
+			g.source = source
+			delete(visited, sel.Name)
+		}
+	}
+}
+
+func (g *generator) collectField(parent *schema.Type, sel *language.Field, optional bool, fields *[]*selectedField, index map[string]*selectedField) {
+	def := g.config.Schema.FieldDefinition(parent, sel.Name)
+	if def == nil {
+		g.errorf(sel.Loc, "Cannot query field %q on type %q.", sel.Name, parent.Name)
+		return
+	}
+	t := g.config.Schema.Type(def.Type.Name())
+	switch {
+	case t.IsComposite() && len(sel.SelectionSet) == 0:
+		g.errorf(sel.Loc, "Field %q of type %q must have a selection of subfields.", sel.Name, def.Type)
+		return
+	case !t.IsComposite() && len(sel.SelectionSet) > 0:
+		g.errorf(sel.Loc, "Field %q must not have a selection since type %q has no subfields.", sel.Name, def.Type)
+		return
+	}
+
+	key := sel.ResponseKey()
+	f := index[key]
+	if f == nil {
+		f = &selectedField{key: key, def: def, optional: optional}
+		index[key] = f
+		*fields = append(*fields, f)
+	} else if f.def.Type.Name() != def.Type.Name() {
+		g.errorf(sel.Loc, "Fields %q conflict because they return different types.", key)
+		return
+	}
+	f.optional = f.optional && optional
+	if len(sel.SelectionSet) > 0 {
+		f.sets = append(f.sets, sel.SelectionSet)
+	}
+}
+
+// typeCondition returns the composite type a fragment applies to.
+func (g *generator) typeCondition(name string, loc language.Location) *schema.Type {
+	t := g.config.Schema.Type(name)
+	if t == nil || !t.IsComposite() {
+		g.errorf(loc, "Fragment cannot condition on type %q.", name)
+		return nil
+	}
+	return t
+}
+
+// conditional reports whether directives include or skip a selection
+// depending on variables.
+func conditional(directives language.DirectiveList) bool {
+	return directives.ForName("include") != nil || directives.ForName("skip") != nil
+}
+
+// =============================================================================
+// Types
+// =============================================================================
+
+// outputType returns the Go type of a response value of type t, whose
+// named type is elem. Nullable and optional values are pointers, except
+// lists, which are nil when null.
+func outputType(t *language.Type, elem string, optional bool) string {
+	if t.Elem != nil {
+		return "[]" + outputType(t.Elem, elem, false)
+	}
+	if !t.NonNull || optional {
+		return "*" + elem
+	}
+	return elem
+}
+
+// inputType returns the Go type of an input value of type t.
+func (g *generator) inputType(t *language.Type) string {
+	var elem string
+	if t.Elem != nil {
+		return "[]" + g.inputType(t.Elem)
+	}
+	named := g.config.Schema.Type(t.NamedType)
+	switch named.Kind {
+	case schema.InputObject:
+		g.named[named.Name] = true
+		elem = exportedName(named.Name)
+	default:
+		elem = g.leafType(named)
+	}
+	if !t.NonNull {
+		return "*" + elem
+	}
+	return elem
+}
+
+// omitEmpty returns the tag option omitting input values of type t when
+// they are nil. Lists are not omitted, as omitempty drops empty ones.
+func omitEmpty(t *language.Type) string {
+	if !t.NonNull && t.Elem == nil {
+		return ",omitempty"
+	}
+	return ""
+}
+
+// leafType returns the Go type of a scalar or enum.
+func (g *generator) leafType(t *schema.Type) string {
+	if t.Kind == schema.Enum {
+		g.named[t.Name] = true
+		return exportedName(t.Name)
+	}
+	if goType, ok := g.config.Scalars[t.Name]; ok {
+		return g.qualified(goType)
+	}
+	if goType, ok := builtinScalars[t.Name]; ok {
+		return goType
+	}
+	g.imports["encoding/json"] = true
+	return "json.RawMessage"
+}
+
+// qualified returns the Go type of a type mapped by Config.Scalars,
+// importing its package.
+func (g *generator) qualified(goType string) string {
+	i := strings.LastIndex(goType, ".")
+	if i < 0 {
+		return goType
+	}
+	path, name := goType[:i], goType[i+1:]
+	g.imports[path] = true
+	return path[strings.LastIndex(path, "/")+1:] + "." + name
+}
+
+// namedTypes declares the enums and input objects used by the
+// operations, and the input objects those use, in name order.
+func (g *generator) namedTypes() {
+	g.source = ""
+	declared := make(map[string]bool)
+	for {
+		var pending []string
+		for name := range g.named {
+			if !declared[name] {
+				pending = append(pending, name)
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+		sort.Strings(pending)
+		for _, name := range pending {
+			declared[name] = true
+			g.namedType(g.config.Schema.Type(name))
+		}
+	}
+}
+
+func (g *generator) namedType(t *schema.Type) {
+	name := exportedName(t.Name)
+	switch t.Kind {
+	case schema.Enum:
+		g.printf("// %s is the %s enum.\n", name, t.Name)
+		g.printf("type %s string\n\n", name)
+		g.printf("const (\n")
+		for _, v := range t.EnumValues {
+			g.printf("\t%s%s %s = %q\n", name, exportedName(v.Name), name, v.Name)
+		}
+		g.printf(")\n\n")
+	case schema.InputObject:
+		g.printf("// %s is the %s input.\n", name, t.Name)
+		g.printf("type %s struct {\n", name)
+		for _, f := range t.InputFields {
+			g.printf("\t%s %s `json:\"%s%s\"`\n", exportedName(f.Name), g.inputType(f.Type), f.Name, omitEmpty(f.Type))
+		}
+		g.printf("}\n\n")
+	}
+}
+
+// =============================================================================
+// Output
+// =============================================================================
+
+// file returns the formatted source of the file.
+func (g *generator) file() ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by bgql-gen. DO NOT EDIT.\n\npackage %s\n\n", g.config.Package)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	// The standard library first, then the rest.
+	for _, std := range []bool{true, false} {
+		for _, path := range imports {
+			if isStandard(path) == std {
+				fmt.Fprintf(&out, "\t%q\n", path)
+			}
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// isStandard reports whether an import path is of the standard library.
+func isStandard(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// goString returns a Go string literal of s, raw when it can be.
+func goString(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// initialisms are the words written in capitals in Go names.
+var initialisms = []string{"API", "HTML", "HTTP", "ID", "JSON", "SQL", "URI", "URL", "UUID"}
+
+// exportedName converts a GraphQL name to an exported Go name:
+// "userId" is "UserID", "ids" is "IDs", "__typename" is "Typename", and
+// "READ_WRITE" is "ReadWrite".
+func exportedName(name string) string {
+	var sb strings.Builder
+	for _, word := range splitWords(name) {
+		upper := strings.ToUpper(word)
+		if slices.Contains(initialisms, upper) {
+			sb.WriteString(upper)
+			continue
+		}
+		if stem, ok := strings.CutSuffix(upper, "S"); ok && slices.Contains(initialisms, stem) {
+			sb.WriteString(stem + "s")
+			continue
+		}
+		if word == upper {
+			word = strings.ToLower(word)
+		}
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return sb.String()
+}
+
+// unexportedName converts a GraphQL name to an unexported Go name.
+func unexportedName(name string) string {
+	exported := exportedName(name)
+	return strings.ToLower(exported[:1]) + exported[1:]
+}
+
+// splitWords splits a name at underscores and at the start of each
+// capitalized word: "getHTTPStatus_code" is "get", "HTTP", "Status", and
+// "code".
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(runes[start:end]))
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			flush(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				flush(i)
+				start = i
+			}
+		}
+	}
+	flush(len(runes))
+	return words
+}
+
+// sourceErrors prefixes the parse errors of a source with its name.
+func sourceErrors(name string, err error) language.ErrorList {
+	var list language.ErrorList
+	switch err := err.(type) {
+	case language.ErrorList:
+		list = err
+	case *language.Error:
+		list = language.ErrorList{err}
+	default:
+		list = language.ErrorList{language.NewError(nil, "%s", err.Error())}
+	}
+	if name == "" {
+		return list
+	}
+	prefixed := make(language.ErrorList, len(list))
+	for i, e := range list {
+		prefixed[i] = &language.Error{Message: name + ": " + e.Message, Locations: e.Locations}
+	}
+	return prefixed
+}
@@ -0,0 +1,263 @@
+package gen
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/manifest"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+const testSchema = `
+scalar DateTime
+scalar JSON
+
+enum Role { ADMIN READ_WRITE }
+
+input UserFilter {
+  role: Role
+  name: String!
+  tags: [String!]
+  parent: UserFilter
+}
+
+interface Node { id: ID! }
+
+type User implements Node {
+  id: ID!
+  name: String!
+  email: String
+  role: Role!
+  createdAt: DateTime!
+  meta: JSON
+  friends(first: Int): [User!]!
+}
+
+type Bot implements Node {
+  id: ID!
+  model: String!
+}
+
+type Query {
+  user(id: ID!): User
+  users(filter: UserFilter, ids: [ID!]): [User]
+  node(id: ID!): Node
+}
+
+type Mutation {
+  setRole(id: ID!, role: Role!): User!
+}
+
+type Subscription { userAdded: User! }
+`
+
+const testOperations = `
+query GetUser($id: ID!, $withFriends: Boolean = false) {
+  user(id: $id) {
+    ...UserFields
+    email
+    friends(first: 3) @include(if: $withFriends) { id }
+  }
+}
+
+fragment UserFields on User {
+  id
+  name
+  createdAt
+  meta
+}
+
+query FindUsers($filter: UserFilter, $ids: [ID!]) {
+  users(filter: $filter, ids: $ids) { id role }
+  node(id: "1") {
+    __typename
+    id
+    ... on User { name }
+    ... on Bot { model }
+  }
+}
+
+mutation SetRole($id: ID!, $role: Role!) {
+  setRole(id: $id, role: $role) { id role }
+}
+`
+
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	s, err := schema.Parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{Package: "api", Schema: s, Scalars: map[string]string{"DateTime": "time.Time"}}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate(testConfig(t), Source{Name: "ops.graphql", Body: testOperations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(src)
+	fields := strings.Join(strings.Fields(out), " ")
+
+	// The generated code compiles against the sdk, together with code
+	// using it.
+	const usage = `package api
+
+import (
+	"context"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+func use(c *sdk.Client) {
+	var res sdk.Result[GetUserData] = sdk.Execute(c, context.Background(), GetUserOperation, GetUserVariables{ID: "1"})
+	if data, ok := res.Value(); ok && data.User != nil {
+		_ = data.User.CreatedAt.Year()
+	}
+	var role Role = RoleReadWrite
+	sdk.Execute(c, context.Background(), SetRoleOperation, SetRoleVariables{ID: "1", Role: role})
+	sdk.Execute(c, context.Background(), FindUsersOperation, FindUsersVariables{Filter: &UserFilter{Name: "a"}})
+}
+`
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, text := range map[string]string{"operations.go": out, "use.go": usage} {
+		f, err := parser.ParseFile(fset, name, text, 0)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, text)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("api", fset, files, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %v\n%s", err, out)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"query operation", `var GetUserOperation = sdk.NewQuery[GetUserVariables, GetUserData]("GetUser", getUserDocument)`},
+		{"mutation operation", `var SetRoleOperation = sdk.NewMutation[SetRoleVariables, SetRoleData]("SetRole", setRoleDocument)`},
+		{"document includes fragments", "fragment UserFields on User {"},
+		{"non-null variable", "ID         string `json:\"id\"`"},
+		{"nullable variable", "WithFriends *bool  `json:\"withFriends,omitempty\"`"},
+		{"input object variable", "Filter *UserFilter `json:\"filter,omitempty\"`"},
+		{"nullable list variable", "IDs []string `json:\"ids\"`"},
+		{"nullable field", "User *GetUserDataUser `json:\"user\"`"},
+		{"fragment field", "CreatedAt time.Time          `json:\"createdAt\"`"},
+		{"unmapped scalar", "Meta      *json.RawMessage    `json:\"meta\"`"},
+		{"conditional field", "Friends   []GetUserDataUserFriends `json:\"friends\"`"},
+		{"nullable list items", "Users []*FindUsersDataUsers `json:\"users\"`"},
+		{"enum field", "Role Role   `json:\"role\"`"},
+		{"typename", "Typename string  `json:\"__typename\"`"},
+		{"field of an inline fragment", "Name     *string `json:\"name\"`"},
+		{"enum", "RoleReadWrite Role = \"READ_WRITE\""},
+		{"recursive input", "Parent *UserFilter `json:\"parent,omitempty\"`"},
+		{"non-null input field", "Name   string      `json:\"name\"`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(fields, strings.Join(strings.Fields(tt.want), " ")) {
+				t.Errorf("output does not contain %q:\n%s", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"syntax error", "query {", "ops.graphql: Syntax Error"},
+		{"anonymous operation", "{ user(id: 1) { id } }", "Operations must be named."},
+		{"duplicate operation", "query A { node(id: 1) { id } } query A { node(id: 1) { id } }", `only one operation named "A"`},
+		{"unknown field", "query A { user(id: 1) { age } }", `ops.graphql: Cannot query field "age" on type "User". (1:25)`},
+		{"missing selection", "query A { user(id: 1) }", `must have a selection of subfields`},
+		{"selection on leaf", "query A { user(id: 1) { id { x } } }", `must not have a selection`},
+		{"unknown fragment", "query A { user(id: 1) { ...F } }", `Unknown fragment "F"`},
+		{"bad type condition", "query A { node(id: 1) { ... on Role { id } } }", `cannot condition on type "Role"`},
+		{"output variable type", "query A($u: User) { node(id: 1) { id } }", `"$u" cannot be of type "User"`},
+		{"subscription", "subscription S { userAdded { id } }", "only queries and mutations"},
+		{"type definition", "type T { a: Int }", "may only define operations and fragments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Generate(testConfig(t), Source{Name: "ops.graphql", Body: tt.body})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"user", "User"},
+		{"userId", "UserID"},
+		{"__typename", "Typename"},
+		{"READ_WRITE", "ReadWrite"},
+		{"getHTTPStatus", "GetHTTPStatus"},
+		{"avatarUrl", "AvatarURL"},
+		{"ID", "ID"},
+		{"page2Items", "Page2Items"},
+		{"ids", "IDs"},
+		{"status", "Status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exportedName(tt.name); got != tt.want {
+				t.Errorf("exportedName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifest(t *testing.T) {
+	config := testConfig(t)
+	src, err := Generate(config, Source{Name: "ops.graphql", Body: testOperations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Manifest(config, Source{Name: "ops.graphql", Body: testOperations})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		operation string
+		document  string
+	}{
+		{"GetUser", "getUserDocument"},
+		{"FindUsers", "findUsersDocument"},
+		{"SetRole", "setRoleDocument"},
+	}
+	if len(m.Operations) != len(tests) {
+		t.Errorf("manifest has %d operations, want %d", len(m.Operations), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			op, ok := m.Operations[tt.operation]
+			if !ok {
+				t.Fatalf("manifest lacks %s", tt.operation)
+			}
+			// The client looks operations up by the text generated code sends.
+			if !strings.Contains(string(src), "const "+tt.document+" = `"+op.Document+"`") {
+				t.Errorf("manifest document of %s differs from the generated one:\n%s", tt.operation, op.Document)
+			}
+			if op.Hash != manifest.Hash(op.Document) {
+				t.Errorf("hash = %s, want %s", op.Hash, manifest.Hash(op.Document))
+			}
+		})
+	}
+}
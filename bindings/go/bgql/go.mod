@@ -1,3 +1,7 @@
 module github.com/ubugeeei/bgql/bindings/go/bgql
 
-go 1.21
+go 1.23
+
+require github.com/ubugeeei/bgql/sdk v0.1.0
+
+require golang.org/x/sync v0.6.0 // indirect
@@ -0,0 +1,5 @@
+// Package version holds the bgql Go SDK version shared by all packages.
+package version
+
+// Version is the bgql Go SDK version.
+const Version = "0.1.0"
@@ -0,0 +1,443 @@
+package language
+
+import "strings"
+
+// Document is a parsed GraphQL document containing executable and/or
+// type system definitions.
+type Document struct {
+	Definitions []Definition
+}
+
+// Operations returns the operation definitions in document order.
+func (d *Document) Operations() []*OperationDefinition {
+	var ops []*OperationDefinition
+	for _, def := range d.Definitions {
+		if op, ok := def.(*OperationDefinition); ok {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// Operation returns the operation with the given name, or with an empty
+// name the document's only operation. It returns nil when there is no
+// such operation.
+func (d *Document) Operation(name string) *OperationDefinition {
+	ops := d.Operations()
+	if name == "" {
+		if len(ops) == 1 {
+			return ops[0]
+		}
+		return nil
+	}
+	for _, op := range ops {
+		if op.Name == name {
+			return op
+		}
+	}
+	return nil
+}
+
+// Fragments returns the fragment definitions in document order.
+func (d *Document) Fragments() []*FragmentDefinition {
+	var frags []*FragmentDefinition
+	for _, def := range d.Definitions {
+		if frag, ok := def.(*FragmentDefinition); ok {
+			frags = append(frags, frag)
+		}
+	}
+	return frags
+}
+
+// Fragment returns the fragment definition with the given name, or nil.
+func (d *Document) Fragment(name string) *FragmentDefinition {
+	for _, def := range d.Definitions {
+		if frag, ok := def.(*FragmentDefinition); ok && frag.Name == name {
+			return frag
+		}
+	}
+	return nil
+}
+
+// Definition is a top-level definition of a document.
+type Definition interface {
+	Position() Location
+	isDefinition()
+}
+
+// OperationType is query, mutation, or subscription.
+type OperationType string
+
+const (
+	Query        OperationType = "query"
+	Mutation     OperationType = "mutation"
+	Subscription OperationType = "subscription"
+)
+
+// OperationDefinition is a query, mutation, or subscription.
+type OperationDefinition struct {
+	Operation           OperationType
+	Name                string
+	VariableDefinitions []*VariableDefinition
+	Directives          DirectiveList
+	SelectionSet        SelectionSet
+	Loc                 Location
+}
+
+// VariableDefinition declares an operation variable.
+type VariableDefinition struct {
+	Variable     string
+	Type         *Type
+	DefaultValue Value
+	Directives   DirectiveList
+	Loc          Location
+}
+
+// FragmentDefinition is a named, reusable selection set.
+type FragmentDefinition struct {
+	Name          string
+	TypeCondition string
+	Directives    DirectiveList
+	SelectionSet  SelectionSet
+	Loc           Location
+}
+
+// SelectionSet is an ordered list of selections.
+type SelectionSet []Selection
+
+// Selection is a Field, FragmentSpread, or InlineFragment.
+type Selection interface {
+	Position() Location
+	isSelection()
+}
+
+// Field is a field selection.
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    ArgumentList
+	Directives   DirectiveList
+	SelectionSet SelectionSet
+	Loc          Location
+}
+
+// ResponseKey returns the alias if present, otherwise the field name.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread is a "...Name" selection.
+type FragmentSpread struct {
+	Name       string
+	Directives DirectiveList
+	Loc        Location
+}
+
+// InlineFragment is a "... on Type { }" selection. TypeCondition is empty
+// when the fragment has no type condition.
+type InlineFragment struct {
+	TypeCondition string
+	Directives    DirectiveList
+	SelectionSet  SelectionSet
+	Loc           Location
+}
+
+// Argument is a name/value pair passed to a field or directive.
+type Argument struct {
+	Name  string
+	Value Value
+	Loc   Location
+}
+
+// ArgumentList is an ordered list of arguments.
+type ArgumentList []*Argument
+
+// ForName returns the argument with the given name, or nil.
+func (l ArgumentList) ForName(name string) *Argument {
+	for _, arg := range l {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// Directive is an applied directive such as @include(if: $flag).
+type Directive struct {
+	Name      string
+	Arguments ArgumentList
+	Loc       Location
+}
+
+// DirectiveList is an ordered list of applied directives.
+type DirectiveList []*Directive
+
+// ForName returns the first directive with the given name, or nil.
+func (l DirectiveList) ForName(name string) *Directive {
+	for _, d := range l {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// Type is a type reference: a named type, a list, or a non-null wrapper.
+// Exactly one of NamedType and Elem is set.
+type Type struct {
+	NamedType string
+	Elem      *Type
+	NonNull   bool
+	Loc       Location
+}
+
+// NamedType returns a reference to the named type.
+func NamedType(name string) *Type {
+	return &Type{NamedType: name}
+}
+
+// ListType returns a list of elem.
+func ListType(elem *Type) *Type {
+	return &Type{Elem: elem}
+}
+
+// NonNullType returns a non-null copy of t.
+func NonNullType(t *Type) *Type {
+	c := *t
+	c.NonNull = true
+	return &c
+}
+
+// Name returns the innermost named type.
+func (t *Type) Name() string {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	return t.NamedType
+}
+
+// Nullable returns t without its non-null wrapper.
+func (t *Type) Nullable() *Type {
+	if !t.NonNull {
+		return t
+	}
+	c := *t
+	c.NonNull = false
+	return &c
+}
+
+// String formats the type as it appears in GraphQL source, e.g. "[ID!]!".
+func (t *Type) String() string {
+	var sb strings.Builder
+	t.write(&sb)
+	return sb.String()
+}
+
+func (t *Type) write(sb *strings.Builder) {
+	if t.Elem != nil {
+		sb.WriteByte('[')
+		t.Elem.write(sb)
+		sb.WriteByte(']')
+	} else {
+		sb.WriteString(t.NamedType)
+	}
+	if t.NonNull {
+		sb.WriteByte('!')
+	}
+}
+
+// Value is an input value literal.
+type Value interface {
+	Position() Location
+	isValue()
+}
+
+// Variable is a "$name" reference.
+type Variable struct {
+	Name string
+	Loc  Location
+}
+
+// IntValue is an integer literal; Value holds the raw digits.
+type IntValue struct {
+	Value string
+	Loc   Location
+}
+
+// FloatValue is a float literal; Value holds the raw text.
+type FloatValue struct {
+	Value string
+	Loc   Location
+}
+
+// StringValue is a string or block string literal.
+type StringValue struct {
+	Value string
+	Block bool
+	Loc   Location
+}
+
+// BooleanValue is true or false.
+type BooleanValue struct {
+	Value bool
+	Loc   Location
+}
+
+// NullValue is the null literal.
+type NullValue struct {
+	Loc Location
+}
+
+// EnumValue is an enum literal.
+type EnumValue struct {
+	Value string
+	Loc   Location
+}
+
+// ListValue is a "[...]" literal.
+type ListValue struct {
+	Values []Value
+	Loc    Location
+}
+
+// ObjectValue is a "{...}" input object literal.
+type ObjectValue struct {
+	Fields []*ObjectField
+	Loc    Location
+}
+
+// ObjectField is a field of an input object literal.
+type ObjectField struct {
+	Name  string
+	Value Value
+	Loc   Location
+}
+
+// =============================================================================
+// Type System Definitions
+// =============================================================================
+
+// TypeKind is the kind of a named type definition.
+type TypeKind string
+
+const (
+	Scalar      TypeKind = "SCALAR"
+	Object      TypeKind = "OBJECT"
+	Interface   TypeKind = "INTERFACE"
+	Union       TypeKind = "UNION"
+	Enum        TypeKind = "ENUM"
+	InputObject TypeKind = "INPUT_OBJECT"
+)
+
+// SchemaDefinition is a "schema { ... }" definition or extension.
+type SchemaDefinition struct {
+	Extend         bool
+	Description    string
+	Directives     DirectiveList
+	OperationTypes []*OperationTypeDefinition
+	Loc            Location
+}
+
+// OperationTypeDefinition maps an operation type to its root type name.
+type OperationTypeDefinition struct {
+	Operation OperationType
+	Type      string
+	Loc       Location
+}
+
+// TypeDefinition is a named type definition or extension. Which of the
+// member lists are populated depends on Kind.
+type TypeDefinition struct {
+	Kind        TypeKind
+	Extend      bool
+	Description string
+	Name        string
+	Interfaces  []string
+	Directives  DirectiveList
+	Fields      []*FieldDefinition
+	Types       []string
+	EnumValues  []*EnumValueDefinition
+	InputFields []*InputValueDefinition
+	Loc         Location
+}
+
+// FieldDefinition is a field of an object or interface type.
+type FieldDefinition struct {
+	Description string
+	Name        string
+	Arguments   []*InputValueDefinition
+	Type        *Type
+	Directives  DirectiveList
+	Loc         Location
+}
+
+// InputValueDefinition is an argument or input object field.
+type InputValueDefinition struct {
+	Description  string
+	Name         string
+	Type         *Type
+	DefaultValue Value
+	Directives   DirectiveList
+	Loc          Location
+}
+
+// EnumValueDefinition is a member of an enum type.
+type EnumValueDefinition struct {
+	Description string
+	Name        string
+	Directives  DirectiveList
+	Loc         Location
+}
+
+// DirectiveDefinition declares a directive.
+type DirectiveDefinition struct {
+	Description string
+	Name        string
+	Arguments   []*InputValueDefinition
+	Repeatable  bool
+	Locations   []string
+	Loc         Location
+}
+
+func (d *OperationDefinition) Position() Location { return d.Loc }
+func (d *FragmentDefinition) Position() Location  { return d.Loc }
+func (d *SchemaDefinition) Position() Location    { return d.Loc }
+func (d *TypeDefinition) Position() Location      { return d.Loc }
+func (d *DirectiveDefinition) Position() Location { return d.Loc }
+
+func (*OperationDefinition) isDefinition() {}
+func (*FragmentDefinition) isDefinition()  {}
+func (*SchemaDefinition) isDefinition()    {}
+func (*TypeDefinition) isDefinition()      {}
+func (*DirectiveDefinition) isDefinition() {}
+
+func (s *Field) Position() Location          { return s.Loc }
+func (s *FragmentSpread) Position() Location { return s.Loc }
+func (s *InlineFragment) Position() Location { return s.Loc }
+
+func (*Field) isSelection()          {}
+func (*FragmentSpread) isSelection() {}
+func (*InlineFragment) isSelection() {}
+
+func (v *Variable) Position() Location     { return v.Loc }
+func (v *IntValue) Position() Location     { return v.Loc }
+func (v *FloatValue) Position() Location   { return v.Loc }
+func (v *StringValue) Position() Location  { return v.Loc }
+func (v *BooleanValue) Position() Location { return v.Loc }
+func (v *NullValue) Position() Location    { return v.Loc }
+func (v *EnumValue) Position() Location    { return v.Loc }
+func (v *ListValue) Position() Location    { return v.Loc }
+func (v *ObjectValue) Position() Location  { return v.Loc }
+
+func (*Variable) isValue()     {}
+func (*IntValue) isValue()     {}
+func (*FloatValue) isValue()   {}
+func (*StringValue) isValue()  {}
+func (*BooleanValue) isValue() {}
+func (*NullValue) isValue()    {}
+func (*EnumValue) isValue()    {}
+func (*ListValue) isValue()    {}
+func (*ObjectValue) isValue()  {}
@@ -0,0 +1,566 @@
+// Package language implements the GraphQL lexer, parser, AST, and printer
+// shared by the bgql server, client, and tooling.
+package language
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Location is a 1-based line and column in a GraphQL source document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error describes a problem found at one or more locations of a document.
+type Error struct {
+	Message   string
+	Locations []Location
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if len(e.Locations) == 0 {
+		return e.Message
+	}
+	loc := e.Locations[0]
+	return fmt.Sprintf("%s (%d:%d)", e.Message, loc.Line, loc.Column)
+}
+
+// NewError creates an Error at the given locations.
+func NewError(locs []Location, format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: locs}
+}
+
+// ErrorList aggregates several errors found in one document.
+type ErrorList []*Error
+
+// Error implements the error interface, listing every error on its own line.
+func (l ErrorList) Error() string {
+	if len(l) == 1 {
+		return l[0].Error()
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d errors:", len(l))
+	for _, err := range l {
+		sb.WriteString("\n  - ")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the individual errors.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, err := range l {
+		errs[i] = err
+	}
+	return errs
+}
+
+// TokenKind identifies the kind of a lexical token.
+type TokenKind int
+
+const (
+	EOF TokenKind = iota
+	Bang
+	Dollar
+	Amp
+	ParenL
+	ParenR
+	Spread
+	Colon
+	Equals
+	At
+	BracketL
+	BracketR
+	BraceL
+	Pipe
+	BraceR
+	Name
+	Int
+	Float
+	String
+	BlockString
+)
+
+var tokenKindNames = [...]string{
+	EOF:         "<EOF>",
+	Bang:        "\"!\"",
+	Dollar:      "\"$\"",
+	Amp:         "\"&\"",
+	ParenL:      "\"(\"",
+	ParenR:      "\")\"",
+	Spread:      "\"...\"",
+	Colon:       "\":\"",
+	Equals:      "\"=\"",
+	At:          "\"@\"",
+	BracketL:    "\"[\"",
+	BracketR:    "\"]\"",
+	BraceL:      "\"{\"",
+	Pipe:        "\"|\"",
+	BraceR:      "\"}\"",
+	Name:        "Name",
+	Int:         "Int",
+	Float:       "Float",
+	String:      "String",
+	BlockString: "BlockString",
+}
+
+// String returns the token kind as it appears in error messages.
+func (k TokenKind) String() string {
+	if int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return "Unknown"
+}
+
+// Token is a lexical token. Start and End are byte offsets into the source,
+// so the raw text of any token is source[Start:End].
+type Token struct {
+	Kind  TokenKind
+	Value string
+	Start int
+	End   int
+	Loc   Location
+}
+
+func (t Token) describe() string {
+	switch t.Kind {
+	case Name, Int, Float:
+		return fmt.Sprintf("%s %q", t.Kind, t.Value)
+	default:
+		return t.Kind.String()
+	}
+}
+
+// Lexer splits a GraphQL document into tokens, skipping whitespace,
+// commas, and comments.
+type Lexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+// NewLexer creates a lexer over source.
+func NewLexer(source string) *Lexer {
+	l := &Lexer{src: source, line: 1}
+	if strings.HasPrefix(source, "\uFEFF") {
+		l.pos = len("\uFEFF")
+		l.lineStart = l.pos
+	}
+	return l
+}
+
+func (l *Lexer) location(pos int) Location {
+	return Location{Line: l.line, Column: utf8.RuneCountInString(l.src[l.lineStart:pos]) + 1}
+}
+
+func (l *Lexer) errorAt(pos int, format string, args ...any) *Error {
+	return NewError([]Location{l.location(pos)}, "Syntax Error: "+format, args...)
+}
+
+func (l *Lexer) newline(next int) {
+	l.line++
+	l.lineStart = next
+}
+
+// Next returns the next significant token.
+func (l *Lexer) Next() (Token, error) {
+	l.skipIgnored()
+
+	start := l.pos
+	tok := Token{Start: start, Loc: l.location(start)}
+	if start >= len(l.src) {
+		tok.Kind = EOF
+		tok.End = start
+		return tok, nil
+	}
+
+	single := func(kind TokenKind) (Token, error) {
+		l.pos++
+		tok.Kind = kind
+		tok.End = l.pos
+		tok.Value = l.src[start:l.pos]
+		return tok, nil
+	}
+
+	c := l.src[start]
+	switch c {
+	case '!':
+		return single(Bang)
+	case '$':
+		return single(Dollar)
+	case '&':
+		return single(Amp)
+	case '(':
+		return single(ParenL)
+	case ')':
+		return single(ParenR)
+	case ':':
+		return single(Colon)
+	case '=':
+		return single(Equals)
+	case '@':
+		return single(At)
+	case '[':
+		return single(BracketL)
+	case ']':
+		return single(BracketR)
+	case '{':
+		return single(BraceL)
+	case '|':
+		return single(Pipe)
+	case '}':
+		return single(BraceR)
+	case '.':
+		if strings.HasPrefix(l.src[start:], "...") {
+			l.pos += 3
+			tok.Kind = Spread
+			tok.End = l.pos
+			tok.Value = "..."
+			return tok, nil
+		}
+		return tok, l.errorAt(start, "Unexpected \".\".")
+	case '"':
+		if strings.HasPrefix(l.src[start:], `"""`) {
+			return l.readBlockString(tok)
+		}
+		return l.readString(tok)
+	}
+
+	if isNameStart(c) {
+		for l.pos < len(l.src) && isNameContinue(l.src[l.pos]) {
+			l.pos++
+		}
+		tok.Kind = Name
+		tok.End = l.pos
+		tok.Value = l.src[start:l.pos]
+		return tok, nil
+	}
+
+	if c == '-' || isDigit(c) {
+		return l.readNumber(tok)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[start:])
+	return tok, l.errorAt(start, "Unexpected character %q.", r)
+}
+
+func (l *Lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', ',':
+			l.pos++
+		case '\n':
+			l.pos++
+			l.newline(l.pos)
+		case '\r':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.pos++
+			}
+			l.newline(l.pos)
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.pos:], "\uFEFF") {
+				l.pos += len("\uFEFF")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (l *Lexer) readNumber(tok Token) (Token, error) {
+	start := l.pos
+	isFloat := false
+
+	if l.peek() == '-' {
+		l.pos++
+	}
+	if l.peek() == '0' {
+		l.pos++
+		if isDigit(l.peek()) {
+			return tok, l.errorAt(l.pos, "Invalid number, unexpected digit after 0: %q.", l.peek())
+		}
+	} else if err := l.readDigits(); err != nil {
+		return tok, err
+	}
+
+	if l.peek() == '.' {
+		isFloat = true
+		l.pos++
+		if err := l.readDigits(); err != nil {
+			return tok, err
+		}
+	}
+	if c := l.peek(); c == 'e' || c == 'E' {
+		isFloat = true
+		l.pos++
+		if c := l.peek(); c == '+' || c == '-' {
+			l.pos++
+		}
+		if err := l.readDigits(); err != nil {
+			return tok, err
+		}
+	}
+
+	if c := l.peek(); c == '.' || isNameStart(c) {
+		return tok, l.errorAt(l.pos, "Invalid number, expected digit but got: %q.", c)
+	}
+
+	tok.Kind = Int
+	if isFloat {
+		tok.Kind = Float
+	}
+	tok.End = l.pos
+	tok.Value = l.src[start:l.pos]
+	return tok, nil
+}
+
+func (l *Lexer) readDigits() error {
+	if !isDigit(l.peek()) {
+		if l.pos >= len(l.src) {
+			return l.errorAt(l.pos, "Invalid number, expected digit but got: <EOF>.")
+		}
+		return l.errorAt(l.pos, "Invalid number, expected digit but got: %q.", l.peek())
+	}
+	for isDigit(l.peek()) {
+		l.pos++
+	}
+	return nil
+}
+
+func (l *Lexer) peek() byte {
+	if l.pos < len(l.src) {
+		return l.src[l.pos]
+	}
+	return 0
+}
+
+func (l *Lexer) readString(tok Token) (Token, error) {
+	l.pos++ // opening quote
+	var sb strings.Builder
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			tok.Kind = String
+			tok.End = l.pos
+			tok.Value = sb.String()
+			return tok, nil
+		case c == '\n' || c == '\r':
+			return tok, l.errorAt(l.pos, "Unterminated string.")
+		case c == '\\':
+			if err := l.readEscape(&sb); err != nil {
+				return tok, err
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			if r < 0x20 && r != '\t' {
+				return tok, l.errorAt(l.pos, "Invalid character within String: %q.", r)
+			}
+			sb.WriteRune(r)
+			l.pos += size
+		}
+	}
+
+	return tok, l.errorAt(l.pos, "Unterminated string.")
+}
+
+func (l *Lexer) readEscape(sb *strings.Builder) error {
+	start := l.pos
+	l.pos++ // backslash
+	if l.pos >= len(l.src) {
+		return l.errorAt(start, "Unterminated string.")
+	}
+
+	c := l.src[l.pos]
+	l.pos++
+	switch c {
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case '/':
+		sb.WriteByte('/')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'u':
+		r, err := l.readUnicodeEscape(start)
+		if err != nil {
+			return err
+		}
+		sb.WriteRune(r)
+	default:
+		return l.errorAt(start, "Invalid character escape sequence: \"\\%c\".", c)
+	}
+	return nil
+}
+
+func (l *Lexer) readUnicodeEscape(start int) (rune, error) {
+	// Variable-width form: \u{1F600}
+	if l.peek() == '{' {
+		end := strings.IndexByte(l.src[l.pos:], '}')
+		if end < 0 {
+			return 0, l.errorAt(start, "Invalid Unicode escape sequence.")
+		}
+		v, err := strconv.ParseUint(l.src[l.pos+1:l.pos+end], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return 0, l.errorAt(start, "Invalid Unicode escape sequence: \"%s\".", l.src[start:l.pos+end+1])
+		}
+		l.pos += end + 1
+		return rune(v), nil
+	}
+
+	r, ok := l.readHex4()
+	if !ok {
+		return 0, l.errorAt(start, "Invalid Unicode escape sequence.")
+	}
+	// Combine UTF-16 surrogate pairs written as two escapes.
+	if r >= 0xD800 && r <= 0xDBFF && strings.HasPrefix(l.src[l.pos:], `\u`) {
+		save := l.pos
+		l.pos += 2
+		if lo, ok := l.readHex4(); ok && lo >= 0xDC00 && lo <= 0xDFFF {
+			return (r-0xD800)<<10 + (lo - 0xDC00) + 0x10000, nil
+		}
+		l.pos = save
+	}
+	if r >= 0xD800 && r <= 0xDFFF {
+		return 0, l.errorAt(start, "Invalid Unicode escape sequence: \"%s\".", l.src[start:l.pos])
+	}
+	return r, nil
+}
+
+func (l *Lexer) readHex4() (rune, bool) {
+	if l.pos+4 > len(l.src) {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	l.pos += 4
+	return rune(v), true
+}
+
+func (l *Lexer) readBlockString(tok Token) (Token, error) {
+	l.pos += 3
+	var raw strings.Builder
+
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.pos += 3
+			tok.Kind = BlockString
+			tok.End = l.pos
+			tok.Value = BlockStringValue(raw.String())
+			return tok, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			raw.WriteString(`"""`)
+			l.pos += 4
+		default:
+			c := l.src[l.pos]
+			raw.WriteByte(c)
+			l.pos++
+			if c == '\n' {
+				l.newline(l.pos)
+			} else if c == '\r' {
+				if l.peek() == '\n' {
+					raw.WriteByte('\n')
+					l.pos++
+				}
+				l.newline(l.pos)
+			}
+		}
+	}
+
+	return tok, l.errorAt(l.pos, "Unterminated string.")
+}
+
+// BlockStringValue implements the spec's BlockStringValue algorithm:
+// it removes the common indentation and leading/trailing blank lines.
+func BlockStringValue(raw string) string {
+	lines := splitLines(raw)
+
+	commonIndent := -1
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+		indent := leadingWhitespace(line)
+		if indent == len(line) {
+			continue
+		}
+		if commonIndent < 0 || indent < commonIndent {
+			commonIndent = indent
+		}
+	}
+
+	if commonIndent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= commonIndent {
+				lines[i] = lines[i][commonIndent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+func leadingWhitespace(s string) int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+func isBlank(s string) bool {
+	return leadingWhitespace(s) == len(s)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
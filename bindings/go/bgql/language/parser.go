@@ -0,0 +1,654 @@
+package language
+
+// Parse parses a GraphQL document. The returned error is an *Error
+// carrying the location of the first syntax error.
+func Parse(source string) (doc *Document, err error) {
+	p := &parser{lexer: NewLexer(source)}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*Error); ok {
+				doc, err = nil, e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p.advance()
+	return p.parseDocument(), nil
+}
+
+// ParseValue parses a single input value literal such as `{a: [1, 2]}`.
+func ParseValue(source string) (v Value, err error) {
+	p := &parser{lexer: NewLexer(source)}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*Error); ok {
+				v, err = nil, e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p.advance()
+	v = p.parseValue(false)
+	p.expect(EOF)
+	return v, nil
+}
+
+// parser is a recursive-descent parser. Syntax errors abort parsing by
+// panicking with an *Error that Parse recovers.
+type parser struct {
+	lexer *Lexer
+	tok   Token
+}
+
+func (p *parser) advance() {
+	tok, err := p.lexer.Next()
+	if err != nil {
+		panic(err)
+	}
+	p.tok = tok
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(NewError([]Location{p.tok.Loc}, "Syntax Error: "+format, args...))
+}
+
+func (p *parser) unexpected() {
+	p.fail("Unexpected %s.", p.tok.describe())
+}
+
+func (p *parser) peek(kind TokenKind) bool {
+	return p.tok.Kind == kind
+}
+
+func (p *parser) peekKeyword(value string) bool {
+	return p.tok.Kind == Name && p.tok.Value == value
+}
+
+func (p *parser) expect(kind TokenKind) Token {
+	tok := p.tok
+	if tok.Kind != kind {
+		p.fail("Expected %s, found %s.", kind, tok.describe())
+	}
+	p.advance()
+	return tok
+}
+
+func (p *parser) skip(kind TokenKind) bool {
+	if p.tok.Kind == kind {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(value string) {
+	if !p.peekKeyword(value) {
+		p.fail("Expected %q, found %s.", value, p.tok.describe())
+	}
+	p.advance()
+}
+
+func (p *parser) parseName() string {
+	return p.expect(Name).Value
+}
+
+func (p *parser) parseDocument() *Document {
+	doc := &Document{}
+	for {
+		doc.Definitions = append(doc.Definitions, p.parseDefinition())
+		if p.peek(EOF) {
+			return doc
+		}
+	}
+}
+
+func (p *parser) parseDefinition() Definition {
+	if p.peek(BraceL) {
+		return p.parseOperationDefinition()
+	}
+
+	hasDescription := p.peek(String) || p.peek(BlockString)
+	keyword := p.tok
+	if hasDescription {
+		// Look past the description to find the definition keyword.
+		saveLexer, saveTok := *p.lexer, p.tok
+		p.advance()
+		keyword = p.tok
+		*p.lexer, p.tok = saveLexer, saveTok
+	}
+
+	if keyword.Kind == Name {
+		switch keyword.Value {
+		case "query", "mutation", "subscription":
+			if !hasDescription {
+				return p.parseOperationDefinition()
+			}
+		case "fragment":
+			if !hasDescription {
+				return p.parseFragmentDefinition()
+			}
+		case "schema", "scalar", "type", "interface", "union", "enum", "input", "directive":
+			return p.parseTypeSystemDefinition()
+		case "extend":
+			if !hasDescription {
+				return p.parseTypeSystemExtension()
+			}
+		}
+	}
+
+	p.unexpected()
+	return nil
+}
+
+// =============================================================================
+// Executable Definitions
+// =============================================================================
+
+func (p *parser) parseOperationDefinition() *OperationDefinition {
+	loc := p.tok.Loc
+	if p.peek(BraceL) {
+		return &OperationDefinition{
+			Operation:    Query,
+			SelectionSet: p.parseSelectionSet(),
+			Loc:          loc,
+		}
+	}
+
+	op := &OperationDefinition{Operation: p.parseOperationType(), Loc: loc}
+	if p.peek(Name) {
+		op.Name = p.parseName()
+	}
+	op.VariableDefinitions = p.parseVariableDefinitions()
+	op.Directives = p.parseDirectives(false)
+	op.SelectionSet = p.parseSelectionSet()
+	return op
+}
+
+func (p *parser) parseOperationType() OperationType {
+	tok := p.expect(Name)
+	switch tok.Value {
+	case "query":
+		return Query
+	case "mutation":
+		return Mutation
+	case "subscription":
+		return Subscription
+	}
+	panic(NewError([]Location{tok.Loc}, "Syntax Error: Unexpected %s.", tok.describe()))
+}
+
+func (p *parser) parseVariableDefinitions() []*VariableDefinition {
+	if !p.skip(ParenL) {
+		return nil
+	}
+	var defs []*VariableDefinition
+	for !p.skip(ParenR) {
+		loc := p.tok.Loc
+		p.expect(Dollar)
+		def := &VariableDefinition{Variable: p.parseName(), Loc: loc}
+		p.expect(Colon)
+		def.Type = p.parseTypeReference()
+		if p.skip(Equals) {
+			def.DefaultValue = p.parseValue(true)
+		}
+		def.Directives = p.parseDirectives(true)
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+func (p *parser) parseSelectionSet() SelectionSet {
+	p.expect(BraceL)
+	var set SelectionSet
+	for {
+		set = append(set, p.parseSelection())
+		if p.skip(BraceR) {
+			return set
+		}
+	}
+}
+
+func (p *parser) parseSelection() Selection {
+	if p.peek(Spread) {
+		return p.parseFragment()
+	}
+	return p.parseField()
+}
+
+func (p *parser) parseField() *Field {
+	loc := p.tok.Loc
+	field := &Field{Loc: loc}
+
+	name := p.parseName()
+	if p.skip(Colon) {
+		field.Alias = name
+		field.Name = p.parseName()
+	} else {
+		field.Name = name
+	}
+
+	field.Arguments = p.parseArguments(false)
+	field.Directives = p.parseDirectives(false)
+	if p.peek(BraceL) {
+		field.SelectionSet = p.parseSelectionSet()
+	}
+	return field
+}
+
+func (p *parser) parseArguments(isConst bool) ArgumentList {
+	if !p.skip(ParenL) {
+		return nil
+	}
+	var args ArgumentList
+	for {
+		loc := p.tok.Loc
+		arg := &Argument{Name: p.parseName(), Loc: loc}
+		p.expect(Colon)
+		arg.Value = p.parseValue(isConst)
+		args = append(args, arg)
+		if p.skip(ParenR) {
+			return args
+		}
+	}
+}
+
+func (p *parser) parseFragment() Selection {
+	loc := p.tok.Loc
+	p.expect(Spread)
+
+	hasTypeCondition := p.peekKeyword("on")
+	if !hasTypeCondition && p.peek(Name) {
+		return &FragmentSpread{
+			Name:       p.parseName(),
+			Directives: p.parseDirectives(false),
+			Loc:        loc,
+		}
+	}
+
+	frag := &InlineFragment{Loc: loc}
+	if hasTypeCondition {
+		p.advance()
+		frag.TypeCondition = p.parseName()
+	}
+	frag.Directives = p.parseDirectives(false)
+	frag.SelectionSet = p.parseSelectionSet()
+	return frag
+}
+
+func (p *parser) parseFragmentDefinition() *FragmentDefinition {
+	loc := p.tok.Loc
+	p.expectKeyword("fragment")
+	if p.peekKeyword("on") {
+		p.unexpected()
+	}
+	frag := &FragmentDefinition{Name: p.parseName(), Loc: loc}
+	p.expectKeyword("on")
+	frag.TypeCondition = p.parseName()
+	frag.Directives = p.parseDirectives(false)
+	frag.SelectionSet = p.parseSelectionSet()
+	return frag
+}
+
+// =============================================================================
+// Values
+// =============================================================================
+
+func (p *parser) parseValue(isConst bool) Value {
+	tok := p.tok
+	loc := tok.Loc
+
+	switch tok.Kind {
+	case BracketL:
+		p.advance()
+		list := &ListValue{Loc: loc}
+		for !p.skip(BracketR) {
+			list.Values = append(list.Values, p.parseValue(isConst))
+		}
+		return list
+	case BraceL:
+		p.advance()
+		obj := &ObjectValue{Loc: loc}
+		for !p.skip(BraceR) {
+			fieldLoc := p.tok.Loc
+			field := &ObjectField{Name: p.parseName(), Loc: fieldLoc}
+			p.expect(Colon)
+			field.Value = p.parseValue(isConst)
+			obj.Fields = append(obj.Fields, field)
+		}
+		return obj
+	case Int:
+		p.advance()
+		return &IntValue{Value: tok.Value, Loc: loc}
+	case Float:
+		p.advance()
+		return &FloatValue{Value: tok.Value, Loc: loc}
+	case String, BlockString:
+		p.advance()
+		return &StringValue{Value: tok.Value, Block: tok.Kind == BlockString, Loc: loc}
+	case Name:
+		p.advance()
+		switch tok.Value {
+		case "true":
+			return &BooleanValue{Value: true, Loc: loc}
+		case "false":
+			return &BooleanValue{Value: false, Loc: loc}
+		case "null":
+			return &NullValue{Loc: loc}
+		}
+		return &EnumValue{Value: tok.Value, Loc: loc}
+	case Dollar:
+		if isConst {
+			p.advance()
+			if p.peek(Name) {
+				p.fail("Unexpected variable \"$%s\" in constant value.", p.tok.Value)
+			}
+			p.unexpected()
+		}
+		p.advance()
+		return &Variable{Name: p.parseName(), Loc: loc}
+	}
+
+	p.unexpected()
+	return nil
+}
+
+func (p *parser) parseDirectives(isConst bool) DirectiveList {
+	var list DirectiveList
+	for p.peek(At) {
+		loc := p.tok.Loc
+		p.advance()
+		list = append(list, &Directive{
+			Name:      p.parseName(),
+			Arguments: p.parseArguments(isConst),
+			Loc:       loc,
+		})
+	}
+	return list
+}
+
+func (p *parser) parseTypeReference() *Type {
+	loc := p.tok.Loc
+	var t *Type
+	if p.skip(BracketL) {
+		elem := p.parseTypeReference()
+		p.expect(BracketR)
+		t = &Type{Elem: elem, Loc: loc}
+	} else {
+		t = &Type{NamedType: p.parseName(), Loc: loc}
+	}
+	if p.skip(Bang) {
+		t.NonNull = true
+	}
+	return t
+}
+
+// =============================================================================
+// Type System Definitions
+// =============================================================================
+
+func (p *parser) parseDescription() string {
+	if p.peek(String) || p.peek(BlockString) {
+		desc := p.tok.Value
+		p.advance()
+		return desc
+	}
+	return ""
+}
+
+func (p *parser) parseTypeSystemDefinition() Definition {
+	loc := p.tok.Loc
+	description := p.parseDescription()
+
+	keyword := p.tok
+	if keyword.Kind != Name {
+		p.unexpected()
+	}
+
+	switch keyword.Value {
+	case "schema":
+		def := p.parseSchemaDefinition(false)
+		def.Description = description
+		def.Loc = loc
+		return def
+	case "directive":
+		def := p.parseDirectiveDefinition()
+		def.Description = description
+		def.Loc = loc
+		return def
+	}
+
+	def := p.parseTypeDefinition(false)
+	def.Description = description
+	def.Loc = loc
+	return def
+}
+
+func (p *parser) parseTypeSystemExtension() Definition {
+	loc := p.tok.Loc
+	p.expectKeyword("extend")
+
+	if p.peekKeyword("schema") {
+		def := p.parseSchemaDefinition(true)
+		def.Loc = loc
+		if len(def.Directives) == 0 && len(def.OperationTypes) == 0 {
+			p.unexpected()
+		}
+		return def
+	}
+
+	def := p.parseTypeDefinition(true)
+	def.Loc = loc
+	if len(def.Interfaces) == 0 && len(def.Directives) == 0 && len(def.Fields) == 0 &&
+		len(def.Types) == 0 && len(def.EnumValues) == 0 && len(def.InputFields) == 0 {
+		p.unexpected()
+	}
+	return def
+}
+
+func (p *parser) parseSchemaDefinition(extend bool) *SchemaDefinition {
+	p.expectKeyword("schema")
+	def := &SchemaDefinition{Extend: extend, Directives: p.parseDirectives(true)}
+
+	if extend && !p.peek(BraceL) {
+		return def
+	}
+
+	p.expect(BraceL)
+	for !p.skip(BraceR) {
+		loc := p.tok.Loc
+		op := p.parseOperationType()
+		p.expect(Colon)
+		def.OperationTypes = append(def.OperationTypes, &OperationTypeDefinition{
+			Operation: op,
+			Type:      p.parseName(),
+			Loc:       loc,
+		})
+	}
+	return def
+}
+
+func (p *parser) parseTypeDefinition(extend bool) *TypeDefinition {
+	keyword := p.expect(Name)
+	def := &TypeDefinition{Extend: extend}
+
+	switch keyword.Value {
+	case "scalar":
+		def.Kind = Scalar
+		def.Name = p.parseName()
+		def.Directives = p.parseDirectives(true)
+	case "type", "interface":
+		def.Kind = Object
+		if keyword.Value == "interface" {
+			def.Kind = Interface
+		}
+		def.Name = p.parseName()
+		def.Interfaces = p.parseImplementsInterfaces()
+		def.Directives = p.parseDirectives(true)
+		def.Fields = p.parseFieldsDefinition()
+	case "union":
+		def.Kind = Union
+		def.Name = p.parseName()
+		def.Directives = p.parseDirectives(true)
+		if p.skip(Equals) {
+			p.skip(Pipe)
+			def.Types = append(def.Types, p.parseName())
+			for p.skip(Pipe) {
+				def.Types = append(def.Types, p.parseName())
+			}
+		}
+	case "enum":
+		def.Kind = Enum
+		def.Name = p.parseName()
+		def.Directives = p.parseDirectives(true)
+		def.EnumValues = p.parseEnumValuesDefinition()
+	case "input":
+		def.Kind = InputObject
+		def.Name = p.parseName()
+		def.Directives = p.parseDirectives(true)
+		def.InputFields = p.parseInputFieldsDefinition()
+	default:
+		panic(NewError([]Location{keyword.Loc}, "Syntax Error: Unexpected %s.", keyword.describe()))
+	}
+
+	return def
+}
+
+func (p *parser) parseImplementsInterfaces() []string {
+	if !p.peekKeyword("implements") {
+		return nil
+	}
+	p.advance()
+	p.skip(Amp)
+	names := []string{p.parseName()}
+	for p.skip(Amp) {
+		names = append(names, p.parseName())
+	}
+	return names
+}
+
+func (p *parser) parseFieldsDefinition() []*FieldDefinition {
+	if !p.skip(BraceL) {
+		return nil
+	}
+	var fields []*FieldDefinition
+	for !p.skip(BraceR) {
+		loc := p.tok.Loc
+		field := &FieldDefinition{Description: p.parseDescription(), Loc: loc}
+		field.Name = p.parseName()
+		field.Arguments = p.parseArgumentDefs()
+		p.expect(Colon)
+		field.Type = p.parseTypeReference()
+		field.Directives = p.parseDirectives(true)
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func (p *parser) parseArgumentDefs() []*InputValueDefinition {
+	if !p.skip(ParenL) {
+		return nil
+	}
+	var args []*InputValueDefinition
+	for !p.skip(ParenR) {
+		args = append(args, p.parseInputValueDef())
+	}
+	return args
+}
+
+func (p *parser) parseInputValueDef() *InputValueDefinition {
+	loc := p.tok.Loc
+	def := &InputValueDefinition{Description: p.parseDescription(), Loc: loc}
+	def.Name = p.parseName()
+	p.expect(Colon)
+	def.Type = p.parseTypeReference()
+	if p.skip(Equals) {
+		def.DefaultValue = p.parseValue(true)
+	}
+	def.Directives = p.parseDirectives(true)
+	return def
+}
+
+func (p *parser) parseEnumValuesDefinition() []*EnumValueDefinition {
+	if !p.skip(BraceL) {
+		return nil
+	}
+	var values []*EnumValueDefinition
+	for !p.skip(BraceR) {
+		loc := p.tok.Loc
+		def := &EnumValueDefinition{Description: p.parseDescription(), Loc: loc}
+		tok := p.expect(Name)
+		switch tok.Value {
+		case "true", "false", "null":
+			panic(NewError([]Location{tok.Loc}, "Syntax Error: %s is reserved and cannot be used for an enum value.", tok.Value))
+		}
+		def.Name = tok.Value
+		def.Directives = p.parseDirectives(true)
+		values = append(values, def)
+	}
+	return values
+}
+
+func (p *parser) parseInputFieldsDefinition() []*InputValueDefinition {
+	if !p.skip(BraceL) {
+		return nil
+	}
+	var fields []*InputValueDefinition
+	for !p.skip(BraceR) {
+		fields = append(fields, p.parseInputValueDef())
+	}
+	return fields
+}
+
+func (p *parser) parseDirectiveDefinition() *DirectiveDefinition {
+	p.expectKeyword("directive")
+	p.expect(At)
+	def := &DirectiveDefinition{Name: p.parseName()}
+	def.Arguments = p.parseArgumentDefs()
+	if p.peekKeyword("repeatable") {
+		p.advance()
+		def.Repeatable = true
+	}
+	p.expectKeyword("on")
+
+	p.skip(Pipe)
+	def.Locations = append(def.Locations, p.parseDirectiveLocation())
+	for p.skip(Pipe) {
+		def.Locations = append(def.Locations, p.parseDirectiveLocation())
+	}
+	return def
+}
+
+func (p *parser) parseDirectiveLocation() string {
+	tok := p.expect(Name)
+	if !directiveLocations[tok.Value] {
+		panic(NewError([]Location{tok.Loc}, "Syntax Error: Unexpected %s.", tok.describe()))
+	}
+	return tok.Value
+}
+
+// directiveLocations lists the valid executable and type system directive locations.
+var directiveLocations = map[string]bool{
+	"QUERY":                  true,
+	"MUTATION":               true,
+	"SUBSCRIPTION":           true,
+	"FIELD":                  true,
+	"FRAGMENT_DEFINITION":    true,
+	"FRAGMENT_SPREAD":        true,
+	"INLINE_FRAGMENT":        true,
+	"VARIABLE_DEFINITION":    true,
+	"SCHEMA":                 true,
+	"SCALAR":                 true,
+	"OBJECT":                 true,
+	"FIELD_DEFINITION":       true,
+	"ARGUMENT_DEFINITION":    true,
+	"INTERFACE":              true,
+	"UNION":                  true,
+	"ENUM":                   true,
+	"ENUM_VALUE":             true,
+	"INPUT_OBJECT":           true,
+	"INPUT_FIELD_DEFINITION": true,
+}
@@ -0,0 +1,364 @@
+package language
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Print formats an AST node as GraphQL source using the canonical
+// two-space layout. It accepts a *Document, any Definition, Selection,
+// SelectionSet, Value, or *Type.
+func Print(node any) string {
+	switch n := node.(type) {
+	case *Document:
+		parts := make([]string, len(n.Definitions))
+		for i, def := range n.Definitions {
+			parts[i] = Print(def)
+		}
+		return strings.Join(parts, "\n\n")
+	case *OperationDefinition:
+		return printOperation(n)
+	case *FragmentDefinition:
+		return "fragment " + n.Name + " on " + n.TypeCondition +
+			wrap(" ", printDirectives(n.Directives), "") + " " + printSelectionSet(n.SelectionSet)
+	case SelectionSet:
+		return printSelectionSet(n)
+	case Selection:
+		return printSelection(n)
+	case Value:
+		return printValue(n)
+	case *Type:
+		return n.String()
+	case *SchemaDefinition:
+		return printSchemaDefinition(n)
+	case *TypeDefinition:
+		return printTypeDefinition(n)
+	case *DirectiveDefinition:
+		return printDirectiveDefinition(n)
+	case *Directive:
+		return printDirective(n)
+	}
+	panic(fmt.Sprintf("language: cannot print %T", node))
+}
+
+func printOperation(op *OperationDefinition) string {
+	selections := printSelectionSet(op.SelectionSet)
+	if op.Operation == Query && op.Name == "" && len(op.VariableDefinitions) == 0 && len(op.Directives) == 0 {
+		return selections
+	}
+
+	var vars []string
+	for _, v := range op.VariableDefinitions {
+		s := "$" + v.Variable + ": " + v.Type.String()
+		if v.DefaultValue != nil {
+			s += " = " + printValue(v.DefaultValue)
+		}
+		s += wrap(" ", printDirectives(v.Directives), "")
+		vars = append(vars, s)
+	}
+
+	head := string(op.Operation) + wrap(" ", op.Name, "")
+	if len(vars) > 0 {
+		head += "(" + strings.Join(vars, ", ") + ")"
+	}
+	head += wrap(" ", printDirectives(op.Directives), "")
+	return head + " " + selections
+}
+
+func printSelectionSet(set SelectionSet) string {
+	if len(set) == 0 {
+		return ""
+	}
+	lines := make([]string, len(set))
+	for i, sel := range set {
+		lines[i] = printSelection(sel)
+	}
+	return block(lines)
+}
+
+func printSelection(sel Selection) string {
+	switch s := sel.(type) {
+	case *Field:
+		out := wrap("", s.Alias, ": ") + s.Name + printArguments(s.Arguments) +
+			wrap(" ", printDirectives(s.Directives), "")
+		if len(s.SelectionSet) > 0 {
+			out += " " + printSelectionSet(s.SelectionSet)
+		}
+		return out
+	case *FragmentSpread:
+		return "..." + s.Name + wrap(" ", printDirectives(s.Directives), "")
+	case *InlineFragment:
+		return "..." + wrap(" on ", s.TypeCondition, "") +
+			wrap(" ", printDirectives(s.Directives), "") + " " + printSelectionSet(s.SelectionSet)
+	}
+	panic(fmt.Sprintf("language: cannot print selection %T", sel))
+}
+
+func printArguments(args ArgumentList) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Name + ": " + printValue(arg.Value)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func printDirective(d *Directive) string {
+	return "@" + d.Name + printArguments(d.Arguments)
+}
+
+func printDirectives(list DirectiveList) string {
+	parts := make([]string, len(list))
+	for i, d := range list {
+		parts[i] = printDirective(d)
+	}
+	return strings.Join(parts, " ")
+}
+
+func printValue(v Value) string {
+	switch v := v.(type) {
+	case *Variable:
+		return "$" + v.Name
+	case *IntValue:
+		return v.Value
+	case *FloatValue:
+		return v.Value
+	case *StringValue:
+		if v.Block {
+			return PrintBlockString(v.Value)
+		}
+		return PrintString(v.Value)
+	case *BooleanValue:
+		if v.Value {
+			return "true"
+		}
+		return "false"
+	case *NullValue:
+		return "null"
+	case *EnumValue:
+		return v.Value
+	case *ListValue:
+		parts := make([]string, len(v.Values))
+		for i, item := range v.Values {
+			parts[i] = printValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *ObjectValue:
+		parts := make([]string, len(v.Fields))
+		for i, f := range v.Fields {
+			parts[i] = f.Name + ": " + printValue(f.Value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	panic(fmt.Sprintf("language: cannot print value %T", v))
+}
+
+// =============================================================================
+// Type System
+// =============================================================================
+
+func printSchemaDefinition(def *SchemaDefinition) string {
+	ops := make([]string, len(def.OperationTypes))
+	for i, op := range def.OperationTypes {
+		ops[i] = string(op.Operation) + ": " + op.Type
+	}
+	out := extendPrefix(def.Extend) + "schema" + wrap(" ", printDirectives(def.Directives), "")
+	if len(ops) > 0 {
+		out += " " + block(ops)
+	}
+	return printDescription(def.Description, true) + out
+}
+
+func printTypeDefinition(def *TypeDefinition) string {
+	head := extendPrefix(def.Extend)
+	switch def.Kind {
+	case Scalar:
+		head += "scalar " + def.Name
+	case Object:
+		head += "type " + def.Name
+	case Interface:
+		head += "interface " + def.Name
+	case Union:
+		head += "union " + def.Name
+	case Enum:
+		head += "enum " + def.Name
+	case InputObject:
+		head += "input " + def.Name
+	}
+	if len(def.Interfaces) > 0 {
+		head += " implements " + strings.Join(def.Interfaces, " & ")
+	}
+	head += wrap(" ", printDirectives(def.Directives), "")
+
+	var body []string
+	switch def.Kind {
+	case Object, Interface:
+		for i, f := range def.Fields {
+			body = append(body, printDescription(f.Description, i == 0)+PrintFieldDefinition(f))
+		}
+	case Union:
+		if len(def.Types) > 0 {
+			head += " = " + strings.Join(def.Types, " | ")
+		}
+	case Enum:
+		for i, v := range def.EnumValues {
+			body = append(body, printDescription(v.Description, i == 0)+
+				v.Name+wrap(" ", printDirectives(v.Directives), ""))
+		}
+	case InputObject:
+		for i, f := range def.InputFields {
+			body = append(body, printDescription(f.Description, i == 0)+PrintInputValueDefinition(f))
+		}
+	}
+
+	out := printDescription(def.Description, true) + head
+	if len(body) > 0 {
+		out += " " + block(body)
+	}
+	return out
+}
+
+// PrintFieldDefinition formats a field definition without its description.
+func PrintFieldDefinition(f *FieldDefinition) string {
+	return f.Name + printArgumentDefs(f.Arguments) + ": " + f.Type.String() +
+		wrap(" ", printDirectives(f.Directives), "")
+}
+
+// PrintInputValueDefinition formats an argument or input field without its description.
+func PrintInputValueDefinition(v *InputValueDefinition) string {
+	out := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != nil {
+		out += " = " + printValue(v.DefaultValue)
+	}
+	return out + wrap(" ", printDirectives(v.Directives), "")
+}
+
+func printArgumentDefs(args []*InputValueDefinition) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	multiline := false
+	for _, arg := range args {
+		if arg.Description != "" {
+			multiline = true
+			break
+		}
+	}
+
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = PrintInputValueDefinition(arg)
+	}
+	if !multiline {
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+
+	lines := make([]string, len(args))
+	for i, arg := range args {
+		lines[i] = indentLines(printDescription(arg.Description, i == 0) + parts[i])
+	}
+	return "(\n" + strings.Join(lines, "\n") + "\n)"
+}
+
+func printDirectiveDefinition(def *DirectiveDefinition) string {
+	out := printDescription(def.Description, true) + "directive @" + def.Name +
+		printArgumentDefs(def.Arguments)
+	if def.Repeatable {
+		out += " repeatable"
+	}
+	return out + " on " + strings.Join(def.Locations, " | ")
+}
+
+// printDescription formats a description followed by a newline. Members
+// other than the first in their block get a separating blank line.
+func printDescription(desc string, first bool) string {
+	if desc == "" {
+		return ""
+	}
+	prefix := ""
+	if !first {
+		prefix = "\n"
+	}
+	return prefix + PrintBlockString(desc) + "\n"
+}
+
+// PrintString formats s as a quoted GraphQL string literal.
+func PrintString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || (r >= 0x7F && r <= 0x9F) {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// PrintBlockString formats s as a """block string""".
+func PrintBlockString(s string) string {
+	escaped := strings.ReplaceAll(s, `"""`, `\"""`)
+	singleLine := !strings.ContainsAny(s, "\n\r")
+	if singleLine && !strings.HasSuffix(s, `"`) && !strings.HasSuffix(s, `\`) &&
+		!strings.HasPrefix(s, " ") && !strings.HasPrefix(s, "\t") {
+		return `"""` + escaped + `"""`
+	}
+	return "\"\"\"\n" + escaped + "\n\"\"\""
+}
+
+func extendPrefix(extend bool) string {
+	if extend {
+		return "extend "
+	}
+	return ""
+}
+
+// wrap returns start+s+end, or "" when s is empty.
+func wrap(start, s, end string) string {
+	if s == "" {
+		return ""
+	}
+	return start + s + end
+}
+
+// block formats lines as an indented "{ }" block.
+func block(lines []string) string {
+	indented := make([]string, len(lines))
+	for i, line := range lines {
+		indented[i] = indentLines(line)
+	}
+	return "{\n" + strings.Join(indented, "\n") + "\n}"
+}
+
+// indentLines indents every non-empty line of s by two spaces.
+func indentLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
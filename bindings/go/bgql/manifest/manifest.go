@@ -0,0 +1,105 @@
+// Package manifest reads and writes persisted operation manifests: the
+// trusted documents of an application by operation name, each with the
+// hash that clients send in place of its text. bgql-gen writes them, the
+// client sends the hashes, and the server executes the documents they
+// name.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Version is the version of the manifest format.
+const Version = 1
+
+// Manifest is a set of persisted operations.
+type Manifest struct {
+	Version int `json:"version"`
+	// Operations are keyed by operation name.
+	Operations map[string]Operation `json:"operations"`
+}
+
+// Operation is a persisted operation.
+type Operation struct {
+	// Hash is the hex SHA-256 of Document.
+	Hash     string `json:"hash"`
+	Document string `json:"document"`
+}
+
+// Hash returns the hex SHA-256 of document, which identifies it in a
+// manifest and in persisted query requests.
+func Hash(document string) string {
+	sum := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(sum[:])
+}
+
+// New creates an empty manifest.
+func New() *Manifest {
+	return &Manifest{Version: Version, Operations: make(map[string]Operation)}
+}
+
+// Add adds the document of the operation named name. It fails if another
+// document was added with that name.
+func (m *Manifest) Add(name, document string) error {
+	if op, ok := m.Operations[name]; ok && op.Document != document {
+		return fmt.Errorf("manifest: operation %q is already persisted with another document", name)
+	}
+	if m.Operations == nil {
+		m.Operations = make(map[string]Operation)
+	}
+	m.Operations[name] = Operation{Hash: Hash(document), Document: document}
+	return nil
+}
+
+// Documents returns the documents of the manifest by hash.
+func (m *Manifest) Documents() map[string]string {
+	documents := make(map[string]string, len(m.Operations))
+	for _, op := range m.Operations {
+		documents[op.Hash] = op.Document
+	}
+	return documents
+}
+
+// MarshalIndent encodes the manifest as indented JSON, with operations in
+// name order.
+func (m *Manifest) MarshalIndent() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Parse decodes a manifest, checking its version and that each hash is
+// that of its document.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("manifest: unsupported version %d", m.Version)
+	}
+	for name, op := range m.Operations {
+		if op.Hash != Hash(op.Document) {
+			return nil, fmt.Errorf("manifest: hash of operation %q does not match its document", name)
+		}
+	}
+	if m.Operations == nil {
+		m.Operations = make(map[string]Operation)
+	}
+	return &m, nil
+}
+
+// ReadFile reads the manifest in the named file.
+func ReadFile(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
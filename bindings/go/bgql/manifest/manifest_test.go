@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	m := New()
+	if err := m.Add("GetUser", "query GetUser { user { id } }"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("GetUser", "query GetUser { user { id } }"); err != nil {
+		t.Errorf("adding the same document again: %v", err)
+	}
+	if err := m.Add("GetUser", "query GetUser { user { name } }"); err == nil {
+		t.Error("adding another document with the same name succeeded")
+	}
+	m.Add("SetName", "mutation SetName { setName }")
+
+	data, err := m.MarshalIndent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Operations) != 2 || parsed.Operations["GetUser"] != m.Operations["GetUser"] {
+		t.Errorf("round trip = %+v, want %+v", parsed, m)
+	}
+	hash := Hash("query GetUser { user { id } }")
+	if got := parsed.Documents()[hash]; got != "query GetUser { user { id } }" {
+		t.Errorf("Documents()[%s] = %q", hash, got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"empty", `{"version":1}`, ""},
+		{"valid", `{"version":1,"operations":{"A":{"hash":"` + Hash("{ a }") + `","document":"{ a }"}}}`, ""},
+		{"wrong hash", `{"version":1,"operations":{"A":{"hash":"00","document":"{ a }"}}}`, `hash of operation "A"`},
+		{"unknown version", `{"version":2}`, "unsupported version 2"},
+		{"not json", `operations`, "manifest:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil || m.Operations == nil {
+					t.Errorf("Parse() = %v, %v", m, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validated represents either a valid value or every error found while
+// validating it. Unlike Result, combining Validated values accumulates
+// errors instead of stopping at the first one.
+type Validated[T any] struct {
+	value T
+	errs  []error
+}
+
+// Valid creates a valid Validated with the given value.
+func Valid[T any](value T) Validated[T] {
+	return Validated[T]{value: value}
+}
+
+// Invalid creates an invalid Validated with the given errors.
+func Invalid[T any](errs ...error) Validated[T] {
+	if len(errs) == 0 {
+		errs = []error{errors.New("invalid")}
+	}
+	return Validated[T]{errs: errs}
+}
+
+// IsValid returns true if there are no errors.
+func (v Validated[T]) IsValid() bool {
+	return len(v.errs) == 0
+}
+
+// Errors returns all accumulated errors.
+func (v Validated[T]) Errors() []error {
+	return v.errs
+}
+
+// Err returns the errors joined into one, or nil if valid.
+func (v Validated[T]) Err() error {
+	return errors.Join(v.errs...)
+}
+
+// Value returns the value and a boolean indicating validity.
+func (v Validated[T]) Value() (T, bool) {
+	return v.value, v.IsValid()
+}
+
+// ToResult converts to a Result, joining all errors.
+func (v Validated[T]) ToResult() Result[T] {
+	if v.IsValid() {
+		return Ok(v.value)
+	}
+	return Err[T](v.Err())
+}
+
+// FromResult converts a Result to a Validated.
+func FromResult[T any](r Result[T]) Validated[T] {
+	if r.ok {
+		return Valid(r.value)
+	}
+	return Invalid[T](r.err)
+}
+
+// MapValidated transforms the value if valid, passes errors through otherwise.
+func MapValidated[T, U any](v Validated[T], fn func(T) U) Validated[U] {
+	if v.IsValid() {
+		return Valid(fn(v.value))
+	}
+	return Validated[U]{errs: v.errs}
+}
+
+// Apply applies a validated function to a validated value, accumulating
+// the errors of both.
+func Apply[T, U any](fn Validated[func(T) U], v Validated[T]) Validated[U] {
+	if fn.IsValid() && v.IsValid() {
+		return Valid(fn.value(v.value))
+	}
+	return Validated[U]{errs: append(append([]error{}, fn.errs...), v.errs...)}
+}
+
+// Combine merges Validated values into one containing a slice, or every
+// error from all of them.
+func Combine[T any](vs ...Validated[T]) Validated[[]T] {
+	values := make([]T, 0, len(vs))
+	var errs []error
+	for _, v := range vs {
+		if v.IsValid() {
+			values = append(values, v.value)
+		} else {
+			errs = append(errs, v.errs...)
+		}
+	}
+	if len(errs) > 0 {
+		return Validated[[]T]{errs: errs}
+	}
+	return Valid(values)
+}
+
+// =============================================================================
+// Struct Validation
+// =============================================================================
+
+// FieldError is a validation error for one field of an input.
+type FieldError struct {
+	// Field is the dotted path of the field, e.g. "address.zip".
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Rule checks one field of a struct. Check receives the field value with
+// pointers dereferenced, or nil when the field is missing or a nil pointer.
+type Rule struct {
+	Field string
+	Check func(value any) error
+}
+
+// Check creates a Rule from a custom check function.
+func Check(field string, fn func(value any) error) Rule {
+	return Rule{Field: field, Check: fn}
+}
+
+// Required fails when the field is nil or its type's zero value.
+func Required(field string) Rule {
+	return Check(field, func(value any) error {
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			return errors.New("is required")
+		}
+		return nil
+	})
+}
+
+// MinLength fails when a string, slice, or map field has fewer than n
+// elements (characters for strings).
+func MinLength(field string, n int) Rule {
+	return Check(field, func(value any) error {
+		if l, unit, ok := length(value); ok && l < n {
+			return fmt.Errorf("must be at least %d %s", n, unit)
+		}
+		return nil
+	})
+}
+
+// MaxLength fails when a string, slice, or map field has more than n
+// elements (characters for strings).
+func MaxLength(field string, n int) Rule {
+	return Check(field, func(value any) error {
+		if l, unit, ok := length(value); ok && l > n {
+			return fmt.Errorf("must be at most %d %s", n, unit)
+		}
+		return nil
+	})
+}
+
+// Range fails when a numeric field is outside [min, max].
+func Range(field string, min, max float64) Rule {
+	return Check(field, func(value any) error {
+		rv := reflect.ValueOf(value)
+		var f float64
+		switch {
+		case value == nil:
+			return nil
+		case rv.CanInt():
+			f = float64(rv.Int())
+		case rv.CanUint():
+			f = float64(rv.Uint())
+		case rv.CanFloat():
+			f = rv.Float()
+		default:
+			return nil
+		}
+		if f < min || f > max {
+			return fmt.Errorf("must be between %v and %v", min, max)
+		}
+		return nil
+	})
+}
+
+// length returns the length of value and the unit it is measured in.
+func length(value any) (int, string, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(rv.String()), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), "items", true
+	}
+	return 0, "", false
+}
+
+// ValidateStruct runs every rule against v and reports all failures as
+// FieldErrors. Fields are looked up by json tag or case-insensitive name,
+// and nested fields use dotted paths.
+func ValidateStruct[T any](v T, rules ...Rule) Validated[T] {
+	var errs []error
+	for _, rule := range rules {
+		if err := rule.Check(lookupField(reflect.ValueOf(v), rule.Field)); err != nil {
+			errs = append(errs, &FieldError{Field: rule.Field, Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return Validated[T]{value: v, errs: errs}
+	}
+	return Valid(v)
+}
+
+func lookupField(rv reflect.Value, path string) any {
+	for _, name := range strings.Split(path, ".") {
+		rv = indirect(rv)
+		if !rv.IsValid() {
+			return nil
+		}
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil
+			}
+			rv = rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		case reflect.Struct:
+			rv = structField(rv, name)
+		default:
+			return nil
+		}
+	}
+	rv = indirect(rv)
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.IsValid() && (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+func structField(rv reflect.Value, name string) reflect.Value {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && strings.EqualFold(sf.Name, name)) {
+			return rv.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,154 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	Zip string `json:"zip"`
+}
+
+type signup struct {
+	Name    string   `json:"name"`
+	Age     int      `json:"age"`
+	Tags    []string `json:"tags"`
+	Address *address `json:"address"`
+}
+
+func fieldErrorStrings(errs []error) []string {
+	out := make([]string, len(errs))
+	for i, err := range errs {
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			return []string{fmt.Sprintf("%T is not a FieldError", err)}
+		}
+		out[i] = fe.Field + " " + fe.Message
+	}
+	return out
+}
+
+func TestValidateStruct(t *testing.T) {
+	rules := []Rule{
+		Required("name"),
+		MaxLength("name", 5),
+		Range("age", 18, 130),
+		MinLength("tags", 1),
+		Required("address.zip"),
+	}
+	tests := []struct {
+		name  string
+		input signup
+		want  []string
+	}{
+		{
+			name:  "valid",
+			input: signup{Name: "Ada", Age: 36, Tags: []string{"x"}, Address: &address{Zip: "12345"}},
+		},
+		{
+			name:  "length and range",
+			input: signup{Name: "Adaline", Age: 12, Tags: []string{"x"}, Address: &address{Zip: "12345"}},
+			want:  []string{"name must be at most 5 characters", "age must be between 18 and 130"},
+		},
+		{
+			name:  "every failure",
+			input: signup{Age: 200},
+			want: []string{
+				"name is required",
+				"age must be between 18 and 130",
+				"tags must be at least 1 items",
+				"address.zip is required",
+			},
+		},
+		{
+			name:  "characters, not bytes",
+			input: signup{Name: "éééé", Age: 18, Tags: []string{"x"}, Address: &address{Zip: "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := ValidateStruct(tt.input, rules...)
+			if v.IsValid() != (len(tt.want) == 0) {
+				t.Fatalf("IsValid = %v, errors %v", v.IsValid(), v.Errors())
+			}
+			if got := fieldErrorStrings(v.Errors()); strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStructMap(t *testing.T) {
+	input := map[string]any{"name": "", "address": map[string]any{"zip": "123"}}
+	v := ValidateStruct(input, Required("name"), MinLength("address.zip", 5), Required("missing"))
+	want := []string{"name is required", "address.zip must be at least 5 characters", "missing is required"}
+	if got := fieldErrorStrings(v.Errors()); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	errA, errB, errC := errors.New("a"), errors.New("b"), errors.New("c")
+	tests := []struct {
+		name     string
+		in       []Validated[int]
+		want     []int
+		wantErrs []error
+	}{
+		{"all valid", []Validated[int]{Valid(1), Valid(2)}, []int{1, 2}, nil},
+		{"errors accumulate", []Validated[int]{Invalid[int](errA), Valid(2), Invalid[int](errB, errC)}, nil, []error{errA, errB, errC}},
+		{"empty", nil, []int{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Combine(tt.in...)
+			if fmt.Sprint(got.Errors()) != fmt.Sprint(tt.wantErrs) {
+				t.Errorf("errors = %v, want %v", got.Errors(), tt.wantErrs)
+			}
+			if value, ok := got.Value(); ok && fmt.Sprint(value) != fmt.Sprint(tt.want) {
+				t.Errorf("value = %v, want %v", value, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	double := Valid(func(n int) int { return n * 2 })
+	if got, ok := Apply(double, Valid(21)).Value(); !ok || got != 42 {
+		t.Errorf("Apply(valid, valid) = %d, %v", got, ok)
+	}
+
+	errFn, errV := errors.New("fn"), errors.New("value")
+	got := Apply(Invalid[func(int) int](errFn), Invalid[int](errV))
+	if fmt.Sprint(got.Errors()) != "[fn value]" {
+		t.Errorf("Apply(invalid, invalid) errors = %v, want both", got.Errors())
+	}
+}
+
+func TestValidatedConversions(t *testing.T) {
+	if got := MapValidated(Valid(2), func(n int) string { return fmt.Sprint(n + 1) }); !got.IsValid() {
+		t.Errorf("MapValidated(valid) = %v", got.Errors())
+	} else if value, _ := got.Value(); value != "3" {
+		t.Errorf("MapValidated(valid) = %q, want 3", value)
+	}
+
+	errA, errB := errors.New("a"), errors.New("b")
+	res := Invalid[int](errA, errB).ToResult()
+	if !res.IsErr() || !errors.Is(res.Error(), errA) || !errors.Is(res.Error(), errB) {
+		t.Errorf("ToResult error = %v, want both joined", res.Error())
+	}
+	if v := FromResult(res); v.IsValid() || len(v.Errors()) != 1 {
+		t.Errorf("FromResult(err) = %v, want one joined error", v.Errors())
+	}
+	if v := FromResult(Ok(7)); !v.IsValid() {
+		t.Errorf("FromResult(ok) is invalid: %v", v.Errors())
+	}
+	if Invalid[int]().IsValid() {
+		t.Error("Invalid without errors is valid")
+	}
+	if Valid(1).Err() != nil {
+		t.Error("Valid has an error")
+	}
+}
@@ -0,0 +1,349 @@
+package schema
+
+import (
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// Parse parses SDL and builds a schema from it.
+func Parse(sdl string) (*Schema, error) {
+	doc, err := language.Parse(sdl)
+	if err != nil {
+		return nil, err
+	}
+	return Build(doc)
+}
+
+// Build builds a schema from the type system definitions in doc. The
+// specified scalars, directives, and introspection types are added
+// automatically. All problems found are reported together as a
+// language.ErrorList.
+func Build(doc *language.Document) (*Schema, error) {
+	b := &builder{
+		schema: &Schema{
+			types:        make(map[string]*Type),
+			directives:   make(map[string]*Directive),
+			implementers: make(map[string][]*Type),
+		},
+		defs: make(map[string][]*language.TypeDefinition),
+	}
+
+	b.declare(doc, false)
+	b.declare(preludeDoc, true)
+	b.extend(doc)
+	for _, t := range b.schema.typeOrder {
+		b.complete(t)
+	}
+	b.roots()
+
+	if len(b.errs) > 0 {
+		return nil, language.ErrorList(b.errs)
+	}
+	return b.schema, nil
+}
+
+type builder struct {
+	schema     *Schema
+	defs       map[string][]*language.TypeDefinition
+	schemaDefs []*language.SchemaDefinition
+	refs       []typeRef
+	errs       []*language.Error
+}
+
+func (b *builder) errorf(loc language.Location, format string, args ...any) {
+	var locs []language.Location
+	if loc.Line > 0 {
+		locs = []language.Location{loc}
+	}
+	b.errs = append(b.errs, language.NewError(locs, format, args...))
+}
+
+// declare registers the named types and directives defined in doc.
+func (b *builder) declare(doc *language.Document, builtIn bool) {
+	s := b.schema
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *language.TypeDefinition:
+			if def.Extend {
+				continue
+			}
+			if existing := s.types[def.Name]; existing != nil {
+				// Restating a specified scalar is allowed and has no effect.
+				if builtIn && existing.Kind == Scalar && def.Kind == Scalar {
+					existing.BuiltIn = true
+					continue
+				}
+				b.errorf(def.Loc, "There can be only one type named %q.", def.Name)
+				continue
+			}
+			t := &Type{
+				Kind:        def.Kind,
+				Name:        def.Name,
+				Description: def.Description,
+				BuiltIn:     builtIn,
+			}
+			s.types[t.Name] = t
+			s.typeOrder = append(s.typeOrder, t)
+			b.defs[t.Name] = append(b.defs[t.Name], def)
+
+		case *language.DirectiveDefinition:
+			if s.directives[def.Name] != nil {
+				if builtIn {
+					continue
+				}
+				b.errorf(def.Loc, "There can be only one directive named \"@%s\".", def.Name)
+				continue
+			}
+			d := &Directive{
+				Name:        def.Name,
+				Description: def.Description,
+				Locations:   def.Locations,
+				Repeatable:  def.Repeatable,
+				BuiltIn:     builtIn,
+			}
+			d.Args = b.inputValues(def.Arguments)
+			s.directives[d.Name] = d
+			s.directiveOrder = append(s.directiveOrder, d)
+
+		case *language.SchemaDefinition:
+			if !def.Extend {
+				if len(b.schemaDefs) > 0 && !b.schemaDefs[0].Extend {
+					b.errorf(def.Loc, "Must provide only one schema definition.")
+					continue
+				}
+				b.schemaDefs = append([]*language.SchemaDefinition{def}, b.schemaDefs...)
+			}
+		}
+	}
+}
+
+// extend records type and schema extensions so they are merged when the
+// types are completed.
+func (b *builder) extend(doc *language.Document) {
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *language.TypeDefinition:
+			if !def.Extend {
+				continue
+			}
+			t := b.schema.types[def.Name]
+			if t == nil {
+				b.errorf(def.Loc, "Cannot extend type %q because it is not defined.", def.Name)
+				continue
+			}
+			if t.Kind != def.Kind {
+				b.errorf(def.Loc, "Cannot extend non-%s type %q.", def.Kind, def.Name)
+				continue
+			}
+			b.defs[def.Name] = append(b.defs[def.Name], def)
+		case *language.SchemaDefinition:
+			if def.Extend {
+				b.schemaDefs = append(b.schemaDefs, def)
+			}
+		}
+	}
+}
+
+// complete fills in the members of t from its definition and extensions.
+func (b *builder) complete(t *Type) {
+	t.fieldIndex = make(map[string]*Field)
+	t.enumIndex = make(map[string]*EnumValue)
+	t.inputFieldIndex = make(map[string]*InputValue)
+
+	for _, def := range b.defs[t.Name] {
+		t.Directives = append(t.Directives, def.Directives...)
+
+		for _, fd := range def.Fields {
+			if t.fieldIndex[fd.Name] != nil {
+				b.errorf(fd.Loc, "Field \"%s.%s\" can only be defined once.", t.Name, fd.Name)
+				continue
+			}
+			b.outputType(fd.Type, t.Name+"."+fd.Name)
+			f := &Field{
+				Name:        fd.Name,
+				Description: fd.Description,
+				Args:        b.inputValues(fd.Arguments),
+				Type:        fd.Type,
+				Directives:  fd.Directives,
+			}
+			f.Deprecated, f.DeprecationReason = deprecation(fd.Directives)
+			t.Fields = append(t.Fields, f)
+			t.fieldIndex[f.Name] = f
+		}
+
+		for _, name := range def.Interfaces {
+			iface := b.schema.types[name]
+			if iface == nil || iface.Kind != Interface {
+				b.errorf(def.Loc, "Type %s must only implement Interface types, it cannot implement %s.", t.Name, name)
+				continue
+			}
+			t.Interfaces = append(t.Interfaces, iface)
+			if t.Kind == Object {
+				b.schema.implementers[name] = append(b.schema.implementers[name], t)
+			}
+		}
+
+		for _, name := range def.Types {
+			member := b.schema.types[name]
+			if member == nil || member.Kind != Object {
+				b.errorf(def.Loc, "Union type %s can only include Object types, it cannot include %s.", t.Name, name)
+				continue
+			}
+			t.Types = append(t.Types, member)
+		}
+
+		for _, vd := range def.EnumValues {
+			if t.enumIndex[vd.Name] != nil {
+				b.errorf(vd.Loc, "Enum value \"%s.%s\" can only be defined once.", t.Name, vd.Name)
+				continue
+			}
+			v := &EnumValue{
+				Name:        vd.Name,
+				Description: vd.Description,
+				Directives:  vd.Directives,
+			}
+			v.Deprecated, v.DeprecationReason = deprecation(vd.Directives)
+			t.EnumValues = append(t.EnumValues, v)
+			t.enumIndex[v.Name] = v
+		}
+
+		for _, v := range b.inputValues(def.InputFields) {
+			if t.inputFieldIndex[v.Name] != nil {
+				b.errorf(def.Loc, "Input field \"%s.%s\" can only be defined once.", t.Name, v.Name)
+				continue
+			}
+			t.InputFields = append(t.InputFields, v)
+			t.inputFieldIndex[v.Name] = v
+		}
+	}
+
+	if t.Kind == InputObject && t.Directives.ForName("oneOf") != nil {
+		t.OneOf = true
+		for _, f := range t.InputFields {
+			if f.Type.NonNull {
+				b.errorf(t.Directives.ForName("oneOf").Loc, "OneOf input field \"%s.%s\" must be nullable.", t.Name, f.Name)
+			}
+			if f.DefaultValue != nil {
+				b.errorf(t.Directives.ForName("oneOf").Loc, "OneOf input field \"%s.%s\" cannot have a default value.", t.Name, f.Name)
+			}
+		}
+	}
+
+	if d := t.Directives.ForName("specifiedBy"); d != nil {
+		if arg := d.Arguments.ForName("url"); arg != nil {
+			if url, ok := arg.Value.(*language.StringValue); ok {
+				t.SpecifiedByURL = url.Value
+			}
+		}
+	}
+}
+
+func (b *builder) inputValues(defs []*language.InputValueDefinition) []*InputValue {
+	values := make([]*InputValue, 0, len(defs))
+	for _, def := range defs {
+		b.inputType(def.Type, def.Name)
+		v := &InputValue{
+			Name:         def.Name,
+			Description:  def.Description,
+			Type:         def.Type,
+			DefaultValue: def.DefaultValue,
+			Directives:   def.Directives,
+		}
+		v.Deprecated, v.DeprecationReason = deprecation(def.Directives)
+		values = append(values, v)
+	}
+	return values
+}
+
+// outputType and inputType record a type reference to be checked once
+// every named type has been declared.
+func (b *builder) outputType(ref *language.Type, owner string) {
+	b.refs = append(b.refs, typeRef{ref: ref, owner: owner})
+}
+
+func (b *builder) inputType(ref *language.Type, owner string) {
+	b.refs = append(b.refs, typeRef{ref: ref, owner: owner, input: true})
+}
+
+type typeRef struct {
+	ref   *language.Type
+	owner string
+	input bool
+}
+
+// roots checks the recorded type references and resolves the root
+// operation types.
+func (b *builder) roots() {
+	s := b.schema
+	for _, r := range b.refs {
+		t := s.types[r.ref.Name()]
+		switch {
+		case t == nil:
+			b.errorf(r.ref.Loc, "Unknown type %q.", r.ref.Name())
+		case r.input && !t.IsInputType():
+			b.errorf(r.ref.Loc, "The type of %s must be Input Type but got: %s.", r.owner, r.ref)
+		case !r.input && !t.IsOutputType():
+			b.errorf(r.ref.Loc, "The type of %s must be Output Type but got: %s.", r.owner, r.ref)
+		}
+	}
+
+	explicit := false
+	for _, def := range b.schemaDefs {
+		if !def.Extend {
+			s.Description = def.Description
+		}
+		s.Directives = append(s.Directives, def.Directives...)
+		for _, op := range def.OperationTypes {
+			explicit = true
+			t := s.types[op.Type]
+			if t == nil || t.Kind != Object {
+				b.errorf(op.Loc, "%s root type must be Object type, it cannot be %s.", op.Operation, op.Type)
+				continue
+			}
+			b.setRoot(op.Operation, t)
+		}
+	}
+
+	if !explicit {
+		for op, name := range map[language.OperationType]string{
+			language.Query:        "Query",
+			language.Mutation:     "Mutation",
+			language.Subscription: "Subscription",
+		} {
+			if t := s.types[name]; t != nil && t.Kind == Object {
+				b.setRoot(op, t)
+			}
+		}
+	}
+
+	if s.Query == nil {
+		b.errorf(language.Location{}, "Query root type must be provided.")
+	}
+}
+
+func (b *builder) setRoot(op language.OperationType, t *Type) {
+	switch op {
+	case language.Query:
+		b.schema.Query = t
+	case language.Mutation:
+		b.schema.Mutation = t
+	case language.Subscription:
+		b.schema.Subscription = t
+	}
+}
+
+// deprecation extracts @deprecated from a directive list.
+func deprecation(directives language.DirectiveList) (bool, string) {
+	d := directives.ForName("deprecated")
+	if d == nil {
+		return false, ""
+	}
+	if arg := d.Arguments.ForName("reason"); arg != nil {
+		if reason, ok := arg.Value.(*language.StringValue); ok {
+			return true, reason.Value
+		}
+	}
+	return true, DefaultDeprecationReason
+}
+
+// DefaultDeprecationReason is the reason reported for @deprecated without arguments.
+const DefaultDeprecationReason = "No longer supported"
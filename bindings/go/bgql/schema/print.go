@@ -0,0 +1,123 @@
+package schema
+
+import (
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// Print formats the schema as SDL. Custom directive definitions come
+// first, then the schema definition when the root types are not the
+// defaults, then every non-built-in type in declaration order.
+// Descriptions and applied directives, including @deprecated and
+// @specifiedBy, are preserved so the output builds an identical schema.
+func Print(s *Schema) string {
+	var parts []string
+
+	for _, d := range s.directiveOrder {
+		if !d.BuiltIn {
+			parts = append(parts, language.Print(d.definition()))
+		}
+	}
+
+	if def := s.schemaDefinition(); def != nil {
+		parts = append(parts, language.Print(def))
+	}
+
+	for _, t := range s.typeOrder {
+		if !t.BuiltIn {
+			parts = append(parts, language.Print(t.definition()))
+		}
+	}
+
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+// String returns the schema as SDL.
+func (s *Schema) String() string {
+	return Print(s)
+}
+
+func (s *Schema) schemaDefinition() *language.SchemaDefinition {
+	isDefault := s.Description == "" && len(s.Directives) == 0 &&
+		(s.Query == nil || s.Query.Name == "Query") &&
+		(s.Mutation == nil || s.Mutation.Name == "Mutation") &&
+		(s.Subscription == nil || s.Subscription.Name == "Subscription")
+	if isDefault {
+		return nil
+	}
+
+	def := &language.SchemaDefinition{
+		Description: s.Description,
+		Directives:  s.Directives,
+	}
+	for _, op := range []language.OperationType{language.Query, language.Mutation, language.Subscription} {
+		if t := s.RootType(op); t != nil {
+			def.OperationTypes = append(def.OperationTypes, &language.OperationTypeDefinition{
+				Operation: op,
+				Type:      t.Name,
+			})
+		}
+	}
+	return def
+}
+
+func (t *Type) definition() *language.TypeDefinition {
+	def := &language.TypeDefinition{
+		Kind:        t.Kind,
+		Description: t.Description,
+		Name:        t.Name,
+		Directives:  t.Directives,
+	}
+	for _, iface := range t.Interfaces {
+		def.Interfaces = append(def.Interfaces, iface.Name)
+	}
+	for _, f := range t.Fields {
+		def.Fields = append(def.Fields, &language.FieldDefinition{
+			Description: f.Description,
+			Name:        f.Name,
+			Arguments:   inputValueDefinitions(f.Args),
+			Type:        f.Type,
+			Directives:  f.Directives,
+		})
+	}
+	for _, member := range t.Types {
+		def.Types = append(def.Types, member.Name)
+	}
+	for _, v := range t.EnumValues {
+		def.EnumValues = append(def.EnumValues, &language.EnumValueDefinition{
+			Description: v.Description,
+			Name:        v.Name,
+			Directives:  v.Directives,
+		})
+	}
+	def.InputFields = inputValueDefinitions(t.InputFields)
+	return def
+}
+
+func (d *Directive) definition() *language.DirectiveDefinition {
+	return &language.DirectiveDefinition{
+		Description: d.Description,
+		Name:        d.Name,
+		Arguments:   inputValueDefinitions(d.Args),
+		Repeatable:  d.Repeatable,
+		Locations:   d.Locations,
+	}
+}
+
+func inputValueDefinitions(values []*InputValue) []*language.InputValueDefinition {
+	if len(values) == 0 {
+		return nil
+	}
+	defs := make([]*language.InputValueDefinition, len(values))
+	for i, v := range values {
+		defs[i] = &language.InputValueDefinition{
+			Description:  v.Description,
+			Name:         v.Name,
+			Type:         v.Type,
+			DefaultValue: v.DefaultValue,
+			Directives:   v.Directives,
+		}
+	}
+	return defs
+}
@@ -0,0 +1,259 @@
+// Package schema builds an executable GraphQL type system from SDL and
+// validates documents against it.
+package schema
+
+import (
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// Kind is the kind of a named type, matching the __TypeKind enum.
+type Kind = language.TypeKind
+
+const (
+	Scalar      = language.Scalar
+	Object      = language.Object
+	Interface   = language.Interface
+	Union       = language.Union
+	Enum        = language.Enum
+	InputObject = language.InputObject
+)
+
+// Schema is a complete, validated GraphQL type system.
+type Schema struct {
+	Description  string
+	Query        *Type
+	Mutation     *Type
+	Subscription *Type
+	Directives   language.DirectiveList
+
+	types          map[string]*Type
+	typeOrder      []*Type
+	directives     map[string]*Directive
+	directiveOrder []*Directive
+	implementers   map[string][]*Type
+}
+
+// Type returns the named type, or nil.
+func (s *Schema) Type(name string) *Type {
+	return s.types[name]
+}
+
+// Types returns all named types in declaration order, followed by the
+// built-in scalars and introspection types.
+func (s *Schema) Types() []*Type {
+	return s.typeOrder
+}
+
+// Directive returns the directive definition with the given name, or nil.
+func (s *Schema) Directive(name string) *Directive {
+	return s.directives[name]
+}
+
+// DirectiveDefinitions returns all directive definitions, custom ones
+// first, followed by the built-in directives.
+func (s *Schema) DirectiveDefinitions() []*Directive {
+	return s.directiveOrder
+}
+
+// RootType returns the root type for an operation, or nil if the schema
+// does not support it.
+func (s *Schema) RootType(op language.OperationType) *Type {
+	switch op {
+	case language.Query:
+		return s.Query
+	case language.Mutation:
+		return s.Mutation
+	case language.Subscription:
+		return s.Subscription
+	}
+	return nil
+}
+
+// PossibleTypes returns the object types that may be returned for an
+// abstract type: union members or interface implementations.
+func (s *Schema) PossibleTypes(t *Type) []*Type {
+	switch t.Kind {
+	case Union:
+		return t.Types
+	case Interface:
+		return s.implementers[t.Name]
+	case Object:
+		return []*Type{t}
+	}
+	return nil
+}
+
+// IsPossibleType reports whether object may be returned where abstract is expected.
+func (s *Schema) IsPossibleType(abstract, object *Type) bool {
+	for _, t := range s.PossibleTypes(abstract) {
+		if t == object {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldDefinition returns the definition of a field selected on parent,
+// including the __typename, __schema, and __type meta fields.
+func (s *Schema) FieldDefinition(parent *Type, name string) *Field {
+	switch name {
+	case "__typename":
+		if parent.IsComposite() {
+			return TypeNameField
+		}
+	case "__schema":
+		if parent == s.Query {
+			return SchemaField
+		}
+	case "__type":
+		if parent == s.Query {
+			return TypeField
+		}
+	}
+	return parent.Field(name)
+}
+
+// Type is a named type definition.
+type Type struct {
+	Kind        Kind
+	Name        string
+	Description string
+	Directives  language.DirectiveList
+
+	// Object and Interface
+	Fields     []*Field
+	Interfaces []*Type
+
+	// Union
+	Types []*Type
+
+	// Enum
+	EnumValues []*EnumValue
+
+	// InputObject
+	InputFields []*InputValue
+	// OneOf marks an input object declared @oneOf: exactly one field must
+	// be provided, and it must not be null.
+	OneOf bool
+
+	// Scalar
+	SpecifiedByURL string
+
+	// BuiltIn marks the specified scalars and introspection types.
+	BuiltIn bool
+
+	fieldIndex      map[string]*Field
+	enumIndex       map[string]*EnumValue
+	inputFieldIndex map[string]*InputValue
+}
+
+// Field returns the field with the given name, or nil.
+func (t *Type) Field(name string) *Field {
+	return t.fieldIndex[name]
+}
+
+// EnumValue returns the enum member with the given name, or nil.
+func (t *Type) EnumValue(name string) *EnumValue {
+	return t.enumIndex[name]
+}
+
+// InputField returns the input field with the given name, or nil.
+func (t *Type) InputField(name string) *InputValue {
+	return t.inputFieldIndex[name]
+}
+
+// IsLeaf reports whether values of t are serialized directly (scalars and enums).
+func (t *Type) IsLeaf() bool {
+	return t.Kind == Scalar || t.Kind == Enum
+}
+
+// IsComposite reports whether t has a selection set (objects, interfaces, unions).
+func (t *Type) IsComposite() bool {
+	return t.Kind == Object || t.Kind == Interface || t.Kind == Union
+}
+
+// IsAbstract reports whether t is an interface or union.
+func (t *Type) IsAbstract() bool {
+	return t.Kind == Interface || t.Kind == Union
+}
+
+// IsInputType reports whether t may be used for arguments and variables.
+func (t *Type) IsInputType() bool {
+	return t.Kind == Scalar || t.Kind == Enum || t.Kind == InputObject
+}
+
+// IsOutputType reports whether t may be used as a field type.
+func (t *Type) IsOutputType() bool {
+	return t.Kind != InputObject
+}
+
+// Field is a field of an object or interface type.
+type Field struct {
+	Name              string
+	Description       string
+	Args              []*InputValue
+	Type              *language.Type
+	Directives        language.DirectiveList
+	Deprecated        bool
+	DeprecationReason string
+}
+
+// Arg returns the argument with the given name, or nil.
+func (f *Field) Arg(name string) *InputValue {
+	for _, arg := range f.Args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// InputValue is an argument or input object field.
+type InputValue struct {
+	Name              string
+	Description       string
+	Type              *language.Type
+	DefaultValue      language.Value
+	Directives        language.DirectiveList
+	Deprecated        bool
+	DeprecationReason string
+}
+
+// EnumValue is a member of an enum type.
+type EnumValue struct {
+	Name              string
+	Description       string
+	Directives        language.DirectiveList
+	Deprecated        bool
+	DeprecationReason string
+}
+
+// Directive is a directive definition.
+type Directive struct {
+	Name        string
+	Description string
+	Args        []*InputValue
+	Locations   []string
+	Repeatable  bool
+	BuiltIn     bool
+}
+
+// Arg returns the argument with the given name, or nil.
+func (d *Directive) Arg(name string) *InputValue {
+	for _, arg := range d.Args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// HasLocation reports whether the directive may appear at loc.
+func (d *Directive) HasLocation(loc string) bool {
+	for _, l := range d.Locations {
+		if l == loc {
+			return true
+		}
+	}
+	return false
+}
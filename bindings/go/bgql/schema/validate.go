@@ -0,0 +1,388 @@
+package schema
+
+import (
+	"strconv"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// Validate checks an executable document against s: that the fields,
+// arguments, directives, and fragments it uses exist where it uses them,
+// that argument values and variables have the right types, and that every
+// variable used is defined. It returns the problems found, with their
+// locations, or nil.
+func Validate(s *Schema, doc *language.Document) language.ErrorList {
+	v := &validator{schema: s, doc: doc}
+
+	ops := doc.Operations()
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *language.OperationDefinition:
+			if def.Name == "" && len(ops) > 1 {
+				v.errorf(def.Loc, "This anonymous operation must be the only defined operation.")
+			}
+		case *language.FragmentDefinition:
+		default:
+			v.errorf(def.Position(), "The document may only contain operations and fragments.")
+		}
+	}
+
+	reached := make(map[string]bool)
+	for _, op := range ops {
+		v.operation(op)
+		for name := range v.visited {
+			reached[name] = true
+		}
+	}
+	// Fragments no operation spreads are checked without variables.
+	v.vars = nil
+	for _, frag := range doc.Fragments() {
+		if !reached[frag.Name] {
+			v.visited = map[string]bool{frag.Name: true}
+			v.fragment(frag)
+		}
+	}
+	return v.errs
+}
+
+type validator struct {
+	schema *Schema
+	doc    *language.Document
+	errs   language.ErrorList
+
+	// vars are the variables of the operation being checked, or nil when
+	// checking a fragment outside of one.
+	vars map[string]*language.VariableDefinition
+	// visited are the fragments spread in the operation.
+	visited map[string]bool
+}
+
+func (v *validator) errorf(loc language.Location, format string, args ...any) {
+	v.errs = append(v.errs, language.NewError([]language.Location{loc}, format, args...))
+}
+
+func (v *validator) operation(op *language.OperationDefinition) {
+	v.vars = make(map[string]*language.VariableDefinition)
+	v.visited = make(map[string]bool)
+	for _, def := range op.VariableDefinitions {
+		if v.vars[def.Variable] != nil {
+			v.errorf(def.Loc, "There can be only one variable named \"$%s\".", def.Variable)
+			continue
+		}
+		v.vars[def.Variable] = def
+		if t := v.schema.Type(def.Type.Name()); t == nil || !t.IsInputType() {
+			v.errorf(def.Loc, "Variable \"$%s\" cannot be non-input type %q.", def.Variable, def.Type)
+			continue
+		}
+		if def.DefaultValue != nil {
+			v.value(def.DefaultValue, def.Type)
+		}
+	}
+
+	root := v.schema.RootType(op.Operation)
+	if root == nil {
+		v.errorf(op.Loc, "The schema does not support %s operations.", op.Operation)
+		return
+	}
+	v.directives(op.Directives)
+	v.selectionSet(root, op.SelectionSet)
+}
+
+func (v *validator) fragment(frag *language.FragmentDefinition) {
+	t := v.typeCondition(frag.TypeCondition, frag.Loc)
+	v.directives(frag.Directives)
+	if t != nil {
+		v.selectionSet(t, frag.SelectionSet)
+	}
+}
+
+// typeCondition returns the composite type a fragment applies to.
+func (v *validator) typeCondition(name string, loc language.Location) *Type {
+	t := v.schema.Type(name)
+	switch {
+	case t == nil:
+		v.errorf(loc, "Unknown type %q.", name)
+		return nil
+	case !t.IsComposite():
+		v.errorf(loc, "Fragment cannot condition on non composite type %q.", name)
+		return nil
+	}
+	return t
+}
+
+func (v *validator) selectionSet(parent *Type, set language.SelectionSet) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			v.field(parent, sel)
+
+		case *language.InlineFragment:
+			v.directives(sel.Directives)
+			t := parent
+			if sel.TypeCondition != "" {
+				if t = v.typeCondition(sel.TypeCondition, sel.Loc); t == nil {
+					continue
+				}
+				v.checkSpreadable(parent, t, sel.Loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.")
+			}
+			v.selectionSet(t, sel.SelectionSet)
+
+		case *language.FragmentSpread:
+			v.directives(sel.Directives)
+			frag := v.doc.Fragment(sel.Name)
+			if frag == nil {
+				v.errorf(sel.Loc, "Unknown fragment %q.", sel.Name)
+				continue
+			}
+			if t := v.schema.Type(frag.TypeCondition); t != nil && t.IsComposite() {
+				v.checkSpreadable(parent, t, sel.Loc, "Fragment \""+sel.Name+"\" cannot be spread here as objects of type %q can never be of type %q.")
+			}
+			if !v.visited[sel.Name] {
+				v.visited[sel.Name] = true
+				v.fragment(frag)
+			}
+		}
+	}
+}
+
+// checkSpreadable reports a fragment on t spread where parent is expected
+// when no object can be both.
+func (v *validator) checkSpreadable(parent, t *Type, loc language.Location, format string) {
+	for _, p := range v.schema.PossibleTypes(parent) {
+		if v.schema.IsPossibleType(t, p) {
+			return
+		}
+	}
+	v.errorf(loc, format, parent.Name, t.Name)
+}
+
+func (v *validator) field(parent *Type, sel *language.Field) {
+	def := v.schema.FieldDefinition(parent, sel.Name)
+	if def == nil {
+		v.errorf(sel.Loc, "Cannot query field %q on type %q.", sel.Name, parent.Name)
+		return
+	}
+	v.arguments(sel.Arguments, def.Args, sel.Loc, "Field \""+sel.Name+"\"", "field \""+parent.Name+"."+sel.Name+"\"")
+	v.directives(sel.Directives)
+
+	t := v.schema.Type(def.Type.Name())
+	switch {
+	case t.IsComposite() && len(sel.SelectionSet) == 0:
+		v.errorf(sel.Loc, "Field %q of type %q must have a selection of subfields.", sel.Name, def.Type)
+	case !t.IsComposite() && len(sel.SelectionSet) > 0:
+		v.errorf(sel.Loc, "Field %q must not have a selection since type %q has no subfields.", sel.Name, def.Type)
+	case t.IsComposite():
+		v.selectionSet(t, sel.SelectionSet)
+	}
+}
+
+// arguments checks the arguments passed to a field or directive against
+// their definitions. Errors name the field or directive as what, or as
+// owner when an argument is unknown.
+func (v *validator) arguments(args language.ArgumentList, defs []*InputValue, loc language.Location, what, owner string) {
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		if seen[arg.Name] {
+			v.errorf(arg.Loc, "There can be only one argument named %q.", arg.Name)
+			continue
+		}
+		seen[arg.Name] = true
+		def := argument(defs, arg.Name)
+		if def == nil {
+			v.errorf(arg.Loc, "Unknown argument %q on %s.", arg.Name, owner)
+			continue
+		}
+		v.valueAt(arg.Value, def.Type, def.DefaultValue != nil)
+	}
+	for _, def := range defs {
+		if def.Type.NonNull && def.DefaultValue == nil && !seen[def.Name] {
+			v.errorf(loc, "%s argument %q of type %q is required, but it was not provided.", what, def.Name, def.Type)
+		}
+	}
+}
+
+func argument(defs []*InputValue, name string) *InputValue {
+	for _, def := range defs {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+func (v *validator) directives(directives language.DirectiveList) {
+	for _, d := range directives {
+		def := v.schema.Directive(d.Name)
+		if def == nil {
+			v.errorf(d.Loc, "Unknown directive \"@%s\".", d.Name)
+			continue
+		}
+		v.arguments(d.Arguments, def.Args, d.Loc, "Directive \"@"+d.Name+"\"", "directive \"@"+d.Name+"\"")
+	}
+}
+
+// =============================================================================
+// Values
+// =============================================================================
+
+// value checks a literal where a value of type t is expected.
+func (v *validator) value(value language.Value, t *language.Type) {
+	v.valueAt(value, t, false)
+}
+
+// valueAt checks a value where one of type t is expected, in a location
+// with a default value if hasDefault is set.
+func (v *validator) valueAt(value language.Value, t *language.Type, hasDefault bool) {
+	switch value := value.(type) {
+	case *language.Variable:
+		v.variable(value, t, hasDefault)
+		return
+	case *language.NullValue:
+		if t.NonNull {
+			v.mismatch(value, t)
+		}
+		return
+	}
+
+	if t.Elem != nil {
+		if list, ok := value.(*language.ListValue); ok {
+			for _, item := range list.Values {
+				v.value(item, t.Elem)
+			}
+			return
+		}
+		// A single value is coerced to a list of one.
+		v.value(value, t.Elem)
+		return
+	}
+
+	named := v.schema.Type(t.NamedType)
+	if named == nil {
+		return
+	}
+	switch named.Kind {
+	case InputObject:
+		obj, ok := value.(*language.ObjectValue)
+		if !ok {
+			v.mismatch(value, t)
+			return
+		}
+		v.object(obj, named)
+	case Enum:
+		enum, ok := value.(*language.EnumValue)
+		if !ok {
+			v.mismatch(value, t)
+			return
+		}
+		if named.EnumValue(enum.Value) == nil {
+			v.errorf(enum.Loc, "Value %q does not exist in %q enum.", enum.Value, named.Name)
+		}
+	case Scalar:
+		if !validScalar(named.Name, value) {
+			v.mismatch(value, t)
+		}
+	}
+}
+
+func (v *validator) object(obj *language.ObjectValue, t *Type) {
+	seen := make(map[string]bool)
+	for _, f := range obj.Fields {
+		if seen[f.Name] {
+			v.errorf(f.Loc, "There can be only one input field named %q.", f.Name)
+			continue
+		}
+		seen[f.Name] = true
+		def := t.InputField(f.Name)
+		if def == nil {
+			v.errorf(f.Loc, "Field %q is not defined by type %q.", f.Name, t.Name)
+			continue
+		}
+		v.valueAt(f.Value, def.Type, def.DefaultValue != nil)
+	}
+	for _, def := range t.InputFields {
+		if def.Type.NonNull && def.DefaultValue == nil && !seen[def.Name] {
+			v.errorf(obj.Loc, "Field \"%s.%s\" of required type %q was not provided.", t.Name, def.Name, def.Type)
+		}
+	}
+	if t.OneOf && len(obj.Fields) != 1 {
+		v.errorf(obj.Loc, "OneOf Input Object %q must specify exactly one key.", t.Name)
+	}
+}
+
+// variable checks a variable used where a value of type t is expected.
+// Outside of an operation, variables are not checked.
+func (v *validator) variable(ref *language.Variable, t *language.Type, hasDefault bool) {
+	if v.vars == nil {
+		return
+	}
+	def := v.vars[ref.Name]
+	if def == nil {
+		v.errorf(ref.Loc, "Variable \"$%s\" is not defined.", ref.Name)
+		return
+	}
+	varType := def.Type
+	if t.NonNull && !varType.NonNull {
+		// A nullable variable may be used for a non-null value if either
+		// has a default, which replaces a missing value.
+		if _, null := def.DefaultValue.(*language.NullValue); (def.DefaultValue == nil || null) && !hasDefault {
+			v.errorf(ref.Loc, "Variable \"$%s\" of type %q used in position expecting type %q.", ref.Name, varType, t)
+			return
+		}
+		t = t.Nullable()
+	}
+	if !compatible(varType, t) {
+		v.errorf(ref.Loc, "Variable \"$%s\" of type %q used in position expecting type %q.", ref.Name, varType, t)
+	}
+}
+
+// compatible reports whether a variable of type varType may be used where
+// a value of type t is expected.
+func compatible(varType, t *language.Type) bool {
+	if t.NonNull {
+		return varType.NonNull && compatible(varType.Nullable(), t.Nullable())
+	}
+	if varType.NonNull {
+		return compatible(varType.Nullable(), t)
+	}
+	if t.Elem != nil {
+		return varType.Elem != nil && compatible(varType.Elem, t.Elem)
+	}
+	return varType.Elem == nil && varType.NamedType == t.NamedType
+}
+
+// validScalar reports whether a literal is valid for a scalar. Custom
+// scalars accept any literal.
+func validScalar(name string, value language.Value) bool {
+	switch name {
+	case "Int":
+		i, ok := value.(*language.IntValue)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(i.Value, 10, 32)
+		return err == nil
+	case "Float":
+		switch value.(type) {
+		case *language.IntValue, *language.FloatValue:
+			return true
+		}
+		return false
+	case "String":
+		_, ok := value.(*language.StringValue)
+		return ok
+	case "Boolean":
+		_, ok := value.(*language.BooleanValue)
+		return ok
+	case "ID":
+		switch value.(type) {
+		case *language.StringValue, *language.IntValue:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func (v *validator) mismatch(value language.Value, t *language.Type) {
+	v.errorf(value.Position(), "Expected value of type %q, found %s.", t, language.Print(value))
+}
@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+const validateSDL = `
+type Query {
+  user(id: ID!): User
+  users(first: Int = 10, filter: UserFilter): [User!]!
+  node(id: ID!): Node
+  search(term: String!): [SearchResult!]!
+}
+
+type Mutation {
+  setRole(id: ID!, role: Role!): User
+}
+
+interface Node { id: ID! }
+
+type User implements Node {
+  id: ID!
+  name: String!
+  role: Role!
+  friends(ids: [ID!]): [User!]!
+}
+
+type Post implements Node {
+  id: ID!
+  title: String!
+}
+
+type Comment {
+  body: String!
+}
+
+union SearchResult = User | Post
+
+enum Role { ADMIN MEMBER }
+
+input UserFilter {
+  role: Role
+  name: String!
+  minAge: Float
+}
+`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse(validateSDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"valid", `query A($id: ID!) { user(id: $id) { id name role friends(ids: [1, "2"]) { name } } }`, nil},
+		{"typename and fragments", `{ node(id: 1) { __typename ... on User { name } ...P } } fragment P on Post { title }`, nil},
+		{"union spread", `{ search(term: "x") { ... on Node { id } ... on User { name } } }`, nil},
+		{"default covers non-null", `query A($first: Int) { users(first: $first) { id } }`, nil},
+		{"nullable variable with default", `query A($id: ID = "1") { user(id: $id) { id } }`, nil},
+		{"single value as list", `{ user(id: 1) { friends(ids: 2) { id } } }`, nil},
+		{"directives", `query A($skip: Boolean!) { user(id: 1) @skip(if: $skip) { id @include(if: true) } }`, nil},
+		{"input object", `{ users(filter: {name: "a", role: ADMIN, minAge: 3}) { id } }`, nil},
+		{"mutation", `mutation { setRole(id: 1, role: MEMBER) { id } }`, nil},
+
+		{"unknown field", `{ user(id: 1) { nam } }`, []string{`Cannot query field "nam" on type "User". (1:17)`}},
+		{"unknown root field", `{ me { id } }`, []string{`Cannot query field "me" on type "Query". (1:3)`}},
+		{"missing subfields", `{ user(id: 1) }`, []string{`Field "user" of type "User" must have a selection of subfields. (1:3)`}},
+		{"leaf subfields", `{ user(id: 1) { name { x } } }`, []string{`Field "name" must not have a selection since type "String!" has no subfields. (1:17)`}},
+		{"unknown argument", `{ user(id: 1, name: "a") { id } }`, []string{`Unknown argument "name" on field "Query.user". (1:15)`}},
+		{"missing argument", `{ user { id } }`, []string{`Field "user" argument "id" of type "ID!" is required, but it was not provided. (1:3)`}},
+		{"wrong argument type", `{ users(first: "ten") { id } }`, []string{`Expected value of type "Int", found "ten". (1:16)`}},
+		{"int out of range", `{ users(first: 3000000000) { id } }`, []string{`Expected value of type "Int", found 3000000000. (1:16)`}},
+		{"null for non-null", `{ user(id: null) { id } }`, []string{`Expected value of type "ID!", found null. (1:12)`}},
+		{"unknown enum value", `mutation { setRole(id: 1, role: OWNER) { id } }`, []string{`Value "OWNER" does not exist in "Role" enum. (1:33)`}},
+		{"unknown input field", `{ users(filter: {name: "a", age: 3}) { id } }`, []string{`Field "age" is not defined by type "UserFilter". (1:29)`}},
+		{"missing input field", `{ users(filter: {role: ADMIN}) { id } }`, []string{`Field "UserFilter.name" of required type "String!" was not provided. (1:17)`}},
+		{"undefined variable", `{ user(id: $id) { id } }`, []string{`Variable "$id" is not defined. (1:12)`}},
+		{"nullable variable", `query A($id: ID) { user(id: $id) { id } }`, []string{`Variable "$id" of type "ID" used in position expecting type "ID!". (1:29)`}},
+		{"wrong variable type", `query A($id: String!) { user(id: $id) { id } }`, []string{`Variable "$id" of type "String!" used in position expecting type "ID!". (1:34)`}},
+		{"output variable type", `query A($u: User) { user(id: 1) { id } }`, []string{`Variable "$u" cannot be non-input type "User". (1:9)`}},
+		{"unknown directive", `{ user(id: 1) @cached { id } }`, []string{`Unknown directive "@cached". (1:15)`}},
+		{"unknown fragment", `{ user(id: 1) { ...F } }`, []string{`Unknown fragment "F". (1:17)`}},
+		{"fragment on unknown type", `{ user(id: 1) { ...F } } fragment F on Person { id }`, []string{`Unknown type "Person". (1:26)`}},
+		{"fragment on scalar", `{ user(id: 1) { ... on String { id } } }`, []string{`Fragment cannot condition on non composite type "String". (1:17)`}},
+		{"impossible inline fragment", `{ user(id: 1) { ... on Post { title } } }`, []string{`Fragment cannot be spread here as objects of type "User" can never be of type "Post". (1:17)`}},
+		{"impossible spread", `{ search(term: "x") { ...C } } fragment C on Comment { body }`, []string{`Fragment "C" cannot be spread here as objects of type "SearchResult" can never be of type "Comment". (1:23)`}},
+		{"unused fragment", `{ user(id: 1) { id } } fragment F on User { nam }`, []string{`Cannot query field "nam" on type "User". (1:45)`}},
+		{"variable in fragment", `query A { user(id: 1) { ...F } } fragment F on User { friends(ids: $ids) { id } }`, []string{`Variable "$ids" is not defined. (1:68)`}},
+		{"unsupported operation", `subscription { user(id: 1) { id } }`, []string{`The schema does not support subscription operations. (1:1)`}},
+		{"anonymous with others", `{ user(id: 1) { id } } query B { user(id: 2) { id } }`, []string{`This anonymous operation must be the only defined operation. (1:1)`}},
+		{"several errors", `{ user(id: 1) { nam } users(first: true) { id } }`, []string{
+			`Cannot query field "nam" on type "User". (1:17)`,
+			`Expected value of type "Int", found true. (1:36)`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := language.Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errs := Validate(s, doc)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("Validate() = %v, want %q", errs, tt.want)
+			}
+			for i, err := range errs {
+				if err.Error() != tt.want[i] {
+					t.Errorf("error %d = %q, want %q", i, err.Error(), tt.want[i])
+				}
+			}
+		})
+	}
+}
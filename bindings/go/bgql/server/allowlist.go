@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/manifest"
+)
+
+// AllowedOperations registers trusted documents keyed by document hash or
+// operation ID. A request may send just the key, as extensions.documentId
+// or a persisted query hash, to execute the document. With
+// Config.RejectUnknownOperations, only these documents are executed.
+func (b *Builder) AllowedOperations(documents map[string]string) *Builder {
+	for id, doc := range documents {
+		b.allowed[id] = doc
+	}
+	return b
+}
+
+// AllowedManifest registers the operations of a persisted operation
+// manifest as trusted documents, keyed by their hashes.
+func (b *Builder) AllowedManifest(m *manifest.Manifest) *Builder {
+	return b.AllowedOperations(m.Documents())
+}
+
+// resolveDocument fills in the query of a request that names a trusted
+// document, or applies automatic persisted queries. It reports whether the
+// query is a trusted document. Persisted queries cannot be registered while
+// unknown operations are rejected.
+func (s *Server) resolveDocument(ctx *Context, req *Request) (bool, *GraphQLError) {
+	id := documentID(req)
+	if req.Query == "" && id != "" {
+		if doc, ok := s.allowed[id]; ok {
+			req.Query = doc
+			return true, nil
+		}
+	}
+	trusted := req.Query != "" && s.allowedQueries[req.Query]
+
+	if s.config.RejectUnknownOperations {
+		if req.Query == "" && id != "" {
+			return false, operationNotAllowed(fmt.Sprintf("Unknown document %q.", id))
+		}
+		return trusted, nil
+	}
+	return trusted, s.resolvePersistedQuery(ctx, req)
+}
+
+// documentID returns the trusted document a request refers to, from
+// extensions.documentId or the persisted query hash.
+func documentID(req *Request) string {
+	if id, ok := req.Extensions["documentId"].(string); ok {
+		return id
+	}
+	if ext, ok := req.Extensions["persistedQuery"].(map[string]any); ok {
+		hash, _ := ext["sha256Hash"].(string)
+		return hash
+	}
+	return ""
+}
+
+// checkTrusted enforces Config.RejectUnknownOperations for an operation
+// that is not a trusted document.
+func (s *Server) checkTrusted(op *language.OperationDefinition) *GraphQLError {
+	if !s.config.RejectUnknownOperations {
+		return nil
+	}
+	if s.config.AllowUnknownIntrospection && isIntrospection(op) {
+		return nil
+	}
+	return operationNotAllowed("Operation is not allowed: only registered documents may be executed.")
+}
+
+// isIntrospection reports whether op selects only introspection fields.
+func isIntrospection(op *language.OperationDefinition) bool {
+	for _, sel := range op.SelectionSet {
+		field, ok := sel.(*language.Field)
+		if !ok || !strings.HasPrefix(field.Name, "__") {
+			return false
+		}
+	}
+	return len(op.SelectionSet) > 0
+}
+
+func operationNotAllowed(message string) *GraphQLError {
+	return &GraphQLError{Message: message, Extensions: map[string]any{"code": "OPERATION_NOT_ALLOWED"}}
+}
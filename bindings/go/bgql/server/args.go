@@ -0,0 +1,250 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+)
+
+// Validator validates decoded arguments, for example by their validate
+// struct tags. The Validate type of github.com/go-playground/validator
+// implements it.
+type Validator interface {
+	Struct(v any) error
+}
+
+// ArgsValidator sets the validator of the arguments DecodeArgs decodes
+// into structs.
+func (b *Builder) ArgsValidator(v Validator) *Builder {
+	b.argsValidator = v
+	return b
+}
+
+// DecodeArgs converts resolver arguments into a T, typically a struct.
+// Arguments are matched to struct fields by graphql tag, json tag, or
+// case-insensitive field name. Input objects decode into nested structs
+// and maps, lists into slices, and numbers into any numeric field that
+// holds them exactly. Struct fields that are not pointers, slices, maps,
+// or interfaces, and not tagged omitempty, are required.
+//
+// Errors are result.FieldErrors naming the argument, joined when several
+// arguments fail. A T with a Validate() error method is then validated,
+// followed by the Builder.ArgsValidator of the server executing ctx, if
+// any. ctx may be nil.
+func DecodeArgs[T any](ctx *Context, args map[string]any) (T, error) {
+	var out T
+	d := decoder{strict: true}
+	if err := d.assign(reflect.ValueOf(&out).Elem(), args, ""); err != nil {
+		return out, err
+	}
+	if v, ok := any(&out).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return out, err
+		}
+	}
+	if ctx != nil && ctx.argsValidator != nil && reflect.Indirect(reflect.ValueOf(out)).Kind() == reflect.Struct {
+		if err := ctx.argsValidator.Struct(out); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// decoder converts argument values. A strict decoder reports missing
+// required struct fields.
+type decoder struct {
+	strict bool
+}
+
+// assign stores value in dst, decoding maps into structs, converting list
+// items one by one, and converting numbers between kinds when no precision
+// is lost. path names the value in errors.
+func (d decoder) assign(dst reflect.Value, value any, path string) error {
+	if value == nil {
+		dst.SetZero()
+		return nil
+	}
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+		if err := d.assign(elem.Elem(), value, path); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		fields, ok := value.(map[string]any)
+		if !ok {
+			break
+		}
+		var errs []error
+		rt := dst.Type()
+		for i := range rt.NumField() {
+			sf := rt.Field(i)
+			name, exact, optional := argName(sf)
+			if !sf.IsExported() || name == "-" {
+				continue
+			}
+			v, found := lookupArg(fields, name, exact)
+			if !found || v == nil {
+				if d.strict && !optional && isRequired(sf.Type) {
+					errs = append(errs, &result.FieldError{Field: joinArgPath(path, name), Message: "is required"})
+				}
+				continue
+			}
+			if err := d.assign(dst.Field(i), v, joinArgPath(path, name)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+
+	case reflect.Map:
+		fields, ok := value.(map[string]any)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := reflect.MakeMapWithSize(dst.Type(), len(fields))
+		for name, v := range fields {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := d.assign(elem, v, joinArgPath(path, name)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(name).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(m)
+		return nil
+
+	case reflect.Slice:
+		if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+			break
+		}
+		items := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := range src.Len() {
+			if err := d.assign(items.Index(i), src.Index(i).Interface(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		dst.Set(items)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch {
+		case src.CanInt():
+			n = src.Int()
+		case src.CanUint() && src.Uint() <= math.MaxInt64:
+			n = int64(src.Uint())
+		case src.CanFloat() && src.Float() == math.Trunc(src.Float()) && math.Abs(src.Float()) <= 1<<53:
+			n = int64(src.Float())
+		default:
+			return argError(path, value, dst.Type())
+		}
+		if dst.OverflowInt(n) {
+			return argError(path, value, dst.Type())
+		}
+		dst.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch {
+		case src.CanUint():
+			n = src.Uint()
+		case src.CanInt() && src.Int() >= 0:
+			n = uint64(src.Int())
+		case src.CanFloat() && src.Float() >= 0 && src.Float() == math.Trunc(src.Float()) && src.Float() <= 1<<53:
+			n = uint64(src.Float())
+		default:
+			return argError(path, value, dst.Type())
+		}
+		if dst.OverflowUint(n) {
+			return argError(path, value, dst.Type())
+		}
+		dst.SetUint(n)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case src.CanFloat():
+			dst.SetFloat(src.Float())
+		case src.CanInt():
+			dst.SetFloat(float64(src.Int()))
+		case src.CanUint():
+			dst.SetFloat(float64(src.Uint()))
+		default:
+			return argError(path, value, dst.Type())
+		}
+		return nil
+	}
+
+	// Named types of the same kind, such as an enum mapped to a string type.
+	if src.Kind() == dst.Kind() && src.Type().ConvertibleTo(dst.Type()) {
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	return argError(path, value, dst.Type())
+}
+
+// argName returns the argument name of a struct field, from its graphql
+// or json tag or else its name, whether the name must match exactly, and
+// whether the tag marks the field omitempty.
+func argName(sf reflect.StructField) (name string, exact, optional bool) {
+	for _, key := range []string{"graphql", "json"} {
+		if tag, ok := sf.Tag.Lookup(key); ok {
+			name, opts, _ := strings.Cut(tag, ",")
+			optional := strings.Contains(","+opts+",", ",omitempty,")
+			if name == "" {
+				return sf.Name, false, optional
+			}
+			return name, true, optional
+		}
+	}
+	return sf.Name, false, false
+}
+
+// lookupArg finds the argument named name, ignoring case unless exact.
+func lookupArg(fields map[string]any, name string, exact bool) (any, bool) {
+	if v, ok := fields[name]; ok || exact {
+		return v, ok
+	}
+	for key, v := range fields {
+		if strings.EqualFold(key, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// isRequired reports whether a field of type t must be given.
+func isRequired(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return false
+	}
+	return true
+}
+
+func joinArgPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// argError reports an argument that cannot be decoded, as a validation
+// error naming the argument.
+func argError(path string, value any, t reflect.Type) error {
+	return &result.FieldError{Field: path, Message: fmt.Sprintf("cannot use %v (%T) as %s", value, value, t)}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+type filterArgs struct {
+	Name  string
+	Limit int `graphql:"first"`
+	Tags  []string
+	Owner *struct {
+		ID int64 `json:"id"`
+	}
+	Note string `json:"note,omitempty"`
+}
+
+type pageArgs struct {
+	Size int
+}
+
+func (a *pageArgs) Validate() error {
+	if a.Size > 100 {
+		return errors.New("size too large")
+	}
+	return nil
+}
+
+func TestDecodeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		want    filterArgs
+		wantErr []string
+	}{
+		{
+			name: "decoded",
+			args: map[string]any{"name": "a", "first": 2.0, "tags": []any{"x", "y"}, "owner": map[string]any{"id": 7}},
+			want: filterArgs{Name: "a", Limit: 2, Tags: []string{"x", "y"}, Owner: &struct {
+				ID int64 `json:"id"`
+			}{ID: 7}},
+		},
+		{
+			name:    "required",
+			args:    map[string]any{"tags": nil},
+			wantErr: []string{"Name", "first"},
+		},
+		{
+			name:    "tag names are exact",
+			args:    map[string]any{"Name": "a", "First": 2},
+			wantErr: []string{"first"},
+		},
+		{
+			name:    "lossy number",
+			args:    map[string]any{"name": "a", "first": 1.5},
+			wantErr: []string{"first"},
+		},
+		{
+			name:    "nested path",
+			args:    map[string]any{"name": "a", "first": 1, "tags": []any{"x", 2}},
+			wantErr: []string{"Tags[1]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeArgs[filterArgs](nil, tt.args)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("DecodeArgs = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			var fields []string
+			for _, e := range flattenErrors(err) {
+				var fe *result.FieldError
+				if !errors.As(e, &fe) {
+					t.Fatalf("error %v is not a FieldError", e)
+				}
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantErr, ",") {
+				t.Errorf("error fields = %v, want %v", fields, tt.wantErr)
+			}
+		})
+	}
+}
+
+// flattenErrors returns the errors joined in err.
+func flattenErrors(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	if err == nil {
+		return nil
+	}
+	return []error{err}
+}
+
+// validatorFunc adapts a function to a Validator.
+type validatorFunc func(v any) error
+
+func (f validatorFunc) Struct(v any) error { return f(v) }
+
+func TestArgsValidator(t *testing.T) {
+	var calls int
+	limit := validatorFunc(func(v any) error {
+		calls++
+		if v.(pageArgs).Size > 10 {
+			return errors.New("size above 10")
+		}
+		return nil
+	})
+	newServer := func(v Validator) *Server {
+		b := NewBuilder().Schema(`type Query { page(size: Int!): Int }`)
+		if v != nil {
+			b.ArgsValidator(v)
+		}
+		return TypedResolver(b, "Query", "page", func(ctx context.Context, p any, a pageArgs, info sdk.ResolverInfo) (int, error) {
+			return a.Size, nil
+		}).Build().Unwrap()
+	}
+	validated, plain := newServer(limit), newServer(nil)
+
+	tests := []struct {
+		name    string
+		server  *Server
+		size    int
+		wantErr string
+	}{
+		{"valid", validated, 5, ""},
+		{"validator rejects", validated, 20, "size above 10"},
+		{"Validate rejects first", validated, 200, "size too large"},
+		{"other server unaffected", plain, 20, ""},
+		{"Validate without validator", plain, 200, "size too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs := postGraphQL(t, tt.server, fmt.Sprintf("{ page(size: %d) }", tt.size))
+			if tt.wantErr == "" {
+				if len(errs) > 0 || string(data) != fmt.Sprintf(`{"page":%d}`, tt.size) {
+					t.Fatalf("response = %s, %v", data, errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Message, tt.wantErr) {
+				t.Fatalf("errors = %v, want %q", errs, tt.wantErr)
+			}
+		})
+	}
+	if calls != 2 {
+		t.Errorf("validator calls = %d, want 2", calls)
+	}
+
+	// DecodeArgs outside a server only runs Validate.
+	if _, err := DecodeArgs[pageArgs](NewContext(context.Background(), nil), map[string]any{"size": 20}); err != nil {
+		t.Errorf("DecodeArgs with a bare context = %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// CacheScope is the scope of a @cacheControl hint.
+type CacheScope string
+
+const (
+	// CacheScopePublic responses are the same for every user.
+	CacheScopePublic CacheScope = "PUBLIC"
+	// CacheScopePrivate responses depend on the user.
+	CacheScopePrivate CacheScope = "PRIVATE"
+)
+
+// CachePolicy is how long the response to a query may be cached, computed
+// from the @cacheControl(maxAge: Int, scope: PUBLIC | PRIVATE) hints of
+// the fields it selects and their types.
+//
+// A field's maxAge comes from its hint, or else from the hint of the type
+// it returns. Root fields and fields returning objects, interfaces, or
+// unions without either have a maxAge of 0; other fields take their
+// parent's. The policy has the lowest maxAge, and is private if any hint
+// is.
+type CachePolicy struct {
+	MaxAge time.Duration
+	Scope  CacheScope
+}
+
+// Cacheable reports whether the response may be cached.
+func (p CachePolicy) Cacheable() bool {
+	return p.MaxAge > 0
+}
+
+// CachePolicy returns the cache policy of the query being executed. It is
+// zero for mutations and before execution.
+func (c *Context) CachePolicy() CachePolicy {
+	if c.state == nil {
+		return CachePolicy{}
+	}
+	return c.state.cachePolicy
+}
+
+// ResponseCache stores the data of query responses under keys derived
+// from the document, variables, cache-relevant headers, and, for private
+// responses, the user.
+type ResponseCache interface {
+	Get(ctx context.Context, key string) (data []byte, ok bool, err error)
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+// ResponseCache enables caching the responses of cacheable queries in
+// cache, for their policy's maxAge. Responses with errors are not cached.
+// A cached response's Data is a json.RawMessage.
+func (b *Builder) ResponseCache(cache ResponseCache) *Builder {
+	b.responseCache = cache
+	return b
+}
+
+// CacheUserID sets how the authenticated user of a request is identified,
+// so private responses are cached per user. Private responses are not
+// cached when fn is unset or returns "".
+func (b *Builder) CacheUserID(fn func(ctx *Context) string) *Builder {
+	b.cacheUserID = fn
+	return b
+}
+
+// executeCached executes a query operation, serving it from the response
+// cache when its policy allows.
+func (e *executor) executeCached(root *schema.Type, op *language.OperationDefinition, resp *Response) {
+	s := e.server
+	maxAge, private := e.cachePolicy(root, op.SelectionSet, true, nil)
+	policy := CachePolicy{MaxAge: time.Duration(max(maxAge, 0)) * time.Second, Scope: CacheScopePublic}
+	if private {
+		policy.Scope = CacheScopePrivate
+	}
+	e.ctx.shared().cachePolicy = policy
+
+	key, ok := s.responseCacheKey(e.ctx, policy)
+	if !ok {
+		e.executeOperation(root, op, resp)
+		return
+	}
+	if data, found, err := s.responseCache.Get(e.ctx, key); err == nil && found {
+		resp.Data = json.RawMessage(data)
+		return
+	}
+	e.executeOperation(root, op, resp)
+	if len(resp.Errors) > 0 {
+		return
+	}
+	if data, err := json.Marshal(resp.Data); err == nil {
+		s.responseCache.Set(e.ctx, key, data, policy.MaxAge)
+	}
+}
+
+// responseCacheKey returns the response cache key of the request of ctx,
+// or false if its response must not be cached.
+func (s *Server) responseCacheKey(ctx *Context, policy CachePolicy) (string, bool) {
+	if s.responseCache == nil || !policy.Cacheable() {
+		return "", false
+	}
+	req := ctx.GraphQL
+	h := sha256.New()
+	variables, err := json.Marshal(req.Variables)
+	if err != nil {
+		return "", false
+	}
+	fmt.Fprintf(h, "%q %q %s", req.Query, req.OperationName, variables)
+	for _, name := range s.config.ResponseCacheHeaders {
+		var values []string
+		if ctx.Request != nil {
+			values = ctx.Request.Header.Values(name)
+		}
+		fmt.Fprintf(h, " %s=%q", http.CanonicalHeaderKey(name), values)
+	}
+	if policy.Scope == CacheScopePrivate {
+		var user string
+		if s.cacheUserID != nil {
+			user = s.cacheUserID(ctx)
+		}
+		if user == "" {
+			return "", false
+		}
+		fmt.Fprintf(h, " user=%q", user)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// cacheControlHeader returns the Cache-Control header for the responses of
+// a request, or "" unless every operation is a cacheable query that
+// succeeded.
+func cacheControlHeader(ctxs []*Context, resps []*Response) string {
+	var policy CachePolicy
+	for i, ctx := range ctxs {
+		p := ctx.CachePolicy()
+		if !p.Cacheable() || len(resps[i].Errors) > 0 {
+			return ""
+		}
+		if i == 0 || p.MaxAge < policy.MaxAge {
+			policy.MaxAge = p.MaxAge
+		}
+		if p.Scope == CacheScopePrivate || policy.Scope == "" {
+			policy.Scope = p.Scope
+		}
+	}
+	return "max-age=" + strconv.Itoa(int(policy.MaxAge/time.Second)) + ", " + strings.ToLower(string(policy.Scope))
+}
+
+// cachePolicy returns the lowest maxAge of the fields of a selection set,
+// -1 if none constrains it, and whether any hint is private. Fragments
+// are followed as for complexity.
+func (e *executor) cachePolicy(t *schema.Type, set language.SelectionSet, root bool, visited map[string]bool) (maxAge int, private bool) {
+	maxAge = -1
+	merge := func(age int, priv bool) {
+		if age >= 0 && (maxAge < 0 || age < maxAge) {
+			maxAge = age
+		}
+		private = private || priv
+	}
+
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			if !e.shouldInclude(sel.Directives) || sel.Name == "__typename" {
+				continue
+			}
+			def := e.schema.FieldDefinition(t, sel.Name)
+			if def == nil {
+				continue
+			}
+			age, priv := cacheHint(def.Directives)
+			child := e.schema.Type(def.Type.Name())
+			composite := child != nil && child.IsComposite()
+			if composite {
+				typeAge, typePriv := cacheHint(child.Directives)
+				if age < 0 {
+					age = typeAge
+				}
+				priv = priv || typePriv
+			}
+			if age < 0 && (root || composite) {
+				age = 0
+			}
+			merge(age, priv)
+			if composite {
+				merge(e.cachePolicy(child, sel.SelectionSet, false, visited))
+			}
+		case *language.InlineFragment:
+			if !e.shouldInclude(sel.Directives) {
+				continue
+			}
+			merge(e.cachePolicy(e.conditionType(t, sel.TypeCondition), sel.SelectionSet, root, visited))
+		case *language.FragmentSpread:
+			frag := e.doc.Fragment(sel.Name)
+			if frag == nil || visited[sel.Name] || !e.shouldInclude(sel.Directives) {
+				continue
+			}
+			if visited == nil {
+				visited = make(map[string]bool)
+			}
+			visited[sel.Name] = true
+			merge(e.cachePolicy(e.conditionType(t, frag.TypeCondition), frag.SelectionSet, root, visited))
+			delete(visited, sel.Name)
+		}
+	}
+	return maxAge, private
+}
+
+// cacheHint returns the maxAge of a @cacheControl directive, or -1, and
+// whether its scope is PRIVATE.
+func cacheHint(directives language.DirectiveList) (maxAge int, private bool) {
+	maxAge = -1
+	d := directives.ForName("cacheControl")
+	if d == nil {
+		return maxAge, false
+	}
+	if arg := d.Arguments.ForName("maxAge"); arg != nil {
+		if v, ok := arg.Value.(*language.IntValue); ok {
+			if n, err := strconv.Atoi(v.Value); err == nil && n >= 0 {
+				maxAge = n
+			}
+		}
+	}
+	if arg := d.Arguments.ForName("scope"); arg != nil {
+		if v, ok := arg.Value.(*language.EnumValue); ok {
+			private = v.Value == string(CacheScopePrivate)
+		}
+	}
+	return maxAge, private
+}
+
+// =============================================================================
+// LRU Cache
+// =============================================================================
+
+// LRUResponseCache keeps the most recently used responses in memory until
+// they expire.
+type LRUResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type responseEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+// NewLRUResponseCache creates a cache holding up to capacity responses.
+func NewLRUResponseCache(capacity int) *LRUResponseCache {
+	return &LRUResponseCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the response stored for key, unless it expired.
+func (c *LRUResponseCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*responseEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return entry.data, true, nil
+}
+
+// Set stores a response for ttl, evicting the least recently used
+// response when full.
+func (c *LRUResponseCache) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &responseEntry{key: key, data: data, expires: time.Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseEntry).key)
+	}
+	return nil
+}
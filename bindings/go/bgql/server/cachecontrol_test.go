@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type spanKey struct{}
+
+func TestCacheControlHeader(t *testing.T) {
+	const sdl = `
+		directive @cacheControl(maxAge: Int, scope: CacheControlScope) on FIELD_DEFINITION | OBJECT
+		enum CacheControlScope { PUBLIC PRIVATE }
+		type Query {
+			news: String @cacheControl(maxAge: 60)
+			me: String @cacheControl(maxAge: 120, scope: PRIVATE)
+			live: String
+		}
+		type Mutation { touch: String }`
+	// replaceContext stands for middleware, like tracing, that passes a
+	// WithContext copy on: the policy computed below it must still reach
+	// the header.
+	replaceContext := func(ctx *Context, next func(*Context) *Response) *Response {
+		return next(ctx.WithContext(context.WithValue(ctx.Context, spanKey{}, "span")))
+	}
+
+	tests := []struct {
+		name       string
+		middleware []Middleware
+		query      string
+		want       string
+	}{
+		{"public", nil, "{ news }", "max-age=60, public"},
+		{"private", nil, "{ news me }", "max-age=60, private"},
+		{"uncached field", nil, "{ news live }", ""},
+		{"mutation", nil, "mutation { touch }", ""},
+		{"replaced context", []Middleware{replaceContext}, "{ news }", "max-age=60, public"},
+		{"replaced twice", []Middleware{replaceContext, replaceContext}, "{ me }", "max-age=120, private"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder().Schema(sdl)
+			for _, field := range []string{"news", "me", "live"} {
+				b.Resolver("Query", field, func(ctx *Context, p any, a map[string]any) (any, error) { return "x", nil })
+			}
+			s := b.Resolver("Mutation", "touch", func(ctx *Context, p any, a map[string]any) (any, error) { return "x", nil }).
+				Build().Unwrap()
+			for _, m := range tt.middleware {
+				s.Use(m)
+			}
+
+			body, _ := json.Marshal(Request{Query: tt.query})
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if got := rec.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q (body %s)", got, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// listSizeArgs are the arguments whose value multiplies the cost of a
+// field's selection set.
+var listSizeArgs = []string{"first", "last", "limit"}
+
+// complexity returns the cost of a selection set, including fields reached
+// through fragments. Fields cost what their FieldCostFn returns, or by
+// default 1 plus their selection set's cost times their list size argument.
+func (e *executor) complexity(t *schema.Type, set language.SelectionSet, visited map[string]bool) int {
+	cost := 0
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			if !e.shouldInclude(sel.Directives) {
+				continue
+			}
+			def := e.schema.FieldDefinition(t, sel.Name)
+			if def == nil {
+				cost++
+				continue
+			}
+			childCost := 0
+			if child := e.schema.Type(def.Type.Name()); child != nil && len(sel.SelectionSet) > 0 {
+				childCost = e.complexity(child, sel.SelectionSet, visited)
+			}
+			args, _ := e.argumentValues(def.Args, sel.Arguments)
+			if fn := e.server.costs[t.Name][sel.Name]; fn != nil {
+				cost += fn(args, childCost)
+			} else {
+				cost += 1 + childCost*listSize(args)
+			}
+		case *language.InlineFragment:
+			if !e.shouldInclude(sel.Directives) {
+				continue
+			}
+			cost += e.complexity(e.conditionType(t, sel.TypeCondition), sel.SelectionSet, visited)
+		case *language.FragmentSpread:
+			frag := e.doc.Fragment(sel.Name)
+			if frag == nil || visited[sel.Name] || !e.shouldInclude(sel.Directives) {
+				continue
+			}
+			if visited == nil {
+				visited = make(map[string]bool)
+			}
+			visited[sel.Name] = true
+			cost += e.complexity(e.conditionType(t, frag.TypeCondition), frag.SelectionSet, visited)
+			delete(visited, sel.Name)
+		}
+	}
+	return cost
+}
+
+// listSize returns the first positive list size argument, or 1.
+func listSize(args map[string]any) int {
+	for _, name := range listSizeArgs {
+		switch n := args[name].(type) {
+		case int:
+			if n > 0 {
+				return n
+			}
+		case float64:
+			if n > 0 {
+				return int(n)
+			}
+		}
+	}
+	return 1
+}
+
+// depth returns the deepest field nesting of a selection set, looking
+// through fragments. A fragment already being expanded is skipped, so
+// cyclic fragments terminate.
+func (e *executor) depth(set language.SelectionSet, visited map[string]bool) int {
+	deepest := 0
+	for _, sel := range set {
+		d := 0
+		switch sel := sel.(type) {
+		case *language.Field:
+			if e.server.config.DepthIgnoresIntrospection && strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			d = 1 + e.depth(sel.SelectionSet, visited)
+		case *language.InlineFragment:
+			d = e.depth(sel.SelectionSet, visited)
+		case *language.FragmentSpread:
+			frag := e.doc.Fragment(sel.Name)
+			if frag == nil || visited[sel.Name] {
+				continue
+			}
+			visited[sel.Name] = true
+			d = e.depth(frag.SelectionSet, visited)
+			delete(visited, sel.Name)
+		}
+		if d > deepest {
+			deepest = d
+		}
+	}
+	return deepest
+}
+
+// conditionType returns the type named by a fragment type condition, or
+// t when the fragment has none.
+func (e *executor) conditionType(t *schema.Type, condition string) *schema.Type {
+	if ct := e.schema.Type(condition); ct != nil {
+		return ct
+	}
+	return t
+}
+
+// complexityExtension reports the cost of operations in the query cost
+// response header and extension.
+type complexityExtension struct {
+	limit int
+}
+
+func (x complexityExtension) OnValidate(ctx *Context, errs []GraphQLError) {
+	cost, ok := ctx.Complexity()
+	if !ok {
+		return
+	}
+	ctx.ResponseHeader().Set(QueryCostHeader, strconv.Itoa(cost))
+	ext := map[string]any{"cost": cost}
+	if x.limit > 0 {
+		ext["maxComplexity"] = x.limit
+	}
+	ctx.SetExtension("complexity", ext)
+}
+
+// checkComplexity enforces Config.MaxComplexity.
+func (e *executor) checkComplexity(op *language.OperationDefinition, cost int) *GraphQLError {
+	limit := e.server.config.MaxComplexity
+	if limit <= 0 || cost <= limit {
+		return nil
+	}
+	err := validationError(op.Loc, "Query complexity %d exceeds the maximum complexity of %d.", cost, limit)
+	err.Extensions = map[string]any{
+		"code":          "MAX_COMPLEXITY_EXCEEDED",
+		"complexity":    cost,
+		"maxComplexity": limit,
+	}
+	return &err
+}
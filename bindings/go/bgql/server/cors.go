@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures cross-origin requests to the GraphQL endpoint. No
+// CORS headers are sent unless AllowedOrigins or AllowOrigin is set. They
+// also decide the pages of other origins that may open WebSocket
+// connections; see checkWebSocketOrigin.
+type CORSConfig struct {
+	// AllowedOrigins lists allowed origins. "*" allows any origin, and a
+	// "*" within an entry, as in "https://*.example.com", matches any
+	// characters.
+	AllowedOrigins []string
+	// AllowOrigin decides dynamically, for origins AllowedOrigins does not
+	// list.
+	AllowOrigin func(origin string) bool
+	// AllowedHeaders lists the request headers allowed in preflights.
+	// When empty, Content-Type and Authorization are allowed.
+	AllowedHeaders []string
+	// ExposedHeaders lists response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is how long preflight results may be cached.
+	MaxAge time.Duration
+}
+
+// enabled reports whether any origin may be allowed.
+func (c *CORSConfig) enabled() bool {
+	return len(c.AllowedOrigins) > 0 || c.AllowOrigin != nil
+}
+
+// allows reports whether origin is allowed, and whether it matched "*".
+func (c *CORSConfig) allows(origin string) (allowed, anyOrigin bool) {
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			return true, true
+		}
+		if matchOrigin(pattern, origin) {
+			return true, false
+		}
+	}
+	return c.AllowOrigin != nil && c.AllowOrigin(origin), false
+}
+
+// matchOrigin matches origin against pattern, in which one "*" matches
+// any characters.
+func matchOrigin(pattern, origin string) bool {
+	before, after, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return strings.EqualFold(pattern, origin)
+	}
+	origin = strings.ToLower(origin)
+	before, after = strings.ToLower(before), strings.ToLower(after)
+	return len(origin) >= len(before)+len(after) &&
+		strings.HasPrefix(origin, before) && strings.HasSuffix(origin, after)
+}
+
+// handleCORS sets the CORS headers of a response. It reports true when r
+// was a preflight, which has then been answered.
+func (s *Server) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	cors := &s.config.CORS
+	if !cors.enabled() {
+		return false
+	}
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	origin := r.Header.Get("Origin")
+	if allowed, anyOrigin := cors.allows(origin); origin != "" && allowed {
+		if anyOrigin && !cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			allowedHeaders := cors.AllowedHeaders
+			if len(allowedHeaders) == 0 {
+				allowedHeaders = []string{"Content-Type", "Authorization"}
+			}
+			header.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+			if cors.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+			}
+		} else if len(cors.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+		}
+	}
+
+	if preflight {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return preflight
+}
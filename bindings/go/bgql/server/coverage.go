@@ -0,0 +1,200 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// CoverageMode sets what Build does about resolver coverage issues.
+type CoverageMode int
+
+const (
+	// CoverageIgnore skips the coverage check.
+	CoverageIgnore CoverageMode = iota
+	// CoverageWarn logs coverage issues.
+	CoverageWarn
+	// CoverageFail makes Build fail on coverage issues.
+	CoverageFail
+)
+
+// CoverageIssueKind classifies a CoverageIssue.
+type CoverageIssueKind string
+
+const (
+	// CoverageMissingRootResolver is a Query, Mutation, or Subscription
+	// field with no resolver.
+	CoverageMissingRootResolver CoverageIssueKind = "MISSING_ROOT_RESOLVER"
+	// CoverageUnresolvedField is an object field with no resolver whose
+	// parent value cannot provide it: no resolver returns the object type,
+	// or the Go type a typed resolver returns has no matching field or
+	// method.
+	CoverageUnresolvedField CoverageIssueKind = "UNRESOLVED_FIELD"
+	// CoverageUnknownField is a resolver registered for a type or field
+	// the schema does not define. Build always fails on these.
+	CoverageUnknownField CoverageIssueKind = "UNKNOWN_FIELD"
+)
+
+// CoverageIssue is a schema field that will likely resolve to null, or a
+// resolver that will never run.
+type CoverageIssue struct {
+	Kind    CoverageIssueKind
+	Type    string
+	Field   string
+	Message string
+}
+
+func (i CoverageIssue) String() string {
+	return i.Message
+}
+
+// Validate cross-references the schema with the registered resolvers and
+// returns the coverage issues, in schema order. It returns nil if the
+// schema does not build; Build reports why.
+func (b *Builder) Validate() []CoverageIssue {
+	parsed, err := b.buildSchema()
+	if err != nil {
+		return nil
+	}
+	issues := unknownResolvers(parsed, b.resolvers)
+	return append(issues, b.coverage(parsed)...)
+}
+
+// checkCoverage applies Config.ResolverCoverage to the coverage issues of
+// the schema, other than unknown resolvers, which checkSchema reports.
+func (b *Builder) checkCoverage(parsed *schema.Schema) language.ErrorList {
+	if b.config.ResolverCoverage == CoverageIgnore {
+		return nil
+	}
+	var errs language.ErrorList
+	for _, issue := range b.coverage(parsed) {
+		if b.config.ResolverCoverage == CoverageFail {
+			errs = append(errs, language.NewError(nil, "%s", issue.Message))
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[bgql] warning: %s\n", issue.Message)
+	}
+	return errs
+}
+
+// coverage reports root fields without resolvers, and fields without
+// resolvers of object types whose values no resolver produces.
+func (b *Builder) coverage(s *schema.Schema) []CoverageIssue {
+	resolved := func(t *schema.Type, f *schema.Field) bool {
+		if _, ok := b.resolvers[t.Name][f.Name]; ok {
+			return true
+		}
+		_, ok := b.subscriptions[f.Name]
+		return ok && t == s.Subscription
+	}
+
+	// An object type is produced when a resolver returns it, or when it is
+	// read from the value of a produced type by a field without one.
+	produced := make(map[*schema.Type]bool)
+	var produce func(name string)
+	produce = func(name string) {
+		t := s.Type(name)
+		if t == nil || produced[t] || !t.IsComposite() {
+			return
+		}
+		produced[t] = true
+		for _, possible := range s.PossibleTypes(t) {
+			produce(possible.Name)
+		}
+		for _, f := range t.Fields {
+			if t.Kind == schema.Object && !resolved(t, f) {
+				produce(f.Type.Name())
+			}
+		}
+	}
+	for _, t := range s.Types() {
+		for _, f := range t.Fields {
+			if t.Kind == schema.Object && resolved(t, f) {
+				produce(f.Type.Name())
+			}
+		}
+	}
+
+	var issues []CoverageIssue
+	for _, t := range s.Types() {
+		if t.BuiltIn || t.Kind != schema.Object {
+			continue
+		}
+		root := t == s.Query || t == s.Mutation || t == s.Subscription
+		for _, f := range t.Fields {
+			if resolved(t, f) {
+				continue
+			}
+			issue := CoverageIssue{Kind: CoverageUnresolvedField, Type: t.Name, Field: f.Name}
+			switch {
+			case root:
+				issue.Kind = CoverageMissingRootResolver
+				issue.Message = fmt.Sprintf("root field %s.%s has no resolver", t.Name, f.Name)
+			case !produced[t]:
+				issue.Message = fmt.Sprintf("field %s.%s has no resolver, and no resolver returns %s values", t.Name, f.Name, t.Name)
+			default:
+				goType := b.missingSource(s, t, f)
+				if goType == nil {
+					continue
+				}
+				issue.Message = fmt.Sprintf("field %s.%s has no resolver, and %s has no field or method %s", t.Name, f.Name, goType, f.Name)
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// missingSource returns the Go type returned for t by a typed resolver
+// that the default resolver cannot read f from, or nil.
+func (b *Builder) missingSource(s *schema.Schema, t *schema.Type, f *schema.Field) reflect.Type {
+	for _, typeName := range slices.Sorted(maps.Keys(b.results)) {
+		for _, fieldName := range slices.Sorted(maps.Keys(b.results[typeName])) {
+			parent := s.Type(typeName)
+			if parent == nil || parent.Field(fieldName) == nil || parent.Field(fieldName).Type.Name() != t.Name {
+				continue
+			}
+			goType := b.results[typeName][fieldName]
+			for goType.Kind() == reflect.Pointer || goType.Kind() == reflect.Slice || goType.Kind() == reflect.Array {
+				goType = goType.Elem()
+			}
+			if goType.Kind() != reflect.Struct {
+				continue
+			}
+			if _, ok := fieldIndex(goType, f.Name); ok {
+				continue
+			}
+			if _, ok := methodIndex(reflect.PointerTo(goType), f.Name); ok {
+				continue
+			}
+			return goType
+		}
+	}
+	return nil
+}
+
+// unknownResolvers reports resolvers registered for types or fields the
+// schema does not define.
+func unknownResolvers(s *schema.Schema, resolvers map[string]map[string]ResolverFn) []CoverageIssue {
+	var issues []CoverageIssue
+	for _, typeName := range slices.Sorted(maps.Keys(resolvers)) {
+		t := s.Type(typeName)
+		for _, fieldName := range slices.Sorted(maps.Keys(resolvers[typeName])) {
+			if t != nil && t.Field(fieldName) != nil {
+				continue
+			}
+			issues = append(issues, CoverageIssue{
+				Kind:    CoverageUnknownField,
+				Type:    typeName,
+				Field:   fieldName,
+				Message: fmt.Sprintf("resolver registered for unknown field %s.%s", typeName, fieldName),
+			})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// csrfPreflightHeaders are headers a simple form cannot send, so a request
+// carrying one must have passed a CORS preflight.
+var csrfPreflightHeaders = []string{"X-Apollo-Operation-Name", "Apollo-Require-Preflight"}
+
+// csrfSimpleContentTypes are the content types a simple form POST can
+// produce without a preflight.
+var csrfSimpleContentTypes = map[string]bool{
+	"application/x-www-form-urlencoded": true,
+	"multipart/form-data":               true,
+	"text/plain":                        true,
+}
+
+// checkCSRF enforces Config.CSRFPrevention: a request must either have a
+// content type that forces a CORS preflight, or carry one of
+// csrfPreflightHeaders. It reports false after rejecting r.
+func (s *Server) checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	if !s.config.CSRFPrevention {
+		return true
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || !csrfSimpleContentTypes[mediaType] {
+			return true
+		}
+	}
+	for _, name := range csrfPreflightHeaders {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+
+	writeRequestError(w, http.StatusBadRequest, GraphQLError{
+		Message: "This operation has been blocked as a potential Cross-Site Request Forgery (CSRF). " +
+			"Please either specify a Content-Type header that is not application/x-www-form-urlencoded, " +
+			"multipart/form-data, or text/plain, or provide a non-empty value for one of the following headers: " +
+			strings.Join(csrfPreflightHeaders, ", "),
+		Extensions: map[string]any{"code": "BAD_REQUEST"},
+	})
+	return false
+}
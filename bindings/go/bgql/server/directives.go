@@ -0,0 +1,92 @@
+package server
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// DirectiveFn implements a schema directive by wrapping the resolver of
+// each field the directive is applied to. args are the directive's
+// arguments in the SDL.
+type DirectiveFn func(next ResolverFn, args map[string]any) ResolverFn
+
+// Directive registers the behavior of a directive declared in the schema
+// on FIELD_DEFINITION. When a field has several, the first one applied
+// runs first.
+func (b *Builder) Directive(name string, fn DirectiveFn) *Builder {
+	b.directives[name] = fn
+	return b
+}
+
+// checkDirectives reports handlers for directives the schema does not
+// declare on field definitions.
+func checkDirectives(s *schema.Schema, directives map[string]DirectiveFn) language.ErrorList {
+	var errs language.ErrorList
+	for _, name := range slices.Sorted(maps.Keys(directives)) {
+		if d := s.Directive(name); d == nil || !d.HasLocation("FIELD_DEFINITION") {
+			errs = append(errs, language.NewError(nil, "directive handler registered for unknown field directive @%s", name))
+		}
+	}
+	return errs
+}
+
+// applyDirectives returns registered with the resolvers of fields of sch
+// that have directives with registered handlers wrapped. Directive
+// arguments are coerced with scalars.
+func (s *Server) applyDirectives(sch *schema.Schema, scalars map[string]ScalarConfig, registered map[string]map[string]ResolverFn, directives map[string]DirectiveFn) (map[string]map[string]ResolverFn, language.ErrorList) {
+	if len(directives) == 0 {
+		return registered, nil
+	}
+
+	var errs language.ErrorList
+	resolvers := make(map[string]map[string]ResolverFn, len(registered))
+	for typeName, fields := range registered {
+		resolvers[typeName] = maps.Clone(fields)
+	}
+
+	e := &executor{server: s, schema: sch, scalars: scalars}
+	for _, t := range sch.Types() {
+		if t.BuiltIn || t.Kind != schema.Object {
+			continue
+		}
+		for _, field := range t.Fields {
+			fn := resolvers[t.Name][field.Name]
+			wrapped := false
+			for i := len(field.Directives) - 1; i >= 0; i-- {
+				d := field.Directives[i]
+				handler := directives[d.Name]
+				if handler == nil {
+					continue
+				}
+				args, err := e.argumentValues(sch.Directive(d.Name).Args, d.Arguments)
+				if err != nil {
+					errs = append(errs, language.NewError([]language.Location{d.Loc}, "@%s on %s.%s: %s", d.Name, t.Name, field.Name, err))
+					continue
+				}
+				if fn == nil {
+					fn = defaultResolverFn(field.Name)
+				}
+				fn = handler(fn, args)
+				wrapped = true
+			}
+			if wrapped {
+				if resolvers[t.Name] == nil {
+					resolvers[t.Name] = make(map[string]ResolverFn)
+				}
+				resolvers[t.Name][field.Name] = fn
+			}
+		}
+	}
+
+	return resolvers, errs
+}
+
+// defaultResolverFn is the resolver used for fields without one.
+func defaultResolverFn(name string) ResolverFn {
+	return func(_ *Context, parent any, _ map[string]any) (any, error) {
+		return defaultResolver(parent, name)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+)
+
+// ErrorPresenter converts an error a resolver returned into the error sent
+// to the client. A path or locations left empty are filled in. Errors the
+// server produces about the request itself are not presented.
+type ErrorPresenter func(ctx *Context, err error) GraphQLError
+
+// ErrorPresenter sets how execution errors are presented to clients. See
+// MaskInternalErrors.
+func (b *Builder) ErrorPresenter(fn ErrorPresenter) *Builder {
+	b.errorPresenter = fn
+	return b
+}
+
+// Errors returns the original errors of the response, before they were
+// presented, in the order of Response.Errors.
+func (c *Context) Errors() []error {
+	return c.errors
+}
+
+// presentErrors records the original errors of resp in ctx and passes
+// those returned by resolvers through the error presenter.
+func (s *Server) presentErrors(ctx *Context, resp *Response) {
+	if resp == nil {
+		return
+	}
+	for i, gqlErr := range resp.Errors {
+		if gqlErr.cause == nil {
+			ctx.errors = append(ctx.errors, gqlErr)
+			continue
+		}
+		err := gqlErr.cause
+		ctx.errors = append(ctx.errors, err)
+		if s.errorPresenter == nil {
+			continue
+		}
+
+		presented := s.errorPresenter(ctx, err)
+		if presented.Path == nil {
+			presented.Path = gqlErr.Path
+		}
+		if presented.Locations == nil {
+			presented.Locations = gqlErr.Locations
+		}
+		resp.Errors[i] = presented
+	}
+}
+
+// =============================================================================
+// Masking
+// =============================================================================
+
+// ClientSafeError is implemented by errors whose message may be shown to
+// clients.
+type ClientSafeError interface {
+	error
+	ClientSafe() bool
+}
+
+// clientSafeCodes are the extensions codes of errors the server reports
+// about the request itself.
+var clientSafeCodes = map[string]bool{
+	"BAD_REQUEST":                   true,
+	"BAD_USER_INPUT":                true,
+	"COST_LIMIT_EXCEEDED":           true,
+	"GRAPHQL_TIMEOUT":               true,
+	"INTERNAL_SERVER_ERROR":         true,
+	"INTROSPECTION_DISABLED":        true,
+	"OPERATION_NOT_ALLOWED":         true,
+	"PAYLOAD_TOO_LARGE":             true,
+	"PERSISTED_QUERY_HASH_MISMATCH": true,
+	"PERSISTED_QUERY_NOT_FOUND":     true,
+	"PERSISTED_QUERY_NOT_SUPPORTED": true,
+	"RATE_LIMITED":                  true,
+	"REQUEST_TOO_LARGE":             true,
+	"SERVICE_UNAVAILABLE":           true,
+	"UNAUTHENTICATED":               true,
+}
+
+// MaskInternalErrors returns a presenter that hides the messages of
+// internal errors, such as database failures, from clients. Input
+// validation errors, GraphQLErrors with a known extensions code, and
+// errors whose ClientSafe method reports true are kept. Anything else is
+// replaced by replacement, with a correlation ID that is logged with the
+// original error.
+func MaskInternalErrors(replacement string) ErrorPresenter {
+	if replacement == "" {
+		replacement = "Internal server error."
+	}
+	return func(ctx *Context, err error) GraphQLError {
+		var safe ClientSafeError
+		if errors.As(err, &safe) && safe.ClientSafe() {
+			return GraphQLError{Message: err.Error()}
+		}
+		var fieldErr *result.FieldError
+		if errors.As(err, &fieldErr) {
+			return GraphQLError{Message: fieldErr.Error(), Extensions: map[string]any{"field": fieldErr.Field}}
+		}
+		var gqlErr GraphQLError
+		if errors.As(err, &gqlErr) && hasClientSafeCode(gqlErr) {
+			return gqlErr
+		}
+		var gqlErrPtr *GraphQLError
+		if errors.As(err, &gqlErrPtr) && hasClientSafeCode(*gqlErrPtr) {
+			return *gqlErrPtr
+		}
+
+		id := correlationID()
+		fmt.Fprintf(os.Stderr, "[bgql] error %s: %v\n", id, err)
+		return GraphQLError{
+			Message:    replacement,
+			Extensions: map[string]any{"code": "INTERNAL_SERVER_ERROR", "correlationId": id},
+		}
+	}
+}
+
+func hasClientSafeCode(err GraphQLError) bool {
+	code, _ := err.Extensions["code"].(string)
+	return clientSafeCodes[code]
+}
+
+// correlationID returns a random ID that ties a masked error to its log
+// line.
+func correlationID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
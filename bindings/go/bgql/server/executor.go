@@ -0,0 +1,1194 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// executor runs a single operation against the server's schema.
+type executor struct {
+	server    *Server
+	schema    *schema.Schema
+	resolvers map[string]map[string]ResolverFn
+	scalars   map[string]ScalarConfig
+	doc       *language.Document
+	ctx       *Context
+	variables map[string]any
+
+	// mu guards errors, timedOut, and setting aborted, which fields
+	// resolving concurrently update.
+	mu       sync.Mutex
+	errors   []GraphQLError
+	aborted  atomic.Bool
+	timeout  time.Duration
+	timedOut bool
+
+	// sched runs the resolvers of fields resolving concurrently. It is
+	// shared by the executors of a subscription's events.
+	sched *scheduler
+
+	fieldMiddlewares []FieldMiddleware
+	hooks            *extensionHooks
+}
+
+// abortError is returned by Abort.
+type abortError struct {
+	err error
+}
+
+func (e *abortError) Error() string { return e.err.Error() }
+func (e *abortError) Unwrap() error { return e.err }
+
+// Abort wraps err so that, when returned from a resolver, execution of the
+// whole operation stops: no further fields are resolved, the remaining
+// fields are null, and err is reported as a single top-level error.
+func Abort(err error) error {
+	return &abortError{err: err}
+}
+
+// IsAbort reports whether err was created by Abort.
+func IsAbort(err error) bool {
+	var abort *abortError
+	return errors.As(err, &abort)
+}
+
+func (s *Server) doExecute(ctx *Context, req *Request) *Response {
+	e, root, op, resp := s.prepare(ctx, req)
+	if e == nil {
+		return resp
+	}
+	if op.Operation == language.Subscription {
+		resp.Errors = []GraphQLError{validationError(op.Loc, "Subscription operations must be sent over a WebSocket or text/event-stream connection.")}
+		return resp
+	}
+	if timeout := s.config.ExecutionTimeout; timeout > 0 {
+		execCtx, cancel := context.WithTimeout(e.ctx, timeout)
+		defer cancel()
+		e.ctx = e.ctx.WithContext(execCtx)
+		e.timeout = timeout
+	}
+	e.hooks.onExecuteStart(e.ctx)
+	if op.Operation == language.Query {
+		e.executeCached(root, op, resp)
+	} else {
+		e.executeOperation(root, op, resp)
+	}
+	e.hooks.onExecuteEnd(e.ctx, resp)
+	return resp
+}
+
+// prepare resolves, parses, validates, and measures an operation. It returns a nil
+// executor and the error response when the operation must not run;
+// otherwise the response to complete.
+func (s *Server) prepare(ctx *Context, req *Request) (*executor, *schema.Type, *language.OperationDefinition, *Response) {
+	trusted, gqlErr := s.resolveDocument(ctx, req)
+	if gqlErr != nil {
+		return nil, nil, nil, &Response{Errors: []GraphQLError{*gqlErr}}
+	}
+	hooks := s.extensionHooks()
+	doc, err := language.Parse(req.Query)
+	hooks.onParse(ctx, doc, err)
+	if err != nil {
+		return nil, nil, nil, &Response{Errors: []GraphQLError{syntaxError(err)}}
+	}
+
+	op := doc.Operation(req.OperationName)
+	if op == nil {
+		switch {
+		case req.OperationName != "":
+			return nil, nil, nil, &Response{Errors: []GraphQLError{{Message: fmt.Sprintf("Unknown operation named %q.", req.OperationName)}}}
+		case len(doc.Operations()) > 1:
+			return nil, nil, nil, &Response{Errors: []GraphQLError{{Message: "Must provide operation name if query contains multiple operations."}}}
+		}
+		return nil, nil, nil, &Response{Errors: []GraphQLError{{Message: "Must provide an operation."}}}
+	}
+	ctx.init()
+	ctx.state.operation = op
+	if !trusted {
+		if err := s.checkTrusted(op); err != nil {
+			return nil, nil, nil, &Response{Errors: []GraphQLError{*err}}
+		}
+	}
+
+	sch, resolvers, scalars := s.snapshot()
+	root := sch.RootType(op.Operation)
+	if root == nil {
+		return nil, nil, nil, &Response{Errors: []GraphQLError{{
+			Message: fmt.Sprintf("Schema is not configured to execute %s operation.", op.Operation),
+		}}}
+	}
+
+	e := &executor{
+		server:    s,
+		schema:    sch,
+		resolvers: resolvers,
+		scalars:   scalars,
+		doc:       doc,
+		ctx:       ctx,
+		variables: req.Variables,
+
+		fieldMiddlewares: s.fieldChain(),
+		hooks:            hooks,
+	}
+	e.sched = newScheduler(s.config.MaxConcurrentResolvers)
+	e.ctx = ctx.WithContext(context.WithValue(ctx.Context, schedulerKey{}, e.sched))
+	if errs := e.check(root, op, req); len(errs) > 0 {
+		hooks.onValidate(ctx, errs)
+		return nil, nil, nil, &Response{Errors: errs}
+	}
+	hooks.onValidate(ctx, nil)
+	return e, root, op, &Response{}
+}
+
+// check validates an operation, coerces its variables, and checks its
+// arguments and cost.
+func (e *executor) check(root *schema.Type, op *language.OperationDefinition, req *Request) []GraphQLError {
+	if errs := e.validate(root, op); len(errs) > 0 {
+		return errs
+	}
+	variables, errs := e.coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return errs
+	}
+	e.variables = variables
+	if errs := e.checkArguments(root, op.SelectionSet, make(map[string]bool)); len(errs) > 0 {
+		return errs
+	}
+
+	cost := e.complexity(root, op.SelectionSet, nil)
+	e.ctx.state.complexity, e.ctx.state.costed = cost, true
+	if err := e.checkComplexity(op, cost); err != nil {
+		return []GraphQLError{*err}
+	}
+	for _, check := range e.ctx.costChecks {
+		if err := check(cost); err != nil {
+			return []GraphQLError{*err}
+		}
+	}
+	return nil
+}
+
+// executeOperation executes a query or mutation into resp. The root fields
+// of a mutation execute one at a time, in document order, since later
+// fields may depend on the effects of earlier ones.
+func (e *executor) executeOperation(root *schema.Type, op *language.OperationDefinition, resp *Response) {
+	e.sched.start()
+	defer e.sched.stop()
+	serial := op.Operation == language.Mutation
+	if data, ok := e.executeFields(root, e.collectFields(root, op.SelectionSet, nil), nil, nil, serial); ok {
+		resp.Data = data
+	}
+	resp.Errors = e.errors
+}
+
+// syntaxError converts a parse error, keeping its location.
+func syntaxError(err error) GraphQLError {
+	gqlErr := GraphQLError{Message: err.Error()}
+	var langErr *language.Error
+	if errors.As(err, &langErr) {
+		gqlErr.Message = langErr.Message
+		for _, loc := range langErr.Locations {
+			gqlErr.Locations = append(gqlErr.Locations, Location{Line: loc.Line, Column: loc.Column})
+		}
+	}
+	return gqlErr
+}
+
+// errorf records an error for the field at path.
+func (e *executor) errorf(fields []*language.Field, path []any, format string, args ...any) {
+	e.addErrors(fields, path, GraphQLError{Message: fmt.Sprintf(format, args...)})
+}
+
+// addErrors records errors for the field at path, unless the operation
+// was aborted.
+func (e *executor) addErrors(fields []*language.Field, path []any, errs ...GraphQLError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.aborted.Load() {
+		return
+	}
+	for _, err := range errs {
+		e.errors = append(e.errors, err)
+		e.locate(len(e.errors)-1, fields, path)
+	}
+}
+
+// fieldError records an error returned by a resolver for the field at
+// path. A GraphQLError keeps its extensions; an Abort error stops the
+// operation.
+func (e *executor) fieldError(err error, fields []*language.Field, path []any) {
+	var abort *abortError
+	if errors.As(err, &abort) {
+		e.abort(abort.err)
+		return
+	}
+
+	// Validation failures are reported as one error per field.
+	if fieldErrs := fieldErrors(err); len(fieldErrs) > 0 {
+		errs := make([]GraphQLError, len(fieldErrs))
+		for i, fe := range fieldErrs {
+			errs[i] = GraphQLError{
+				Message:    fe.Error(),
+				Extensions: map[string]any{"field": fe.Field},
+				cause:      fe,
+			}
+		}
+		e.addErrors(fields, path, errs...)
+		return
+	}
+	e.addErrors(fields, path, resolverError(err))
+}
+
+// abort stops the operation on the first Abort. Its error replaces those
+// of the fields and has no path, since it is not the error of one field.
+func (e *executor) abort(err error) {
+	gqlErr := resolverError(err)
+	gqlErr.Path, gqlErr.Locations = nil, nil
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.aborted.Swap(true) {
+		return
+	}
+	e.errors = []GraphQLError{gqlErr}
+}
+
+// resolverError converts an error returned by a resolver, keeping the
+// extensions of a GraphQLError.
+func resolverError(err error) GraphQLError {
+	var gqlErr GraphQLError
+	var gqlErrPtr *GraphQLError
+	switch {
+	case errors.As(err, &gqlErr):
+	case errors.As(err, &gqlErrPtr):
+		gqlErr = *gqlErrPtr
+	default:
+		gqlErr = GraphQLError{Message: err.Error()}
+	}
+	if gqlErr.cause == nil {
+		gqlErr.cause = err
+	}
+	return gqlErr
+}
+
+// checkTimeout reports whether Config.ExecutionTimeout has passed, in
+// which case the field at path is not resolved. Fields resolved before are
+// kept; the first field that did not finish gets a GRAPHQL_TIMEOUT error,
+// and the rest are null.
+func (e *executor) checkTimeout(fields []*language.Field, path []any) bool {
+	if e.timeout == 0 || !errors.Is(e.ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.timedOut && !e.aborted.Load() {
+		e.timedOut = true
+		e.errors = append(e.errors, GraphQLError{
+			Message:    fmt.Sprintf("Execution timed out after %s.", e.timeout),
+			Extensions: map[string]any{"code": "GRAPHQL_TIMEOUT"},
+		})
+		e.locate(len(e.errors)-1, fields, path)
+	}
+	return true
+}
+
+// locate sets the path and location of the i-th error, unless it already
+// has them. e.mu must be held.
+func (e *executor) locate(i int, fields []*language.Field, path []any) {
+	err := &e.errors[i]
+	if err.Path == nil {
+		err.Path = path
+	}
+	if err.Locations == nil {
+		loc := fields[0].Loc
+		err.Locations = []Location{{Line: loc.Line, Column: loc.Column}}
+	}
+}
+
+// appendPath returns path extended by a field response key or list index,
+// never sharing the backing array with other paths.
+func appendPath(path []any, segment any) []any {
+	return append(path[:len(path):len(path)], segment)
+}
+
+// fieldErrors collects the result.FieldErrors within err, including
+// errors joined by result.Validated.
+func fieldErrors(err error) []*result.FieldError {
+	if fe, ok := err.(*result.FieldError); ok {
+		return []*result.FieldError{fe}
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []*result.FieldError
+		for _, inner := range joined.Unwrap() {
+			out = append(out, fieldErrors(inner)...)
+		}
+		return out
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		return fieldErrors(inner)
+	}
+	return nil
+}
+
+// =============================================================================
+// Selection Sets
+// =============================================================================
+
+// executeSelectionSet resolves the selections of an object value. It
+// reports false when a non-null field was null, in which case the whole
+// object is null.
+func (e *executor) executeSelectionSet(t *schema.Type, set language.SelectionSet, parent any, path []any) (*resultMap, bool) {
+	return e.executeFields(t, e.collectFields(t, set, nil), parent, path, false)
+}
+
+// executeFields resolves grouped fields, concurrently unless serial, and
+// returns them in selection order.
+func (e *executor) executeFields(t *schema.Type, groups [][]*language.Field, parent any, path []any, serial bool) (*resultMap, bool) {
+	values := make([]any, len(groups))
+	oks := make([]bool, len(groups))
+	resolve := func(i int) {
+		values[i], oks[i] = e.executeField(t, parent, groups[i], appendPath(path, groups[i][0].ResponseKey()))
+	}
+	if serial {
+		for i := range groups {
+			resolve(i)
+		}
+	} else {
+		e.concurrently(len(groups), resolve)
+	}
+
+	fields := newResultMap()
+	for i, group := range groups {
+		if !oks[i] {
+			return nil, false
+		}
+		fields.set(group[0].ResponseKey(), values[i])
+	}
+	return fields, true
+}
+
+// concurrently calls fn for each index below n, each on its own goroutine
+// once it gets a token of the scheduler. The calling goroutine gives its
+// token back meanwhile, so nested calls never hold up each other. A panic
+// is re-raised on the calling goroutine.
+func (e *executor) concurrently(n int, fn func(i int)) {
+	if e.sched == nil || e.sched.sem == nil || n < 2 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+
+	// This goroutine keeps counting as running while it starts the others,
+	// so no batch is dispatched before every index has loaded its keys.
+	token := e.sched.release()
+	var wg sync.WaitGroup
+	var panicked atomic.Value
+	for i := range n {
+		e.sched.start()
+		wg.Add(1)
+		go func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					panicked.CompareAndSwap(nil, recovered)
+				}
+				e.sched.stop()
+				wg.Done()
+			}()
+			fn(i)
+		}()
+	}
+	e.sched.leave()
+	wg.Wait()
+	e.sched.resume(token)
+	if recovered := panicked.Load(); recovered != nil {
+		panic(recovered)
+	}
+}
+
+// collectFields groups the fields selected on t by response key, in the
+// order they first appear, expanding fragments whose type condition
+// applies.
+func (e *executor) collectFields(t *schema.Type, set language.SelectionSet, visited map[string]bool) [][]*language.Field {
+	var groups [][]*language.Field
+	index := make(map[string]int)
+
+	var collect func(set language.SelectionSet)
+	collect = func(set language.SelectionSet) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *language.Field:
+				if !e.shouldInclude(sel.Directives) {
+					continue
+				}
+				key := sel.ResponseKey()
+				if i, ok := index[key]; ok {
+					groups[i] = append(groups[i], sel)
+				} else {
+					index[key] = len(groups)
+					groups = append(groups, []*language.Field{sel})
+				}
+			case *language.InlineFragment:
+				if e.shouldInclude(sel.Directives) && e.fragmentApplies(t, sel.TypeCondition) {
+					collect(sel.SelectionSet)
+				}
+			case *language.FragmentSpread:
+				if visited[sel.Name] || !e.shouldInclude(sel.Directives) {
+					continue
+				}
+				if visited == nil {
+					visited = make(map[string]bool)
+				}
+				visited[sel.Name] = true
+				frag := e.doc.Fragment(sel.Name)
+				if frag != nil && e.fragmentApplies(t, frag.TypeCondition) {
+					collect(frag.SelectionSet)
+				}
+			}
+		}
+	}
+	collect(set)
+	return groups
+}
+
+// shouldInclude evaluates @skip and @include: a selection is included
+// unless @skip(if: true) or @include(if: false) is applied to it.
+func (e *executor) shouldInclude(directives language.DirectiveList) bool {
+	if d := directives.ForName("skip"); d != nil && e.directiveCondition(d) {
+		return false
+	}
+	if d := directives.ForName("include"); d != nil && !e.directiveCondition(d) {
+		return false
+	}
+	return true
+}
+
+// directiveCondition returns the "if" argument of @skip or @include.
+func (e *executor) directiveCondition(d *language.Directive) bool {
+	def := e.schema.Directive(d.Name)
+	if def == nil {
+		return false
+	}
+	args, err := e.argumentValues(def.Args, d.Arguments)
+	if err != nil {
+		return false
+	}
+	cond, _ := args["if"].(bool)
+	return cond
+}
+
+func (e *executor) fragmentApplies(t *schema.Type, condition string) bool {
+	if condition == "" || condition == t.Name {
+		return true
+	}
+	if ct := e.schema.Type(condition); ct != nil && ct.IsAbstract() {
+		return e.schema.IsPossibleType(ct, t)
+	}
+	return false
+}
+
+// =============================================================================
+// Fields
+// =============================================================================
+
+func (e *executor) executeField(parentType *schema.Type, parent any, fields []*language.Field, path []any) (any, bool) {
+	field := fields[0]
+	def := e.schema.FieldDefinition(parentType, field.Name)
+	if def == nil {
+		e.errorf(fields, path, "Cannot query field %q on type %q.", field.Name, parentType.Name)
+		return nil, true
+	}
+	// After Abort, remaining fields are null without resolving them.
+	if e.aborted.Load() {
+		return nil, !def.Type.NonNull
+	}
+	if def == schema.TypeNameField {
+		return parentType.Name, true
+	}
+	if e.checkTimeout(fields, path) {
+		return nil, !def.Type.NonNull
+	}
+
+	args, err := e.argumentValues(def.Args, field.Arguments)
+	if err != nil {
+		e.errorf(fields, path, "%s", err.Error())
+		return nil, !def.Type.NonNull
+	}
+
+	value, err := e.resolveField(parentType, def, parent, args, path)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && e.checkTimeout(fields, path) {
+			return nil, !def.Type.NonNull
+		}
+		e.fieldError(err, fields, path)
+		return nil, !def.Type.NonNull
+	}
+	// Results of fields that finish after an Abort are dropped.
+	if e.aborted.Load() {
+		return nil, !def.Type.NonNull
+	}
+
+	return e.completeValue(def.Type, parentType.Name+"."+def.Name, fields, value, path)
+}
+
+func (e *executor) resolveField(parentType *schema.Type, def *schema.Field, parent any, args map[string]any, path []any) (value any, err error) {
+	// A panicking resolver fails only its own field.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			value, err = nil, e.recoverPanic(parentType.Name+"."+def.Name, recovered)
+		}
+	}()
+
+	switch {
+	case def == schema.SchemaField:
+		return e.schema, nil
+	case def == schema.TypeField:
+		name, _ := args["name"].(string)
+		if e.schema.Type(name) == nil {
+			return nil, nil
+		}
+		return language.NamedType(name), nil
+	case parentType.BuiltIn:
+		return e.introspect(parentType, def.Name, parent, args)
+	}
+
+	fn := e.resolvers[parentType.Name][def.Name]
+	wrapped := len(e.fieldMiddlewares) > 0 || len(e.hooks.field) > 0
+	if fn == nil {
+		if !wrapped {
+			return defaultResolver(parent, def.Name)
+		}
+		info := FieldInfo{ParentType: parentType.Name, Field: def.Name, Path: path, Parent: parent, Args: args, Default: true}
+		return e.callResolver(func(_ *Context, parent any, _ map[string]any) (any, error) {
+			return defaultResolver(parent, def.Name)
+		}, info)
+	}
+
+	if e.ctx.timings != nil {
+		defer e.ctx.timings.record(parentType.Name+"."+def.Name, time.Now())
+	}
+	if wrapped {
+		info := FieldInfo{ParentType: parentType.Name, Field: def.Name, Path: path, Parent: parent, Args: args}
+		return e.callResolver(fn, info)
+	}
+	return fn(e.ctx, parent, args)
+}
+
+// recoverPanic passes a panic recovered from the resolver of field to
+// Config.PanicHandler, returning the error reported to the client.
+func (e *executor) recoverPanic(field string, recovered any) error {
+	stack := debug.Stack()
+	if handler := e.server.config.PanicHandler; handler != nil {
+		handler(e.ctx, recovered, stack)
+	} else {
+		fmt.Fprintf(os.Stderr, "[bgql] panic resolving %s: %v\n%s", field, recovered, stack)
+	}
+	return GraphQLError{
+		Message:    "Internal server error.",
+		Extensions: map[string]any{"code": "INTERNAL_SERVER_ERROR"},
+	}
+}
+
+// callResolver calls fn inside the field extensions and then the field
+// middleware chain, the first of each outermost.
+func (e *executor) callResolver(fn ResolverFn, info FieldInfo) (value any, err error) {
+	next := fn
+	for i := len(e.fieldMiddlewares) - 1; i >= 0; i-- {
+		middleware, inner := e.fieldMiddlewares[i], next
+		next = func(ctx *Context, parent any, args map[string]any) (any, error) {
+			info := info
+			info.Parent, info.Args = parent, args
+			return middleware(ctx, info, inner)
+		}
+	}
+
+	hooks := e.hooks.field
+	ctx := e.ctx
+	ctxs := make([]*Context, len(hooks))
+	for i, ext := range hooks {
+		ctx = ext.OnFieldStart(ctx, info)
+		ctxs[i] = ctx
+	}
+	if len(hooks) > 0 {
+		// End the hooks even if the resolver panics.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				value, err = nil, e.recoverPanic(info.ParentType+"."+info.Field, recovered)
+			}
+			for i := len(hooks) - 1; i >= 0; i-- {
+				hooks[i].OnFieldEnd(ctxs[i], info, value, err)
+			}
+		}()
+	}
+	return next(ctx, info.Parent, info.Args)
+}
+
+// completeValue converts a resolved value to its response form according
+// to the field type. It reports false when the value is null in a
+// non-null position, after the error has been recorded.
+func (e *executor) completeValue(t *language.Type, name string, fields []*language.Field, value any, path []any) (any, bool) {
+	completed, ok := e.completeNullable(t, name, fields, value, path)
+	if !t.NonNull {
+		return completed, true
+	}
+	if !ok {
+		return nil, false
+	}
+	if completed == nil {
+		e.errorf(fields, path, "Cannot return null for non-nullable field %s.", name)
+		return nil, false
+	}
+	return completed, true
+}
+
+// isLeaf reports whether values of t, or of its innermost item type, are
+// scalars or enums, which complete without resolvers.
+func (e *executor) isLeaf(t *language.Type) bool {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	named := e.schema.Type(t.NamedType)
+	return named != nil && named.IsLeaf()
+}
+
+func (e *executor) completeNullable(t *language.Type, name string, fields []*language.Field, value any, path []any) (any, bool) {
+	if isNil(value) {
+		return nil, true
+	}
+
+	if t.Elem != nil {
+		rv := reflect.Indirect(reflect.ValueOf(value))
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.errorf(fields, path, "Expected Iterable, but did not find one for field %s.", name)
+			return nil, false
+		}
+		items := make([]any, rv.Len())
+		oks := make([]bool, len(items))
+		complete := func(i int) {
+			items[i], oks[i] = e.completeValue(t.Elem, name, fields, rv.Index(i).Interface(), appendPath(path, i))
+		}
+		if e.isLeaf(t.Elem) {
+			for i := range items {
+				complete(i)
+				if !oks[i] {
+					return nil, false
+				}
+			}
+		} else {
+			e.concurrently(len(items), complete)
+		}
+		for _, ok := range oks {
+			if !ok {
+				return nil, false
+			}
+		}
+		return items, true
+	}
+
+	named := e.schema.Type(t.NamedType)
+	switch {
+	case named == nil:
+		e.errorf(fields, path, "Unknown type %q for field %s.", t.NamedType, name)
+		return nil, false
+	case named.Kind == schema.Enum:
+		serialized, err := e.serializeEnum(named, value)
+		if err != nil {
+			e.errorf(fields, path, "%s", err.Error())
+			return nil, false
+		}
+		return serialized, true
+	case named.IsLeaf():
+		serialize := func(v any) (any, error) { return serializeLeaf(named, v) }
+		if sc, ok := e.scalars[named.Name]; ok && sc.Serialize != nil {
+			serialize = sc.Serialize
+		}
+		serialized, err := serialize(value)
+		if err != nil {
+			e.errorf(fields, path, "%s", err.Error())
+			return nil, false
+		}
+		return serialized, true
+	}
+
+	objectType := named
+	if named.IsAbstract() {
+		var err error
+		if objectType, err = e.resolveType(named, name, value); err != nil {
+			e.fieldError(err, fields, path)
+			return nil, false
+		}
+	}
+
+	var set language.SelectionSet
+	for _, f := range fields {
+		set = append(set, f.SelectionSet...)
+	}
+	object, ok := e.executeSelectionSet(objectType, set, value, path)
+	if !ok {
+		return nil, false
+	}
+	return object, true
+}
+
+// resolveType returns the concrete object type of a value of an abstract
+// type using the type's TypeResolver.
+func (e *executor) resolveType(abstract *schema.Type, field string, value any) (*schema.Type, error) {
+	resolve := e.server.types[abstract.Name]
+	if resolve == nil {
+		return nil, fmt.Errorf("Abstract type %q must resolve to an Object type at runtime for field %s. Register a TypeResolver for %q.",
+			abstract.Name, field, abstract.Name)
+	}
+	name, err := resolve(e.ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	t := e.schema.Type(name)
+	switch {
+	case t == nil || t.Kind != schema.Object:
+		return nil, fmt.Errorf("Abstract type %q must resolve to an Object type at runtime for field %s. Received %q.",
+			abstract.Name, field, name)
+	case !e.schema.IsPossibleType(abstract, t):
+		return nil, fmt.Errorf("Runtime Object type %q is not a possible type for %q.", name, abstract.Name)
+	}
+	return t, nil
+}
+
+// =============================================================================
+// Arguments
+// =============================================================================
+
+// argumentValues coerces the literal arguments of a field, substituting
+// variables and applying declared defaults.
+func (e *executor) argumentValues(defs []*schema.InputValue, args language.ArgumentList) (map[string]any, error) {
+	values := make(map[string]any, len(defs))
+	for _, def := range defs {
+		if arg := args.ForName(def.Name); arg != nil {
+			value, provided, err := e.coerceLiteral(def.Type, arg.Value, nil)
+			if err != nil {
+				return nil, err.argumentError(def.Name)
+			}
+			if provided {
+				values[def.Name] = value
+			}
+		}
+		if _, ok := values[def.Name]; !ok && def.DefaultValue != nil {
+			value, _, err := e.coerceLiteral(def.Type, def.DefaultValue, nil)
+			if err != nil {
+				return nil, err.argumentError(def.Name)
+			}
+			values[def.Name] = value
+		}
+		if value, ok := values[def.Name]; ok {
+			if err := e.checkOneOf(def.Type, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// checkOneOf enforces @oneOf on input objects anywhere within value.
+func (e *executor) checkOneOf(t *language.Type, value any) error {
+	if value == nil {
+		return nil
+	}
+	if t.Elem != nil {
+		items, ok := value.([]any)
+		if !ok {
+			return e.checkOneOf(t.Elem, value)
+		}
+		for _, item := range items {
+			if err := e.checkOneOf(t.Elem, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	named := e.schema.Type(t.NamedType)
+	fields, ok := value.(map[string]any)
+	if named == nil || named.Kind != schema.InputObject || !ok {
+		return nil
+	}
+
+	if named.OneOf {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) != 1 {
+			return fmt.Errorf("OneOf Input Object %q must specify exactly one key, got %d: [%s].",
+				named.Name, len(keys), strings.Join(keys, ", "))
+		}
+		if fields[keys[0]] == nil {
+			return fmt.Errorf("Field \"%s.%s\" must be non-null.", named.Name, keys[0])
+		}
+	}
+
+	for name, fieldValue := range fields {
+		if f := named.InputField(name); f != nil {
+			if err := e.checkOneOf(f.Type, fieldValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// valueFromAST converts an input literal to a Go value: int, float64,
+// string, bool, nil, []any, or map[string]any. Explicit nulls are kept as
+// nil entries so resolvers can tell them apart from omitted fields.
+func valueFromAST(v language.Value, variables map[string]any) any {
+	switch v := v.(type) {
+	case *language.Variable:
+		return variables[v.Name]
+	case *language.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		if err != nil {
+			f, _ := strconv.ParseFloat(v.Value, 64)
+			return f
+		}
+		return n
+	case *language.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *language.StringValue:
+		return v.Value
+	case *language.BooleanValue:
+		return v.Value
+	case *language.EnumValue:
+		return v.Value
+	case *language.ListValue:
+		items := make([]any, len(v.Values))
+		for i, item := range v.Values {
+			items[i] = valueFromAST(item, variables)
+		}
+		return items
+	case *language.ObjectValue:
+		fields := make(map[string]any, len(v.Fields))
+		for _, f := range v.Fields {
+			// A field given an unprovided variable is absent, not null.
+			if ref, ok := f.Value.(*language.Variable); ok {
+				if _, provided := variables[ref.Name]; !provided {
+					continue
+				}
+			}
+			fields[f.Name] = valueFromAST(f.Value, variables)
+		}
+		return fields
+	}
+	return nil
+}
+
+// =============================================================================
+// Default Resolver
+// =============================================================================
+
+// defaultResolver resolves a field without a registered resolver by
+// looking up a map key, a struct field (by json tag or case-insensitive
+// name), or a method taking no arguments.
+func defaultResolver(parent any, name string) (any, error) {
+	if parent == nil {
+		return nil, nil
+	}
+	if m, ok := parent.(map[string]any); ok {
+		return m[name], nil
+	}
+
+	rv := reflect.ValueOf(parent)
+	if method, ok := findMethod(rv, name); ok {
+		return callMethod(method)
+	}
+
+	rv = reflect.Indirect(rv)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, nil
+		}
+		v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	case reflect.Struct:
+		if v, ok := findField(rv, name); ok {
+			return v.Interface(), nil
+		}
+	}
+	return nil, nil
+}
+
+func findField(rv reflect.Value, name string) (reflect.Value, bool) {
+	if i, ok := fieldIndex(rv.Type(), name); ok {
+		return rv.Field(i), true
+	}
+	return reflect.Value{}, false
+}
+
+// fieldIndex returns the index of the field of struct type rt that the
+// default resolver reads for name: the field with that json tag, or else
+// the first untagged field with that name, ignoring case.
+func fieldIndex(rt reflect.Type, name string) (int, bool) {
+	fallback := -1
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if tag, _, _ := strings.Cut(sf.Tag.Get("json"), ","); tag != "" {
+			if tag == name {
+				return i, true
+			}
+			continue
+		}
+		if fallback < 0 && strings.EqualFold(sf.Name, name) {
+			fallback = i
+		}
+	}
+	return fallback, fallback >= 0
+}
+
+func findMethod(rv reflect.Value, name string) (reflect.Value, bool) {
+	if i, ok := methodIndex(rv.Type(), name); ok {
+		return rv.Method(i), true
+	}
+	return reflect.Value{}, false
+}
+
+// methodIndex returns the index of the method of rt that the default
+// resolver calls for name.
+func methodIndex(rt reflect.Type, name string) (int, bool) {
+	for i := 0; i < rt.NumMethod(); i++ {
+		m := rt.Method(i)
+		if !strings.EqualFold(m.Name, name) {
+			continue
+		}
+		// Receiver plus no arguments; returns a value and optionally an error.
+		if m.Type.NumIn() == 1 && (m.Type.NumOut() == 1 || m.Type.NumOut() == 2 && m.Type.Out(1) == errorType) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func callMethod(method reflect.Value) (any, error) {
+	out := method.Call(nil)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// =============================================================================
+// Leaf Serialization
+// =============================================================================
+
+// EnumMarshaler is implemented by Go values that serialize as a member of
+// a GraphQL enum.
+type EnumMarshaler interface {
+	MarshalGQLEnum() string
+}
+
+// serializeEnum converts a resolved value to the name of a member of enum
+// t: via MarshalGQLEnum, the values registered with Builder.EnumValues, or
+// a string holding the name.
+func (e *executor) serializeEnum(t *schema.Type, value any) (any, error) {
+	name, ok := "", false
+	if m, isMarshaler := value.(EnumMarshaler); isMarshaler {
+		name, ok = m.MarshalGQLEnum(), true
+	}
+	if mapping := e.server.enums[t.Name]; !ok && mapping != nil {
+		for _, member := range t.EnumValues {
+			if internal, registered := mapping[member.Name]; registered && enumValueEqual(internal, value) {
+				name, ok = member.Name, true
+				break
+			}
+		}
+	}
+	if rv := reflect.Indirect(reflect.ValueOf(value)); !ok && rv.Kind() == reflect.String {
+		name, ok = rv.String(), true
+	}
+	if !ok || t.EnumValue(name) == nil {
+		return nil, fmt.Errorf("Enum %q cannot represent value: %s", t.Name, jsonString(value))
+	}
+	return name, nil
+}
+
+// enumValueEqual compares an internal enum value with a resolved one,
+// treating numbers of different Go types as equal when they are equal.
+func enumValueEqual(internal, value any) bool {
+	a, b := reflect.ValueOf(internal), reflect.Indirect(reflect.ValueOf(value))
+	if af, ok := numberValue(a); ok {
+		bf, ok := numberValue(b)
+		return ok && af == bf
+	}
+	return b.IsValid() && reflect.DeepEqual(internal, b.Interface())
+}
+
+func numberValue(rv reflect.Value) (float64, bool) {
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// serializeLeaf converts a resolved value to the wire form of a scalar
+// type.
+func serializeLeaf(t *schema.Type, value any) (any, error) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	switch t.Name {
+	case "Int":
+		switch {
+		case rv.CanInt():
+			if n := rv.Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int32(n), nil
+			}
+		case rv.CanUint():
+			if n := rv.Uint(); n <= math.MaxInt32 {
+				return int32(n), nil
+			}
+		case rv.CanFloat():
+			if f := rv.Float(); f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+				return int32(f), nil
+			}
+		case rv.Kind() == reflect.Bool:
+			if rv.Bool() {
+				return int32(1), nil
+			}
+			return int32(0), nil
+		}
+		return nil, fmt.Errorf("Int cannot represent value: %v", value)
+	case "Float":
+		switch {
+		case rv.CanInt():
+			return float64(rv.Int()), nil
+		case rv.CanUint():
+			return float64(rv.Uint()), nil
+		case rv.CanFloat():
+			if f := rv.Float(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("Float cannot represent value: %v", value)
+	case "String", "ID":
+		switch {
+		case rv.Kind() == reflect.String:
+			return rv.String(), nil
+		case rv.CanInt():
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case rv.CanUint():
+			return strconv.FormatUint(rv.Uint(), 10), nil
+		case t.Name == "String" && rv.CanFloat():
+			return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+		case t.Name == "String" && rv.Kind() == reflect.Bool:
+			return strconv.FormatBool(rv.Bool()), nil
+		}
+		if s, ok := value.(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+		return nil, fmt.Errorf("%s cannot represent value: %v", t.Name, value)
+	case "Boolean":
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+		return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %v", value)
+	}
+
+	if rv.IsValid() {
+		return rv.Interface(), nil
+	}
+	return value, nil
+}
+
+// =============================================================================
+// Result Map
+// =============================================================================
+
+// resultMap is a JSON object that preserves the order in which fields
+// were selected, as the response format requires.
+type resultMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newResultMap() *resultMap {
+	return &resultMap{values: make(map[string]any)}
+}
+
+func (m *resultMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value stored for key.
+func (m *resultMap) Get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// MarshalJSON encodes the fields in selection order.
+func (m *resultMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
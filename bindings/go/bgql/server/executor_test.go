@@ -0,0 +1,517 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+)
+
+// postQuery sends query to s over HTTP and decodes the response data into
+// data, failing the test on errors.
+func postQuery(t *testing.T, s *Server, query string, data any) {
+	t.Helper()
+	raw := postQueryRaw(t, s, query)
+	if err := json.Unmarshal(raw, data); err != nil {
+		t.Fatalf("bad data %s: %v", raw, err)
+	}
+}
+
+// newUserServer serves users(n) listing n IDs, each of whose name is
+// loaded through a loader counting its batches.
+func newUserServer(t *testing.T, concurrency int, batches *atomic.Int32) *Server {
+	t.Helper()
+	config := DefaultConfig()
+	config.MaxConcurrentResolvers = concurrency
+	loader := NewDataLoader(func(ctx context.Context, ids []int) (map[int]string, error) {
+		batches.Add(1)
+		names := make(map[int]string, len(ids))
+		for _, id := range ids {
+			names[id] = fmt.Sprintf("user%d", id)
+		}
+		return names, nil
+	})
+	return NewBuilder().
+		Config(config).
+		Schema(`type Query { users(n: Int!): [User!]! } type User { id: Int! name: String! }`).
+		Resolver("Query", "users", func(ctx *Context, p any, a map[string]any) (any, error) {
+			ids := make([]any, a["n"].(int))
+			for i := range ids {
+				ids[i] = map[string]any{"id": i}
+			}
+			return ids, nil
+		}).
+		Resolver("User", "name", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return loader.Load(ctx, p.(map[string]any)["id"].(int))
+		}).
+		Build().Unwrap()
+}
+
+func TestListItemsLoadInOneBatch(t *testing.T) {
+	tests := []struct {
+		items, concurrency int
+	}{
+		{30, 16},
+		{100, 16},
+		{100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d items, %d resolvers", tt.items, tt.concurrency), func(t *testing.T) {
+			var batches atomic.Int32
+			s := newUserServer(t, tt.concurrency, &batches)
+			var data struct{ Users []struct{ Name string } }
+			postQuery(t, s, fmt.Sprintf("{ users(n: %d) { id name } }", tt.items), &data)
+			if len(data.Users) != tt.items || data.Users[tt.items-1].Name != fmt.Sprintf("user%d", tt.items-1) {
+				t.Fatalf("unexpected data %+v", data)
+			}
+			if n := batches.Load(); n != 1 {
+				t.Errorf("batch function called %d times, want 1", n)
+			}
+		})
+	}
+}
+func TestSiblingFieldsResolveConcurrently(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		concurrency int
+		wantMax     int32
+	}{
+		{"query", "{ a b c d e f }", 16, 6},
+		{"limited", "{ a b c d e f }", 2, 2},
+		{"mutation", "mutation { a b c d e f }", 16, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, maxRunning, held atomic.Int32
+			var started sync.WaitGroup
+			started.Add(int(tt.wantMax))
+			resolve := func(name string) ResolverFn {
+				return func(ctx *Context, p any, a map[string]any) (any, error) {
+					n := running.Add(1)
+					defer running.Add(-1)
+					for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+					}
+					if held.Add(1) <= tt.wantMax {
+						// Hold the first resolvers until as many as allowed run.
+						started.Done()
+						started.Wait()
+					}
+					return name, nil
+				}
+			}
+			config := DefaultConfig()
+			config.MaxConcurrentResolvers = tt.concurrency
+			b := NewBuilder().Config(config).Schema(`
+				type Query { a: String b: String c: String d: String e: String f: String }
+				type Mutation { a: String b: String c: String d: String e: String f: String }`)
+			for _, f := range []string{"a", "b", "c", "d", "e", "f"} {
+				b.Resolver("Query", f, resolve(f)).Resolver("Mutation", f, resolve(f))
+			}
+			s := b.Build().Unwrap()
+
+			var data map[string]string
+			raw := postQueryRaw(t, s, tt.query)
+			if err := json.Unmarshal(raw, &data); err != nil || len(data) != 6 || data["f"] != "f" {
+				t.Fatalf("unexpected data %s", raw)
+			}
+			if want := `{"a":"a","b":"b","c":"c","d":"d","e":"e","f":"f"}`; string(raw) != want {
+				t.Errorf("data = %s, want fields in selection order %s", raw, want)
+			}
+			if n := maxRunning.Load(); n != tt.wantMax {
+				t.Errorf("%d resolvers ran at once, want %d", n, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestSiblingRootFieldsLoadInOneBatch(t *testing.T) {
+	var batches atomic.Int32
+	loader := NewDataLoader(func(ctx context.Context, keys []string) (map[string]string, error) {
+		batches.Add(1)
+		values := make(map[string]string, len(keys))
+		for _, k := range keys {
+			values[k] = strings.ToUpper(k)
+		}
+		return values, nil
+	})
+	b := NewBuilder().
+		Config(DefaultConfig()).
+		Schema(`type Query { a: String b: String c: String d: String e: String f: String }`)
+	for _, f := range []string{"a", "b", "c", "d", "e", "f"} {
+		b.Resolver("Query", f, func(ctx *Context, p any, a map[string]any) (any, error) {
+			return loader.Load(ctx, f)
+		})
+	}
+	var data map[string]string
+	postQuery(t, b.Build().Unwrap(), "{ a b c d e f }", &data)
+	if data["a"] != "A" || data["f"] != "F" {
+		t.Fatalf("unexpected data %v", data)
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("batch function called %d times, want 1", n)
+	}
+}
+
+func TestNestedListsLoadOneBatchPerLevel(t *testing.T) {
+	var userBatches, postBatches atomic.Int32
+	postsLoader := NewDataLoader(func(ctx context.Context, users []int) (map[int][]int, error) {
+		userBatches.Add(1)
+		posts := make(map[int][]int, len(users))
+		for _, u := range users {
+			posts[u] = []int{u * 10, u*10 + 1, u*10 + 2}
+		}
+		return posts, nil
+	})
+	authorsLoader := NewDataLoader(func(ctx context.Context, posts []int) (map[int]string, error) {
+		postBatches.Add(1)
+		authors := make(map[int]string, len(posts))
+		for _, p := range posts {
+			authors[p] = fmt.Sprintf("user%d", p/10)
+		}
+		return authors, nil
+	})
+	s := NewBuilder().
+		Schema(`
+			type Query { users: [User!]! }
+			type User { id: Int! posts: [Post!]! }
+			type Post { id: Int! author: String! }`).
+		Resolver("Query", "users", func(ctx *Context, p any, a map[string]any) (any, error) {
+			users := make([]any, 25)
+			for i := range users {
+				users[i] = map[string]any{"id": i}
+			}
+			return users, nil
+		}).
+		Resolver("User", "posts", func(ctx *Context, p any, a map[string]any) (any, error) {
+			ids, err := postsLoader.LoadThunk(ctx, p.(map[string]any)["id"].(int))()
+			posts := make([]any, len(ids))
+			for i, id := range ids {
+				posts[i] = map[string]any{"id": id}
+			}
+			return posts, err
+		}).
+		Resolver("Post", "author", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return authorsLoader.LoadThunk(ctx, p.(map[string]any)["id"].(int))()
+		}).
+		Build().Unwrap()
+
+	var data struct {
+		Users []struct{ Posts []struct{ Author string } }
+	}
+	postQuery(t, s, "{ users { id posts { id author } } }", &data)
+	if len(data.Users) != 25 || data.Users[24].Posts[2].Author != "user24" {
+		t.Fatalf("unexpected data %+v", data)
+	}
+	if n := userBatches.Load(); n != 1 {
+		t.Errorf("posts batch function called %d times, want 1", n)
+	}
+	if n := postBatches.Load(); n != 1 {
+		t.Errorf("authors batch function called %d times, want 1", n)
+	}
+}
+
+func TestMutationRootFieldsRunInOrder(t *testing.T) {
+	var counter int
+	var mu sync.Mutex
+	s := NewBuilder().
+		Schema(`type Query { counter: Int } type Mutation { increment(by: Int!): Int read: Int }`).
+		Resolver("Query", "counter", func(ctx *Context, p any, a map[string]any) (any, error) { return 0, nil }).
+		Resolver("Mutation", "increment", func(ctx *Context, p any, a map[string]any) (any, error) {
+			mu.Lock()
+			before := counter
+			mu.Unlock()
+			// Later fields would overtake this one if they ran concurrently.
+			time.Sleep(time.Duration(10-a["by"].(int)) * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			counter = before + a["by"].(int)
+			return counter, nil
+		}).
+		Resolver("Mutation", "read", func(ctx *Context, p any, a map[string]any) (any, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return counter, nil
+		}).
+		Build().Unwrap()
+
+	got := string(postQueryRaw(t, s, `mutation { a: increment(by: 1) b: read c: increment(by: 2) d: increment(by: 3) e: read }`))
+	if want := `{"a":1,"b":1,"c":3,"d":6,"e":6}`; got != want {
+		t.Errorf("data = %s, want %s", got, want)
+	}
+}
+
+func TestAbortStopsExecution(t *testing.T) {
+	var batches, resolved atomic.Int32
+	loader := NewDataLoader(func(ctx context.Context, ids []int) (map[int]string, error) {
+		batches.Add(1)
+		names := make(map[int]string, len(ids))
+		for _, id := range ids {
+			names[id] = fmt.Sprintf("user%d", id)
+		}
+		return names, nil
+	})
+	load := func(id int) ResolverFn {
+		return func(ctx *Context, p any, a map[string]any) (any, error) {
+			resolved.Add(1)
+			return loader.Load(ctx, id)
+		}
+	}
+	// Siblings resolve in order, so none has started when the first aborts.
+	config := DefaultConfig()
+	config.MaxConcurrentResolvers = 0
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { broken: Int a: String b: String c: String }`).
+		Resolver("Query", "broken", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return nil, errors.New("boom")
+		}).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return nil, Abort(GraphQLError{
+				Message:    "Tenant suspended",
+				Path:       []any{"a"},
+				Extensions: map[string]any{"code": "TENANT_SUSPENDED"},
+			})
+		}).
+		Resolver("Query", "b", load(1)).
+		Resolver("Query", "c", load(2)).
+		Build().Unwrap()
+
+	tests := []struct {
+		query, want string
+	}{
+		{`{ a b c }`, `{"a":null,"b":null,"c":null}`},
+		{`{ broken a b c }`, `{"broken":null,"a":null,"b":null,"c":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			data, errs := postGraphQL(t, s, tt.query)
+			if string(data) != tt.want {
+				t.Errorf("data = %s, want %s", data, tt.want)
+			}
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want only the abort", errs)
+			}
+			err := errs[0]
+			if err.Message != "Tenant suspended" || err.Extensions["code"] != "TENANT_SUSPENDED" || err.Path != nil || err.Locations != nil {
+				t.Errorf("error = %+v, want a top-level TENANT_SUSPENDED", err)
+			}
+			if n := resolved.Load(); n != 0 {
+				t.Errorf("%d siblings resolved after the abort", n)
+			}
+			if n := batches.Load(); n != 0 {
+				t.Errorf("loader dispatched %d batches after the abort", n)
+			}
+		})
+	}
+}
+
+func TestOneOfInputObjects(t *testing.T) {
+	s := NewBuilder().
+		Schema(`
+			input UserBy @oneOf { id: ID email: String }
+			type Query { user(by: UserBy!): String users(by: [UserBy!]): Int }
+		`).
+		Resolver("Query", "user", func(ctx *Context, p any, a map[string]any) (any, error) {
+			for key, value := range a["by"].(map[string]any) {
+				return fmt.Sprintf("%s=%v", key, value), nil
+			}
+			return nil, nil
+		}).
+		Resolver("Query", "users", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return len(a["by"].([]any)), nil
+		}).
+		Build().Unwrap()
+
+	const byVariable = `query ($by: UserBy!) { user(by: $by) }`
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		want      string
+		wantErr   string
+	}{
+		{name: "literal no keys", query: `{ user(by: {}) }`, wantErr: "exactly one key"},
+		{name: "literal one key", query: `{ user(by: {id: 1}) }`, want: `{"user":"id=1"}`},
+		{name: "literal two keys", query: `{ user(by: {id: 1, email: "a@b.c"}) }`, wantErr: "exactly one key"},
+		{name: "literal null key", query: `{ user(by: {id: null}) }`, wantErr: "must be non-null"},
+		{name: "variable no keys", query: byVariable, variables: map[string]any{"by": map[string]any{}}, wantErr: "exactly one key"},
+		{name: "variable one key", query: byVariable, variables: map[string]any{"by": map[string]any{"email": "a@b.c"}}, want: `{"user":"email=a@b.c"}`},
+		{name: "variable two keys", query: byVariable, variables: map[string]any{"by": map[string]any{"id": "1", "email": "a@b.c"}}, wantErr: "exactly one key"},
+		{name: "variable null key", query: byVariable, variables: map[string]any{"by": map[string]any{"id": nil}}, wantErr: "must be non-null"},
+		{name: "variable in a field", query: `query ($id: ID) { user(by: {id: $id}) }`, variables: map[string]any{"id": nil}, wantErr: "must be non-null"},
+		{name: "list items", query: `{ users(by: [{id: 1}, {id: 2, email: "a@b.c"}]) }`, wantErr: "exactly one key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs := postRequest(t, s, Request{Query: tt.query, Variables: tt.variables})
+			if tt.wantErr == "" {
+				if len(errs) > 0 || string(data) != tt.want {
+					t.Errorf("data = %s, errors = %v, want %s", data, errs, tt.want)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Message, tt.wantErr) {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsReportedTogether(t *testing.T) {
+	s := NewBuilder().
+		Schema(`
+			type Query { a: Int }
+			input SignupInput { name: String! email: String! age: Int }
+			type Mutation { signup(input: SignupInput!): Boolean }
+		`).
+		Resolver("Mutation", "signup", func(ctx *Context, p any, a map[string]any) (any, error) {
+			v := result.ValidateStruct(a["input"].(map[string]any),
+				result.MinLength("name", 2),
+				result.Check("email", func(value any) error {
+					if !strings.Contains(value.(string), "@") {
+						return errors.New("must be an email address")
+					}
+					return nil
+				}),
+				result.Range("age", 18, 130),
+			)
+			if !v.IsValid() {
+				return nil, v.Err()
+			}
+			return true, nil
+		}).
+		Build().Unwrap()
+
+	data, errs := postGraphQL(t, s, `mutation { signup(input: {name: "A", email: "nope", age: 7}) }`)
+	if string(data) != `{"signup":null}` {
+		t.Errorf("data = %s", data)
+	}
+	want := []string{
+		"name: must be at least 2 characters",
+		"email: must be an email address",
+		"age: must be between 18 and 130",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %+v, want %d", errs, len(want))
+	}
+	for i, err := range errs {
+		field, _, _ := strings.Cut(want[i], ":")
+		if err.Message != want[i] || err.Extensions["field"] != field || fmt.Sprint(err.Path) != "[signup]" {
+			t.Errorf("error %d = %+v, want %q on field %s", i, err, want[i], field)
+		}
+	}
+}
+
+func TestFieldMiddlewareOrder(t *testing.T) {
+	var log []string
+	record := func(name string) FieldMiddleware {
+		return func(ctx *Context, info FieldInfo, next ResolverFn) (any, error) {
+			field := info.ParentType + "." + info.Field
+			if info.Default {
+				field += " (default)"
+			}
+			log = append(log, name+" > "+field)
+			defer func() { log = append(log, name+" < "+field) }()
+			return next(ctx, info.Parent, info.Args)
+		}
+	}
+	config := DefaultConfig()
+	config.MaxConcurrentResolvers = 0
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { user: User } type User { name: String }`).
+		Resolver("Query", "user", func(ctx *Context, p any, a map[string]any) (any, error) {
+			log = append(log, "resolve Query.user")
+			return map[string]any{"name": "ada"}, nil
+		}).
+		FieldMiddleware(record("first")).
+		FieldMiddleware(record("second")).
+		Build().Unwrap()
+	s.UseField(record("used"))
+
+	var data struct {
+		User struct{ Name string }
+	}
+	postQuery(t, s, `{ __typename user { __typename name } }`, &data)
+	if data.User.Name != "ada" {
+		t.Errorf("name = %q, want ada", data.User.Name)
+	}
+	want := []string{
+		"first > Query.user",
+		"second > Query.user",
+		"used > Query.user",
+		"resolve Query.user",
+		"used < Query.user",
+		"second < Query.user",
+		"first < Query.user",
+		"first > User.name (default)",
+		"second > User.name (default)",
+		"used > User.name (default)",
+		"used < User.name (default)",
+		"second < User.name (default)",
+		"first < User.name (default)",
+	}
+	if strings.Join(log, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFieldMiddlewareShortCircuit(t *testing.T) {
+	var resolved, inner []string
+	resolver := func(value any) ResolverFn {
+		return func(ctx *Context, p any, a map[string]any) (any, error) {
+			resolved = append(resolved, fmt.Sprint(value, a))
+			return value, nil
+		}
+	}
+	config := DefaultConfig()
+	config.MaxConcurrentResolvers = 0
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { secret: String, cached: Int, scaled(n: Int!): Int }`).
+		Resolver("Query", "secret", resolver("s3cr3t")).
+		Resolver("Query", "cached", resolver(1)).
+		Resolver("Query", "scaled", func(ctx *Context, p any, a map[string]any) (any, error) {
+			resolved = append(resolved, fmt.Sprint("scaled ", a))
+			return a["n"], nil
+		}).
+		FieldMiddleware(func(ctx *Context, info FieldInfo, next ResolverFn) (any, error) {
+			switch info.Field {
+			case "secret":
+				return nil, errors.New("forbidden")
+			case "cached":
+				return 42, nil
+			case "scaled":
+				return next(ctx, info.Parent, map[string]any{"n": info.Args["n"].(int) * 10})
+			}
+			return next(ctx, info.Parent, info.Args)
+		}).
+		FieldMiddleware(func(ctx *Context, info FieldInfo, next ResolverFn) (any, error) {
+			inner = append(inner, fmt.Sprint(info.Field, " ", info.Args))
+			return next(ctx, info.Parent, info.Args)
+		}).
+		Build().Unwrap()
+
+	data, errs := postGraphQL(t, s, `{ secret cached scaled(n: 2) }`)
+	if string(data) != `{"secret":null,"cached":42,"scaled":20}` {
+		t.Errorf("data = %s", data)
+	}
+	if len(errs) != 1 || errs[0].Message != "forbidden" || fmt.Sprint(errs[0].Path) != "[secret]" {
+		t.Errorf("errors = %+v, want forbidden at secret", errs)
+	}
+	if want := []string{"scaled map[n:20]"}; !slices.Equal(resolved, want) {
+		t.Errorf("resolved %q, want %q", resolved, want)
+	}
+	if want := []string{"scaled map[n:20]"}; !slices.Equal(inner, want) {
+		t.Errorf("inner middleware saw %q, want %q", inner, want)
+	}
+}
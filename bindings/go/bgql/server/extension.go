@@ -0,0 +1,193 @@
+package server
+
+import (
+	"maps"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// Extension hooks into the execution of every request. It implements any
+// of RequestExtension, ParseExtension, ValidationExtension,
+// ExecutionExtension, and FieldExtension; the hooks of each extension run
+// in the order the extensions were added, and end hooks in reverse.
+//
+// Hooks may add keys to the response extensions with
+// Context.SetExtension.
+type Extension any
+
+// RequestExtension hooks into requests, outside the middleware chain.
+type RequestExtension interface {
+	// OnRequest runs when a request arrives. The Context it returns, for
+	// example one carrying a tracing span, is used for the request.
+	OnRequest(ctx *Context) *Context
+	// OnResponse runs with the response before it is sent, or, for a
+	// subscription, once it has started or failed to.
+	OnResponse(ctx *Context, resp *Response)
+}
+
+// ParseExtension hooks into parsing the document.
+type ParseExtension interface {
+	// OnParse runs after the document is parsed, with the syntax error
+	// if it failed.
+	OnParse(ctx *Context, doc *language.Document, err error)
+}
+
+// ValidationExtension hooks into validating the operation.
+type ValidationExtension interface {
+	// OnValidate runs after the operation is validated, its variables and
+	// arguments coerced, and its cost checked, with the errors that
+	// reject it.
+	OnValidate(ctx *Context, errs []GraphQLError)
+}
+
+// ExecutionExtension hooks into executing queries and mutations.
+type ExecutionExtension interface {
+	OnExecuteStart(ctx *Context)
+	OnExecuteEnd(ctx *Context, resp *Response)
+}
+
+// FieldExtension hooks into resolving fields outside introspection,
+// including fields without a registered resolver.
+type FieldExtension interface {
+	// OnFieldStart runs before the field middleware and resolver. The
+	// Context it returns, derived through WithContext, is passed to them
+	// and to OnFieldEnd.
+	OnFieldStart(ctx *Context, info FieldInfo) *Context
+	// OnFieldEnd runs with the result of the resolver, or the error it
+	// returned or panicked with.
+	OnFieldEnd(ctx *Context, info FieldInfo, value any, err error)
+}
+
+// Extension adds an extension to the server. Built-in extensions, such as
+// metrics, run first.
+func (b *Builder) Extension(ext Extension) *Builder {
+	b.extensions = append(b.extensions, ext)
+	return b
+}
+
+// UseExtension adds an extension to the server. It is safe to call while
+// the server is handling requests.
+func (s *Server) UseExtension(ext Extension) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = s.hooks.with(ext)
+	return s
+}
+
+func (s *Server) extensionHooks() *extensionHooks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hooks
+}
+
+// SetExtension sets a key of the response extensions. It is safe for
+// concurrent use.
+func (c *Context) SetExtension(key string, value any) {
+	state := c.shared()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.extensions == nil {
+		state.extensions = make(map[string]any)
+	}
+	state.extensions[key] = value
+}
+
+// Complexity returns the cost of the operation, as computed for
+// MaxComplexity, and whether it has been computed yet.
+func (c *Context) Complexity() (int, bool) {
+	if c.state == nil {
+		return 0, false
+	}
+	return c.state.complexity, c.state.costed
+}
+
+// =============================================================================
+// Hooks
+// =============================================================================
+
+// extensionHooks holds the extensions of a server by the hooks they
+// implement. It is replaced, not modified, when an extension is added.
+type extensionHooks struct {
+	request  []RequestExtension
+	parse    []ParseExtension
+	validate []ValidationExtension
+	execute  []ExecutionExtension
+	field    []FieldExtension
+}
+
+// with returns the hooks of h and ext.
+func (h *extensionHooks) with(ext Extension) *extensionHooks {
+	out := &extensionHooks{}
+	if h != nil {
+		*out = *h
+	}
+	if ext, ok := ext.(RequestExtension); ok {
+		out.request = append(out.request[:len(out.request):len(out.request)], ext)
+	}
+	if ext, ok := ext.(ParseExtension); ok {
+		out.parse = append(out.parse[:len(out.parse):len(out.parse)], ext)
+	}
+	if ext, ok := ext.(ValidationExtension); ok {
+		out.validate = append(out.validate[:len(out.validate):len(out.validate)], ext)
+	}
+	if ext, ok := ext.(ExecutionExtension); ok {
+		out.execute = append(out.execute[:len(out.execute):len(out.execute)], ext)
+	}
+	if ext, ok := ext.(FieldExtension); ok {
+		out.field = append(out.field[:len(out.field):len(out.field)], ext)
+	}
+	return out
+}
+
+// onRequest runs the OnRequest hooks, returning the Context of each, the
+// last of which the request uses.
+func (h *extensionHooks) onRequest(ctx *Context) []*Context {
+	ctxs := make([]*Context, len(h.request))
+	for i, ext := range h.request {
+		ctx = ext.OnRequest(ctx)
+		ctxs[i] = ctx
+	}
+	return ctxs
+}
+
+// onResponse runs the OnResponse hooks in reverse, then adds the keys set
+// with SetExtension to resp.
+func (h *extensionHooks) onResponse(ctx *Context, ctxs []*Context, resp *Response) {
+	for i := len(h.request) - 1; i >= 0; i-- {
+		h.request[i].OnResponse(ctxs[i], resp)
+	}
+	state := ctx.shared()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if len(state.extensions) == 0 || resp == nil {
+		return
+	}
+	if resp.Extensions == nil {
+		resp.Extensions = make(map[string]any, len(state.extensions))
+	}
+	maps.Copy(resp.Extensions, state.extensions)
+}
+
+func (h *extensionHooks) onParse(ctx *Context, doc *language.Document, err error) {
+	for _, ext := range h.parse {
+		ext.OnParse(ctx, doc, err)
+	}
+}
+
+func (h *extensionHooks) onValidate(ctx *Context, errs []GraphQLError) {
+	for _, ext := range h.validate {
+		ext.OnValidate(ctx, errs)
+	}
+}
+
+func (h *extensionHooks) onExecuteStart(ctx *Context) {
+	for _, ext := range h.execute {
+		ext.OnExecuteStart(ctx)
+	}
+}
+
+func (h *extensionHooks) onExecuteEnd(ctx *Context, resp *Response) {
+	for i := len(h.execute) - 1; i >= 0; i-- {
+		h.execute[i].OnExecuteEnd(ctx, resp)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// mountHealth registers the liveness and readiness endpoints. They are
+// served directly, bypassing GraphQL middleware.
+func (s *Server) mountHealth(mux *http.ServeMux) {
+	if path := s.config.HealthPath; path != "" {
+		mux.HandleFunc(path, s.handleHealth)
+	}
+	if path := s.config.ReadyPath; path != "" {
+		mux.HandleFunc(path, s.handleReady)
+	}
+}
+
+// handleHealth always succeeds while the server is serving.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// handleReady succeeds when Config.ReadyCheck, if any, returns nil.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if check := s.config.ReadyCheck; check != nil {
+		if err := check(r.Context()); err != nil {
+			writeHealth(w, http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "error": err.Error()})
+			return
+		}
+	}
+	writeHealth(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+func writeHealth(w http.ResponseWriter, status int, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// introspect resolves a field of one of the built-in introspection types.
+// Parents are represented by the schema model itself: *schema.Schema for
+// __Schema, *language.Type for __Type (so wrapping types can be
+// described), and *schema.Field, *schema.InputValue, *schema.EnumValue,
+// and *schema.Directive for the rest.
+func (e *executor) introspect(parentType *schema.Type, field string, parent any, args map[string]any) (any, error) {
+	includeDeprecated, _ := args["includeDeprecated"].(bool)
+
+	switch p := parent.(type) {
+	case *schema.Schema:
+		return e.introspectSchema(p, field), nil
+	case *language.Type:
+		return e.introspectType(p, field, includeDeprecated), nil
+	case *schema.Field:
+		switch field {
+		case "name":
+			return p.Name, nil
+		case "description":
+			return nullableString(p.Description), nil
+		case "args":
+			return inputValues(p.Args, includeDeprecated), nil
+		case "type":
+			return p.Type, nil
+		case "isDeprecated":
+			return p.Deprecated, nil
+		case "deprecationReason":
+			return deprecationReason(p.Deprecated, p.DeprecationReason), nil
+		}
+	case *schema.InputValue:
+		switch field {
+		case "name":
+			return p.Name, nil
+		case "description":
+			return nullableString(p.Description), nil
+		case "type":
+			return p.Type, nil
+		case "defaultValue":
+			if p.DefaultValue == nil {
+				return nil, nil
+			}
+			return language.Print(p.DefaultValue), nil
+		case "isDeprecated":
+			return p.Deprecated, nil
+		case "deprecationReason":
+			return deprecationReason(p.Deprecated, p.DeprecationReason), nil
+		}
+	case *schema.EnumValue:
+		switch field {
+		case "name":
+			return p.Name, nil
+		case "description":
+			return nullableString(p.Description), nil
+		case "isDeprecated":
+			return p.Deprecated, nil
+		case "deprecationReason":
+			return deprecationReason(p.Deprecated, p.DeprecationReason), nil
+		}
+	case *schema.Directive:
+		switch field {
+		case "name":
+			return p.Name, nil
+		case "description":
+			return nullableString(p.Description), nil
+		case "isRepeatable":
+			return p.Repeatable, nil
+		case "locations":
+			return p.Locations, nil
+		case "args":
+			return inputValues(p.Args, includeDeprecated), nil
+		}
+	}
+	return nil, nil
+}
+
+func (e *executor) introspectSchema(s *schema.Schema, field string) any {
+	switch field {
+	case "description":
+		return nullableString(s.Description)
+	case "types":
+		types := make([]*language.Type, 0, len(s.Types()))
+		for _, t := range s.Types() {
+			types = append(types, language.NamedType(t.Name))
+		}
+		return types
+	case "queryType":
+		return typeRef(s.Query)
+	case "mutationType":
+		return typeRef(s.Mutation)
+	case "subscriptionType":
+		return typeRef(s.Subscription)
+	case "directives":
+		return s.DirectiveDefinitions()
+	}
+	return nil
+}
+
+func (e *executor) introspectType(ref *language.Type, field string, includeDeprecated bool) any {
+	switch {
+	case ref.NonNull:
+		switch field {
+		case "kind":
+			return "NON_NULL"
+		case "ofType":
+			return ref.Nullable()
+		}
+		return nil
+	case ref.Elem != nil:
+		switch field {
+		case "kind":
+			return "LIST"
+		case "ofType":
+			return ref.Elem
+		}
+		return nil
+	}
+
+	t := e.schema.Type(ref.NamedType)
+	if t == nil {
+		return nil
+	}
+
+	switch field {
+	case "kind":
+		return string(t.Kind)
+	case "name":
+		return t.Name
+	case "description":
+		return nullableString(t.Description)
+	case "specifiedByURL":
+		if t.Kind != schema.Scalar {
+			return nil
+		}
+		return nullableString(t.SpecifiedByURL)
+	case "fields":
+		if t.Kind != schema.Object && t.Kind != schema.Interface {
+			return nil
+		}
+		fields := make([]*schema.Field, 0, len(t.Fields))
+		for _, f := range t.Fields {
+			if includeDeprecated || !f.Deprecated {
+				fields = append(fields, f)
+			}
+		}
+		return fields
+	case "interfaces":
+		if t.Kind != schema.Object && t.Kind != schema.Interface {
+			return nil
+		}
+		interfaces := make([]*language.Type, 0, len(t.Interfaces))
+		for _, iface := range t.Interfaces {
+			interfaces = append(interfaces, language.NamedType(iface.Name))
+		}
+		return interfaces
+	case "possibleTypes":
+		if !t.IsAbstract() {
+			return nil
+		}
+		possible := e.schema.PossibleTypes(t)
+		types := make([]*language.Type, 0, len(possible))
+		for _, pt := range possible {
+			types = append(types, language.NamedType(pt.Name))
+		}
+		return types
+	case "enumValues":
+		if t.Kind != schema.Enum {
+			return nil
+		}
+		values := make([]*schema.EnumValue, 0, len(t.EnumValues))
+		for _, v := range t.EnumValues {
+			if includeDeprecated || !v.Deprecated {
+				values = append(values, v)
+			}
+		}
+		return values
+	case "inputFields":
+		if t.Kind != schema.InputObject {
+			return nil
+		}
+		return inputValues(t.InputFields, includeDeprecated)
+	case "isOneOf":
+		if t.Kind != schema.InputObject {
+			return nil
+		}
+		return t.OneOf
+	}
+	return nil
+}
+
+func inputValues(values []*schema.InputValue, includeDeprecated bool) []*schema.InputValue {
+	out := make([]*schema.InputValue, 0, len(values))
+	for _, v := range values {
+		if includeDeprecated || !v.Deprecated {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func typeRef(t *schema.Type) any {
+	if t == nil {
+		return nil
+	}
+	return language.NamedType(t.Name)
+}
+
+// nullableString returns s, or nil when s is empty.
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func deprecationReason(deprecated bool, reason string) any {
+	if !deprecated {
+		return nil
+	}
+	return reason
+}
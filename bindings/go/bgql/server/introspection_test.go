@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// introspectionSDL declares every construct introspection reports.
+const introspectionSDL = `
+"The root query."
+type Query {
+  "Looks up a user."
+  user("The user's ID." id: ID!, legacy: Boolean @deprecated(reason: "Unused.")): User
+  oldUser: User @deprecated(reason: "Use user.")
+  time: DateTime
+}
+
+"""
+A person.
+"""
+type User {
+  id: ID!
+  name: String @tag(name: "public") @tag(name: "profile")
+  role: Role
+}
+
+"An access role."
+enum Role {
+  ADMIN
+  "Can edit."
+  EDITOR
+  GUEST @deprecated(reason: "Sign up instead.")
+}
+
+input UserFilter {
+  name: String
+  nick: String @deprecated
+}
+
+"An RFC 3339 timestamp."
+scalar DateTime @specifiedBy(url: "https://tools.ietf.org/html/rfc3339")
+
+"Tags an element for a contract."
+directive @tag("The contract." name: String!) repeatable on FIELD_DEFINITION | OBJECT
+
+directive @auth(requires: Role = ADMIN) on FIELD_DEFINITION
+`
+
+const introspectionQuery = `{
+  __schema {
+    directives { name description locations isRepeatable args { ...InputValue } }
+    types { ...FullType }
+  }
+}
+fragment FullType on __Type {
+  kind name description specifiedByURL
+  fields(includeDeprecated: true) { name description isDeprecated deprecationReason args(includeDeprecated: true) { ...InputValue } type { ...TypeRef } }
+  inputFields(includeDeprecated: true) { ...InputValue }
+  enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+}
+fragment InputValue on __InputValue { name description defaultValue isDeprecated deprecationReason type { ...TypeRef } }
+fragment TypeRef on __Type { kind name ofType { kind name ofType { kind name } } }
+`
+
+func TestIntrospectionGolden(t *testing.T) {
+	s := NewBuilder().
+		Schema(introspectionSDL + "extend type Query { filter(by: UserFilter): [User!] }").
+		Build().Unwrap()
+
+	got, err := json.MarshalIndent(json.RawMessage(postQueryRaw(t, s, introspectionQuery)), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "introspection.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, append(got, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if string(got)+"\n" != string(want) {
+		t.Errorf("introspection differs from %s (run with -update to refresh it):\n%s", golden, got)
+	}
+}
+
+func TestPrintedSchemaRoundTrips(t *testing.T) {
+	s := NewBuilder().Schema(introspectionSDL).Build().Unwrap()
+	printed := s.SDL()
+	reparsed := NewBuilder().Schema(printed).Build()
+	if reparsed.IsErr() {
+		t.Fatalf("printed schema does not build: %v\n%s", reparsed.Error(), printed)
+	}
+	if again := reparsed.Unwrap().SDL(); again != printed {
+		t.Errorf("printing is not stable:\nfirst:\n%s\nsecond:\n%s", printed, again)
+	}
+	for _, want := range []string{
+		`"""Tags an element for a contract."""`,
+		`) repeatable on FIELD_DEFINITION | OBJECT`,
+		`@specifiedBy(url: "https://tools.ietf.org/html/rfc3339")`,
+		`@deprecated(reason: "Use user.")`,
+		`"""Can edit."""`,
+		`nick: String @deprecated`,
+	} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("printed schema lacks %s:\n%s", want, printed)
+		}
+	}
+}
+
+func TestIntrospectionHidesDeprecated(t *testing.T) {
+	s := NewBuilder().
+		Schema(introspectionSDL + "extend type Query { filter(by: UserFilter): [User!] }").
+		Build().Unwrap()
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ __type(name: "Role") { enumValues { name } } }`, `{"__type":{"enumValues":[{"name":"ADMIN"},{"name":"EDITOR"}]}}`},
+		{`{ __type(name: "Query") { fields { name } } }`, `{"__type":{"fields":[{"name":"user"},{"name":"time"},{"name":"filter"}]}}`},
+		{`{ __type(name: "Query") { fields { args { name } } } }`, `{"__type":{"fields":[{"args":[{"name":"id"}]},{"args":[]},{"args":[{"name":"by"}]}]}}`},
+		{`{ __type(name: "UserFilter") { inputFields { name } } }`, `{"__type":{"inputFields":[{"name":"name"}]}}`},
+	}
+	for _, tt := range tests {
+		if got := string(postQueryRaw(t, s, tt.query)); got != tt.want {
+			t.Errorf("%s\n got %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIntrospectionIsOneOf(t *testing.T) {
+	s := NewBuilder().
+		Schema(`
+			input UserBy @oneOf { id: ID email: String }
+			input UserFilter { name: String }
+			type Query { user(by: UserBy!, filter: UserFilter): String }
+		`).
+		Build().Unwrap()
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ __type(name: "UserBy") { kind isOneOf } }`, `{"__type":{"kind":"INPUT_OBJECT","isOneOf":true}}`},
+		{`{ __type(name: "UserFilter") { isOneOf } }`, `{"__type":{"isOneOf":false}}`},
+		{`{ __type(name: "Query") { isOneOf } }`, `{"__type":{"isOneOf":null}}`},
+		{`{ __schema { directives { name } } }`, `"name":"oneOf"`},
+	}
+	for _, tt := range tests {
+		if got := string(postQueryRaw(t, s, tt.query)); !strings.Contains(got, tt.want) {
+			t.Errorf("%s\n got %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// JWTConfig configures JWTAuthMiddleware. At least one of Secret,
+// PublicKey, and JWKSURL must be set.
+type JWTConfig struct {
+	// Secret verifies HS256 tokens.
+	Secret []byte
+	// PublicKey verifies RS256 tokens.
+	PublicKey *rsa.PublicKey
+	// JWKSURL is where RS256 keys are fetched from, selected by the
+	// token's kid header.
+	JWKSURL string
+	// JWKSCacheTTL is how long fetched keys are used before they are
+	// fetched again. Defaults to an hour.
+	JWKSCacheTTL time.Duration
+	// HTTPClient fetches JWKSURL. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// RolesClaim names the claim listing the user's roles. Defaults to
+	// "roles".
+	RolesClaim string
+	// Leeway allows for clock skew when checking exp and nbf.
+	Leeway time.Duration
+
+	// Optional lets requests without a token through with no identity.
+	// Invalid tokens are still rejected.
+	Optional bool
+}
+
+// JWTClaimsKey is the Context.Data key of a verified token's claims.
+const JWTClaimsKey = "JWTClaims"
+
+// JWTAuthMiddleware authenticates requests by the JWT in their
+// "Authorization: Bearer" header. The subject and roles of a valid token
+// are stored in Context.Data and in the request's context.Context under
+// sdk.CurrentUserID and sdk.UserRoles, and its claims under JWTClaimsKey.
+// Requests with a missing, expired, or invalid token are rejected with
+// code UNAUTHENTICATED, unless Optional lets tokenless requests through;
+// why a token is invalid is logged, not returned.
+func JWTAuthMiddleware(config JWTConfig) Middleware {
+	v := &jwtVerifier{config: config}
+	if v.config.RolesClaim == "" {
+		v.config.RolesClaim = "roles"
+	}
+	if v.config.JWKSCacheTTL <= 0 {
+		v.config.JWKSCacheTTL = time.Hour
+	}
+	if v.config.HTTPClient == nil {
+		v.config.HTTPClient = http.DefaultClient
+	}
+
+	return func(ctx *Context, next func(*Context) *Response) *Response {
+		token, found := bearerToken(ctx.Request)
+		if !found {
+			if config.Optional {
+				return next(ctx)
+			}
+			return unauthenticated("Authentication required.")
+		}
+		claims, err := v.verify(ctx, token)
+		if err != nil {
+			// Why a token failed helps forge the next one, so only the log
+			// says.
+			fmt.Fprintf(os.Stderr, "[bgql] invalid token: %v\n", err)
+			return unauthenticated("Invalid token.")
+		}
+
+		userID, _ := claims["sub"].(string)
+		roles := claimStrings(claims[v.config.RolesClaim])
+		ctx.Set(sdk.CurrentUserID.String(), userID)
+		ctx.Set(sdk.UserRoles.String(), roles)
+		ctx.Set(JWTClaimsKey, claims)
+		authed := sdk.UserRoles.Set(sdk.CurrentUserID.Set(ctx.Context, userID), roles)
+		return next(ctx.WithContext(authed))
+	}
+}
+
+func unauthenticated(message string) *Response {
+	return &Response{Errors: []GraphQLError{{
+		Message:    message,
+		Extensions: map[string]any{"code": "UNAUTHENTICATED"},
+	}}}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// claimStrings converts a roles claim, a list or a space-separated string,
+// to strings.
+func claimStrings(claim any) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []any:
+		out := make([]string, 0, len(claim))
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// =============================================================================
+// Verification
+// =============================================================================
+
+type jwtVerifier struct {
+	config JWTConfig
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PublicKey
+	fetched  time.Time
+	fetching *jwksFetch
+}
+
+// jwksFetch is a fetch of the JWKS in flight, which concurrent requests
+// needing new keys wait for instead of fetching again.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the signature and registered claims of a compact JWT and
+// returns its claims.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch header.Alg {
+	case "HS256":
+		if len(v.config.Secret) == 0 {
+			return nil, errors.New("unsupported algorithm HS256")
+		}
+		mac := hmac.New(sha256.New, v.config.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errors.New("signature mismatch")
+		}
+	case "RS256":
+		key, err := v.rsaKey(ctx, header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return nil, errors.New("signature mismatch")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed claims")
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checkClaims checks the exp, nbf, iss, and aud claims.
+func (v *jwtVerifier) checkClaims(claims map[string]any) error {
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.config.Leeway)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-v.config.Leeway)) {
+		return errors.New("token is not valid yet")
+	}
+	if v.config.Issuer != "" && claims["iss"] != v.config.Issuer {
+		return errors.New("unexpected issuer")
+	}
+	if v.config.Audience != "" {
+		audience := claimStrings(claims["aud"])
+		if !slices.Contains(audience, v.config.Audience) {
+			return errors.New("unexpected audience")
+		}
+	}
+	return nil
+}
+
+// rsaKey returns the RS256 key for kid: PublicKey, or a key from the JWKS,
+// which is fetched again when it is stale or lacks kid.
+func (v *jwtVerifier) rsaKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if v.config.JWKSURL == "" {
+		if v.config.PublicKey == nil {
+			return nil, errors.New("unsupported algorithm RS256")
+		}
+		return v.config.PublicKey, nil
+	}
+
+	v.mu.Lock()
+	stale := time.Since(v.fetched) > v.config.JWKSCacheTTL
+	key, ok := v.keys[kid]
+	// Refetch for an unknown kid at most once a minute, so forged kids
+	// cannot hammer the key server.
+	if (ok && !stale) || (!stale && time.Since(v.fetched) <= time.Minute) {
+		v.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	// The fetch runs unlocked, so requests with cached keys go on.
+	fetch := v.fetching
+	if fetch == nil {
+		fetch = &jwksFetch{done: make(chan struct{})}
+		v.fetching = fetch
+		v.mu.Unlock()
+		keys, err := v.fetchJWKS(ctx)
+		v.mu.Lock()
+		if err == nil {
+			v.keys, v.fetched = keys, time.Now()
+		}
+		fetch.err = err
+		v.fetching = nil
+		v.mu.Unlock()
+		close(fetch.done)
+	} else {
+		v.mu.Unlock()
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if fetch.err != nil {
+		return nil, fmt.Errorf("fetching keys: %w", fetch.err)
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// fetchJWKS fetches the RSA keys of the JWKS by kid.
+func (v *jwtVerifier) fetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
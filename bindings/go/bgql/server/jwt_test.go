@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// signJWT returns a compact JWT of claims, signed with HS256 by a []byte
+// key or RS256 by an *rsa.PrivateKey.
+func signJWT(t *testing.T, key any, kid string, claims map[string]any) string {
+	t.Helper()
+	header := map[string]string{"alg": "HS256", "kid": kid}
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		header["alg"] = "RS256"
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthMiddleware(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]any{"sub": "u1", "roles": []any{"admin"}, "iss": "bgql", "exp": time.Now().Add(time.Hour).Unix()}
+	with := func(key string, value any) map[string]any {
+		claims := make(map[string]any, len(valid))
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+	config := JWTConfig{Secret: secret, PublicKey: &rsaKey.PublicKey, Issuer: "bgql"}
+
+	tests := []struct {
+		name     string
+		config   JWTConfig
+		header   string
+		wantUser string
+		wantErr  string
+	}{
+		{"hs256", config, "Bearer " + signJWT(t, secret, "", valid), "u1", ""},
+		{"rs256", config, "Bearer " + signJWT(t, rsaKey, "", valid), "u1", ""},
+		{"missing", config, "", "", "Authentication required."},
+		{"optional", JWTConfig{Secret: secret, Optional: true}, "", "", ""},
+		{"not bearer", config, "Basic dTpw", "", "Authentication required."},
+		{"expired", config, "Bearer " + signJWT(t, secret, "", with("exp", time.Now().Add(-time.Hour).Unix())), "", "Invalid token."},
+		{"not yet valid", config, "Bearer " + signJWT(t, secret, "", with("nbf", time.Now().Add(time.Hour).Unix())), "", "Invalid token."},
+		{"wrong issuer", config, "Bearer " + signJWT(t, secret, "", with("iss", "evil")), "", "Invalid token."},
+		{"wrong secret", config, "Bearer " + signJWT(t, []byte("guess"), "", valid), "", "Invalid token."},
+		{"malformed", config, "Bearer abc.def", "", "Invalid token."},
+		{"optional invalid", JWTConfig{Secret: secret, Optional: true}, "Bearer abc.def", "", "Invalid token."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			var user string
+			var roles []string
+			resp := JWTAuthMiddleware(tt.config)(NewContext(context.Background(), r), func(ctx *Context) *Response {
+				user, _ = sdk.CurrentUserID.Get(ctx)
+				roles, _ = sdk.UserRoles.Get(ctx)
+				return &Response{}
+			})
+			if tt.wantErr != "" {
+				if len(resp.Errors) != 1 || resp.Errors[0].Message != tt.wantErr || resp.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+					t.Fatalf("errors = %+v, want %q", resp.Errors, tt.wantErr)
+				}
+				return
+			}
+			if len(resp.Errors) > 0 {
+				t.Fatalf("errors = %+v", resp.Errors)
+			}
+			if user != tt.wantUser {
+				t.Errorf("user = %q, want %q", user, tt.wantUser)
+			}
+			if tt.wantUser != "" && !slices.Equal(roles, []string{"admin"}) {
+				t.Errorf("roles = %v, want [admin]", roles)
+			}
+		})
+	}
+}
+
+func TestJWKSFetchDoesNotBlockCachedKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := func(kid string) map[string]string {
+		return map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}
+	}
+
+	var fetches atomic.Int32
+	var kids atomic.Value
+	kids.Store([]string{"k1"})
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			entered <- struct{}{}
+			<-release
+		}
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for _, kid := range kids.Load().([]string) {
+			set.Keys = append(set.Keys, jwk(kid))
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	defer hs.Close()
+
+	v := &jwtVerifier{config: JWTConfig{JWKSURL: hs.URL, JWKSCacheTTL: time.Hour, HTTPClient: hs.Client()}}
+	claims := map[string]any{"sub": "u1"}
+	if _, err := v.verify(context.Background(), signJWT(t, rsaKey, "k1", claims)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A minute on, an unknown kid refetches the keys. Requests with the
+	// cached key go on meanwhile, and others needing k2 share the fetch.
+	v.mu.Lock()
+	v.fetched = v.fetched.Add(-2 * time.Minute)
+	v.mu.Unlock()
+	kids.Store([]string{"k1", "k2"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	verifyK2 := func() {
+		defer wg.Done()
+		_, err := v.verify(context.Background(), signJWT(t, rsaKey, "k2", claims))
+		errs <- err
+	}
+	wg.Add(1)
+	go verifyK2()
+	<-entered
+	for i := 0; i < 7; i++ {
+		wg.Add(1)
+		go verifyK2()
+	}
+
+	cached := make(chan error, 1)
+	go func() {
+		_, err := v.verify(context.Background(), signJWT(t, rsaKey, "k1", claims))
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached key blocked by the JWKS fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("verify k2: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetches = %d, want 2", n)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+)
+
+// LoaderFactory creates the DataLoader of a request, for loaders
+// registered with Builder.Loader. Create one with NewLoaderFactory.
+type LoaderFactory interface {
+	newLoader() any
+}
+
+type loaderFactory[K comparable, V any] struct {
+	batchFn func(ctx context.Context, keys []K) (map[K]V, error)
+	config  DataLoaderConfig
+}
+
+func (f loaderFactory[K, V]) newLoader() any {
+	return NewDataLoaderWithConfig(f.batchFn, f.config)
+}
+
+// NewLoaderFactory returns a factory of DataLoaders fetching with batchFn.
+func NewLoaderFactory[K comparable, V any](batchFn func(ctx context.Context, keys []K) (map[K]V, error), config DataLoaderConfig) LoaderFactory {
+	return loaderFactory[K, V]{batchFn: batchFn, config: config}
+}
+
+// Loader registers a DataLoader that each request gets its own of, created
+// by factory on first use. Resolvers fetch it with the Loader function.
+func (b *Builder) Loader(name string, factory LoaderFactory) *Builder {
+	if b.loaders == nil {
+		b.loaders = make(map[string]LoaderFactory)
+	}
+	b.loaders[name] = factory
+	return b
+}
+
+// Loader returns the request's DataLoader registered as name with
+// Builder.Loader, or one created with GetLoader. It fails if there is no
+// such loader, or it has other key or value types.
+func Loader[K comparable, V any](ctx *Context, name string) (*DataLoader[K, V], error) {
+	store := ctx.Loaders
+	if store == nil {
+		return nil, fmt.Errorf("loader %q: no loaders in context", name)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	loader, ok := store.loaders[name]
+	if !ok {
+		factory, ok := store.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("loader %q is not registered", name)
+		}
+		loader = factory.newLoader()
+		store.loaders[name] = loader
+	}
+	typed, ok := loader.(*DataLoader[K, V])
+	if !ok {
+		return nil, fmt.Errorf("loader %q is a %T, not a %T", name, loader, typed)
+	}
+	return typed, nil
+}
+
+// newLoaderStore returns an empty store of the loaders registered with
+// Builder.Loader.
+func (s *Server) newLoaderStore() *LoaderStore {
+	store := NewLoaderStore()
+	store.factories = s.loaders
+	return store
+}
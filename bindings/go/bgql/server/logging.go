@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// RequestLog describes a completed GraphQL request.
+type RequestLog struct {
+	// RequestID is set by RequestIDMiddleware, when it runs first.
+	RequestID     string
+	Path          string
+	OperationName string
+	// OperationType is query, mutation, or subscription, or "" if no
+	// operation was selected.
+	OperationType string
+	// DocumentHash is the hex SHA-256 of the query text, as used by
+	// automatic persisted queries.
+	DocumentHash string
+	Duration     time.Duration
+	Client       ClientInfo
+	// ErrorCodes lists the distinct extensions.code values of the errors
+	// in the response, in order. Errors without a code are only counted.
+	ErrorCodes []string
+	Errors     int
+	// Variables holds the variables, redacted, only when LogVariables is
+	// set.
+	Variables map[string]any
+}
+
+// Attrs returns the fields of l as slog attributes.
+func (l RequestLog) Attrs() []slog.Attr {
+	var attrs []slog.Attr
+	if l.RequestID != "" {
+		attrs = append(attrs, slog.String("requestId", l.RequestID))
+	}
+	attrs = append(attrs,
+		slog.String("path", l.Path),
+		slog.String("operationName", l.OperationName),
+		slog.String("operationType", l.OperationType),
+		slog.String("documentHash", l.DocumentHash),
+		slog.Duration("duration", l.Duration),
+		slog.Int("errors", l.Errors),
+	)
+	if len(l.ErrorCodes) > 0 {
+		attrs = append(attrs, slog.Any("errorCodes", l.ErrorCodes))
+	}
+	if client := l.Client.String(); client != "" {
+		attrs = append(attrs, slog.String("client", client))
+	}
+	if l.Variables != nil {
+		attrs = append(attrs, slog.Any("variables", l.Variables))
+	}
+	return attrs
+}
+
+// String formats l as a single log line.
+func (l RequestLog) String() string {
+	var b strings.Builder
+	operation := l.OperationName
+	if operation == "" {
+		operation = "anonymous"
+	}
+	for _, part := range []string{l.Path, l.OperationType} {
+		if part != "" {
+			b.WriteString(part + " ")
+		}
+	}
+	fmt.Fprintf(&b, "%s completed in %v (hash: %s, errors: %d", operation, l.Duration, l.DocumentHash, l.Errors)
+	if len(l.ErrorCodes) > 0 {
+		fmt.Fprintf(&b, ", codes: %s", strings.Join(l.ErrorCodes, ","))
+	}
+	if client := l.Client.String(); client != "" {
+		fmt.Fprintf(&b, ", client: %s", client)
+	}
+	if l.RequestID != "" {
+		fmt.Fprintf(&b, ", request: %s", l.RequestID)
+	}
+	if l.Variables != nil {
+		variables, _ := json.Marshal(l.Variables)
+		fmt.Fprintf(&b, ", variables: %s", variables)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// RedactedValue replaces the values of redacted variables in logs.
+const RedactedValue = "[REDACTED]"
+
+// LoggingConfig configures LoggingMiddlewareWithConfig and
+// SlogMiddleware.
+type LoggingConfig struct {
+	// Sink receives the record of each request. Defaults to printing it.
+	// SlogMiddleware ignores it.
+	Sink func(RequestLog)
+	// LogVariables includes the request variables in the record.
+	LogVariables bool
+	// RedactVariables names the variables, and the fields of input objects
+	// at any depth, whose values are replaced with RedactedValue.
+	// Names are matched case-insensitively.
+	RedactVariables []string
+}
+
+// LoggingMiddleware logs a line for each request with logger, which
+// defaults to printing to stdout.
+func LoggingMiddleware(logger func(format string, args ...any)) Middleware {
+	if logger == nil {
+		logger = func(format string, args ...any) {
+			fmt.Printf(format+"\n", args...)
+		}
+	}
+	return LoggingMiddlewareWithConfig(LoggingConfig{
+		Sink: func(l RequestLog) { logger("[bgql] %s", l) },
+	})
+}
+
+// LoggingMiddlewareWithConfig passes a record of each request to
+// config.Sink.
+func LoggingMiddlewareWithConfig(config LoggingConfig) Middleware {
+	sink := config.Sink
+	if sink == nil {
+		sink = func(l RequestLog) { fmt.Printf("[bgql] %s\n", l) }
+	}
+	return loggingMiddleware(config, func(ctx *Context, l RequestLog) { sink(l) })
+}
+
+// SlogMiddleware logs each request to logger, or slog.Default() if it is
+// nil: at Info level, or Warn if the response has errors.
+func SlogMiddleware(logger *slog.Logger, config LoggingConfig) Middleware {
+	return loggingMiddleware(config, func(ctx *Context, l RequestLog) {
+		logger := logger
+		if logger == nil {
+			// Resolve the default per request, as slog.SetDefault may run
+			// after the middleware is created.
+			logger = slog.Default()
+		}
+		level := slog.LevelInfo
+		if l.Errors > 0 {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(context.WithoutCancel(ctx), level, "graphql request", l.Attrs()...)
+	})
+}
+
+func loggingMiddleware(config LoggingConfig, emit func(*Context, RequestLog)) Middleware {
+	redacted := make(map[string]bool, len(config.RedactVariables))
+	for _, name := range config.RedactVariables {
+		redacted[strings.ToLower(name)] = true
+	}
+
+	return func(ctx *Context, next func(*Context) *Response) *Response {
+		start := time.Now()
+		resp := next(ctx)
+
+		record := RequestLog{
+			RequestID: ctx.GetString(sdk.RequestID.String()),
+			Duration:  time.Since(start),
+			Client:    ctx.ClientInfo(),
+		}
+		if ctx.Request != nil {
+			record.Path = ctx.Request.URL.Path
+		}
+		if req := ctx.GraphQL; req != nil {
+			sum := sha256.Sum256([]byte(req.Query))
+			record.DocumentHash = hex.EncodeToString(sum[:])
+			record.OperationName = req.OperationName
+			op := ctx.Operation()
+			if op == nil {
+				// Middleware may have executed a copy of ctx.
+				if doc, err := language.Parse(req.Query); err == nil {
+					op = doc.Operation(req.OperationName)
+				}
+			}
+			if op != nil {
+				record.OperationName, record.OperationType = op.Name, string(op.Operation)
+			}
+			if config.LogVariables {
+				record.Variables = redactValue(req.Variables, redacted).(map[string]any)
+			}
+		}
+		if resp != nil {
+			record.Errors = len(resp.Errors)
+			seen := make(map[string]bool)
+			for _, err := range resp.Errors {
+				if code, ok := err.Extensions["code"].(string); ok && !seen[code] {
+					seen[code] = true
+					record.ErrorCodes = append(record.ErrorCodes, code)
+				}
+			}
+		}
+
+		emit(ctx, record)
+		return resp
+	}
+}
+
+// redactValue copies the maps and lists of a variable value, replacing the
+// values of redacted keys.
+func redactValue(v any, redacted map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			if redacted[strings.ToLower(key)] {
+				out[key] = RedactedValue
+				continue
+			}
+			out[key] = redactValue(value, redacted)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = redactValue(value, redacted)
+		}
+		return out
+	}
+	return v
+}
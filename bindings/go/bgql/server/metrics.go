@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server/metrics"
+)
+
+// Metrics sets the collector that receives request, resolver, and rate
+// limiting metrics. When it is an http.Handler, such as
+// metrics.Prometheus, it is served at Config.MetricsPath.
+func (b *Builder) Metrics(collector metrics.Collector) *Builder {
+	b.metrics = collector
+	return b
+}
+
+// mountMetrics serves the collector at Config.MetricsPath, bypassing
+// GraphQL middleware.
+func (s *Server) mountMetrics(mux *http.ServeMux) {
+	if handler, ok := s.metrics.(http.Handler); ok && s.config.MetricsPath != "" {
+		mux.Handle(s.config.MetricsPath, handler)
+	}
+}
+
+// metricsExtension reports requests and resolver calls to a collector.
+type metricsExtension struct {
+	collector metrics.Collector
+}
+
+// startKey is the context.Context key of the time a request or resolver
+// call started.
+type startKey struct{}
+
+func (x metricsExtension) OnRequest(ctx *Context) *Context {
+	return ctx.WithContext(context.WithValue(ctx.Context, startKey{}, time.Now()))
+}
+
+func (x metricsExtension) OnResponse(ctx *Context, resp *Response) {
+	start, _ := ctx.Value(startKey{}).(time.Time)
+	client := ctx.ClientInfo()
+	r := metrics.Request{
+		OperationName: metrics.UnknownOperation,
+		OperationType: metrics.UnknownOperation,
+		Duration:      time.Since(start),
+		Errors:        len(resp.Errors),
+		ClientName:    client.Name,
+		ClientVersion: client.Version,
+	}
+	if op := ctx.Operation(); op != nil {
+		r.OperationType = string(op.Operation)
+		if op.Name != "" {
+			r.OperationName = op.Name
+		}
+	}
+	x.collector.ObserveRequest(r)
+
+	for _, err := range resp.Errors {
+		if err.Extensions["code"] == "RATE_LIMITED" {
+			x.collector.ObserveRateLimited()
+			break
+		}
+	}
+}
+
+// OnFieldStart times calls of registered resolvers.
+func (x metricsExtension) OnFieldStart(ctx *Context, info FieldInfo) *Context {
+	if info.Default {
+		return ctx
+	}
+	return ctx.WithContext(context.WithValue(ctx.Context, startKey{}, time.Now()))
+}
+
+func (x metricsExtension) OnFieldEnd(ctx *Context, info FieldInfo, value any, err error) {
+	if info.Default {
+		return
+	}
+	start, _ := ctx.Value(startKey{}).(time.Time)
+	x.collector.ObserveResolver(info.ParentType+"."+info.Field, time.Since(start))
+}
@@ -0,0 +1,37 @@
+// Package metrics collects operational metrics of a bgql server.
+package metrics
+
+import "time"
+
+// UnknownOperation is the operation name and type label of anonymous
+// operations and of requests whose operation could not be determined, so
+// they share a single series.
+const UnknownOperation = "unknown"
+
+// Request describes one executed GraphQL request.
+type Request struct {
+	OperationName string
+	// OperationType is "query", "mutation", "subscription", or
+	// UnknownOperation.
+	OperationType string
+	Duration      time.Duration
+	// Errors is the number of errors in the response.
+	Errors int
+	// ClientName and ClientVersion identify the application that sent the
+	// request, from its apollographql-client-name and -version headers.
+	// They are empty when it sent none.
+	ClientName    string
+	ClientVersion string
+}
+
+// Collector receives server metrics. Implementations must be safe for
+// concurrent use.
+type Collector interface {
+	// ObserveRequest records an executed request.
+	ObserveRequest(r Request)
+	// ObserveResolver records one call of the resolver of field, named as
+	// "Type.field".
+	ObserveResolver(field string, duration time.Duration)
+	// ObserveRateLimited records a request rejected by rate limiting.
+	ObserveRateLimited()
+}
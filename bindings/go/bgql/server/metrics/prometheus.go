@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the latency histogram buckets, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus is a Collector that serves its metrics in the Prometheus text
+// exposition format.
+type Prometheus struct {
+	mu               sync.Mutex
+	requests         *counterVec
+	requestErrors    *counterVec
+	requestDuration  *histogramVec
+	resolverDuration *histogramVec
+	rateLimited      *counterVec
+}
+
+// NewPrometheus creates a Prometheus collector.
+func NewPrometheus() *Prometheus {
+	operation := []string{"operation_name", "operation_type", "client_name", "client_version"}
+	return &Prometheus{
+		requests:         newCounterVec("bgql_requests_total", "GraphQL requests executed.", operation),
+		requestErrors:    newCounterVec("bgql_request_errors_total", "GraphQL requests whose response has errors.", operation),
+		requestDuration:  newHistogramVec("bgql_request_duration_seconds", "GraphQL request latency.", operation),
+		resolverDuration: newHistogramVec("bgql_resolver_duration_seconds", "Resolver latency.", []string{"field"}),
+		rateLimited:      newCounterVec("bgql_rate_limited_total", "Requests rejected by rate limiting.", nil),
+	}
+}
+
+// ObserveRequest records an executed request.
+func (p *Prometheus) ObserveRequest(r Request) {
+	name, typ := r.OperationName, r.OperationType
+	if name == "" {
+		name = UnknownOperation
+	}
+	if typ == "" {
+		typ = UnknownOperation
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests.inc(name, typ, r.ClientName, r.ClientVersion)
+	if r.Errors > 0 {
+		p.requestErrors.inc(name, typ, r.ClientName, r.ClientVersion)
+	}
+	p.requestDuration.observe(r.Duration, name, typ, r.ClientName, r.ClientVersion)
+}
+
+// ObserveResolver records one resolver call.
+func (p *Prometheus) ObserveResolver(field string, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolverDuration.observe(duration, field)
+}
+
+// ObserveRateLimited records a rate-limited request.
+func (p *Prometheus) ObserveRateLimited() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rateLimited.inc()
+}
+
+// ServeHTTP writes the metrics, for mounting at /metrics.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	var b strings.Builder
+	p.requests.write(&b)
+	p.requestErrors.write(&b)
+	p.requestDuration.write(&b)
+	p.resolverDuration.write(&b)
+	p.rateLimited.write(&b)
+	p.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// =============================================================================
+// Series
+// =============================================================================
+
+// seriesKey joins label values into a map key.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// labelEscaper escapes label values for the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats label pairs, with extra appended, as {a="x",b="y"}.
+func formatLabels(names []string, key string, extra ...string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, names[i]+`="`+labelEscaper.Replace(value)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+	values     map[string]float64
+}
+
+func newCounterVec(name, help string, labels []string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) inc(values ...string) {
+	c.values[seriesKey(values)]++
+}
+
+func (c *counterVec) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if len(c.labels) == 0 {
+		fmt.Fprintf(b, "%s %s\n", c.name, formatFloat(c.values[""]))
+		return
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.values)) {
+		fmt.Fprintf(b, "%s%s %s\n", c.name, formatLabels(c.labels, key), formatFloat(c.values[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	series     map[string]*histogram
+}
+
+func newHistogramVec(name, help string, labels []string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: DefaultBuckets, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(d time.Duration, values ...string) {
+	key := seriesKey(values)
+	s := h.series[key]
+	if s == nil {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	seconds := d.Seconds()
+	for i, upper := range h.buckets {
+		if seconds <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += seconds
+	s.count++
+}
+
+func (h *histogramVec) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, formatLabels(h.labels, key), s.count)
+	}
+}
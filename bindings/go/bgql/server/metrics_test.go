@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server/metrics"
+)
+
+func TestMetricsLabelClients(t *testing.T) {
+	config := DefaultConfig()
+	config.MetricsPath = "/metrics"
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Metrics(metrics.NewPrometheus()).
+		Build().Unwrap()
+	handler := s.Handler()
+
+	clients := []http.Header{
+		{"Apollographql-Client-Name": {"web"}, "Apollographql-Client-Version": {"1.2.0"}},
+		{"Apollographql-Client-Name": {"web"}, "Apollographql-Client-Version": {"1.2.0"}},
+		{"Apollographql-Client-Name": {"ios"}},
+		{},
+	}
+	for _, header := range clients {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"query Q { a }"}`))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`bgql_requests_total{operation_name="Q",operation_type="query",client_name="web",client_version="1.2.0"} 2`,
+		`bgql_requests_total{operation_name="Q",operation_type="query",client_name="ios",client_version=""} 1`,
+		`bgql_requests_total{operation_name="Q",operation_type="query",client_name="",client_version=""} 1`,
+		`bgql_request_duration_seconds_count{operation_name="Q",operation_type="query",client_name="web",client_version="1.2.0"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics lack %s:\n%s", want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// PersistedQueryStore stores the text of automatic persisted queries by
+// the hex SHA-256 hash of the query.
+type PersistedQueryStore interface {
+	Get(ctx context.Context, hash string) (query string, ok bool, err error)
+	Put(ctx context.Context, hash, query string) error
+}
+
+// defaultPersistedQueries is the capacity of the default store.
+const defaultPersistedQueries = 1000
+
+// PersistedQueries sets where automatic persisted queries are stored. By
+// default the most recently used 1000 queries are kept in memory; a nil
+// store disables persisted queries.
+func (b *Builder) PersistedQueries(store PersistedQueryStore) *Builder {
+	b.persisted = store
+	return b
+}
+
+// resolvePersistedQuery applies the persistedQuery extension of req: a
+// request with only a hash gets the stored query, and one with a hash and
+// a query stores it.
+func (s *Server) resolvePersistedQuery(ctx *Context, req *Request) *GraphQLError {
+	ext, ok := req.Extensions["persistedQuery"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	if s.persisted == nil {
+		return persistedQueryError("PersistedQueryNotSupported", "PERSISTED_QUERY_NOT_SUPPORTED")
+	}
+	if version, _ := ext["version"].(float64); version != 1 {
+		return persistedQueryError("Unsupported persisted query version.", "PERSISTED_QUERY_NOT_SUPPORTED")
+	}
+	hash, _ := ext["sha256Hash"].(string)
+	if hash == "" {
+		return persistedQueryError("Persisted query is missing sha256Hash.", "BAD_USER_INPUT")
+	}
+	hash = strings.ToLower(hash)
+
+	if req.Query == "" {
+		query, found, err := s.persisted.Get(ctx, hash)
+		if err != nil {
+			return &GraphQLError{Message: err.Error()}
+		}
+		if !found {
+			return persistedQueryError("PersistedQueryNotFound", "PERSISTED_QUERY_NOT_FOUND")
+		}
+		req.Query = query
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(req.Query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return persistedQueryError("Provided sha256Hash does not match query.", "PERSISTED_QUERY_HASH_MISMATCH")
+	}
+	if err := s.persisted.Put(ctx, hash, req.Query); err != nil {
+		return &GraphQLError{Message: err.Error()}
+	}
+	return nil
+}
+
+func persistedQueryError(message, code string) *GraphQLError {
+	return &GraphQLError{Message: message, Extensions: map[string]any{"code": code}}
+}
+
+// =============================================================================
+// LRU Store
+// =============================================================================
+
+// LRUPersistedQueryStore keeps the most recently used persisted queries
+// in memory.
+type LRUPersistedQueryStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type persistedEntry struct {
+	hash  string
+	query string
+}
+
+// NewLRUPersistedQueryStore creates a store holding up to capacity
+// queries.
+func NewLRUPersistedQueryStore(capacity int) *LRUPersistedQueryStore {
+	return &LRUPersistedQueryStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the query stored for hash.
+func (s *LRUPersistedQueryStore) Get(ctx context.Context, hash string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[hash]
+	if !ok {
+		return "", false, nil
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*persistedEntry).query, true, nil
+}
+
+// Put stores query, evicting the least recently used query when full.
+func (s *LRUPersistedQueryStore) Put(ctx context.Context, hash, query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[hash]; ok {
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.entries[hash] = s.order.PushFront(&persistedEntry{hash: hash, query: query})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*persistedEntry).hash)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+//go:embed playground/assets
+var playgroundBundle embed.FS
+
+// bundledPlayground returns the playground assets built into the server.
+func bundledPlayground() fs.FS {
+	sub, err := fs.Sub(playgroundBundle, "playground/assets")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// PlaygroundAssetMode selects the source of the playground's scripts and styles.
+type PlaygroundAssetMode int
+
+const (
+	// PlaygroundAssetsEmbedded, the default, serves a bundled playground
+	// under <PlaygroundPath>/assets/, from PlaygroundAssets.FS if set or
+	// else from the assets built into the server.
+	PlaygroundAssetsEmbedded PlaygroundAssetMode = iota
+	// PlaygroundAssetsCDN loads GraphiQL and React from jsdelivr.
+	PlaygroundAssetsCDN
+	// PlaygroundAssetsCustomBaseURL loads GraphiQL and React from an
+	// internal mirror of the npm CDN layout.
+	PlaygroundAssetsCustomBaseURL
+)
+
+// DefaultPlaygroundCDN is the npm CDN used by PlaygroundAssetsCDN.
+const DefaultPlaygroundCDN = "https://cdn.jsdelivr.net/npm"
+
+// PlaygroundAssets configures where the playground loads its assets from.
+type PlaygroundAssets struct {
+	Mode PlaygroundAssetMode
+	// BaseURL is the mirror root for PlaygroundAssetsCustomBaseURL. It must
+	// serve the same paths as jsdelivr, e.g. BaseURL + "/graphiql@3/graphiql.min.js".
+	BaseURL string
+	// FS replaces the built-in bundle for PlaygroundAssetsEmbedded. It must
+	// contain playground.js and playground.css at its root.
+	FS fs.FS
+}
+
+// PlaygroundConfig customizes the playground page. Empty fields take the
+// defaults noted below.
+type PlaygroundConfig struct {
+	// Title is the page title. Defaults to "bgql Playground".
+	Title string
+	// DefaultQuery is the query shown in a fresh editor.
+	DefaultQuery string
+	// DefaultHeaders is a JSON object of request headers prefilled in the
+	// headers editor, e.g. `{"Authorization": "Bearer "}`.
+	DefaultHeaders string
+	// Endpoint is the URL queries are sent to. Defaults to Config.Path
+	// under the prefix the server is mounted at.
+	Endpoint string
+	// SubscriptionEndpoint is the WebSocket URL subscriptions use.
+	// Defaults to Config.SubscriptionPath, or else the endpoint.
+	SubscriptionEndpoint string
+	// Theme is the editor theme, "light" or "dark". Defaults to dark for
+	// the embedded playground and to the user's choice for GraphiQL.
+	Theme string
+}
+
+// resolvePlaygroundConfig fills in the defaults of Config.PlaygroundConfig
+// for a server mounted under prefix.
+func (s *Server) resolvePlaygroundConfig(prefix string) PlaygroundConfig {
+	cfg := s.config.PlaygroundConfig
+	if cfg.Title == "" {
+		cfg.Title = "bgql Playground"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = prefix + s.path()
+	}
+	if cfg.SubscriptionEndpoint == "" {
+		if path := s.config.SubscriptionPath; path != "" {
+			cfg.SubscriptionEndpoint = prefix + path
+		} else {
+			cfg.SubscriptionEndpoint = cfg.Endpoint
+		}
+	}
+	return cfg
+}
+
+// checkPlaygroundConfig reports a PlaygroundConfig that cannot be served.
+func checkPlaygroundConfig(cfg PlaygroundConfig) language.ErrorList {
+	var errs language.ErrorList
+	if cfg.DefaultHeaders != "" {
+		var headers map[string]any
+		if err := json.Unmarshal([]byte(cfg.DefaultHeaders), &headers); err != nil {
+			errs = append(errs, language.NewError(nil, "playground default headers must be a JSON object: %v", err))
+		}
+	}
+	switch cfg.Theme {
+	case "", "light", "dark":
+	default:
+		errs = append(errs, language.NewError(nil, "unknown playground theme %q", cfg.Theme))
+	}
+	return errs
+}
+
+// playgroundAssets returns the asset configuration in effect, applying
+// Config.PlaygroundCDN and the built-in bundle.
+func (s *Server) playgroundAssets() PlaygroundAssets {
+	assets := s.config.PlaygroundAssets
+	if s.config.PlaygroundCDN {
+		return PlaygroundAssets{Mode: PlaygroundAssetsCDN}
+	}
+	if assets.Mode == PlaygroundAssetsEmbedded && assets.FS == nil {
+		assets.FS = bundledPlayground()
+	}
+	return assets
+}
+
+// mountPlayground registers the playground page and, in embedded mode, its assets.
+func (s *Server) mountPlayground(mux *http.ServeMux) {
+	assets := s.playgroundAssets()
+	version := ""
+	if assets.Mode == PlaygroundAssetsEmbedded {
+		version = assetVersion(assets.FS)
+		prefix := s.playgroundAssetPrefix()
+		mux.Handle(prefix, http.StripPrefix(prefix, serveAssets(assets.FS)))
+	}
+
+	mux.HandleFunc(s.config.PlaygroundPath, func(w http.ResponseWriter, r *http.Request) {
+		s.handlePlayground(w, r, assets, version)
+	})
+}
+
+// serveAssets serves the files of fsys with their content types. Asset
+// URLs carry the bundle's version, so responses may be cached for good.
+func serveAssets(fsys fs.FS) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := mime.TypeByExtension(path.Ext(r.URL.Path)); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// assetVersion returns a short digest of the bundle's script and styles,
+// which changes whenever they do.
+func assetVersion(fsys fs.FS) string {
+	h := sha256.New()
+	for _, name := range []string{"playground.js", "playground.css"} {
+		data, _ := fs.ReadFile(fsys, name)
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:6])
+}
+
+func (s *Server) playgroundAssetPrefix() string {
+	return strings.TrimSuffix(s.config.PlaygroundPath, "/") + "/assets/"
+}
+
+func (s *Server) handlePlayground(w http.ResponseWriter, r *http.Request, assets PlaygroundAssets, version string) {
+	cfg := s.resolvePlaygroundConfig(mountPrefix(r))
+	if s.playgroundHTML != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, s.playgroundHTML(cfg))
+		return
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data := playgroundData{
+		PlaygroundConfig: cfg,
+		Nonce:            nonce,
+		Version:          version,
+	}
+
+	var tmpl *template.Template
+	switch assets.Mode {
+	case PlaygroundAssetsEmbedded:
+		tmpl = embeddedPlaygroundTemplate
+		data.AssetBase = mountPrefix(r) + strings.TrimSuffix(s.playgroundAssetPrefix(), "/")
+	case PlaygroundAssetsCustomBaseURL:
+		tmpl = cdnPlaygroundTemplate
+		data.AssetBase = strings.TrimSuffix(assets.BaseURL, "/")
+	default:
+		tmpl = cdnPlaygroundTemplate
+		data.AssetBase = DefaultPlaygroundCDN
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", playgroundCSP(nonce, data.AssetBase, cfg.Endpoint, cfg.SubscriptionEndpoint))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// mountPrefix returns the path prefix removed by http.StripPrefix when the
+// server's Handler is mounted under it, or "".
+func mountPrefix(r *http.Request) string {
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil || !strings.HasSuffix(u.Path, r.URL.Path) {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, r.URL.Path)
+}
+
+type playgroundData struct {
+	PlaygroundConfig
+	AssetBase string
+	Version   string
+	Nonce     string
+}
+
+// newNonce returns a random base64url value for the CSP script nonce,
+// which html/template leaves unescaped in attributes.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// playgroundCSP builds a Content-Security-Policy that only runs scripts
+// carrying the nonce, only loads styles and fonts from the asset origin,
+// and only connects to the origins of the endpoints.
+func playgroundCSP(nonce, assetBase string, endpoints ...string) string {
+	origin := "'self'" + urlOrigin(assetBase)
+	connect := "'self'"
+	for _, endpoint := range endpoints {
+		connect += urlOrigin(endpoint)
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'nonce-" + nonce + "'",
+		"style-src 'unsafe-inline' " + origin,
+		"font-src data: " + origin,
+		"img-src 'self' data:",
+		"connect-src " + connect,
+		"base-uri 'none'",
+		"frame-ancestors 'self'",
+	}, "; ")
+}
+
+// urlOrigin returns " scheme://host" for an absolute URL, or "".
+func urlOrigin(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return " " + u.Scheme + "://" + u.Host
+	}
+	return ""
+}
+
+var cdnPlaygroundTemplate = template.Must(template.New("playground").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.AssetBase}}/graphiql@3/graphiql.min.css" />
+  <style>
+    body { margin: 0; height: 100vh; }
+    #graphiql { height: 100vh; }
+  </style>
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script nonce="{{.Nonce}}" crossorigin src="{{.AssetBase}}/react@18/umd/react.production.min.js"></script>
+  <script nonce="{{.Nonce}}" crossorigin src="{{.AssetBase}}/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script nonce="{{.Nonce}}" crossorigin src="{{.AssetBase}}/graphiql@3/graphiql.min.js"></script>
+  <script nonce="{{.Nonce}}">
+    const subscriptionUrl = new URL({{.SubscriptionEndpoint}}, location.href);
+    subscriptionUrl.protocol = subscriptionUrl.protocol.replace(/^http/, 'ws');
+    const root = ReactDOM.createRoot(document.getElementById('graphiql'));
+    root.render(
+      React.createElement(GraphiQL, {
+        fetcher: GraphiQL.createFetcher({ url: {{.Endpoint}}, subscriptionUrl: subscriptionUrl.href }),
+        defaultEditorToolsVisibility: true,{{with .DefaultQuery}}
+        defaultQuery: {{.}},{{end}}{{with .DefaultHeaders}}
+        defaultHeaders: {{.}},{{end}}{{with .Theme}}
+        forcedTheme: {{.}},{{end}}
+      })
+    );
+  </script>
+</body>
+</html>`))
+
+var embeddedPlaygroundTemplate = template.Must(template.New("playground").Parse(`<!DOCTYPE html>
+<html{{with .Theme}} data-theme="{{.}}"{{end}}>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.AssetBase}}/playground.css?v={{.Version}}" />
+</head>
+<body>
+  <div id="bgql-playground" data-endpoint="{{.Endpoint}}" data-subscription-endpoint="{{.SubscriptionEndpoint}}" data-title="{{.Title}}"
+    {{- with .DefaultQuery}} data-default-query="{{.}}"{{end}}{{with .DefaultHeaders}} data-default-headers="{{.}}"{{end}}>Loading...</div>
+  <script nonce="{{.Nonce}}" src="{{.AssetBase}}/playground.js?v={{.Version}}"></script>
+</body>
+</html>`))
@@ -0,0 +1,285 @@
+// bgql playground: a dependency-free GraphQL IDE served from the Go binary.
+(function () {
+  "use strict";
+
+  var mount = document.getElementById("bgql-playground");
+  var endpoint = mount.getAttribute("data-endpoint") || "/graphql";
+  var subscriptionEndpoint = mount.getAttribute("data-subscription-endpoint") || endpoint;
+  var storageKey = "bgql-playground:" + endpoint;
+
+  var defaultQuery = mount.getAttribute("data-default-query") ||
+    "# Welcome to the bgql playground.\n# Press Ctrl+Enter (Cmd+Enter) to run.\n\n{\n  __typename\n}\n";
+  var defaultHeaders = mount.getAttribute("data-default-headers") || "";
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      if (key === "text") {
+        node.textContent = attrs[key];
+      } else {
+        node.setAttribute(key, attrs[key]);
+      }
+    });
+    (children || []).forEach(function (child) {
+      node.appendChild(child);
+    });
+    return node;
+  }
+
+  function load() {
+    try {
+      return JSON.parse(localStorage.getItem(storageKey)) || {};
+    } catch (e) {
+      return {};
+    }
+  }
+
+  function save(state) {
+    try {
+      localStorage.setItem(storageKey, JSON.stringify(state));
+    } catch (e) {
+      // Storage may be unavailable (private mode); the session still works.
+    }
+  }
+
+  var saved = load();
+
+  var query = el("textarea", { class: "bgql-editor", spellcheck: "false", "aria-label": "Query" });
+  query.value = saved.query || defaultQuery;
+
+  var variables = el("textarea", { class: "bgql-editor", spellcheck: "false", "aria-label": "Variables" });
+  variables.value = saved.variables || "";
+
+  var headers = el("textarea", { class: "bgql-editor", spellcheck: "false", "aria-label": "Headers" });
+  headers.value = saved.headers || defaultHeaders;
+
+  var output = el("pre", { class: "bgql-output", "aria-live": "polite" });
+  var status = el("span", { class: "bgql-status" });
+  var run = el("button", { class: "bgql-run", type: "button", title: "Run (Ctrl+Enter)", text: "▶ Run" });
+  var docsToggle = el("button", { class: "bgql-docs-toggle", type: "button", text: "Docs" });
+  var docs = el("aside", { class: "bgql-docs", hidden: "" });
+
+  var varsTab = el("button", { class: "bgql-tab bgql-tab-active", type: "button", text: "Variables" });
+  var headersTab = el("button", { class: "bgql-tab", type: "button", text: "Headers" });
+  headers.hidden = true;
+
+  function selectTab(showVariables) {
+    variables.hidden = !showVariables;
+    headers.hidden = showVariables;
+    varsTab.classList.toggle("bgql-tab-active", showVariables);
+    headersTab.classList.toggle("bgql-tab-active", !showVariables);
+  }
+  varsTab.addEventListener("click", function () { selectTab(true); });
+  headersTab.addEventListener("click", function () { selectTab(false); });
+
+  mount.textContent = "";
+  mount.appendChild(el("header", { class: "bgql-header" }, [
+    el("span", { class: "bgql-title", text: mount.getAttribute("data-title") || "bgql Playground" }),
+    run,
+    status,
+    el("span", { class: "bgql-endpoint", text: endpoint }),
+    docsToggle,
+  ]));
+  mount.appendChild(el("main", { class: "bgql-main" }, [
+    el("section", { class: "bgql-pane bgql-input" }, [
+      query,
+      el("div", { class: "bgql-tabs" }, [varsTab, headersTab]),
+      variables,
+      headers,
+    ]),
+    el("section", { class: "bgql-pane" }, [output]),
+    docs,
+  ]));
+
+  function parseJSON(text, label) {
+    if (!text.trim()) {
+      return {};
+    }
+    try {
+      return JSON.parse(text);
+    } catch (e) {
+      throw new Error(label + " are not valid JSON: " + e.message);
+    }
+  }
+
+  function request(body) {
+    var extraHeaders = parseJSON(headers.value, "Headers");
+    var allHeaders = { "Content-Type": "application/json", Accept: "application/json" };
+    Object.keys(extraHeaders).forEach(function (key) {
+      allHeaders[key] = String(extraHeaders[key]);
+    });
+    return fetch(endpoint, {
+      method: "POST",
+      headers: allHeaders,
+      body: JSON.stringify(body),
+      credentials: "same-origin",
+    }).then(function (resp) {
+      return resp.text().then(function (text) {
+        try {
+          return { status: resp.status, body: JSON.parse(text) };
+        } catch (e) {
+          return { status: resp.status, body: text };
+        }
+      });
+    });
+  }
+
+  // Subscriptions run over graphql-transport-ws; each event is appended to
+  // the output until the server completes the stream or another run starts.
+  var socket = null;
+
+  function isSubscription(text) {
+    return /^\s*subscription\b/.test(text.replace(/#[^\n]*/g, ""));
+  }
+
+  function subscribe(body) {
+    var extraHeaders = parseJSON(headers.value, "Headers");
+    var url = new URL(subscriptionEndpoint, location.href);
+    url.protocol = url.protocol.replace(/^http/, "ws");
+    var ws = new WebSocket(url.href, "graphql-transport-ws");
+    socket = ws;
+    var events = 0;
+    output.textContent = "";
+    status.textContent = "Connecting…";
+    ws.onopen = function () {
+      ws.send(JSON.stringify({ type: "connection_init", payload: extraHeaders }));
+    };
+    ws.onmessage = function (event) {
+      var msg = JSON.parse(event.data);
+      if (msg.type === "connection_ack") {
+        status.textContent = "Subscribed";
+        ws.send(JSON.stringify({ id: "1", type: "subscribe", payload: body }));
+      } else if (msg.type === "ping") {
+        ws.send(JSON.stringify({ type: "pong" }));
+      } else if (msg.type === "next" || msg.type === "error") {
+        events++;
+        status.textContent = events + (events === 1 ? " event" : " events");
+        output.textContent += JSON.stringify(msg.payload, null, 2) + "\n";
+      } else if (msg.type === "complete") {
+        ws.close(1000);
+      }
+    };
+    ws.onclose = function (event) {
+      if (socket === ws) {
+        socket = null;
+        status.textContent = events + " events · closed" + (event.reason ? ": " + event.reason : "");
+      }
+    };
+  }
+
+  function execute() {
+    save({ query: query.value, variables: variables.value, headers: headers.value });
+    if (socket) {
+      socket.close(1000);
+      socket = null;
+    }
+    var vars;
+    try {
+      vars = parseJSON(variables.value, "Variables");
+      if (isSubscription(query.value)) {
+        subscribe({ query: query.value, variables: vars });
+        return;
+      }
+    } catch (e) {
+      output.textContent = e.message;
+      return;
+    }
+    var started = performance.now();
+    status.textContent = "Running…";
+    request({ query: query.value, variables: vars }).then(function (result) {
+      var ms = Math.round(performance.now() - started);
+      status.textContent = "HTTP " + result.status + " · " + ms + " ms";
+      output.textContent = typeof result.body === "string" ? result.body : JSON.stringify(result.body, null, 2);
+    }).catch(function (e) {
+      status.textContent = "";
+      output.textContent = String(e && e.message ? e.message : e);
+    });
+  }
+
+  run.addEventListener("click", execute);
+  mount.addEventListener("keydown", function (event) {
+    if (event.key === "Enter" && (event.ctrlKey || event.metaKey)) {
+      event.preventDefault();
+      execute();
+    }
+  });
+  [query, variables, headers].forEach(function (area) {
+    area.addEventListener("keydown", function (event) {
+      if (event.key === "Tab" && !event.shiftKey) {
+        event.preventDefault();
+        var start = area.selectionStart;
+        area.setRangeText("  ", start, area.selectionEnd, "end");
+      }
+    });
+  });
+
+  // Documentation explorer backed by a small introspection query.
+  var docsQuery = "query PlaygroundDocs { __schema { queryType { name } mutationType { name } subscriptionType { name } " +
+    "types { kind name description fields(includeDeprecated: true) { name description isDeprecated " +
+    "args { name type { ...Ref } } type { ...Ref } } inputFields { name type { ...Ref } } enumValues { name } } } } " +
+    "fragment Ref on __Type { kind name ofType { kind name ofType { kind name ofType { kind name } } } }";
+
+  function typeName(ref) {
+    if (!ref) {
+      return "";
+    }
+    if (ref.kind === "NON_NULL") {
+      return typeName(ref.ofType) + "!";
+    }
+    if (ref.kind === "LIST") {
+      return "[" + typeName(ref.ofType) + "]";
+    }
+    return ref.name;
+  }
+
+  function renderDocs(schema) {
+    docs.textContent = "";
+    var types = schema.types.filter(function (t) { return t.name.indexOf("__") !== 0; });
+    types.forEach(function (type) {
+      var section = el("details", { class: "bgql-doc-type" }, [
+        el("summary", { text: type.kind.toLowerCase() + " " + type.name }),
+      ]);
+      if (type.description) {
+        section.appendChild(el("p", { class: "bgql-doc-desc", text: type.description }));
+      }
+      var list = el("ul");
+      (type.fields || []).forEach(function (f) {
+        var args = (f.args || []).map(function (a) { return a.name + ": " + typeName(a.type); }).join(", ");
+        var item = el("li", { text: f.name + (args ? "(" + args + ")" : "") + ": " + typeName(f.type) });
+        if (f.isDeprecated) {
+          item.classList.add("bgql-deprecated");
+        }
+        if (f.description) {
+          item.title = f.description;
+        }
+        list.appendChild(item);
+      });
+      (type.inputFields || []).forEach(function (f) {
+        list.appendChild(el("li", { text: f.name + ": " + typeName(f.type) }));
+      });
+      (type.enumValues || []).forEach(function (v) {
+        list.appendChild(el("li", { text: v.name }));
+      });
+      section.appendChild(list);
+      docs.appendChild(section);
+    });
+  }
+
+  docsToggle.addEventListener("click", function () {
+    docs.hidden = !docs.hidden;
+    if (docs.hidden || docs.getAttribute("data-loaded")) {
+      return;
+    }
+    docs.textContent = "Loading schema…";
+    request({ query: docsQuery, operationName: "PlaygroundDocs" }).then(function (result) {
+      if (!result.body || !result.body.data || !result.body.data.__schema) {
+        docs.textContent = "Schema unavailable (introspection may be disabled).";
+        return;
+      }
+      docs.setAttribute("data-loaded", "true");
+      renderDocs(result.body.data.__schema);
+    }).catch(function (e) {
+      docs.textContent = String(e && e.message ? e.message : e);
+    });
+  });
+})();
@@ -0,0 +1,32 @@
+// Package playground bundles a self-hosted playground IDE for the bgql server.
+//
+// The server serves these assets by default. This package exposes them for
+// serving elsewhere, such as from a CDN origin or a separate asset server.
+package playground
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// FS returns the embedded playground assets.
+func FS() fs.FS {
+	sub, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Assets returns a PlaygroundAssets configuration serving the embedded bundle.
+func Assets() server.PlaygroundAssets {
+	return server.PlaygroundAssets{
+		Mode: server.PlaygroundAssetsEmbedded,
+		FS:   FS(),
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedPlayground(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int }`).
+		EnablePlayground("/playground").
+		Build().Unwrap()
+	handler := s.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/playground", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("playground status %d", rec.Code)
+	}
+	html := rec.Body.String()
+
+	csp := rec.Header().Get("Content-Security-Policy")
+	m := regexp.MustCompile(`script-src 'nonce-([^']+)'`).FindStringSubmatch(csp)
+	if m == nil {
+		t.Fatalf("CSP lacks a script nonce: %q", csp)
+	}
+	scripts := regexp.MustCompile(`<script nonce="([^"]*)"`).FindAllStringSubmatch(html, -1)
+	if len(scripts) == 0 {
+		t.Fatalf("page has no scripts:\n%s", html)
+	}
+	if n := strings.Count(html, "<script"); n != len(scripts) {
+		t.Errorf("%d of %d scripts carry a nonce", len(scripts), n)
+	}
+	for _, script := range scripts {
+		if script[1] != m[1] {
+			t.Errorf("script nonce %q, want CSP nonce %q", script[1], m[1])
+		}
+	}
+
+	assets := regexp.MustCompile(`(?:src|href)="([^"]+)"`).FindAllStringSubmatch(html, -1)
+	if len(assets) != 2 {
+		t.Fatalf("page references %d assets, want the script and styles:\n%s", len(assets), html)
+	}
+	wantTypes := map[string]string{".js": "javascript", ".css": "text/css"}
+	for _, asset := range assets {
+		url := asset[1]
+		if !strings.HasPrefix(url, "/playground/assets/") {
+			t.Errorf("asset %s is not served from the playground", url)
+			continue
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET %s: status %d, %d bytes", url, rec.Code, rec.Body.Len())
+			continue
+		}
+		ext := url[strings.LastIndex(url, "."):strings.Index(url, "?")]
+		if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, wantTypes[ext]) {
+			t.Errorf("GET %s: content type %q", url, ct)
+		}
+	}
+
+	// Each page gets a fresh nonce.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/playground", nil))
+	if rec.Header().Get("Content-Security-Policy") == csp {
+		t.Error("nonce reused across pages")
+	}
+}
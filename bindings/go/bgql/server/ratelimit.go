@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig configures RateLimitMiddlewareWithConfig.
+type RateLimitConfig struct {
+	// Window is the length of a rate limit window.
+	Window time.Duration
+	// MaxRequests is how many requests a client may make per window.
+	MaxRequests int
+	// Store holds the request counts. Defaults to an in-memory store; use
+	// a shared store to limit clients across replicas.
+	Store RateLimitStore
+	// TrustedProxies are the addresses of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers identify the client. See
+	// ClientIP.
+	TrustedProxies []netip.Prefix
+}
+
+// RateLimitMiddleware limits each client IP to maxRequests requests per
+// window, counted in memory.
+func RateLimitMiddleware(window time.Duration, maxRequests int) Middleware {
+	return RateLimitMiddlewareWithConfig(RateLimitConfig{Window: window, MaxRequests: maxRequests})
+}
+
+// RateLimitMiddlewareWithConfig limits the request rate of each client IP.
+// The quota is reported in the X-RateLimit headers and the rateLimit
+// response extension; requests over it are rejected with code
+// RATE_LIMITED. If the store fails, requests are let through.
+func RateLimitMiddlewareWithConfig(config RateLimitConfig) Middleware {
+	store := config.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore(config.Window)
+	}
+
+	return func(ctx *Context, next func(*Context) *Response) *Response {
+		key := "ratelimit:request:" + ClientIP(ctx.Request, config.TrustedProxies)
+		count, reset, err := store.Incr(ctx, key, 1, config.Window)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[bgql] rate limit store: %v\n", err)
+			return next(ctx)
+		}
+
+		remaining := max(config.MaxRequests-count, 0)
+		quota := map[string]any{
+			"limit":     config.MaxRequests,
+			"remaining": remaining,
+			"reset":     reset.Unix(),
+		}
+		header := ctx.ResponseHeader()
+		header.Set(RateLimitLimitHeader, strconv.Itoa(config.MaxRequests))
+		header.Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+		header.Set(RateLimitResetHeader, strconv.FormatInt(reset.Unix(), 10))
+
+		if count > config.MaxRequests {
+			retryAfter := time.Until(reset)
+			header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+			return &Response{
+				Errors: []GraphQLError{
+					{
+						Message: "Rate limit exceeded",
+						Extensions: map[string]any{
+							"code":       "RATE_LIMITED",
+							"retryAfter": retryAfter.Milliseconds(),
+						},
+					},
+				},
+				Extensions: map[string]any{"rateLimit": quota},
+			}
+		}
+
+		resp := next(ctx)
+		if resp != nil {
+			if resp.Extensions == nil {
+				resp.Extensions = make(map[string]any)
+			}
+			resp.Extensions["rateLimit"] = quota
+		}
+		return resp
+	}
+}
+
+// CostLimitMiddleware gives each client a budget of query cost per
+// window, as computed for MaxComplexity. Each operation's cost is deducted
+// before it executes; an operation that would exceed the remaining budget
+// is rejected with code COST_LIMIT_EXCEEDED. keyFn identifies the client,
+// for example by API key or user ID, and defaults to its IP address. The
+// remaining budget is reported in the costLimit response extension.
+func CostLimitMiddleware(budgetPerWindow int, window time.Duration, keyFn func(*Context) string) Middleware {
+	if keyFn == nil {
+		keyFn = func(ctx *Context) string { return ClientIP(ctx.Request, nil) }
+	}
+	store := NewMemoryRateLimitStore(window)
+
+	return func(ctx *Context, next func(*Context) *Response) *Response {
+		key := "ratelimit:cost:" + keyFn(ctx)
+		var quota map[string]any
+		ctx.costChecks = append(ctx.costChecks, func(cost int) *GraphQLError {
+			used, reset, err := store.Incr(ctx, key, cost, window)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[bgql] cost limit store: %v\n", err)
+				return nil
+			}
+			exceeded := used > budgetPerWindow
+			if exceeded {
+				used, reset, _ = store.Incr(ctx, key, -cost, window)
+			}
+			quota = map[string]any{
+				"limit":     budgetPerWindow,
+				"remaining": max(budgetPerWindow-used, 0),
+				"reset":     reset.Unix(),
+			}
+			if !exceeded {
+				return nil
+			}
+			return &GraphQLError{
+				Message: fmt.Sprintf("Query cost %d exceeds the remaining budget of %d.", cost, max(budgetPerWindow-used, 0)),
+				Extensions: map[string]any{
+					"code":       "COST_LIMIT_EXCEEDED",
+					"retryAfter": time.Until(reset).Milliseconds(),
+				},
+			}
+		})
+
+		resp := next(ctx)
+		if resp != nil && quota != nil {
+			if resp.Extensions == nil {
+				resp.Extensions = make(map[string]any)
+			}
+			resp.Extensions["costLimit"] = quota
+		}
+		return resp
+	}
+}
+
+// ClientIP returns the IP address of the client that sent r: the host of
+// its RemoteAddr or, when that is one of trustedProxies, the last address
+// in X-Forwarded-For not in trustedProxies, or else X-Real-IP.
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	if r == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host, trustedProxies) {
+		return host
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrustedProxy(addr.String(), trustedProxies) || i == 0 {
+			return addr.Unmap().String()
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
+
+func isTrustedProxy(host string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// Stores
+// =============================================================================
+
+// RateLimitStore counts usage per key in fixed windows.
+type RateLimitStore interface {
+	// Get returns the count of key and when its window resets, or zero
+	// values if it has none.
+	Get(ctx context.Context, key string) (count int, reset time.Time, err error)
+	// Incr adds n to the count of key, starting a window of ttl if it has
+	// none, and returns the new count and when the window resets.
+	Incr(ctx context.Context, key string, n int, ttl time.Duration) (count int, reset time.Time, err error)
+}
+
+// MemoryRateLimitStore keeps rate limit counts in memory. Expired windows
+// are swept as counts are added, so the store needs no goroutine and
+// nothing to stop.
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	entries  map[string]rateLimitEntry
+	interval time.Duration
+	swept    time.Time
+}
+
+type rateLimitEntry struct {
+	count int
+	reset time.Time
+}
+
+// NewMemoryRateLimitStore creates a store that removes expired windows at
+// most every cleanupInterval, or every minute if it is not positive.
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	return &MemoryRateLimitStore{
+		entries:  make(map[string]rateLimitEntry),
+		interval: cleanupInterval,
+		swept:    time.Now(),
+	}
+}
+
+// Get returns the count of key in its current window.
+func (s *MemoryRateLimitStore) Get(ctx context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.reset) {
+		return 0, time.Time{}, nil
+	}
+	return entry.count, entry.reset, nil
+}
+
+// Incr adds n to the count of key.
+func (s *MemoryRateLimitStore) Incr(ctx context.Context, key string, n int, ttl time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) >= s.interval {
+		s.sweep(now)
+	}
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.reset) {
+		entry = rateLimitEntry{reset: now.Add(ttl)}
+	}
+	entry.count += n
+	s.entries[key] = entry
+	return entry.count, entry.reset, nil
+}
+
+// sweep removes the windows expired by now. s.mu must be held.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if now.After(entry.reset) {
+			delete(s.entries, key)
+		}
+	}
+	s.swept = now
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int, b: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Query", "b", func(ctx *Context, p any, a map[string]any) (any, error) { return 2, nil }).
+		Build().Unwrap()
+	s.Use(RateLimitMiddleware(time.Minute, 2))
+	handler := s.Handler()
+
+	tests := []struct {
+		remote        string
+		query         string
+		wantRemaining string
+		wantCost      string
+		wantLimited   bool
+	}{
+		{"10.0.0.1:1", "{ a }", "1", "1", false},
+		{"10.0.0.1:2", "{ a b }", "0", "2", false},
+		{"10.0.0.1:3", "{ a }", "0", "", true},
+		{"10.0.0.2:1", "{ a b }", "1", "2", false},
+	}
+	resets := make(map[string]string)
+	for i, tt := range tests {
+		body, _ := json.Marshal(map[string]any{"query": tt.query})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = tt.remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		header := rec.Header()
+		if got := header.Get(RateLimitLimitHeader); got != "2" {
+			t.Errorf("request %d: limit = %q, want 2", i, got)
+		}
+		if got := header.Get(RateLimitRemainingHeader); got != tt.wantRemaining {
+			t.Errorf("request %d: remaining = %q, want %s", i, got, tt.wantRemaining)
+		}
+		if got := header.Get(QueryCostHeader); got != tt.wantCost {
+			t.Errorf("request %d: query cost = %q, want %q", i, got, tt.wantCost)
+		}
+		reset, err := strconv.ParseInt(header.Get(RateLimitResetHeader), 10, 64)
+		if err != nil || reset <= time.Now().Unix() || reset > time.Now().Add(time.Minute).Unix() {
+			t.Errorf("request %d: reset = %q, want within the window", i, header.Get(RateLimitResetHeader))
+		}
+		ip, _, _ := strings.Cut(tt.remote, ":")
+		if prev, ok := resets[ip]; ok && prev != header.Get(RateLimitResetHeader) {
+			t.Errorf("request %d: reset moved from %s to %s within a window", i, prev, header.Get(RateLimitResetHeader))
+		}
+		resets[ip] = header.Get(RateLimitResetHeader)
+
+		var resp Response
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		limited := len(resp.Errors) == 1 && resp.Errors[0].Extensions["code"] == "RATE_LIMITED"
+		if limited != tt.wantLimited {
+			t.Errorf("request %d: limited = %v, want %v (%s)", i, limited, tt.wantLimited, rec.Body)
+		}
+		if limited && header.Get("Retry-After") == "" {
+			t.Errorf("request %d: limited without Retry-After", i)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreSweepsLazily(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRateLimitStore(20 * time.Millisecond)
+	store.Incr(ctx, "a", 1, time.Millisecond)
+	store.Incr(ctx, "b", 1, time.Hour)
+	time.Sleep(30 * time.Millisecond)
+
+	if count, _, _ := store.Get(ctx, "a"); count != 0 {
+		t.Errorf("expired count = %d, want 0", count)
+	}
+	store.Incr(ctx, "c", 1, time.Hour)
+	store.mu.Lock()
+	_, expired := store.entries["a"]
+	n := len(store.entries)
+	store.mu.Unlock()
+	if expired || n != 2 {
+		t.Errorf("entries after sweep = %d (expired kept: %v), want b and c", n, expired)
+	}
+	if count, _, _ := store.Get(ctx, "b"); count != 1 {
+		t.Errorf("live count = %d, want 1", count)
+	}
+}
+
+func TestRateLimitMiddlewaresStartNoGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		RateLimitMiddleware(time.Minute, 10)
+		CostLimitMiddleware(100, time.Minute, nil)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after creating middlewares, was %d", after, before)
+	}
+}
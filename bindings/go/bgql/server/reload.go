@@ -0,0 +1,68 @@
+package server
+
+import "github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+
+// UpdateSchema replaces the server's schema with sdl while it is serving.
+// resolvers replaces the registered resolvers; nil keeps the current ones.
+// The new schema is checked against the resolvers and everything else
+// registered on the Builder, and directive handlers are applied to it.
+//
+// Requests already executing finish against the old schema. If the
+// schema is invalid, the error is returned and the old schema stays
+// active.
+func (s *Server) UpdateSchema(sdl string, resolvers map[string]map[string]ResolverFn) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	b := s.registered
+	b.schemas = []schemaSource{{sdl: sdl}}
+	if resolvers != nil {
+		b.resolvers = resolvers
+	}
+
+	parsed, err := b.buildSchema()
+	if err != nil {
+		return err
+	}
+	errs := b.checkSchema(parsed)
+	if len(errs) == 0 {
+		errs = b.checkCoverage(parsed)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	scalars := b.schemaScalars(parsed)
+	wrapped, errs := s.applyDirectives(parsed, scalars, b.resolvers, b.directives)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	s.mu.Lock()
+	old := s.schema
+	s.schema, s.resolvers, s.scalars = parsed, wrapped, scalars
+	onChange := s.onSchemaChange
+	s.mu.Unlock()
+	s.registered = b
+
+	if onChange != nil {
+		onChange(schema.Print(old), schema.Print(parsed))
+	}
+	return nil
+}
+
+// OnSchemaChange sets a function called with the old and new SDL after
+// UpdateSchema replaces the schema.
+func (s *Server) OnSchemaChange(fn func(old, new string)) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSchemaChange = fn
+	return s
+}
+
+// snapshot returns the schema, resolvers, and scalars a request executes
+// against.
+func (s *Server) snapshot() (*schema.Schema, map[string]map[string]ResolverFn, map[string]ScalarConfig) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schema, s.resolvers, s.scalars
+}
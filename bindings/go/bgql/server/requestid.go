@@ -0,0 +1,104 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// RequestIDHeader is the default header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDConfig configures RequestIDMiddlewareWithConfig.
+type RequestIDConfig struct {
+	// Header is read for the ID of incoming requests and set on responses.
+	// Defaults to RequestIDHeader.
+	Header string
+	// Generate creates IDs for requests without one. Defaults to
+	// NewRequestID.
+	Generate func() string
+}
+
+// RequestIDMiddleware gives each request an ID for correlating logs and
+// support reports. See RequestIDMiddlewareWithConfig.
+func RequestIDMiddleware() Middleware {
+	return RequestIDMiddlewareWithConfig(RequestIDConfig{})
+}
+
+// RequestIDMiddlewareWithConfig identifies each request by its request ID
+// header, or a generated ID when it has none or an invalid one. The ID is
+// stored in Context.Data and the request's context.Context under
+// sdk.RequestID, sent back in the header, and added to the extensions of
+// every error in the response as requestId.
+func RequestIDMiddlewareWithConfig(config RequestIDConfig) Middleware {
+	if config.Header == "" {
+		config.Header = RequestIDHeader
+	}
+	if config.Generate == nil {
+		config.Generate = NewRequestID
+	}
+
+	return func(ctx *Context, next func(*Context) *Response) *Response {
+		var id string
+		if ctx.Request != nil {
+			id = ctx.Request.Header.Get(config.Header)
+		}
+		if !validRequestID(id) {
+			id = config.Generate()
+		}
+		ctx.Set(sdk.RequestID.String(), id)
+		ctx.ResponseHeader().Set(config.Header, id)
+
+		resp := next(ctx.WithContext(sdk.RequestID.Set(ctx.Context, id)))
+		if resp != nil {
+			for i := range resp.Errors {
+				err := &resp.Errors[i]
+				if err.Extensions == nil {
+					err.Extensions = make(map[string]any)
+				}
+				err.Extensions["requestId"] = id
+			}
+		}
+		return resp
+	}
+}
+
+// validRequestID reports whether a client-sent ID is short and printable
+// ASCII, so it is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random UUIDv7, which sorts by creation time.
+func NewRequestID() string {
+	var u [16]byte
+	rand.Read(u[6:])
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(u[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
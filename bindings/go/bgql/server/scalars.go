@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// ScalarConfig defines how a custom scalar is serialized and parsed.
+type ScalarConfig struct {
+	// Serialize converts a resolver's return value to its wire form.
+	Serialize func(value any) (any, error)
+	// ParseValue converts a value supplied through variables.
+	ParseValue func(value any) (any, error)
+	// ParseLiteral converts a value written inline in the query. When nil,
+	// the literal is converted to a JSON-like value and passed to ParseValue.
+	ParseLiteral func(value language.Value) (any, error)
+}
+
+// Scalar registers the serialization and parsing of a custom scalar.
+func (b *Builder) Scalar(name string, serialize func(any) (any, error), parseValue func(any) (any, error)) *Builder {
+	return b.ScalarWithConfig(name, ScalarConfig{Serialize: serialize, ParseValue: parseValue})
+}
+
+// ScalarWithConfig registers a custom scalar, including how its literals
+// are parsed.
+func (b *Builder) ScalarWithConfig(name string, config ScalarConfig) *Builder {
+	b.scalars[name] = config
+	return b
+}
+
+// builtinScalars are used for scalars of these names that the schema
+// declares but no Scalar call registers.
+var builtinScalars = map[string]ScalarConfig{
+	"DateTime": DateTimeScalar,
+	"Time":     TimeScalar,
+	"JSON":     JSONScalar,
+	"Upload":   UploadScalar,
+}
+
+// DateTimeScalar serializes time.Time as an RFC 3339 timestamp and parses
+// RFC 3339 strings to time.Time.
+var DateTimeScalar = ScalarConfig{
+	Serialize: func(value any) (any, error) {
+		return formatTime("DateTime", value, time.RFC3339Nano)
+	},
+	ParseValue: func(value any) (any, error) {
+		return parseTime("DateTime", value, time.RFC3339)
+	},
+}
+
+// timeLayout is the RFC 3339 partial-time; fractional seconds are
+// accepted when parsing.
+const timeLayout = "15:04:05"
+
+// TimeScalar serializes the time of day of a time.Time as "15:04:05" with
+// optional fractional seconds, and parses it to a time.Time on the zero date.
+var TimeScalar = ScalarConfig{
+	Serialize: func(value any) (any, error) {
+		return formatTime("Time", value, timeLayout+".999999999")
+	},
+	ParseValue: func(value any) (any, error) {
+		return parseTime("Time", value, timeLayout)
+	},
+}
+
+// JSONScalar passes arbitrary JSON values through unchanged.
+var JSONScalar = ScalarConfig{
+	Serialize: func(value any) (any, error) {
+		if _, err := json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("JSON cannot represent value: %v", err)
+		}
+		return value, nil
+	},
+	ParseValue: func(value any) (any, error) {
+		return value, nil
+	},
+}
+
+func formatTime(scalar string, value any, layout string) (any, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case *time.Time:
+		if v != nil {
+			return v.Format(layout), nil
+		}
+	case string:
+		if _, err := time.Parse(layout, v); err == nil {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%s cannot represent value: %s", scalar, jsonString(value))
+}
+
+func parseTime(scalar string, value any, layout string) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot represent a non string value: %s", scalar, jsonString(value))
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s cannot represent an invalid value: %q", scalar, s)
+	}
+	return t, nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import "sync"
+
+// scheduler bounds how many of an operation's resolvers run at once, and
+// dispatches the DataLoader batches they wait for once none runs, so that
+// each batch holds the keys of every sibling field and list item.
+type scheduler struct {
+	// sem holds a token per running goroutine. It is nil when fields
+	// resolve serially.
+	sem chan struct{}
+
+	mu      sync.Mutex
+	running int
+	held    []func()
+}
+
+// schedulerKey is the context key of the operation's scheduler.
+type schedulerKey struct{}
+
+func newScheduler(limit int) *scheduler {
+	s := &scheduler{}
+	if limit > 0 {
+		s.sem = make(chan struct{}, limit)
+	}
+	return s
+}
+
+// start counts a goroutine that is about to run resolvers, once it has a
+// token.
+func (s *scheduler) start() {
+	s.resume(true)
+}
+
+// stop stops counting a goroutine that has finished running resolvers.
+func (s *scheduler) stop() {
+	s.pause()
+}
+
+// pause stops counting the calling goroutine while it waits, giving back
+// its token. It reports whether a token was given back, for resume.
+func (s *scheduler) pause() bool {
+	if s == nil {
+		return false
+	}
+	s.leave()
+	return s.release()
+}
+
+// resume counts the calling goroutine again after pause, waiting for a
+// token first if it gave one back.
+func (s *scheduler) resume(token bool) {
+	if s == nil {
+		return
+	}
+	if token {
+		s.acquire()
+	}
+	s.enter()
+}
+
+// acquire waits for a token.
+func (s *scheduler) acquire() {
+	if s.sem != nil {
+		s.sem <- struct{}{}
+	}
+}
+
+// release gives back a token, reporting false if none was taken.
+func (s *scheduler) release() bool {
+	if s.sem == nil {
+		return false
+	}
+	select {
+	case <-s.sem:
+		return true
+	default:
+		return false
+	}
+}
+
+// enter counts a running goroutine.
+func (s *scheduler) enter() {
+	s.mu.Lock()
+	s.running++
+	s.mu.Unlock()
+}
+
+// leave stops counting a running goroutine, dispatching the held batches
+// if it was the last.
+func (s *scheduler) leave() {
+	s.mu.Lock()
+	s.running--
+	var held []func()
+	if s.running <= 0 {
+		held, s.held = s.held, nil
+	}
+	s.mu.Unlock()
+	for _, dispatch := range held {
+		go dispatch()
+	}
+}
+
+// hold calls dispatch once no goroutine runs.
+func (s *scheduler) hold(dispatch func()) {
+	s.mu.Lock()
+	if s.running > 0 {
+		s.held = append(s.held, dispatch)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	go dispatch()
+}
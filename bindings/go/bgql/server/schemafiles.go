@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// schemaSource is an SDL document of the schema, or files holding them,
+// which are read when the server is built.
+type schemaSource struct {
+	name string
+	sdl  string
+
+	file string
+	fsys fs.FS
+	glob string
+}
+
+// SchemaStrings adds SDL documents to the schema.
+func (b *Builder) SchemaStrings(sdl ...string) *Builder {
+	for _, doc := range sdl {
+		b.schemas = append(b.schemas, schemaSource{sdl: doc})
+	}
+	return b
+}
+
+// SchemaFile adds the SDL document in the named file to the schema.
+func (b *Builder) SchemaFile(path string) *Builder {
+	b.schemas = append(b.schemas, schemaSource{file: path})
+	return b
+}
+
+// SchemaFS adds the SDL documents in the files of fsys matching glob, as
+// by fs.Glob, to the schema, in lexical order.
+func (b *Builder) SchemaFS(fsys fs.FS, glob string) *Builder {
+	b.schemas = append(b.schemas, schemaSource{fsys: fsys, glob: glob})
+	return b
+}
+
+// buildSchema reads, parses, and merges the schema documents into one
+// schema. Type extensions apply across documents, and a scalar declared in
+// several documents is declared once. Parse errors name their file.
+func (b *Builder) buildSchema() (*schema.Schema, error) {
+	sources, err := b.readSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &language.Document{}
+	scalars := make(map[string]bool)
+	var errs language.ErrorList
+	for _, src := range sources {
+		doc, err := language.Parse(src.sdl)
+		if err != nil {
+			errs = append(errs, sourceErrors(src.name, err)...)
+			continue
+		}
+		for _, def := range doc.Definitions {
+			if def, ok := def.(*language.TypeDefinition); ok && def.Kind == language.Scalar && !def.Extend {
+				if scalars[def.Name] {
+					continue
+				}
+				scalars[def.Name] = true
+			}
+			merged.Definitions = append(merged.Definitions, def)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return schema.Build(merged)
+}
+
+// readSchemas reads the files of the schema sources.
+func (b *Builder) readSchemas() ([]schemaSource, error) {
+	var sources []schemaSource
+	for _, src := range b.schemas {
+		switch {
+		case src.file != "":
+			data, err := os.ReadFile(src.file)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, schemaSource{name: src.file, sdl: string(data)})
+
+		case src.fsys != nil:
+			names, err := fs.Glob(src.fsys, src.glob)
+			if err != nil {
+				return nil, err
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf("no schema files match %q", src.glob)
+			}
+			for _, name := range names {
+				data, err := fs.ReadFile(src.fsys, name)
+				if err != nil {
+					return nil, err
+				}
+				sources = append(sources, schemaSource{name: name, sdl: string(data)})
+			}
+
+		default:
+			sources = append(sources, src)
+		}
+	}
+	return sources, nil
+}
+
+// sourceErrors prefixes the parse errors of a document with its name.
+func sourceErrors(name string, err error) language.ErrorList {
+	var list language.ErrorList
+	switch err := err.(type) {
+	case language.ErrorList:
+		list = err
+	case *language.Error:
+		list = language.ErrorList{err}
+	default:
+		list = language.ErrorList{language.NewError(nil, "%s", err.Error())}
+	}
+	if name == "" {
+		return list
+	}
+	prefixed := make(language.ErrorList, len(list))
+	for i, e := range list {
+		prefixed[i] = &language.Error{Message: name + ": " + e.Message, Locations: e.Locations}
+	}
+	return prefixed
+}
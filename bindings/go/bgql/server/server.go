@@ -2,39 +2,183 @@
 package server
 
 import (
+	"bytes"
+	"container/list"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
 	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server/metrics"
+	"github.com/ubugeeei/bgql/sdk"
 )
 
 // Config holds server configuration.
 type Config struct {
-	Port           int
-	Host           string
-	Introspection  bool
-	Playground     bool
-	PlaygroundPath string
-	MaxDepth       int
-	MaxComplexity  int
-	Timeout        time.Duration
+	Port          int
+	Host          string
+	Path          string
+	Introspection bool
+	// IntrospectionPolicy, when set, decides per request whether
+	// __schema and __type may be selected, instead of Introspection. For
+	// example, it may allow only authenticated admins. __typename is
+	// always allowed.
+	IntrospectionPolicy func(ctx *Context) bool
+	Playground          bool
+	PlaygroundPath      string
+	// PlaygroundAssets selects where the playground loads GraphiQL from.
+	// The zero value serves the assets built into the server.
+	PlaygroundAssets PlaygroundAssets
+	// PlaygroundCDN loads the playground from the jsdelivr CDN instead of
+	// the built-in assets, overriding PlaygroundAssets.
+	PlaygroundCDN bool
+	// PlaygroundConfig sets the playground's title, endpoints, theme, and
+	// default query and headers.
+	PlaygroundConfig PlaygroundConfig
+	// ResolverCoverage sets whether Build logs or fails on schema fields
+	// that will likely resolve to null. See Builder.Validate.
+	ResolverCoverage CoverageMode
+	// MaxDepth is the deepest selection nesting a query may have. Zero
+	// means no limit.
+	MaxDepth int
+	// DepthIgnoresIntrospection excludes introspection fields from the
+	// depth count, so tooling such as GraphiQL can still fetch the schema.
+	DepthIgnoresIntrospection bool
+	MaxComplexity             int
+	// MaxBatch is how many requests of a batched (JSON array) request run
+	// concurrently. Zero or one runs them one at a time.
+	MaxBatch int
+	// SubscriptionPath is where WebSocket connections are accepted, in
+	// addition to Path.
+	SubscriptionPath string
+	// ConnectionInitTimeout is how long a WebSocket client has to send
+	// connection_init before the connection is closed with code 4408.
+	// Zero waits indefinitely.
+	ConnectionInitTimeout time.Duration
+	// MaxBodySize is the largest JSON or application/graphql request
+	// body, in bytes. Zero means no limit. Multipart uploads are limited
+	// by MaxUploadSize instead.
+	MaxBodySize int64
+	// MaxUploadSize is the largest multipart upload request body, in
+	// bytes. Zero means no limit.
+	MaxUploadSize int64
+	// ResponseCacheHeaders are the request headers, such as
+	// Accept-Language, whose values are part of response cache keys.
+	ResponseCacheHeaders []string
+	// RejectUnknownOperations refuses operations that are not registered
+	// with Builder.AllowedOperations.
+	RejectUnknownOperations bool
+	// AllowUnknownIntrospection exempts operations selecting only
+	// introspection fields from RejectUnknownOperations.
+	AllowUnknownIntrospection bool
+	// CORS configures cross-origin requests.
+	CORS CORSConfig
+	// CSRFPrevention rejects requests a simple HTML form could send. See
+	// checkCSRF.
+	CSRFPrevention bool
+	// HealthPath and ReadyPath serve liveness and readiness probes. An
+	// empty path disables the endpoint.
+	HealthPath string
+	ReadyPath  string
+	// ReadyCheck reports whether the server is ready to receive traffic,
+	// for example by pinging its database.
+	ReadyCheck func(ctx context.Context) error
+	// MetricsPath is where the metrics collector is served, when it is an
+	// http.Handler. Empty disables the endpoint.
+	MetricsPath string
+	// TLS, when set, makes Listen serve HTTPS with this configuration,
+	// whose certificates may come from memory or an autocert manager.
+	TLS *tls.Config
+	// MaxConcurrentResolvers is how many of an operation's resolvers may
+	// run at once, resolving sibling fields and list items concurrently.
+	// Resolvers waiting for a DataLoader batch do not count, so the keys
+	// of a whole list are fetched together. The root fields of a mutation
+	// always execute serially, in document order. Zero resolves every
+	// field serially.
+	MaxConcurrentResolvers int
+	// ExecutionTimeout bounds the execution of a query or mutation. Its
+	// resolvers' context is cancelled when it passes, and fields that did
+	// not finish are null with a GRAPHQL_TIMEOUT error. Unlike the HTTP
+	// timeouts, it does not affect the connection. Zero disables it.
+	ExecutionTimeout time.Duration
+	// PanicHandler receives panics recovered from resolvers, with their
+	// stack trace. The field is reported to the client as an internal
+	// error without the panic value. By default panics are logged.
+	PanicHandler func(ctx *Context, recovered any, stack []byte)
+	// DrainTimeout is how long Stop waits for in-flight executions
+	// before cancelling their contexts.
+	DrainTimeout time.Duration
+	// KeepAlive is how often streaming connections are kept alive:
+	// WebSocket clients are pinged and event streams receive a comment.
+	// Zero disables keep-alives.
+	KeepAlive time.Duration
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout, and IdleTimeout are
+	// the timeouts of the http.Server that Listen serves. Event streams
+	// and WebSocket connections are exempt from the write and read
+	// deadlines once they start. Zero means no timeout.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// Timeout sets those of ReadTimeout, ReadHeaderTimeout, WriteTimeout,
+	// and IdleTimeout that are zero.
+	//
+	// Deprecated: Set the timeouts individually.
+	Timeout time.Duration
+	// OnWebSocketInit authenticates a WebSocket connection from its
+	// connection_init payload, after the Builder's OnWebSocketInit hook.
+	// The values it returns are merged into the Context.Data of each
+	// operation on the connection, and a CurrentUserID string and
+	// UserRoles list also set the sdk context keys. An error closes the
+	// connection with code 4401 if it is ErrUnauthenticated, or else 4403.
+	OnWebSocketInit func(ctx context.Context, payload map[string]any) (map[string]any, error)
+	// OnWebSocketPing revalidates an acknowledged WebSocket connection
+	// when the client pings, from the ping payload. ctx holds the
+	// connection's current values. Like OnWebSocketInit, it returns the
+	// values of later operations, replacing those it or OnWebSocketInit
+	// returned before, or an error that closes the connection.
+	OnWebSocketPing func(ctx context.Context, payload map[string]any) (map[string]any, error)
 }
 
 // DefaultConfig returns default server configuration.
 func DefaultConfig() Config {
 	return Config{
-		Port:           4000,
-		Host:           "localhost",
-		Introspection:  true,
-		Playground:     true,
-		PlaygroundPath: "/playground",
-		MaxDepth:       10,
-		MaxComplexity:  1000,
-		Timeout:        30 * time.Second,
+		Port:                   4000,
+		Host:                   "localhost",
+		Path:                   "/graphql",
+		Introspection:          true,
+		Playground:             true,
+		PlaygroundPath:         "/playground",
+		ResolverCoverage:       CoverageWarn,
+		MaxDepth:               10,
+		MaxComplexity:          1000,
+		MaxConcurrentResolvers: 16,
+		ConnectionInitTimeout:  10 * time.Second,
+		MaxBodySize:            4 << 20,
+		MaxUploadSize:          32 << 20,
+		CSRFPrevention:         true,
+		HealthPath:             "/healthz",
+		ReadyPath:              "/readyz",
+		DrainTimeout:           10 * time.Second,
+		ReadTimeout:            30 * time.Second,
+		ReadHeaderTimeout:      10 * time.Second,
+		WriteTimeout:           30 * time.Second,
+		IdleTimeout:            2 * time.Minute,
 	}
 }
 
@@ -43,12 +187,14 @@ type Request struct {
 	Query         string         `json:"query"`
 	Variables     map[string]any `json:"variables,omitempty"`
 	OperationName string         `json:"operationName,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
 }
 
 // Response represents a GraphQL response.
 type Response struct {
-	Data   any            `json:"data,omitempty"`
-	Errors []GraphQLError `json:"errors,omitempty"`
+	Data       any            `json:"data,omitempty"`
+	Errors     []GraphQLError `json:"errors,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
 }
 
 // GraphQLError represents a GraphQL error.
@@ -57,6 +203,13 @@ type GraphQLError struct {
 	Path       []any          `json:"path,omitempty"`
 	Locations  []Location     `json:"locations,omitempty"`
 	Extensions map[string]any `json:"extensions,omitempty"`
+
+	// cause is the error a resolver returned, before it was converted.
+	cause error
+}
+
+func (e GraphQLError) Error() string {
+	return e.Message
 }
 
 // Location represents a location in a GraphQL document.
@@ -65,38 +218,145 @@ type Location struct {
 	Column int `json:"column"`
 }
 
+// Response headers set by the server.
+const (
+	QueryCostHeader          = "X-BGQL-Query-Cost"
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
 // Context holds request-scoped data.
 type Context struct {
 	context.Context
 	Request *http.Request
+	// GraphQL is the GraphQL request being executed.
+	GraphQL *Request
 	Loaders *LoaderStore
-	Data    map[string]any
+	// Data holds the values stored with Set. Fields may resolve
+	// concurrently, so resolvers should use Set and Get instead.
+	Data map[string]any
+
+	dataMu         *sync.RWMutex
+	responseHeader http.Header
+	state          *requestState
+	timings        *resolverTimings
+	errors         []error
+	// costChecks run with the cost of the operation before it executes,
+	// and reject it by returning an error.
+	costChecks []func(cost int) *GraphQLError
+	// argsValidator is the server's Builder.ArgsValidator, for DecodeArgs.
+	argsValidator Validator
 }
 
-// NewContext creates a new context.
+// NewContext creates a new context. The headers of req are stored under
+// sdk.RequestHeaders.
 func NewContext(ctx context.Context, req *http.Request) *Context {
+	if req != nil {
+		ctx = sdk.RequestHeaders.Set(ctx, req.Header)
+	}
 	return &Context{
-		Context: ctx,
-		Request: req,
-		Loaders: NewLoaderStore(),
-		Data:    make(map[string]any),
+		Context:        ctx,
+		Request:        req,
+		Loaders:        NewLoaderStore(),
+		Data:           make(map[string]any),
+		dataMu:         new(sync.RWMutex),
+		responseHeader: make(http.Header),
+		state:          new(requestState),
+	}
+}
+
+// requestState is the state of a request that executing it records, shared
+// by the copies of its Context.
+type requestState struct {
+	operation   *language.OperationDefinition
+	complexity  int
+	costed      bool
+	cachePolicy CachePolicy
+
+	mu         sync.Mutex
+	extensions map[string]any
+}
+
+// WithContext returns a shallow copy of c that carries ctx, for example a
+// context holding a tracing span. Request-scoped data is shared.
+func (c *Context) WithContext(ctx context.Context) *Context {
+	// Allocate the shared state first, so the copy writes to the same one.
+	c.ResponseHeader()
+	c.shared()
+	copied := *c
+	copied.Context = ctx
+	return &copied
+}
+
+// Operation returns the operation being executed, once it has been
+// selected from the document, or nil.
+func (c *Context) Operation() *language.OperationDefinition {
+	if c.state == nil {
+		return nil
+	}
+	return c.state.operation
+}
+
+// shared returns the state of the request, allocating it for a Context
+// not created with NewContext.
+func (c *Context) shared() *requestState {
+	if c.state == nil {
+		c.state = new(requestState)
+	}
+	return c.state
+}
+
+// ResponseHeader returns the headers that will be written with the HTTP
+// response. Middleware and resolvers may add to it before the body is sent.
+func (c *Context) ResponseHeader() http.Header {
+	if c.responseHeader == nil {
+		c.responseHeader = make(http.Header)
 	}
+	return c.responseHeader
 }
 
-// Set stores a value in the context.
+// init allocates the state of c that is otherwise created lazily, before
+// resolvers may use it concurrently.
+func (c *Context) init() {
+	if c.Data == nil {
+		c.Data = make(map[string]any)
+	}
+	if c.dataMu == nil {
+		c.dataMu = new(sync.RWMutex)
+	}
+	if c.Loaders == nil {
+		c.Loaders = NewLoaderStore()
+	}
+	c.ResponseHeader()
+	c.shared()
+}
+
+// Set stores a value in the context. It is safe for concurrent use.
 func (c *Context) Set(key string, value any) {
+	if c.dataMu != nil {
+		c.dataMu.Lock()
+		defer c.dataMu.Unlock()
+	}
+	if c.Data == nil {
+		c.Data = make(map[string]any)
+	}
 	c.Data[key] = value
 }
 
-// Get retrieves a value from the context.
+// Get retrieves a value from the context. It is safe for concurrent use.
 func (c *Context) Get(key string) (any, bool) {
+	if c.dataMu != nil {
+		c.dataMu.RLock()
+		defer c.dataMu.RUnlock()
+	}
 	v, ok := c.Data[key]
 	return v, ok
 }
 
 // GetString retrieves a string value from the context.
 func (c *Context) GetString(key string) string {
-	if v, ok := c.Data[key]; ok {
+	if v, ok := c.Get(key); ok {
 		if s, ok := v.(string); ok {
 			return s
 		}
@@ -104,33 +364,160 @@ func (c *Context) GetString(key string) string {
 	return ""
 }
 
+// ClientInfo identifies the application that sent a request.
+type ClientInfo struct {
+	Name    string
+	Version string
+}
+
+// ClientInfo returns the client identification sent via the
+// apollographql-client-name/-version headers, if any.
+func (c *Context) ClientInfo() ClientInfo {
+	if c.Request == nil {
+		return ClientInfo{}
+	}
+	return ClientInfo{
+		Name:    c.Request.Header.Get("apollographql-client-name"),
+		Version: c.Request.Header.Get("apollographql-client-version"),
+	}
+}
+
+// String formats the client as name/version, or "" when unidentified.
+func (ci ClientInfo) String() string {
+	switch {
+	case ci.Name == "":
+		return ""
+	case ci.Version == "":
+		return ci.Name
+	default:
+		return ci.Name + "/" + ci.Version
+	}
+}
+
 // Server is the GraphQL server.
 type Server struct {
-	config      Config
-	schema      string
-	resolvers   map[string]map[string]ResolverFn
-	middlewares []Middleware
-	httpServer  *http.Server
+	config     Config
+	schema     *schema.Schema
+	resolvers  map[string]map[string]ResolverFn
+	costs      map[string]map[string]FieldCostFn
+	enums      map[string]map[string]any
+	scalars    map[string]ScalarConfig
+	types      map[string]TypeResolverFn
+	httpServer *http.Server
+
+	subscriptions   map[string]SubscriptionFn
+	onConnect       ConnectFn
+	onWebSocketInit WebSocketInitFn
+	persisted       PersistedQueryStore
+
+	allowed        map[string]string
+	allowedQueries map[string]bool
+	metrics        metrics.Collector
+	errorPresenter ErrorPresenter
+	playgroundHTML func(PlaygroundConfig) string
+	responseCache  ResponseCache
+	cacheUserID    func(*Context) string
+	loaders        map[string]LoaderFactory
+	argsValidator  Validator
+
+	lifecycle lifecycle
+
+	// registered keeps what the Builder registered, for UpdateSchema.
+	registered     Builder
+	reloadMu       sync.Mutex
+	onSchemaChange func(old, new string)
+
+	// mu guards the middleware chains, and the schema, resolvers, and
+	// scalars, which UpdateSchema replaces.
+	mu               sync.RWMutex
+	middlewares      []Middleware
+	fieldMiddlewares []FieldMiddleware
+	hooks            *extensionHooks
 }
 
-// ResolverFn is a resolver function type.
+// ResolverFn is a resolver function type. An argument or input field that
+// was omitted is missing from args, while an explicit null is present with
+// a nil value.
 type ResolverFn func(ctx *Context, parent any, args map[string]any) (any, error)
 
+// TypeResolverFn returns the name of the concrete object type of a value
+// resolved for an interface or union.
+type TypeResolverFn func(ctx *Context, value any) (string, error)
+
+// FieldCostFn computes the complexity of one field from its arguments and
+// the complexity of its selection set.
+type FieldCostFn func(args map[string]any, childCost int) int
+
 // Middleware is a server middleware function.
 type Middleware func(ctx *Context, next func(*Context) *Response) *Response
 
+// FieldInfo describes the field a resolver is called for.
+type FieldInfo struct {
+	ParentType string
+	Field      string
+	Path       []any
+	Parent     any
+	Args       map[string]any
+	// Default reports whether the field has no registered resolver and is
+	// read from the parent value.
+	Default bool
+}
+
+// FieldMiddleware wraps the resolution of each field outside
+// introspection, including fields without a registered resolver. It may
+// return without calling next to skip the resolver, or call next with a
+// Context derived through WithContext or with other parent and argument
+// values, which the inner middlewares see in their FieldInfo.
+type FieldMiddleware func(ctx *Context, info FieldInfo, next ResolverFn) (any, error)
+
 // Builder is a server builder.
 type Builder struct {
-	config    Config
-	schema    string
-	resolvers map[string]map[string]ResolverFn
+	config     Config
+	schemas    []schemaSource
+	resolvers  map[string]map[string]ResolverFn
+	costs      map[string]map[string]FieldCostFn
+	enums      map[string]map[string]any
+	scalars    map[string]ScalarConfig
+	types      map[string]TypeResolverFn
+	directives map[string]DirectiveFn
+
+	fieldMiddlewares []FieldMiddleware
+	extensions       []Extension
+
+	// results holds the Go result types of typed resolvers, for the
+	// coverage check.
+	results map[string]map[string]reflect.Type
+
+	subscriptions   map[string]SubscriptionFn
+	onConnect       ConnectFn
+	onWebSocketInit WebSocketInitFn
+	persisted       PersistedQueryStore
+	allowed         map[string]string
+	metrics         metrics.Collector
+
+	errorPresenter ErrorPresenter
+	playgroundHTML func(PlaygroundConfig) string
+	responseCache  ResponseCache
+	cacheUserID    func(*Context) string
+	loaders        map[string]LoaderFactory
+	argsValidator  Validator
 }
 
 // NewBuilder creates a new server builder.
 func NewBuilder() *Builder {
 	return &Builder{
-		config:    DefaultConfig(),
-		resolvers: make(map[string]map[string]ResolverFn),
+		config:     DefaultConfig(),
+		resolvers:  make(map[string]map[string]ResolverFn),
+		costs:      make(map[string]map[string]FieldCostFn),
+		enums:      make(map[string]map[string]any),
+		scalars:    make(map[string]ScalarConfig),
+		types:      make(map[string]TypeResolverFn),
+		directives: make(map[string]DirectiveFn),
+		results:    make(map[string]map[string]reflect.Type),
+
+		subscriptions: make(map[string]SubscriptionFn),
+		persisted:     NewLRUPersistedQueryStore(defaultPersistedQueries),
+		allowed:       make(map[string]string),
 	}
 }
 
@@ -146,12 +533,18 @@ func (b *Builder) Port(port int) *Builder {
 	return b
 }
 
-// Schema sets the schema from SDL.
-func (b *Builder) Schema(sdl string) *Builder {
-	b.schema = sdl
+// Path sets the GraphQL endpoint path.
+func (b *Builder) Path(path string) *Builder {
+	b.config.Path = path
 	return b
 }
 
+// Schema adds an SDL document to the schema. Documents added by Schema,
+// SchemaStrings, SchemaFile, and SchemaFS are merged.
+func (b *Builder) Schema(sdl string) *Builder {
+	return b.SchemaStrings(sdl)
+}
+
 // Resolver adds a resolver.
 func (b *Builder) Resolver(typeName, fieldName string, fn ResolverFn) *Builder {
 	if b.resolvers[typeName] == nil {
@@ -161,6 +554,40 @@ func (b *Builder) Resolver(typeName, fieldName string, fn ResolverFn) *Builder {
 	return b
 }
 
+// FieldCost overrides how the complexity of a field is computed. By
+// default a field costs 1 plus its selection set's cost times its
+// first/last/limit argument.
+func (b *Builder) FieldCost(typeName, fieldName string, fn FieldCostFn) *Builder {
+	if b.costs[typeName] == nil {
+		b.costs[typeName] = make(map[string]FieldCostFn)
+	}
+	b.costs[typeName][fieldName] = fn
+	return b
+}
+
+// FieldMiddleware adds a middleware run around the resolution of every
+// field, after those added before it. Middleware added to the built server
+// with UseField runs inside it.
+func (b *Builder) FieldMiddleware(middleware FieldMiddleware) *Builder {
+	b.fieldMiddlewares = append(b.fieldMiddlewares, middleware)
+	return b
+}
+
+// TypeResolver sets how the concrete type of values of an interface or
+// union is determined. Every abstract type a field returns needs one.
+func (b *Builder) TypeResolver(abstractName string, fn TypeResolverFn) *Builder {
+	b.types[abstractName] = fn
+	return b
+}
+
+// EnumValues maps the members of an enum to Go values. Resolvers receive
+// the mapped value for enum arguments, and may return it for enum fields.
+// Members without a mapping use their name.
+func (b *Builder) EnumValues(enumName string, values map[string]any) *Builder {
+	b.enums[enumName] = values
+	return b
+}
+
 // EnablePlayground enables the GraphQL playground.
 func (b *Builder) EnablePlayground(path string) *Builder {
 	b.config.Playground = true
@@ -170,6 +597,33 @@ func (b *Builder) EnablePlayground(path string) *Builder {
 	return b
 }
 
+// PlaygroundAssets sets where the playground loads its assets from.
+func (b *Builder) PlaygroundAssets(assets PlaygroundAssets) *Builder {
+	b.config.PlaygroundAssets = assets
+	return b
+}
+
+// PlaygroundConfig customizes the playground page.
+func (b *Builder) PlaygroundConfig(cfg PlaygroundConfig) *Builder {
+	b.config.PlaygroundConfig = cfg
+	return b
+}
+
+// PlaygroundHTML replaces the playground page with the HTML fn returns,
+// for serving a different IDE. fn receives the playground configuration
+// with its defaults filled in. No Content-Security-Policy is set.
+func (b *Builder) PlaygroundHTML(fn func(cfg PlaygroundConfig) string) *Builder {
+	b.playgroundHTML = fn
+	return b
+}
+
+// IntrospectionPolicy sets a function deciding per request whether
+// introspection is allowed, independently of the playground.
+func (b *Builder) IntrospectionPolicy(policy func(ctx *Context) bool) *Builder {
+	b.config.IntrospectionPolicy = policy
+	return b
+}
+
 // DisablePlayground disables the GraphQL playground.
 func (b *Builder) DisablePlayground() *Builder {
 	b.config.Playground = false
@@ -178,95 +632,505 @@ func (b *Builder) DisablePlayground() *Builder {
 
 // Build creates the server.
 func (b *Builder) Build() result.Result[*Server] {
-	if b.schema == "" {
+	if len(b.schemas) == 0 {
 		return result.ErrMsg[*Server]("schema is required")
 	}
 
-	return result.Ok(&Server{
+	parsed, err := b.buildSchema()
+	if err != nil {
+		return result.Err[*Server](err)
+	}
+	errs := b.checkSchema(parsed)
+	if len(errs) == 0 {
+		errs = b.checkCoverage(parsed)
+	}
+	errs = append(errs, checkPlaygroundConfig(b.config.PlaygroundConfig)...)
+	if len(errs) > 0 {
+		return result.Err[*Server](errs)
+	}
+
+	s := &Server{
 		config:    b.config,
-		schema:    b.schema,
+		schema:    parsed,
 		resolvers: b.resolvers,
-	})
+		costs:     b.costs,
+		enums:     b.enums,
+		scalars:   b.schemaScalars(parsed),
+		types:     b.types,
+
+		subscriptions:   b.subscriptions,
+		onConnect:       b.onConnect,
+		onWebSocketInit: b.onWebSocketInit,
+		persisted:       b.persisted,
+
+		allowed:        b.allowed,
+		allowedQueries: make(map[string]bool, len(b.allowed)),
+		metrics:        b.metrics,
+		errorPresenter: b.errorPresenter,
+		playgroundHTML: b.playgroundHTML,
+		responseCache:  b.responseCache,
+		cacheUserID:    b.cacheUserID,
+		loaders:        maps.Clone(b.loaders),
+		argsValidator:  b.argsValidator,
+
+		fieldMiddlewares: slices.Clone(b.fieldMiddlewares),
+		registered:       *b,
+	}
+	for _, doc := range b.allowed {
+		s.allowedQueries[doc] = true
+	}
+	s.hooks = s.hooks.with(complexityExtension{limit: b.config.MaxComplexity})
+	if b.metrics != nil {
+		s.hooks = s.hooks.with(metricsExtension{collector: b.metrics})
+	}
+	for _, ext := range b.extensions {
+		s.hooks = s.hooks.with(ext)
+	}
+	resolvers, errs := s.applyDirectives(parsed, s.scalars, b.resolvers, b.directives)
+	if len(errs) > 0 {
+		return result.Err[*Server](errs)
+	}
+	s.resolvers = resolvers
+	return result.Ok(s)
 }
 
-// Use adds middleware to the server.
+// checkSchema reports registrations of b that the schema does not define.
+func (b *Builder) checkSchema(parsed *schema.Schema) language.ErrorList {
+	errs := checkFieldRefs(parsed, "resolver", b.resolvers)
+	errs = append(errs, checkFieldRefs(parsed, "field cost", b.costs)...)
+	errs = append(errs, checkEnumValues(parsed, b.enums)...)
+	for _, name := range slices.Sorted(maps.Keys(b.scalars)) {
+		if t := parsed.Type(name); t == nil || t.Kind != schema.Scalar {
+			errs = append(errs, language.NewError(nil, "scalar registered for unknown scalar %s", name))
+		}
+	}
+	errs = append(errs, checkTypeResolvers(parsed, b.types)...)
+	errs = append(errs, checkDirectives(parsed, b.directives)...)
+	errs = append(errs, checkSubscriptions(parsed, b.subscriptions)...)
+	return errs
+}
+
+// schemaScalars returns the registered scalars along with the built-in
+// scalars the schema declares.
+func (b *Builder) schemaScalars(parsed *schema.Schema) map[string]ScalarConfig {
+	scalars := maps.Clone(b.scalars)
+	for name, config := range builtinScalars {
+		if t := parsed.Type(name); t != nil && t.Kind == schema.Scalar {
+			if _, ok := scalars[name]; !ok {
+				scalars[name] = config
+			}
+		}
+	}
+	return scalars
+}
+
+// checkFieldRefs reports entries of refs, registered per type and field
+// as what, that name types or fields the schema does not define. Errors
+// are in a stable order.
+func checkFieldRefs[V any](s *schema.Schema, what string, refs map[string]map[string]V) language.ErrorList {
+	var errs language.ErrorList
+	for _, typeName := range slices.Sorted(maps.Keys(refs)) {
+		t := s.Type(typeName)
+		if t == nil {
+			errs = append(errs, language.NewError(nil, "%s registered for unknown type %s", what, typeName))
+			continue
+		}
+		for _, fieldName := range slices.Sorted(maps.Keys(refs[typeName])) {
+			if t.Field(fieldName) == nil {
+				errs = append(errs, language.NewError(nil, "%s registered for unknown field %s.%s", what, typeName, fieldName))
+			}
+		}
+	}
+	return errs
+}
+
+// checkTypeResolvers reports type resolvers for types that are not
+// abstract, and lists the abstract types returned by some field that have
+// no type resolver.
+func checkTypeResolvers(s *schema.Schema, resolvers map[string]TypeResolverFn) language.ErrorList {
+	var errs language.ErrorList
+	for _, name := range slices.Sorted(maps.Keys(resolvers)) {
+		if t := s.Type(name); t == nil || !t.IsAbstract() {
+			errs = append(errs, language.NewError(nil, "type resolver registered for unknown interface or union %s", name))
+		}
+	}
+
+	var missing []string
+	for _, t := range s.Types() {
+		if t.BuiltIn || !t.IsAbstract() || resolvers[t.Name] != nil || !isReturned(s, t) {
+			continue
+		}
+		missing = append(missing, t.Name)
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		errs = append(errs, language.NewError(nil, "abstract types returned by fields need a TypeResolver: %s", strings.Join(missing, ", ")))
+	}
+	return errs
+}
+
+// isReturned reports whether some field of a user-defined type returns t.
+func isReturned(s *schema.Schema, t *schema.Type) bool {
+	for _, parent := range s.Types() {
+		if parent.BuiltIn {
+			continue
+		}
+		for _, f := range parent.Fields {
+			if f.Type.Name() == t.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkEnumValues reports enum value mappings for types that are not
+// enums or members they do not define.
+func checkEnumValues(s *schema.Schema, enums map[string]map[string]any) language.ErrorList {
+	var errs language.ErrorList
+	for _, enumName := range slices.Sorted(maps.Keys(enums)) {
+		t := s.Type(enumName)
+		if t == nil || t.Kind != schema.Enum {
+			errs = append(errs, language.NewError(nil, "enum values registered for unknown enum %s", enumName))
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(enums[enumName])) {
+			if t.EnumValue(name) == nil {
+				errs = append(errs, language.NewError(nil, "enum value registered for unknown member %s.%s", enumName, name))
+			}
+		}
+	}
+	return errs
+}
+
+// Schema returns the executable schema built from the SDL.
+func (s *Server) Schema() *schema.Schema {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schema
+}
+
+// SDL returns the schema printed as SDL, including descriptions and
+// applied directives.
+func (s *Server) SDL() string {
+	return schema.Print(s.Schema())
+}
+
+// Use adds middleware to the server. It is safe to call while the server
+// is handling requests; requests already executing keep the chain they
+// started with.
 func (s *Server) Use(middleware Middleware) *Server {
-	s.middlewares = append(s.middlewares, middleware)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Copy on write so snapshots taken by execute are never mutated.
+	s.middlewares = append(s.middlewares[:len(s.middlewares):len(s.middlewares)], middleware)
 	return s
 }
 
-// Listen starts the server.
+// chain returns a snapshot of the middleware chain.
+func (s *Server) chain() []Middleware {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.middlewares
+}
+
+// UseField adds a field middleware, run around every resolver call after
+// those added before it.
+func (s *Server) UseField(middleware FieldMiddleware) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fieldMiddlewares = append(s.fieldMiddlewares[:len(s.fieldMiddlewares):len(s.fieldMiddlewares)], middleware)
+	return s
+}
+
+func (s *Server) fieldChain() []FieldMiddleware {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fieldMiddlewares
+}
+
+// Listen starts the server. It serves HTTPS when Config.TLS is set.
 func (s *Server) Listen() error {
-	mux := http.NewServeMux()
+	srv, err := s.newHTTPServer()
+	if err != nil {
+		return err
+	}
+	if s.config.TLS != nil {
+		s.logStart(srv, "https")
+		return srv.ListenAndServeTLS("", "")
+	}
+	s.logStart(srv, "http")
+	return srv.ListenAndServe()
+}
 
-	// GraphQL endpoint
-	mux.HandleFunc("/graphql", s.handleGraphQL)
+// ListenTLS starts the server over HTTPS with the certificate and key in
+// the given PEM files. HTTP/2 is negotiated automatically.
+func (s *Server) ListenTLS(certFile, keyFile string) error {
+	srv, err := s.newHTTPServer()
+	if err != nil {
+		return err
+	}
+	s.logStart(srv, "https")
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
 
-	// Playground endpoint (if enabled)
+func (s *Server) logStart(srv *http.Server, scheme string) {
+	fmt.Printf("[bgql] Server starting on %s://%s\n", scheme, srv.Addr)
 	if s.config.Playground {
-		mux.HandleFunc(s.config.PlaygroundPath, s.handlePlayground)
+		fmt.Printf("[bgql] Playground available at %s://%s%s\n", scheme, srv.Addr, s.config.PlaygroundPath)
 	}
+}
 
-	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+// Handler returns the routes Listen serves: the GraphQL endpoint at
+// Config.Path and, when enabled, the playground, health, and metrics
+// endpoints. To mount it in an existing router under a prefix, wrap it in
+// http.StripPrefix; the playground adjusts its URLs to the prefix.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
 
-	s.httpServer = &http.Server{
-		Addr:         addr,
-		Handler:      mux,
-		ReadTimeout:  s.config.Timeout,
-		WriteTimeout: s.config.Timeout,
+	// GraphQL endpoint
+	mux.HandleFunc(s.path(), s.handleGraphQL)
+	if path := s.config.SubscriptionPath; path != "" && path != s.path() {
+		mux.HandleFunc(path, s.handleWebSocket)
 	}
 
-	fmt.Printf("[bgql] Server starting on http://%s\n", addr)
+	// Playground endpoint (if enabled)
 	if s.config.Playground {
-		fmt.Printf("[bgql] Playground available at http://%s%s\n", addr, s.config.PlaygroundPath)
+		s.mountPlayground(mux)
 	}
 
-	return s.httpServer.ListenAndServe()
+	// Health and metrics endpoints
+	s.mountHealth(mux)
+	s.mountMetrics(mux)
+
+	// Unknown paths get a JSON 404, unless an endpoint is served at the root.
+	if s.path() != "/" && !(s.config.Playground && s.config.PlaygroundPath == "/") {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			writeRequestError(w, http.StatusNotFound, GraphQLError{Message: fmt.Sprintf("Not found: %s", r.URL.Path)})
+		})
+	}
+	return mux
 }
 
-// Stop stops the server.
-func (s *Server) Stop(ctx context.Context) error {
-	if s.httpServer != nil {
-		return s.httpServer.Shutdown(ctx)
+// path returns the GraphQL endpoint path.
+func (s *Server) path() string {
+	if s.config.Path == "" {
+		return "/graphql"
 	}
-	return nil
+	return s.config.Path
+}
+
+// ServeHTTP serves the GraphQL endpoint alone, at whatever path the
+// server is mounted.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handleGraphQL(w, r)
 }
 
 func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	ctx, end, ok := s.begin(r.Context())
+	if !ok {
+		writeRequestError(w, http.StatusServiceUnavailable, GraphQLError{
+			Message:    "Server is shutting down.",
+			Extensions: map[string]any{"code": "SERVICE_UNAVAILABLE"},
+		})
+		return
+	}
+	defer end()
+	r = r.WithContext(ctx)
+
+	if isWebSocketUpgrade(r) {
+		s.handleWebSocket(w, r)
+		return
+	}
+	if s.handleCORS(w, r) || !s.checkCSRF(w, r) {
+		return
+	}
+	if acceptsEventStream(r) {
+		s.handleSSE(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	reqs, batch, status, err := s.decodeRequest(w, r)
+	if err != nil {
+		writeRequestError(w, status, err)
 		return
 	}
-
-	ctx := NewContext(r.Context(), r)
-
-	// Execute query
-	resp := s.execute(ctx, &req)
+	defer cleanupUploads(r, reqs)
+
+	// Execute each request with its own context, so middleware sees every
+	// operation of a batch individually.
+	ctxs := make([]*Context, len(reqs))
+	resps := make([]*Response, len(reqs))
+	for i := range reqs {
+		ctxs[i] = NewContext(r.Context(), r)
+	}
+	s.executeBatch(ctxs, reqs, resps)
 
 	// Write response
+	for _, ctx := range ctxs {
+		for key, values := range ctx.ResponseHeader() {
+			w.Header()[key] = values
+		}
+	}
+	if value := cacheControlHeader(ctxs, resps); value != "" {
+		w.Header().Set("Cache-Control", value)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	if batch {
+		json.NewEncoder(w).Encode(resps)
+		return
+	}
+	json.NewEncoder(w).Encode(resps[0])
+}
+
+// executeBatch executes reqs into resps, running at most Config.MaxBatch
+// requests at once.
+func (s *Server) executeBatch(ctxs []*Context, reqs []*Request, resps []*Response) {
+	limit := s.config.MaxBatch
+	if limit <= 1 || len(reqs) == 1 {
+		for i, req := range reqs {
+			resps[i] = s.execute(ctxs[i], req)
+		}
+		return
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resps[i] = s.execute(ctxs[i], req)
+		}()
+	}
+	wg.Wait()
+}
+
+// decodeRequest reads GraphQL requests from a JSON envelope or array of
+// envelopes, from an application/graphql body holding just the query, or
+// from a multipart upload request. batch reports whether the body was an
+// array. A missing Content-Type is treated as JSON.
+func (s *Server) decodeRequest(w http.ResponseWriter, r *http.Request) (reqs []*Request, batch bool, status int, err error) {
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return nil, false, http.StatusUnsupportedMediaType, GraphQLError{Message: fmt.Sprintf("Invalid Content-Type %q.", ct)}
+		}
+	}
+
+	switch mediaType {
+	case "application/json":
+		body, status, err := s.readBody(w, r)
+		if err != nil {
+			return nil, false, status, err
+		}
+		return decodeJSONRequest(body)
+	case "application/graphql":
+		body, status, err := s.readBody(w, r)
+		if err != nil {
+			return nil, false, status, err
+		}
+		return []*Request{{Query: string(body)}}, false, http.StatusOK, nil
+	case "multipart/form-data":
+		return s.decodeMultipartRequest(w, r)
+	}
+	return nil, false, http.StatusUnsupportedMediaType, GraphQLError{
+		Message: fmt.Sprintf("Unsupported Content-Type %q: use application/json, application/graphql, or multipart/form-data.", mediaType),
+	}
+}
+
+// readBody reads the request body, up to Config.MaxBodySize.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, int, error) {
+	limit := s.config.MaxBodySize
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, http.StatusRequestEntityTooLarge, GraphQLError{
+				Message:    fmt.Sprintf("Request body exceeds the maximum size of %d bytes.", limit),
+				Extensions: map[string]any{"code": "REQUEST_TOO_LARGE", "maxBodySize": limit},
+			}
+		}
+		return nil, http.StatusBadRequest, errors.New("Invalid request body")
+	}
+	return body, http.StatusOK, nil
 }
 
-func (s *Server) handlePlayground(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(playgroundHTML))
+// decodeJSONRequest decodes a JSON envelope or array of envelopes.
+func decodeJSONRequest(body []byte) (reqs []*Request, batch bool, status int, err error) {
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, true, http.StatusBadRequest, errors.New("Invalid request body")
+		}
+		if len(reqs) == 0 {
+			return nil, true, http.StatusBadRequest, errors.New("Empty batch")
+		}
+		for _, req := range reqs {
+			if req == nil {
+				return nil, true, http.StatusBadRequest, errors.New("Invalid request body")
+			}
+		}
+		return reqs, true, http.StatusOK, nil
+	}
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, false, http.StatusBadRequest, errors.New("Invalid request body")
+	}
+	return []*Request{&req}, false, http.StatusOK, nil
+}
+
+// writeRequestError rejects a request that could not be decoded. A
+// GraphQLError is sent as a GraphQL response body.
+func writeRequestError(w http.ResponseWriter, status int, err error) {
+	var gqlErr GraphQLError
+	if !errors.As(err, &gqlErr) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&Response{Errors: []GraphQLError{gqlErr}})
 }
 
 func (s *Server) execute(ctx *Context, req *Request) *Response {
-	// Build middleware chain
-	handler := func(ctx *Context) *Response {
-		return s.doExecute(ctx, req)
+	hooks := s.extensionHooks()
+	ctx.GraphQL = req
+	// Each request gets its own loaders, emptied once it completes in case
+	// the Context outlives it.
+	ctx.Loaders = s.newLoaderStore()
+	defer ctx.Loaders.ClearAll()
+	ctxs := hooks.onRequest(ctx)
+	if len(ctxs) > 0 {
+		ctx = ctxs[len(ctxs)-1]
 	}
+	resp := s.withMiddleware(ctx, req, func(ctx *Context) *Response {
+		resp := s.doExecute(ctx, req)
+		s.presentErrors(ctx, resp)
+		return resp
+	})
+	hooks.onResponse(ctx, ctxs, resp)
+	return resp
+}
 
-	for i := len(s.middlewares) - 1; i >= 0; i-- {
-		middleware := s.middlewares[i]
+// withMiddleware runs handler for req inside the middleware chain.
+func (s *Server) withMiddleware(ctx *Context, req *Request, handler func(*Context) *Response) *Response {
+	ctx.GraphQL = req
+	ctx.argsValidator = s.argsValidator
+
+	middlewares := s.chain()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		middleware := middlewares[i]
 		next := handler
 		handler = func(ctx *Context) *Response {
 			return middleware(ctx, next)
@@ -276,109 +1140,309 @@ func (s *Server) execute(ctx *Context, req *Request) *Response {
 	return handler(ctx)
 }
 
-func (s *Server) doExecute(ctx *Context, req *Request) *Response {
-	// TODO: Implement actual GraphQL execution
-	// For now, return a placeholder response
-	return &Response{
-		Errors: []GraphQLError{
-			{Message: "Execution not yet implemented"},
-		},
-	}
-}
-
-// Playground HTML template
-const playgroundHTML = `<!DOCTYPE html>
-<html>
-<head>
-  <meta charset="utf-8">
-  <title>bgql Playground</title>
-  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/graphiql@3/graphiql.min.css" />
-  <style>
-    body { margin: 0; height: 100vh; }
-    #graphiql { height: 100vh; }
-  </style>
-</head>
-<body>
-  <div id="graphiql">Loading...</div>
-  <script crossorigin src="https://cdn.jsdelivr.net/npm/react@18/umd/react.production.min.js"></script>
-  <script crossorigin src="https://cdn.jsdelivr.net/npm/react-dom@18/umd/react-dom.production.min.js"></script>
-  <script crossorigin src="https://cdn.jsdelivr.net/npm/graphiql@3/graphiql.min.js"></script>
-  <script>
-    const root = ReactDOM.createRoot(document.getElementById('graphiql'));
-    root.render(
-      React.createElement(GraphiQL, {
-        fetcher: GraphiQL.createFetcher({ url: '/graphql' }),
-        defaultEditorToolsVisibility: true,
-      })
-    );
-  </script>
-</body>
-</html>`
-
 // =============================================================================
 // DataLoader
 // =============================================================================
 
-// DataLoader batches and caches data loading.
+// DataLoader batches and caches data loading. Keys loaded within a short
+// window, typically by the resolvers of a list's items, are fetched with a
+// single batchFn call.
+//
+// batchFn receives the values of the context of the first Load of the
+// batch. Its context is cancelled once every Load of the batch has given
+// up, in which case a batch that has not started is not fetched.
 type DataLoader[K comparable, V any] struct {
-	batchFn     func(keys []K) (map[K]V, error)
-	cache       map[K]V
-	batch       []K
-	batchChan   chan struct{}
-	mu          sync.Mutex
+	batchFn      func(ctx context.Context, keys []K) (map[K]V, error)
+	cache        *loaderCache[V]
+	mu           sync.Mutex
 	maxBatchSize int
+	wait         time.Duration
+	keyFn        func(key any) string
+
+	// batch is collecting keys, and pending maps the cache keys of batch
+	// and of the batches being fetched to their batch.
+	batch   *loaderBatch[K, V]
+	pending map[any]*loaderBatch[K, V]
 }
 
+// loaderBatch is one call of a DataLoader's batch function. done is closed
+// once results, by cache key, and err are set.
+type loaderBatch[K comparable, V any] struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	keys       []K
+	waiters    int
+	dispatched bool
+	// sched, if not nil, is the scheduler of the operation that started
+	// the batch, which dispatches it instead of the Wait timer.
+	sched   *scheduler
+	done    chan struct{}
+	results map[any]V
+	err     error
+}
+
+// DataLoaderConfig configures NewDataLoaderWithConfig.
+type DataLoaderConfig struct {
+	// Wait is how long keys are collected after the first before the batch
+	// is fetched, outside an operation. Within one, a batch is fetched once
+	// all the operation's resolvers wait. Defaults to DefaultLoaderWait.
+	Wait time.Duration
+	// MaxBatchSize fetches a batch early once it has this many keys.
+	// Defaults to 100.
+	MaxBatchSize int
+	// CacheTTL expires cached values this long after they are loaded or
+	// primed. Zero keeps them until cleared.
+	CacheTTL time.Duration
+	// MaxCacheSize evicts the least recently used values once the cache
+	// holds this many. Zero means no limit.
+	MaxCacheSize int
+	// CacheDisabled batches Loads without caching their values, so each
+	// batch fetches its keys anew. Concurrent Loads of a key still share
+	// one fetch.
+	CacheDisabled bool
+	// CacheKeyFn maps the loader's keys to the identity they are cached
+	// and fetched by, so that keys equal to the batch function, such as
+	// strings differing only in case, share a value. Defaults to the key
+	// itself.
+	CacheKeyFn func(key any) string
+}
+
+// DefaultLoaderWait is the default batching window of a DataLoader used
+// outside an operation.
+const DefaultLoaderWait = time.Millisecond
+
 // NewDataLoader creates a new DataLoader.
-func NewDataLoader[K comparable, V any](batchFn func(keys []K) (map[K]V, error)) *DataLoader[K, V] {
+func NewDataLoader[K comparable, V any](batchFn func(ctx context.Context, keys []K) (map[K]V, error)) *DataLoader[K, V] {
+	return NewDataLoaderWithConfig(batchFn, DataLoaderConfig{})
+}
+
+// NewDataLoaderWithConfig creates a DataLoader with custom batching and
+// caching.
+func NewDataLoaderWithConfig[K comparable, V any](batchFn func(ctx context.Context, keys []K) (map[K]V, error), config DataLoaderConfig) *DataLoader[K, V] {
+	if config.Wait <= 0 {
+		config.Wait = DefaultLoaderWait
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = 100
+	}
 	return &DataLoader[K, V]{
-		batchFn:     batchFn,
-		cache:       make(map[K]V),
-		maxBatchSize: 100,
+		batchFn:      batchFn,
+		cache:        newLoaderCache[V](config),
+		maxBatchSize: config.MaxBatchSize,
+		wait:         config.Wait,
+		keyFn:        config.CacheKeyFn,
+		pending:      make(map[any]*loaderBatch[K, V]),
 	}
 }
 
-// Load loads a single value by key.
+// cacheKey returns the identity of key in the cache and among pending
+// loads.
+func (dl *DataLoader[K, V]) cacheKey(key K) any {
+	if dl.keyFn != nil {
+		return dl.keyFn(key)
+	}
+	return key
+}
+
+// Load loads a single value by key. Concurrent Loads of a key share one
+// fetch, and an error from the batch function is returned to each.
 func (dl *DataLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
-	dl.mu.Lock()
+	return dl.LoadThunk(ctx, key)()
+}
+
+// LoadThunk adds key to the current batch and returns at once. Calling the
+// returned function waits for the batch and returns the value, so keys of
+// many fields can be queued before any is fetched.
+func (dl *DataLoader[K, V]) LoadThunk(ctx context.Context, key K) func() (V, error) {
+	thunk, _ := dl.loadThunk(ctx, key)
+	return thunk
+}
 
-	// Check cache
-	if v, ok := dl.cache[key]; ok {
+// loadThunk is LoadThunk, also returning the batch fetching key, or nil if
+// it is cached.
+func (dl *DataLoader[K, V]) loadThunk(ctx context.Context, key K) (func() (V, error), *loaderBatch[K, V]) {
+	ck := dl.cacheKey(key)
+	dl.mu.Lock()
+	if v, ok := dl.cache.get(ck); ok {
 		dl.mu.Unlock()
-		return v, nil
+		return func() (V, error) { return v, nil }, nil
 	}
-
+	b, full := dl.enqueue(ctx, key, ck)
 	dl.mu.Unlock()
+	if full {
+		go dl.dispatch(b)
+	}
 
-	// For simplicity, just call batch function directly
-	// In production, this would batch requests across the same tick
-	result, err := dl.batchFn([]K{key})
-	if err != nil {
+	thunk := sync.OnceValues(func() (V, error) {
 		var zero V
-		return zero, err
+		if err := dl.await(ctx, b); err != nil {
+			return zero, err
+		}
+		if b.err != nil {
+			return zero, b.err
+		}
+		if v, ok := b.results[ck]; ok {
+			return v, nil
+		}
+		return zero, fmt.Errorf("key not found: %v", key)
+	})
+	return thunk, b
+}
+
+// await waits until b is fetched or ctx is done. A resolver stops
+// counting as running meanwhile, so the resolvers of sibling fields and
+// list items run and load their keys into the same batch, which is
+// dispatched once they all wait.
+func (dl *DataLoader[K, V]) await(ctx context.Context, b *loaderBatch[K, V]) error {
+	select {
+	case <-b.done:
+		return nil
+	default:
+	}
+	if sched, ok := ctx.Value(schedulerKey{}).(*scheduler); ok {
+		if b.sched != sched {
+			sched.hold(func() { dl.dispatch(b) })
+		}
+		defer sched.resume(sched.pause())
 	}
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		dl.leave(b)
+		return ctx.Err()
+	}
+}
+
+// enqueue returns the batch fetching key, whose cache key is ck, adding it
+// to the collecting batch unless one already is, and whether that batch is
+// now full. The caller waits for the batch until it leaves. dl.mu must be
+// held.
+func (dl *DataLoader[K, V]) enqueue(ctx context.Context, key K, ck any) (*loaderBatch[K, V], bool) {
+	if b, ok := dl.pending[ck]; ok {
+		b.waiters++
+		return b, false
+	}
+	if dl.batch == nil {
+		batchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		b := &loaderBatch[K, V]{ctx: batchCtx, cancel: cancel, done: make(chan struct{})}
+		dl.batch = b
+		if sched, ok := ctx.Value(schedulerKey{}).(*scheduler); ok {
+			b.sched = sched
+			sched.hold(func() { dl.dispatch(b) })
+		} else {
+			time.AfterFunc(dl.wait, func() { dl.dispatch(b) })
+		}
+	}
+	b := dl.batch
+	b.waiters++
+	b.keys = append(b.keys, key)
+	dl.pending[ck] = b
+	if len(b.keys) < dl.maxBatchSize {
+		return b, false
+	}
+	dl.batch = nil
+	return b, true
+}
 
+// leave stops a caller waiting for a batch. Once no caller waits, the
+// batch is cancelled and its keys are fetched anew by later Loads.
+func (dl *DataLoader[K, V]) leave(b *loaderBatch[K, V]) {
 	dl.mu.Lock()
 	defer dl.mu.Unlock()
+	b.waiters--
+	if b.waiters > 0 {
+		return
+	}
+	b.cancel()
+	if dl.batch == b {
+		dl.batch = nil
+	}
+	dl.release(b)
+}
 
-	if v, ok := result[key]; ok {
-		dl.cache[key] = v
-		return v, nil
+// release removes the keys of b from pending. dl.mu must be held.
+func (dl *DataLoader[K, V]) release(b *loaderBatch[K, V]) {
+	for _, key := range b.keys {
+		ck := dl.cacheKey(key)
+		if dl.pending[ck] == b {
+			delete(dl.pending, ck)
+		}
 	}
+}
 
-	var zero V
-	return zero, fmt.Errorf("key not found: %v", key)
+// dispatch fetches a batch, unless it has been already or was cancelled.
+func (dl *DataLoader[K, V]) dispatch(b *loaderBatch[K, V]) {
+	dl.mu.Lock()
+	if b.dispatched {
+		dl.mu.Unlock()
+		return
+	}
+	b.dispatched = true
+	if dl.batch == b {
+		dl.batch = nil
+	}
+	dl.mu.Unlock()
+	defer b.cancel()
+
+	if err := b.ctx.Err(); err != nil {
+		b.err = err
+	} else {
+		func() {
+			defer func() {
+				// As with resolvers, the panic value is logged but not
+				// reported to the client.
+				if recovered := recover(); recovered != nil {
+					fmt.Fprintf(os.Stderr, "[bgql] panic in batch function: %v\n%s", recovered, debug.Stack())
+					b.err = GraphQLError{
+						Message:    "Internal server error.",
+						Extensions: map[string]any{"code": "INTERNAL_SERVER_ERROR"},
+						cause:      fmt.Errorf("batch function panicked: %v", recovered),
+					}
+				}
+			}()
+			var results map[K]V
+			results, b.err = dl.batchFn(b.ctx, b.keys)
+			b.results = make(map[any]V, len(results))
+			for key, v := range results {
+				b.results[dl.cacheKey(key)] = v
+			}
+		}()
+	}
+
+	dl.mu.Lock()
+	dl.release(b)
+	if b.err == nil {
+		for ck, v := range b.results {
+			dl.cache.set(ck, v)
+		}
+	}
+	dl.mu.Unlock()
+	close(b.done)
 }
 
-// LoadMany loads multiple values by keys.
+// LoadMany loads multiple values by keys. The keys that are not cached are
+// fetched at once, in batches of at most MaxBatchSize, without waiting for
+// the batching window. The values and errors are in the order of keys: the
+// error of each key is nil, or why its value is the zero value.
 func (dl *DataLoader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []error) {
+	thunks := make([]func() (V, error), len(keys))
+	batches := make(map[*loaderBatch[K, V]]bool)
+	for i, key := range keys {
+		var b *loaderBatch[K, V]
+		thunks[i], b = dl.loadThunk(ctx, key)
+		if b != nil {
+			batches[b] = true
+		}
+	}
+	for b := range batches {
+		go dl.dispatch(b)
+	}
+
 	values := make([]V, len(keys))
 	errors := make([]error, len(keys))
-
-	for i, key := range keys {
-		v, err := dl.Load(ctx, key)
-		values[i] = v
-		errors[i] = err
+	for i, thunk := range thunks {
+		values[i], errors[i] = thunk()
 	}
 
 	return values, errors
@@ -386,29 +1450,108 @@ func (dl *DataLoader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []erro
 
 // Clear clears a key from the cache.
 func (dl *DataLoader[K, V]) Clear(key K) {
+	ck := dl.cacheKey(key)
 	dl.mu.Lock()
 	defer dl.mu.Unlock()
-	delete(dl.cache, key)
+	dl.cache.delete(ck)
 }
 
 // ClearAll clears all keys from the cache.
 func (dl *DataLoader[K, V]) ClearAll() {
 	dl.mu.Lock()
 	defer dl.mu.Unlock()
-	dl.cache = make(map[K]V)
+	dl.cache.clear()
 }
 
-// Prime primes the cache with a value.
+// Prime primes the cache with a value. It is a no-op when the cache is
+// disabled.
 func (dl *DataLoader[K, V]) Prime(key K, value V) {
+	ck := dl.cacheKey(key)
 	dl.mu.Lock()
 	defer dl.mu.Unlock()
-	dl.cache[key] = value
+	dl.cache.set(ck, value)
+}
+
+// loaderCache holds the values of a DataLoader by cache key, evicting them
+// by age and recent use. It is guarded by the loader's mutex.
+type loaderCache[V any] struct {
+	ttl      time.Duration
+	capacity int
+	disabled bool
+	order    *list.List // most recently used first
+	entries  map[any]*list.Element
+}
+
+type loaderEntry[V any] struct {
+	key     any
+	value   V
+	expires time.Time
+}
+
+func newLoaderCache[V any](config DataLoaderConfig) *loaderCache[V] {
+	return &loaderCache[V]{
+		ttl:      config.CacheTTL,
+		capacity: config.MaxCacheSize,
+		disabled: config.CacheDisabled,
+		order:    list.New(),
+		entries:  make(map[any]*list.Element),
+	}
+}
+
+func (c *loaderCache[V]) get(key any) (V, bool) {
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*loaderEntry[V])
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *loaderCache[V]) set(key any, value V) {
+	if c.disabled {
+		return
+	}
+	entry := &loaderEntry[V]{key: key, value: value}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*loaderEntry[V]).key)
+	}
+}
+
+func (c *loaderCache[V]) delete(key any) {
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+func (c *loaderCache[V]) clear() {
+	c.order.Init()
+	c.entries = make(map[any]*list.Element)
 }
 
 // LoaderStore stores DataLoaders per request.
 type LoaderStore struct {
-	loaders map[string]any
-	mu      sync.RWMutex
+	loaders   map[string]any
+	factories map[string]LoaderFactory
+	mu        sync.RWMutex
 }
 
 // NewLoaderStore creates a new loader store.
@@ -418,102 +1561,73 @@ func NewLoaderStore() *LoaderStore {
 	}
 }
 
-// Get gets or creates a DataLoader.
-func GetLoader[K comparable, V any](store *LoaderStore, name string, batchFn func(keys []K) (map[K]V, error)) *DataLoader[K, V] {
+// GetLoader gets or creates the DataLoader named name. If a loader with
+// other key or value types has the name, a warning is logged and a
+// separate loader of these types is used under it.
+func GetLoader[K comparable, V any](store *LoaderStore, name string, batchFn func(ctx context.Context, keys []K) (map[K]V, error)) *DataLoader[K, V] {
+	typedName := typedLoaderName[K, V](name)
+
 	store.mu.RLock()
-	if loader, ok := store.loaders[name]; ok {
-		store.mu.RUnlock()
-		return loader.(*DataLoader[K, V])
+	loader, ok := store.loaders[name].(*DataLoader[K, V])
+	if !ok {
+		loader, ok = store.loaders[typedName].(*DataLoader[K, V])
 	}
 	store.mu.RUnlock()
+	if ok {
+		return loader
+	}
 
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
 	// Double check
-	if loader, ok := store.loaders[name]; ok {
-		return loader.(*DataLoader[K, V])
+	key := name
+	if existing, ok := store.loaders[name]; ok {
+		if loader, ok := existing.(*DataLoader[K, V]); ok {
+			return loader
+		}
+		key = typedName
+		if loader, ok := store.loaders[key].(*DataLoader[K, V]); ok {
+			return loader
+		}
+		fmt.Fprintf(os.Stderr, "[bgql] warning: loader %q is a %T, not a %T; using a separate loader\n", name, existing, (*DataLoader[K, V])(nil))
 	}
 
-	loader := NewDataLoader(batchFn)
-	store.loaders[name] = loader
+	loader = NewDataLoader(batchFn)
+	store.loaders[key] = loader
 	return loader
 }
 
-// ClearAll clears all loaders.
-func (s *LoaderStore) ClearAll() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.loaders = make(map[string]any)
+// typedLoaderName qualifies a loader name with the loader's types, for a
+// loader whose name is taken by one of other types.
+func typedLoaderName[K comparable, V any](name string) string {
+	return fmt.Sprintf("%s\x00%T", name, (*DataLoader[K, V])(nil))
 }
 
-// =============================================================================
-// Built-in Middleware
-// =============================================================================
+// Has reports whether a loader named name exists.
+func (s *LoaderStore) Has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.loaders[name]
+	return ok
+}
 
-// LoggingMiddleware logs requests.
-func LoggingMiddleware(logger func(format string, args ...any)) Middleware {
-	if logger == nil {
-		logger = func(format string, args ...any) {
-			fmt.Printf(format+"\n", args...)
+// Clear removes the loaders named name, so their cached values are
+// fetched anew, for example after a mutation changes them.
+func (s *LoaderStore) Clear(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.loaders, name)
+	for key := range s.loaders {
+		if strings.HasPrefix(key, name+"\x00") {
+			delete(s.loaders, key)
 		}
 	}
-
-	return func(ctx *Context, next func(*Context) *Response) *Response {
-		start := time.Now()
-		logger("[bgql] Request started: %s", ctx.Request.URL.Path)
-
-		resp := next(ctx)
-
-		duration := time.Since(start)
-		hasErrors := len(resp.Errors) > 0
-		logger("[bgql] Request completed in %v (hasErrors: %v)", duration, hasErrors)
-
-		return resp
-	}
 }
 
-// RateLimitMiddleware limits request rate.
-func RateLimitMiddleware(windowMs time.Duration, maxRequests int) Middleware {
-	var mu sync.Mutex
-	requests := make(map[string]struct {
-		count     int
-		resetTime time.Time
-	})
-
-	return func(ctx *Context, next func(*Context) *Response) *Response {
-		ip := ctx.Request.RemoteAddr
-
-		mu.Lock()
-		now := time.Now()
-		entry, ok := requests[ip]
-		if !ok || now.After(entry.resetTime) {
-			entry = struct {
-				count     int
-				resetTime time.Time
-			}{
-				count:     0,
-				resetTime: now.Add(windowMs),
-			}
-		}
-		entry.count++
-		requests[ip] = entry
-		mu.Unlock()
-
-		if entry.count > maxRequests {
-			return &Response{
-				Errors: []GraphQLError{
-					{
-						Message: "Rate limit exceeded",
-						Extensions: map[string]any{
-							"code":       "RATE_LIMITED",
-							"retryAfter": entry.resetTime.Sub(now).Milliseconds(),
-						},
-					},
-				},
-			}
-		}
-
-		return next(ctx)
-	}
+// ClearAll clears all loaders.
+func (s *LoaderStore) ClearAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaders = make(map[string]any)
 }
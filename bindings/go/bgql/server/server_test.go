@@ -0,0 +1,1520 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/result"
+)
+
+// postQueryRaw sends query to s over HTTP and returns the response data,
+// failing the test on errors.
+func postQueryRaw(t *testing.T, s *Server, query string) json.RawMessage {
+	t.Helper()
+	data, errs := postGraphQL(t, s, query)
+	if len(errs) > 0 {
+		t.Fatalf("errors: %v", errs)
+	}
+	return data
+}
+
+// postGraphQL sends query to s over HTTP and returns the response data and
+// errors.
+func postGraphQL(t *testing.T, s *Server, query string) (json.RawMessage, []GraphQLError) {
+	t.Helper()
+	return postRequest(t, s, Request{Query: query})
+}
+
+// postRequest sends r to s over HTTP and returns the response data and
+// errors.
+func postRequest(t *testing.T, s *Server, r Request) (json.RawMessage, []GraphQLError) {
+	t.Helper()
+	body, _ := json.Marshal(r)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.handleGraphQL(rec, req)
+	var resp struct {
+		Data   json.RawMessage
+		Errors []GraphQLError
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("bad response %s: %v", rec.Body, err)
+	}
+	return resp.Data, resp.Errors
+}
+
+// httpTest is a request sent to a server's Handler and the response
+// expected of it.
+type httpTest struct {
+	name string
+	// method defaults to POST and path to /graphql. POST requests are
+	// sent as application/json unless header sets a Content-Type.
+	method string
+	path   string
+	header map[string]string
+	body   string
+	// wantStatus defaults to 200.
+	wantStatus int
+	// wantHeader maps response headers to their values; an empty value
+	// expects the header to be absent.
+	wantHeader map[string]string
+	// wantBody and notBody are substrings the response body must and
+	// must not contain.
+	wantBody []string
+	notBody  []string
+}
+
+// runHTTPTests sends each test's request to handler and checks the response.
+func runHTTPTests(t *testing.T, handler http.Handler, tests []httpTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path := tt.method, tt.path
+			if method == "" {
+				method = http.MethodPost
+			}
+			if path == "" {
+				path = "/graphql"
+			}
+			req := httptest.NewRequest(method, path, strings.NewReader(tt.body))
+			if method == http.MethodPost {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if rec.Code != wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, wantStatus, rec.Body)
+			}
+			for name, want := range tt.wantHeader {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body lacks %s:\n%s", want, rec.Body)
+				}
+			}
+			for _, unwanted := range tt.notBody {
+				if strings.Contains(rec.Body.String(), unwanted) {
+					t.Errorf("body contains %s:\n%s", unwanted, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxDepthHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxDepth = 2
+	config.DepthIgnoresIntrospection = true
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { me: User } type User { name: String friends: [User!]! }`).
+		Resolver("Query", "me", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return map[string]any{"name": "ada", "friends": []any{}}, nil
+		}).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "within the limit",
+			body:     `{"query":"{ me { name } }"}`,
+			wantBody: []string{`"data":{"me":{"name":"ada"}}`},
+		},
+		{
+			name:     "too deep",
+			body:     `{"query":"{ me { friends { name } } }"}`,
+			wantBody: []string{`Query depth 3 exceeds the maximum depth of 2.`, `"code":"MAX_DEPTH_EXCEEDED"`, `"maxDepth":2`},
+			notBody:  []string{`"data"`},
+		},
+		{
+			name:     "too deep through a fragment",
+			body:     `{"query":"{ me { ...F } } fragment F on User { friends { name } }"}`,
+			wantBody: []string{`"code":"MAX_DEPTH_EXCEEDED"`},
+		},
+		{
+			name:     "introspection is exempt",
+			body:     `{"query":"{ __schema { types { fields { type { name } } } } }"}`,
+			wantBody: []string{`"__schema"`},
+			notBody:  []string{`"errors"`},
+		},
+	})
+}
+
+// orderKey is the context key of the middlewares a request went through.
+type orderKey struct{}
+
+func TestUseDuringRequests(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+	query := func(order *[]int) int {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ a }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), orderKey{}, order))
+		rec := httptest.NewRecorder()
+		s.handleGraphQL(rec, req)
+		return rec.Code
+	}
+
+	const added = 20
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				var order []int
+				if code := query(&order); code != http.StatusOK {
+					t.Errorf("status %d", code)
+					return
+				}
+				for j, n := range order {
+					if n != j {
+						t.Errorf("middlewares ran in order %v, want a prefix of 0..%d", order, added-1)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < added; i++ {
+		i := i
+		s.Use(func(ctx *Context, next func(*Context) *Response) *Response {
+			order := ctx.Value(orderKey{}).(*[]int)
+			*order = append(*order, i)
+			return next(ctx)
+		})
+	}
+	wg.Wait()
+
+	var order []int
+	query(&order)
+	if len(order) != added {
+		t.Errorf("request went through %d middlewares, want %d", len(order), added)
+	}
+}
+
+func TestDataLoaderBatchesConcurrentLoads(t *testing.T) {
+	errDown := errors.New("database down")
+	tests := []struct {
+		name      string
+		keys      []int
+		err       error
+		wantKeys  int
+		wantBatch int32
+	}{
+		{name: "distinct keys", keys: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, wantKeys: 10, wantBatch: 1},
+		{name: "duplicate keys", keys: []int{1, 1, 2, 2, 2, 3}, wantKeys: 3, wantBatch: 1},
+		{name: "batch error", keys: []int{1, 2, 3}, err: errDown, wantKeys: 3, wantBatch: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches atomic.Int32
+			var fetched []int
+			dl := NewDataLoaderWithConfig(func(ctx context.Context, keys []int) (map[int]int, error) {
+				batches.Add(1)
+				fetched = keys
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				values := make(map[int]int, len(keys))
+				for _, k := range keys {
+					values[k] = k * 10
+				}
+				return values, nil
+			}, DataLoaderConfig{Wait: 20 * time.Millisecond})
+
+			var wg sync.WaitGroup
+			for _, key := range tt.keys {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					v, err := dl.Load(context.Background(), key)
+					if !errors.Is(err, tt.err) {
+						t.Errorf("Load(%d) error = %v, want %v", key, err, tt.err)
+					}
+					if tt.err == nil && v != key*10 {
+						t.Errorf("Load(%d) = %d, want %d", key, v, key*10)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if n := batches.Load(); n != tt.wantBatch {
+				t.Errorf("batch function called %d times, want %d", n, tt.wantBatch)
+			}
+			if len(fetched) != tt.wantKeys {
+				t.Errorf("fetched keys %v, want %d keys", fetched, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestDataLoaderMaxBatchSize(t *testing.T) {
+	var batches atomic.Int32
+	dl := NewDataLoaderWithConfig(func(ctx context.Context, keys []int) (map[int]int, error) {
+		batches.Add(1)
+		if len(keys) > 4 {
+			t.Errorf("batch of %d keys, want at most 4", len(keys))
+		}
+		values := make(map[int]int, len(keys))
+		for _, k := range keys {
+			values[k] = k
+		}
+		return values, nil
+	}, DataLoaderConfig{Wait: time.Hour, MaxBatchSize: 4})
+
+	var wg sync.WaitGroup
+	for key := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dl.Load(context.Background(), key)
+		}()
+	}
+	wg.Wait()
+	if n := batches.Load(); n != 2 {
+		t.Errorf("batch function called %d times, want 2", n)
+	}
+}
+
+func TestDataLoaderLoadThunk(t *testing.T) {
+	var batches atomic.Int32
+	dl := NewDataLoaderWithConfig(func(ctx context.Context, keys []int) (map[int]int, error) {
+		batches.Add(1)
+		values := make(map[int]int, len(keys))
+		for _, k := range keys {
+			values[k] = k * 10
+		}
+		return values, nil
+	}, DataLoaderConfig{Wait: 10 * time.Millisecond})
+
+	// Queue every key from one goroutine before waiting for any.
+	thunks := make([]func() (int, error), 20)
+	for i := range thunks {
+		thunks[i] = dl.LoadThunk(context.Background(), i)
+	}
+	for i, thunk := range thunks {
+		for call := 0; call < 2; call++ {
+			if v, err := thunk(); err != nil || v != i*10 {
+				t.Errorf("thunk %d call %d = %d, %v, want %d", i, call, v, err, i*10)
+			}
+		}
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("batch function called %d times, want 1", n)
+	}
+
+	// Cached keys resolve without another batch.
+	if v, err := dl.LoadThunk(context.Background(), 3)(); err != nil || v != 30 {
+		t.Errorf("cached thunk = %d, %v, want 30", v, err)
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("batch function called %d times after a cached load, want 1", n)
+	}
+}
+
+func TestDataLoaderCacheControls(t *testing.T) {
+	type step struct {
+		key       string
+		wantFetch bool
+	}
+	tests := []struct {
+		name   string
+		config DataLoaderConfig
+		prime  string
+		sleep  time.Duration
+		steps  []step
+	}{
+		{
+			name:   "cached",
+			config: DataLoaderConfig{},
+			steps:  []step{{"a", true}, {"a", false}, {"b", true}},
+		},
+		{
+			name:   "ttl",
+			config: DataLoaderConfig{CacheTTL: 20 * time.Millisecond},
+			sleep:  40 * time.Millisecond,
+			steps:  []step{{"a", true}, {"a", false}, {"sleep", false}, {"a", true}},
+		},
+		{
+			name:   "lru",
+			config: DataLoaderConfig{MaxCacheSize: 2},
+			steps:  []step{{"a", true}, {"b", true}, {"a", false}, {"c", true}, {"a", false}, {"b", true}},
+		},
+		{
+			name:   "disabled",
+			config: DataLoaderConfig{CacheDisabled: true},
+			prime:  "a",
+			steps:  []step{{"a", true}, {"a", true}},
+		},
+		{
+			name:   "primed",
+			config: DataLoaderConfig{},
+			prime:  "a",
+			steps:  []step{{"a", false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetched []string
+			tt.config.Wait = time.Microsecond
+			dl := NewDataLoaderWithConfig(func(ctx context.Context, keys []string) (map[string]string, error) {
+				fetched = append(fetched, keys...)
+				values := make(map[string]string, len(keys))
+				for _, k := range keys {
+					values[k] = strings.ToUpper(k)
+				}
+				return values, nil
+			}, tt.config)
+			if tt.prime != "" {
+				dl.Prime(tt.prime, strings.ToUpper(tt.prime))
+			}
+			for i, s := range tt.steps {
+				if s.key == "sleep" {
+					time.Sleep(tt.sleep)
+					continue
+				}
+				before := len(fetched)
+				if v, err := dl.Load(context.Background(), s.key); err != nil || v != strings.ToUpper(s.key) {
+					t.Fatalf("step %d: Load(%q) = %q, %v", i, s.key, v, err)
+				}
+				if fetch := len(fetched) > before; fetch != s.wantFetch {
+					t.Errorf("step %d: Load(%q) fetched = %v, want %v", i, s.key, fetch, s.wantFetch)
+				}
+			}
+		})
+	}
+}
+
+func TestDataLoaderCacheConcurrentAccess(t *testing.T) {
+	dl := NewDataLoaderWithConfig(func(ctx context.Context, keys []int) (map[int]int, error) {
+		values := make(map[int]int, len(keys))
+		for _, k := range keys {
+			values[k] = -k
+		}
+		return values, nil
+	}, DataLoaderConfig{MaxCacheSize: 8, CacheTTL: time.Millisecond, Wait: time.Microsecond})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := (g*7 + i) % 32
+				switch i % 10 {
+				case 0:
+					dl.Prime(key, -key)
+				case 1:
+					dl.Clear(key)
+				default:
+					if v, err := dl.Load(context.Background(), key); err != nil || v != -key {
+						t.Errorf("Load(%d) = %d, %v", key, v, err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestDataLoaderPrimeUsesCacheKeyFn(t *testing.T) {
+	var batches atomic.Int32
+	dl := NewDataLoaderWithConfig(func(ctx context.Context, keys []string) (map[string]string, error) {
+		batches.Add(1)
+		return nil, errors.New("unexpected fetch")
+	}, DataLoaderConfig{CacheKeyFn: func(key any) string { return strings.ToLower(key.(string)) }})
+	dl.Prime("Alice", "primed")
+
+	for _, key := range []string{"Alice", "alice", "ALICE"} {
+		if v, err := dl.Load(context.Background(), key); err != nil || v != "primed" {
+			t.Errorf("Load(%q) = %q, %v, want the primed value", key, v, err)
+		}
+	}
+	if n := batches.Load(); n != 0 {
+		t.Errorf("batch function called %d times, want 0", n)
+	}
+}
+
+func TestMaxComplexityHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxComplexity = 10
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { users(first: Int): [User!]! search: [User!]! } type User { name: String }`).
+		Resolver("Query", "users", func(ctx *Context, p any, a map[string]any) (any, error) { return []any{}, nil }).
+		Resolver("Query", "search", func(ctx *Context, p any, a map[string]any) (any, error) { return []any{}, nil }).
+		FieldCost("Query", "search", func(args map[string]any, childCost int) int { return 20 }).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:       "within the limit",
+			body:       `{"query":"{ users(first: 9) { name } }"}`,
+			wantHeader: map[string]string{QueryCostHeader: "10"},
+			wantBody:   []string{`"users":[]`, `"complexity":{"cost":10,"maxComplexity":10}`},
+		},
+		{
+			name:       "list size multiplies",
+			body:       `{"query":"{ users(first: 10) { name } }"}`,
+			wantHeader: map[string]string{QueryCostHeader: "11"},
+			wantBody:   []string{`Query complexity 11 exceeds the maximum complexity of 10.`, `"code":"MAX_COMPLEXITY_EXCEEDED"`},
+			notBody:    []string{`"data"`},
+		},
+		{
+			name:     "field cost hook",
+			body:     `{"query":"{ search { name } }"}`,
+			wantBody: []string{`Query complexity 20 exceeds`},
+		},
+		{
+			name:       "skipped fields are free",
+			body:       `{"query":"{ users { name } search @skip(if: true) { name } }"}`,
+			wantHeader: map[string]string{QueryCostHeader: "2"},
+			notBody:    []string{`"errors"`},
+		},
+	})
+}
+
+func TestVariableCoercionHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`
+			input Range { min: Int! max: Int = 100 }
+			type Query { echo(n: Int, ids: [ID!], range: Range): String }
+		`).
+		Resolver("Query", "echo", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return fmt.Sprintf("%#v %#v %v", a["n"], a["ids"], a["range"]), nil
+		}).
+		Build().Unwrap()
+
+	const echo = `query Q($n: Int!, $ids: [ID!], $range: Range, $d: Int = 7) { echo(n: $n, ids: $ids, range: $range) d: echo(n: $d) }`
+	request := func(variables string) string {
+		return fmt.Sprintf(`{"query":%q,"variables":%s}`, echo, variables)
+	}
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "coerced",
+			body:     request(`{"n":3,"ids":[1,"b"],"range":{"min":1}}`),
+			wantBody: []string{`"echo":"3 []interface {}{\"1\", \"b\"} map[max:100 min:1]"`, `"d":"7 `},
+		},
+		{
+			name:     "single value to list",
+			body:     request(`{"n":3,"ids":"a"}`),
+			wantBody: []string{`[]interface {}{\"a\"}`},
+		},
+		{
+			name:     "required",
+			body:     request(`{}`),
+			wantBody: []string{`Variable \"$n\" of required type \"Int!\" was not provided.`},
+			notBody:  []string{`"data"`},
+		},
+		{
+			name:     "null for non-null",
+			body:     request(`{"n":null}`),
+			wantBody: []string{`Variable \"$n\" of non-null type \"Int!\" must not be null.`},
+		},
+		{
+			name:     "wrong type",
+			body:     request(`{"n":"three"}`),
+			wantBody: []string{`Variable \"$n\" got invalid value \"three\"`},
+		},
+		{
+			name:     "fractional int",
+			body:     request(`{"n":1.5}`),
+			wantBody: []string{`Variable \"$n\" got invalid value 1.5`},
+		},
+		{
+			name:     "nested field",
+			body:     request(`{"n":1,"range":{"max":2}}`),
+			wantBody: []string{`Variable \"$range\" got invalid value`, `min`},
+		},
+		{
+			name:     "unknown field",
+			body:     request(`{"n":1,"range":{"min":1,"step":2}}`),
+			wantBody: []string{`Variable \"$range\" got invalid value`, `step`},
+		},
+	})
+}
+
+func TestEnumsHTTP(t *testing.T) {
+	type role int
+	const (
+		admin role = iota + 1
+		editor
+	)
+	s := NewBuilder().
+		Schema(`
+			enum Role { ADMIN EDITOR GUEST }
+			type Query { role(r: Role!): Role roles: [Role!]! bad: Role }
+		`).
+		EnumValues("Role", map[string]any{"ADMIN": admin, "EDITOR": editor}).
+		Resolver("Query", "role", func(ctx *Context, p any, a map[string]any) (any, error) {
+			if _, ok := a["r"].(role); !ok && a["r"] != "GUEST" {
+				return nil, fmt.Errorf("argument %#v is not mapped", a["r"])
+			}
+			return a["r"], nil
+		}).
+		Resolver("Query", "roles", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return []any{editor, "GUEST"}, nil
+		}).
+		Resolver("Query", "bad", func(ctx *Context, p any, a map[string]any) (any, error) { return "OWNER", nil }).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "mapped literal",
+			body:     `{"query":"{ role(r: ADMIN) roles }"}`,
+			wantBody: []string{`"role":"ADMIN"`, `"roles":["EDITOR","GUEST"]`},
+		},
+		{
+			name:     "unmapped member",
+			body:     `{"query":"{ role(r: GUEST) }"}`,
+			wantBody: []string{`"role":"GUEST"`},
+		},
+		{
+			name:     "variable",
+			body:     `{"query":"query($r: Role!) { role(r: $r) }","variables":{"r":"EDITOR"}}`,
+			wantBody: []string{`"role":"EDITOR"`},
+		},
+		{
+			name:     "unknown literal",
+			body:     `{"query":"{ role(r: OWNER) }"}`,
+			wantBody: []string{`Value \"OWNER\" does not exist in \"Role\" enum.`},
+			notBody:  []string{`"data"`},
+		},
+		{
+			name:     "unknown variable value",
+			body:     `{"query":"query($r: Role!) { role(r: $r) }","variables":{"r":"OWNER"}}`,
+			wantBody: []string{`Variable \"$r\" got invalid value \"OWNER\"`},
+		},
+		{
+			name:     "unknown result value",
+			body:     `{"query":"{ bad }"}`,
+			wantBody: []string{`"bad":null`, `"path":["bad"]`},
+		},
+	})
+}
+
+func TestCustomScalarsHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`scalar Date type Query { next(d: Date!): Date }`).
+		Scalar("Date",
+			func(v any) (any, error) { return v.(time.Time).Format(time.DateOnly), nil },
+			func(v any) (any, error) {
+				str, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("Date must be a string")
+				}
+				return time.Parse(time.DateOnly, str)
+			}).
+		Resolver("Query", "next", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return a["d"].(time.Time).AddDate(0, 0, 1), nil
+		}).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "literal",
+			body:     `{"query":"{ next(d: \"2024-02-28\") }"}`,
+			wantBody: []string{`"next":"2024-02-29"`},
+		},
+		{
+			name:     "variable",
+			body:     `{"query":"query($d: Date!) { next(d: $d) }","variables":{"d":"2024-12-31"}}`,
+			wantBody: []string{`"next":"2025-01-01"`},
+		},
+		{
+			name:     "invalid literal",
+			body:     `{"query":"{ next(d: 20240228) }"}`,
+			wantBody: []string{`Date must be a string`},
+			notBody:  []string{`"next":"`},
+		},
+		{
+			name:     "invalid variable",
+			body:     `{"query":"query($d: Date!) { next(d: $d) }","variables":{"d":"tomorrow"}}`,
+			wantBody: []string{`Variable \"$d\" got invalid value \"tomorrow\"`},
+		},
+	})
+}
+
+func TestSkipIncludeHTTP(t *testing.T) {
+	var resolved atomic.Int32
+	s := NewBuilder().
+		Schema(`type Query { a: Int b: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Query", "b", func(ctx *Context, p any, a map[string]any) (any, error) {
+			resolved.Add(1)
+			return 2, nil
+		}).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "skip", body: `{"query":"{ a b @skip(if: true) }"}`, wantBody: []string{`"data":{"a":1}`}},
+		{name: "not skipped", body: `{"query":"{ a b @skip(if: false) }"}`, wantBody: []string{`"data":{"a":1,"b":2}`}},
+		{name: "include", body: `{"query":"{ a b @include(if: false) }"}`, wantBody: []string{`"data":{"a":1}`}},
+		{name: "both", body: `{"query":"{ a b @include(if: true) @skip(if: true) }"}`, wantBody: []string{`"data":{"a":1}`}},
+		{
+			name:     "variable",
+			body:     `{"query":"query($skip: Boolean!) { a b @skip(if: $skip) }","variables":{"skip":true}}`,
+			wantBody: []string{`"data":{"a":1}`},
+		},
+		{
+			name:     "fragments",
+			body:     `{"query":"{ a ... @include(if: false) { b } ...F @skip(if: true) } fragment F on Query { b }"}`,
+			wantBody: []string{`"data":{"a":1}`},
+		},
+		{
+			name:     "missing condition",
+			body:     `{"query":"{ a b @skip }"}`,
+			wantBody: []string{`Directive \"@skip\" argument \"if\" of type \"Boolean!\" is required, but it was not provided.`},
+			notBody:  []string{`"data"`},
+		},
+		{
+			name:     "invalid condition",
+			body:     `{"query":"{ a ... @include(if: 1, unless: true) { b } }"}`,
+			wantBody: []string{`Unknown argument \"unless\" on directive \"@include\".`, `Argument \"if\"`},
+			notBody:  []string{`"data"`},
+		},
+	})
+	if n := resolved.Load(); n != 1 {
+		t.Errorf("b resolved %d times, want once, when not skipped", n)
+	}
+}
+
+func TestOperationNameHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int b: Int } type Mutation { c: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Query", "b", func(ctx *Context, p any, a map[string]any) (any, error) { return 2, nil }).
+		Resolver("Mutation", "c", func(ctx *Context, p any, a map[string]any) (any, error) { return 3, nil }).
+		Build().Unwrap()
+
+	const doc = `query A { a } query B { b } mutation C { c }`
+	request := func(operationName string) string {
+		return fmt.Sprintf(`{"query":%q,"operationName":%q}`, doc, operationName)
+	}
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "first", body: request("A"), wantBody: []string{`"data":{"a":1}`}},
+		{name: "second", body: request("B"), wantBody: []string{`"data":{"b":2}`}},
+		{name: "mutation", body: request("C"), wantBody: []string{`"data":{"c":3}`}},
+		{name: "unknown", body: request("D"), wantBody: []string{`Unknown operation named \"D\".`}, notBody: []string{`"data"`}},
+		{
+			name:     "missing",
+			body:     fmt.Sprintf(`{"query":%q}`, doc),
+			wantBody: []string{`Must provide operation name if query contains multiple operations.`},
+		},
+		{
+			name:     "single operation",
+			body:     `{"query":"query A { a }","operationName":null}`,
+			wantBody: []string{`"data":{"a":1}`},
+		},
+	})
+}
+
+func TestGraphQLContentTypeHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:       "application/graphql",
+			header:     map[string]string{"Content-Type": "application/graphql"},
+			body:       `{ a }`,
+			wantHeader: map[string]string{"Content-Type": "application/json"},
+			wantBody:   []string{`"data":{"a":1}`},
+		},
+		{
+			name:     "with charset",
+			header:   map[string]string{"Content-Type": "application/graphql; charset=utf-8"},
+			body:     `query { a }`,
+			wantBody: []string{`"data":{"a":1}`},
+		},
+		{
+			name:     "body is the query, not JSON",
+			header:   map[string]string{"Content-Type": "application/graphql"},
+			body:     `{"query":"{ a }"}`,
+			wantBody: []string{`"errors"`},
+			notBody:  []string{`"data"`},
+		},
+		{
+			name:       "unsupported",
+			header:     map[string]string{"Content-Type": "application/xml"},
+			body:       `{ a }`,
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantBody:   []string{`Unsupported Content-Type \"application/xml\"`},
+		},
+	})
+}
+
+func TestBatchingHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxBatch = 2
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { echo(n: Int!): Int }`).
+		Resolver("Query", "echo", func(ctx *Context, p any, a map[string]any) (any, error) { return a["n"], nil }).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name: "in order",
+			body: `[{"query":"{ echo(n: 1) }"},{"query":"query($n: Int!) { echo(n: $n) }","variables":{"n":2}},{"query":"{ echo(n: 3) }"}]`,
+			wantBody: []string{
+				`[{"data":{"echo":1}`,
+				`},{"data":{"echo":2}`,
+				`},{"data":{"echo":3}`,
+			},
+		},
+		{
+			name:     "errors stay with their request",
+			body:     `[{"query":"{ echo(n: 1) }"},{"query":"{ echo }"}]`,
+			wantBody: []string{`[{"data":{"echo":1}`, `},{"errors":[{"message":"Field \"Query.echo\" argument \"n\"`},
+		},
+		{
+			name:     "single request is not an array",
+			body:     ` {"query":"{ echo(n: 1) }"}`,
+			wantBody: []string{`{"data":{"echo":1}`},
+			notBody:  []string{`[`},
+		},
+		{name: "empty", body: `[]`, wantStatus: http.StatusBadRequest, wantBody: []string{"Empty batch"}},
+		{name: "null entry", body: `[null]`, wantStatus: http.StatusBadRequest, wantBody: []string{"Invalid request body"}},
+	})
+}
+
+func TestServerSentEventsHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int } type Subscription { count(to: Int!): Int! }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Subscription("count", func(ctx *Context, args map[string]any) (<-chan any, error) {
+			events := make(chan any)
+			go func() {
+				defer close(events)
+				for i := 1; i <= args["to"].(int); i++ {
+					select {
+					case events <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return events, nil
+		}).
+		Build().Unwrap()
+
+	stream := map[string]string{"Accept": "text/event-stream"}
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			path:       "/graphql?query=" + url.QueryEscape("subscription { count(to: 2) }"),
+			header:     map[string]string{"Accept": "text/event-stream", "Apollo-Require-Preflight": "true"},
+			wantHeader: map[string]string{"Content-Type": "text/event-stream", "Cache-Control": "no-cache"},
+			wantBody: []string{
+				"event: next\ndata: {\"data\":{\"count\":1}}\n\n" +
+					"event: next\ndata: {\"data\":{\"count\":2}}\n\n" +
+					"event: complete\ndata:\n\n",
+			},
+		},
+		{
+			name:     "post with variables",
+			header:   stream,
+			body:     `{"query":"subscription($to: Int!) { count(to: $to) }","variables":{"to":1}}`,
+			wantBody: []string{"event: next\ndata: {\"data\":{\"count\":1}}\n\nevent: complete"},
+			notBody:  []string{`"count":2`},
+		},
+		{
+			name:     "query",
+			header:   stream,
+			body:     `{"query":"{ a }"}`,
+			wantBody: []string{"event: next\ndata: {\"data\":{\"a\":1}", "event: complete"},
+		},
+		{
+			name:       "two root fields",
+			header:     stream,
+			body:       `{"query":"subscription { a: count(to: 1) b: count(to: 2) }"}`,
+			wantStatus: http.StatusBadRequest,
+			wantHeader: map[string]string{"Content-Type": "application/json"},
+			wantBody:   []string{`Anonymous Subscription must select only one top level field.`},
+		},
+		{
+			name:       "batch",
+			header:     stream,
+			body:       `[{"query":"subscription { count(to: 1) }"}]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"Batched requests cannot be streamed"},
+		},
+		{
+			name:     "without an event stream",
+			body:     `{"query":"subscription { count(to: 1) }"}`,
+			wantBody: []string{`Subscription operations must be sent over a WebSocket or text/event-stream connection.`},
+		},
+	})
+}
+
+// multipartUpload encodes a multipart request of operations whose files
+// are mapped by map, returning its body and content type.
+func multipartUpload(t *testing.T, operations, fileMap string, files map[string]string) (string, string) {
+	t.Helper()
+	var body strings.Builder
+	w := multipart.NewWriter(&body)
+	w.WriteField("operations", operations)
+	w.WriteField("map", fileMap)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		part, err := w.CreateFormFile(name, name+".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(part, files[name])
+	}
+	w.Close()
+	return body.String(), w.FormDataContentType()
+}
+
+func TestUploadsHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxUploadSize = 1 << 10
+	s := NewBuilder().
+		Config(config).
+		Schema(`scalar Upload type Query { a: Int } type Mutation { upload(file: Upload!): String uploadMany(files: [Upload!]!): [String!]! }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Mutation", "upload", func(ctx *Context, p any, a map[string]any) (any, error) {
+			upload := a["file"].(Upload)
+			data, err := io.ReadAll(upload.File)
+			return fmt.Sprintf("%s %d %s", upload.Filename, upload.Size, data), err
+		}).
+		Resolver("Mutation", "uploadMany", func(ctx *Context, p any, a map[string]any) (any, error) {
+			var names []any
+			for _, file := range a["files"].([]any) {
+				names = append(names, file.(Upload).Filename)
+			}
+			return names, nil
+		}).
+		Build().Unwrap()
+
+	const single = `{"query":"mutation($file: Upload!) { upload(file: $file) }","variables":{"file":null}}`
+	upload := func(operations, fileMap string, files map[string]string) (string, map[string]string) {
+		body, contentType := multipartUpload(t, operations, fileMap, files)
+		return body, map[string]string{"Content-Type": contentType, "Apollo-Require-Preflight": "true"}
+	}
+	singleBody, singleHeader := upload(single, `{"0":["variables.file"]}`, map[string]string{"0": "hello"})
+	manyBody, manyHeader := upload(
+		`{"query":"mutation($files: [Upload!]!) { uploadMany(files: $files) }","variables":{"files":[null,null]}}`,
+		`{"a":["variables.files.0"],"b":["variables.files.1"]}`,
+		map[string]string{"a": "1", "b": "2"})
+	batchBody, batchHeader := upload("["+single+","+single+"]",
+		`{"0":["0.variables.file","1.variables.file"]}`, map[string]string{"0": "hi"})
+	missingBody, missingHeader := upload(single, `{"0":["variables.file"]}`, nil)
+	badPathBody, badPathHeader := upload(single, `{"0":["variables.nope.x"]}`, map[string]string{"0": "x"})
+	largeBody, largeHeader := upload(single, `{"0":["variables.file"]}`, map[string]string{"0": strings.Repeat("x", 2<<10)})
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "single", header: singleHeader, body: singleBody, wantBody: []string{`"upload":"0.txt 5 hello"`}},
+		{name: "list", header: manyHeader, body: manyBody, wantBody: []string{`"uploadMany":["a.txt","b.txt"]`}},
+		{
+			name:     "batch sharing a file",
+			header:   batchHeader,
+			body:     batchBody,
+			wantBody: []string{`[{"data":{"upload":"0.txt 2 hi"}`, `},{"data":{"upload":"0.txt 2 hi"}`},
+		},
+		{name: "missing file", header: missingHeader, body: missingBody, wantStatus: http.StatusBadRequest, wantBody: []string{`File "0" is missing from the request`}},
+		{name: "invalid path", header: badPathHeader, body: badPathBody, wantStatus: http.StatusBadRequest, wantBody: []string{`Invalid path "variables.nope.x"`}},
+		{
+			name:       "too large",
+			header:     largeHeader,
+			body:       largeBody,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   []string{`"code":"PAYLOAD_TOO_LARGE"`, `"maxUploadSize":1024`},
+		},
+		{
+			name:     "inline",
+			body:     `{"query":"mutation { upload(file: \"x\") }"}`,
+			wantBody: []string{`Upload cannot be written inline; pass it through a variable`},
+		},
+		{
+			name:     "not a file",
+			body:     `{"query":"mutation($file: Upload!) { upload(file: $file) }","variables":{"file":"x"}}`,
+			wantBody: []string{`send it as a multipart file`},
+		},
+	})
+}
+
+func TestPersistedQueriesHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+
+	const query = "{ a }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	persisted := func(query, hash string) string {
+		return fmt.Sprintf(`{"query":%q,"extensions":{"persistedQuery":{"version":1,"sha256Hash":%q}}}`, query, hash)
+	}
+	// The requests run in order against one store.
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "not yet stored", body: persisted("", hash), wantBody: []string{`"message":"PersistedQueryNotFound"`, `"code":"PERSISTED_QUERY_NOT_FOUND"`}},
+		{name: "hash mismatch", body: persisted("{ __typename }", hash), wantBody: []string{`"code":"PERSISTED_QUERY_HASH_MISMATCH"`}},
+		{name: "register", body: persisted(query, hash), wantBody: []string{`"data":{"a":1}`}},
+		{name: "by hash", body: persisted("", hash), wantBody: []string{`"data":{"a":1}`}},
+		{name: "uppercase hash", body: persisted("", strings.ToUpper(hash)), wantBody: []string{`"data":{"a":1}`}},
+		{
+			name:     "unsupported version",
+			body:     fmt.Sprintf(`{"extensions":{"persistedQuery":{"version":2,"sha256Hash":%q}}}`, hash),
+			wantBody: []string{`"code":"PERSISTED_QUERY_NOT_SUPPORTED"`},
+		},
+	})
+
+	disabled := NewBuilder().
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		PersistedQueries(nil).
+		Build().Unwrap()
+	runHTTPTests(t, disabled.Handler(), []httpTest{
+		{name: "disabled", body: persisted("", hash), wantBody: []string{`"message":"PersistedQueryNotSupported"`}},
+	})
+}
+
+func TestAllowedOperationsHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.RejectUnknownOperations = true
+	config.AllowUnknownIntrospection = true
+	const trusted = "query A { a }"
+	sum := sha256.Sum256([]byte(trusted))
+	hash := hex.EncodeToString(sum[:])
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int b: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Query", "b", func(ctx *Context, p any, a map[string]any) (any, error) { return 2, nil }).
+		AllowedOperations(map[string]string{"a-v1": trusted, hash: trusted}).
+		Build().Unwrap()
+
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "document id", body: `{"extensions":{"documentId":"a-v1"}}`, wantBody: []string{`"data":{"a":1}`}},
+		{
+			name:     "persisted query hash",
+			body:     fmt.Sprintf(`{"extensions":{"persistedQuery":{"version":1,"sha256Hash":%q}}}`, hash),
+			wantBody: []string{`"data":{"a":1}`},
+		},
+		{name: "trusted text", body: fmt.Sprintf(`{"query":%q}`, trusted), wantBody: []string{`"data":{"a":1}`}},
+		{
+			name:     "unknown text",
+			body:     `{"query":"{ b }"}`,
+			wantBody: []string{`Operation is not allowed: only registered documents may be executed.`, `"code":"OPERATION_NOT_ALLOWED"`},
+			notBody:  []string{`"data"`},
+		},
+		{name: "unknown id", body: `{"extensions":{"documentId":"b-v1"}}`, wantBody: []string{`Unknown document \"b-v1\".`}},
+		{
+			name:     "persisted queries cannot register",
+			body:     `{"query":"{ b }","extensions":{"persistedQuery":{"version":1,"sha256Hash":"00"}}}`,
+			wantBody: []string{`"code":"OPERATION_NOT_ALLOWED"`},
+		},
+		{name: "introspection", body: `{"query":"{ __typename }"}`, wantBody: []string{`"data":{"__typename":"Query"}`}},
+		{name: "mixed introspection", body: `{"query":"{ __typename b }"}`, wantBody: []string{`"code":"OPERATION_NOT_ALLOWED"`}},
+	})
+}
+
+func TestCORSHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.CORS = CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowedHeaders:   []string{"Content-Type", "X-Tenant"},
+		ExposedHeaders:   []string{QueryCostHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+
+	preflight := func(origin string) map[string]string {
+		return map[string]string{"Origin": origin, "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type"}
+	}
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			header:     preflight("https://app.example.com"),
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST, OPTIONS",
+				"Access-Control-Allow-Headers":     "Content-Type, X-Tenant",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Expose-Headers":    "",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:       "preflight from a wildcard origin",
+			method:     http.MethodOptions,
+			header:     preflight("https://pr-12.preview.example.com"),
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://pr-12.preview.example.com"},
+		},
+		{
+			name:       "preflight from another origin",
+			method:     http.MethodOptions,
+			header:     preflight("https://evil.example"),
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": "", "Vary": "Origin"},
+		},
+		{
+			name:       "request",
+			header:     map[string]string{"Origin": "https://app.example.com"},
+			body:       `{"query":"{ a }"}`,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Access-Control-Expose-Headers": QueryCostHeader},
+			wantBody:   []string{`"data":{"a":1}`},
+		},
+		{
+			name:       "request from another origin",
+			header:     map[string]string{"Origin": "https://evil.example"},
+			body:       `{"query":"{ a }"}`,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	})
+
+	config.CORS = CORSConfig{AllowedOrigins: []string{"*"}}
+	public := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+	runHTTPTests(t, public.Handler(), []httpTest{
+		{
+			name:       "any origin",
+			method:     http.MethodOptions,
+			header:     preflight("https://anywhere.example"),
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Headers": "Content-Type, Authorization", "Access-Control-Allow-Credentials": ""},
+		},
+	})
+}
+
+func TestCSRFPreventionHTTP(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+
+	const blocked = "This operation has been blocked as a potential Cross-Site Request Forgery (CSRF)."
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "json", body: `{"query":"{ a }"}`, wantBody: []string{`"data":{"a":1}`}},
+		{
+			name:       "text/plain",
+			header:     map[string]string{"Content-Type": "text/plain"},
+			body:       `{"query":"{ a }"}`,
+			wantStatus: http.StatusBadRequest,
+			wantHeader: map[string]string{"Content-Type": "application/json"},
+			wantBody:   []string{blocked, `"code":"BAD_REQUEST"`},
+			notBody:    []string{`"data"`},
+		},
+		{
+			name:       "form",
+			header:     map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:       `query=%7B+a+%7D`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{blocked},
+		},
+		{
+			name:       "multipart",
+			header:     map[string]string{"Content-Type": "multipart/form-data; boundary=x"},
+			body:       "--x--\r\n",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{blocked},
+		},
+		{
+			name:       "get without a content type",
+			method:     http.MethodGet,
+			header:     map[string]string{"Accept": "text/event-stream"},
+			path:       "/graphql?query=%7B+a+%7D",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{blocked},
+		},
+		{
+			name:       "preflight header",
+			method:     http.MethodGet,
+			header:     map[string]string{"Accept": "text/event-stream", "X-Apollo-Operation-Name": "A"},
+			path:       "/graphql?query=%7B+a+%7D",
+			wantHeader: map[string]string{"Content-Type": "text/event-stream"},
+			wantBody:   []string{`"data":{"a":1}`},
+		},
+		{
+			name:       "empty preflight header",
+			header:     map[string]string{"Content-Type": "text/plain", "Apollo-Require-Preflight": ""},
+			body:       `{"query":"{ a }"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	})
+
+	config := DefaultConfig()
+	config.CSRFPrevention = false
+	open := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+	runHTTPTests(t, open.Handler(), []httpTest{
+		{
+			name:       "disabled",
+			header:     map[string]string{"Content-Type": "text/plain"},
+			body:       `{"query":"{ a }"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+			notBody:    []string{"CSRF"},
+		},
+	})
+}
+
+func TestHealthHTTP(t *testing.T) {
+	var down atomic.Bool
+	config := DefaultConfig()
+	config.ReadyCheck = func(ctx context.Context) error {
+		if down.Load() {
+			return errors.New("database unreachable")
+		}
+		return nil
+	}
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+	s.Use(func(ctx *Context, next func(*Context) *Response) *Response {
+		return &Response{Errors: []GraphQLError{{Message: "blocked"}}}
+	})
+	handler := s.Handler()
+
+	healthy := func(path string) httpTest {
+		return httpTest{
+			name:       path,
+			method:     http.MethodGet,
+			path:       path,
+			wantHeader: map[string]string{"Content-Type": "application/json", "Cache-Control": "no-store"},
+			wantBody:   []string{`{"status":"ok"}`},
+			notBody:    []string{"blocked"},
+		}
+	}
+	runHTTPTests(t, handler, []httpTest{healthy("/healthz"), healthy("/readyz")})
+
+	down.Store(true)
+	runHTTPTests(t, handler, []httpTest{
+		healthy("/healthz"),
+		{
+			name:       "not ready",
+			method:     http.MethodGet,
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   []string{`"status":"unavailable"`, `"error":"database unreachable"`},
+		},
+	})
+
+	config.HealthPath, config.ReadyPath = "/live", ""
+	custom := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+	runHTTPTests(t, custom.Handler(), []httpTest{
+		healthy("/live"),
+		{name: "disabled", method: http.MethodGet, path: "/readyz", wantStatus: http.StatusNotFound, wantBody: []string{"Not found: /readyz"}},
+		{name: "default moved", method: http.MethodGet, path: "/healthz", wantStatus: http.StatusNotFound},
+	})
+}
+
+func TestExecutionTimeoutHTTP(t *testing.T) {
+	config := DefaultConfig()
+	config.ExecutionTimeout = 50 * time.Millisecond
+	block := func(ctx *Context, p any, a map[string]any) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	var thirdCalled atomic.Bool
+	s := NewBuilder().
+		Config(config).
+		Schema(`
+			type Query { fast: Int, slow: Int }
+			type Mutation { first: Int, slow: Int, third: Int }
+		`).
+		Resolver("Query", "fast", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Query", "slow", block).
+		Resolver("Mutation", "first", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Mutation", "slow", block).
+		Resolver("Mutation", "third", func(ctx *Context, p any, a map[string]any) (any, error) {
+			thirdCalled.Store(true)
+			return 3, nil
+		}).
+		Build().Unwrap()
+
+	timedOut := `"errors":[{"message":"Execution timed out after 50ms.","path":["slow"],"locations":[{"line":1,"column":%d}],"extensions":{"code":"GRAPHQL_TIMEOUT"}}]`
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "query",
+			body:     `{"query":"{ fast slow }"}`,
+			wantBody: []string{`"data":{"fast":1,"slow":null}`, fmt.Sprintf(timedOut, 8)},
+		},
+		{
+			name:     "mutation",
+			body:     `{"query":"mutation { first slow third }"}`,
+			wantBody: []string{`"data":{"first":1,"slow":null,"third":null}`, fmt.Sprintf(timedOut, 18)},
+			notBody:  []string{`"path":["third"]`},
+		},
+	})
+	if thirdCalled.Load() {
+		t.Error("field after the timeout was resolved")
+	}
+}
+
+func TestPanicRecoveryHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var recovered []string
+	config := DefaultConfig()
+	config.PanicHandler = func(ctx *Context, value any, stack []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(stack) == 0 {
+			t.Error("panic reported without a stack")
+		}
+		recovered = append(recovered, fmt.Sprint(value))
+	}
+	loader := NewDataLoader(func(ctx context.Context, ids []int) (map[int]string, error) {
+		panic("loader secret")
+	})
+	s := NewBuilder().
+		Config(config).
+		Schema(`
+			type Query { ok: Int, boom: Int, items: [Item!], loaded: String }
+			type Item { id: Int!, name: String }
+		`).
+		Resolver("Query", "ok", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Query", "boom", func(ctx *Context, p any, a map[string]any) (any, error) {
+			panic(errors.New("password=hunter2"))
+		}).
+		Resolver("Query", "items", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return []any{map[string]any{"id": 1}, map[string]any{"id": 2}, map[string]any{"id": 3}}, nil
+		}).
+		Resolver("Item", "name", func(ctx *Context, p any, a map[string]any) (any, error) {
+			if id := p.(map[string]any)["id"].(int); id == 2 {
+				panic("item secret")
+			}
+			return "item", nil
+		}).
+		Resolver("Query", "loaded", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return loader.Load(ctx, 1)
+		}).
+		Build().Unwrap()
+
+	internal := `{"message":"Internal server error.","path":%s,"locations":[{"line":1,"column":%d}],"extensions":{"code":"INTERNAL_SERVER_ERROR"}}`
+	secrets := []string{"hunter2", "secret", "panic", "goroutine"}
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "resolver",
+			body:     `{"query":"{ ok boom }"}`,
+			wantBody: []string{`"data":{"ok":1,"boom":null}`, fmt.Sprintf(internal, `["boom"]`, 6)},
+			notBody:  secrets,
+		},
+		{
+			name: "list item",
+			body: `{"query":"{ items { id name } }"}`,
+			wantBody: []string{
+				`"data":{"items":[{"id":1,"name":"item"},{"id":2,"name":null},{"id":3,"name":"item"}]}`,
+				fmt.Sprintf(internal, `["items",1,"name"]`, 14),
+			},
+			notBody: secrets,
+		},
+		{
+			name:     "batch function",
+			body:     `{"query":"{ ok loaded }"}`,
+			wantBody: []string{`"data":{"ok":1,"loaded":null}`, `"message":"Internal server error."`, `"code":"INTERNAL_SERVER_ERROR"`},
+			notBody:  secrets,
+		},
+	})
+
+	if want := []string{"password=hunter2", "item secret"}; !slices.Equal(recovered, want) {
+		t.Errorf("PanicHandler received %q, want %q", recovered, want)
+	}
+}
+
+type clientSafeError string
+
+func (e clientSafeError) Error() string    { return string(e) }
+func (e clientSafeError) ClientSafe() bool { return true }
+
+func TestMaskInternalErrorsHTTP(t *testing.T) {
+	failing := func(err error) ResolverFn {
+		return func(ctx *Context, p any, a map[string]any) (any, error) { return nil, err }
+	}
+	s := NewBuilder().
+		Schema(`type Query {
+			db: Int
+			unknownCode: Int
+			auth: Int
+			wrapped: Int
+			safe: Int
+			invalid(name: String): Int
+			ok: Int
+		}`).
+		Resolver("Query", "db", failing(errors.New("pq: password authentication failed for user admin"))).
+		Resolver("Query", "unknownCode", failing(GraphQLError{Message: "shard 3 down", Extensions: map[string]any{"code": "DB_ERROR"}})).
+		Resolver("Query", "auth", failing(GraphQLError{Message: "Sign in first.", Extensions: map[string]any{"code": "UNAUTHENTICATED"}})).
+		Resolver("Query", "wrapped", failing(fmt.Errorf("loading: %w", &GraphQLError{Message: "Slow down.", Extensions: map[string]any{"code": "RATE_LIMITED"}}))).
+		Resolver("Query", "safe", failing(clientSafeError("Out of stock."))).
+		Resolver("Query", "invalid", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return nil, result.ValidateStruct(a, result.Required("name")).Err()
+		}).
+		Resolver("Query", "ok", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		ErrorPresenter(MaskInternalErrors("Something went wrong.")).
+		Build().Unwrap()
+	var originals []error
+	s.Use(func(ctx *Context, next func(*Context) *Response) *Response {
+		resp := next(ctx)
+		originals = ctx.Errors()
+		return resp
+	})
+
+	masked := `{"message":"Something went wrong.","path":["%s"],"locations":[{"line":1,"column":3}],"extensions":{"code":"INTERNAL_SERVER_ERROR","correlationId":"`
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{
+			name:     "internal error",
+			body:     `{"query":"{ db }"}`,
+			wantBody: []string{`"data":{"db":null}`, fmt.Sprintf(masked, "db")},
+			notBody:  []string{"pq:", "admin"},
+		},
+		{
+			name:     "unknown code",
+			body:     `{"query":"{ unknownCode }"}`,
+			wantBody: []string{fmt.Sprintf(masked, "unknownCode")},
+			notBody:  []string{"shard", "DB_ERROR"},
+		},
+		{
+			name:     "known code",
+			body:     `{"query":"{ auth }"}`,
+			wantBody: []string{`{"message":"Sign in first.","path":["auth"],"locations":[{"line":1,"column":3}],"extensions":{"code":"UNAUTHENTICATED"}}`},
+		},
+		{
+			name:     "wrapped known code",
+			body:     `{"query":"{ wrapped }"}`,
+			wantBody: []string{`{"message":"Slow down.","path":["wrapped"],"locations":[{"line":1,"column":3}],"extensions":{"code":"RATE_LIMITED"}}`},
+			notBody:  []string{"loading:"},
+		},
+		{
+			name:     "client safe",
+			body:     `{"query":"{ safe }"}`,
+			wantBody: []string{`{"message":"Out of stock.","path":["safe"],"locations":[{"line":1,"column":3}]}`},
+		},
+		{
+			name:     "input validation",
+			body:     `{"query":"{ invalid }"}`,
+			wantBody: []string{`{"message":"name: is required","path":["invalid"],"locations":[{"line":1,"column":3}],"extensions":{"field":"name"}}`},
+		},
+		{
+			name:     "request errors are not presented",
+			body:     `{"query":"{ ok(x: 1) }"}`,
+			wantBody: []string{`Unknown argument \"x\" on field \"Query.ok\".`},
+			notBody:  []string{"Something went wrong."},
+		},
+	})
+
+	// The original errors are kept for logging.
+	runHTTPTests(t, s.Handler(), []httpTest{{name: "original", body: `{"query":"{ ok db }"}`}})
+	if len(originals) != 1 || !strings.Contains(originals[0].Error(), "pq: password authentication failed") {
+		t.Errorf("Context.Errors() = %v, want the unmasked error", originals)
+	}
+}
+
+func TestMaxBodySizeHTTP(t *testing.T) {
+	const limit = 64
+	config := DefaultConfig()
+	config.MaxBodySize = limit
+	s := NewBuilder().
+		Config(config).
+		Schema(`scalar Upload type Query { a: Int } type Mutation { upload(file: Upload!): String }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Resolver("Mutation", "upload", func(ctx *Context, p any, a map[string]any) (any, error) {
+			return a["file"].(Upload).Filename, nil
+		}).
+		Build().Unwrap()
+
+	// pad returns body padded with whitespace to n bytes.
+	pad := func(body string, n int) string {
+		return body[:len(body)-1] + strings.Repeat(" ", n-len(body)) + body[len(body)-1:]
+	}
+	tooLarge := `"errors":[{"message":"Request body exceeds the maximum size of 64 bytes.","extensions":{"code":"REQUEST_TOO_LARGE","maxBodySize":64}}]`
+	upload, contentType := multipartUpload(t,
+		`{"query":"mutation($file: Upload!) { upload(file: $file) }","variables":{"file":null}}`,
+		`{"0":["variables.file"]}`,
+		map[string]string{"0": strings.Repeat("x", 2*limit)})
+	runHTTPTests(t, s.Handler(), []httpTest{
+		{name: "at the limit", body: pad(`{"query":"{ a }"}`, limit), wantBody: []string{`"data":{"a":1}`}},
+		{
+			name:       "json",
+			body:       pad(`{"query":"{ a }"}`, limit+1),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantHeader: map[string]string{"Content-Type": "application/json"},
+			wantBody:   []string{tooLarge},
+			notBody:    []string{`"data"`},
+		},
+		{
+			name:       "batch",
+			body:       `[` + strings.Repeat(`{"query":"{ a }"},`, 4) + `{"query":"{ a }"}]`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   []string{tooLarge},
+		},
+		{
+			name:       "application/graphql",
+			header:     map[string]string{"Content-Type": "application/graphql"},
+			body:       "{ a " + strings.Repeat("a ", limit) + "}",
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   []string{tooLarge},
+		},
+		{
+			name:     "uploads are limited separately",
+			header:   map[string]string{"Content-Type": contentType, "Apollo-Require-Preflight": "true"},
+			body:     upload,
+			wantBody: []string{`"data":{"upload":"0.txt"}`},
+		},
+	})
+
+	config.MaxBodySize = 0
+	unlimited := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Build().Unwrap()
+	runHTTPTests(t, unlimited.Handler(), []httpTest{
+		{name: "no limit", body: pad(`{"query":"{ a }"}`, 8<<20), wantBody: []string{`"data":{"a":1}`}},
+	})
+}
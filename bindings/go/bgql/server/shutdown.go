@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// wsGoingAway is the WebSocket close code sent to connections when the
+// server shuts down.
+const wsGoingAway = 1001
+
+// shutdownGrace is how long ListenWithGracefulShutdown waits, after the
+// drain period, for cancelled executions to return.
+const shutdownGrace = 5 * time.Second
+
+// lifecycle tracks the in-flight work Stop drains.
+type lifecycle struct {
+	mu       sync.Mutex
+	stopping bool
+	sessions map[*wsSession]struct{}
+	inflight sync.WaitGroup
+
+	// base is cancelled when the drain period ends, cancelling every
+	// in-flight execution.
+	base   context.Context
+	cancel context.CancelFunc
+
+	// done is closed, with err set, once Stop has finished.
+	done chan struct{}
+	err  error
+}
+
+// init allocates the lifecycle state. l.mu must be held.
+func (l *lifecycle) init() {
+	if l.base == nil {
+		l.base, l.cancel = context.WithCancel(context.Background())
+		l.done = make(chan struct{})
+		l.sessions = make(map[*wsSession]struct{})
+	}
+}
+
+// begin registers an in-flight execution. The returned context is ctx,
+// also cancelled once the drain period of Stop ends, and end must be
+// called when the execution finishes. begin reports false once the server
+// is stopping.
+func (s *Server) begin(ctx context.Context) (_ context.Context, end func(), ok bool) {
+	l := &s.lifecycle
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopping {
+		return nil, nil, false
+	}
+	l.init()
+	l.inflight.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(l.base, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		l.inflight.Done()
+	}, true
+}
+
+// trackSession registers a WebSocket connection to close on Stop. It
+// reports false once the server is stopping.
+func (s *Server) trackSession(ws *wsSession) bool {
+	l := &s.lifecycle
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopping {
+		return false
+	}
+	l.init()
+	l.sessions[ws] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackSession(ws *wsSession) {
+	l := &s.lifecycle
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, ws)
+}
+
+// newHTTPServer creates the http.Server Listen serves, or fails once the
+// server is stopping.
+func (s *Server) newHTTPServer() (*http.Server, error) {
+	l := &s.lifecycle
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopping {
+		return nil, http.ErrServerClosed
+	}
+	s.httpServer = &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", s.config.Host, s.config.Port),
+		Handler:           s.Handler(),
+		TLSConfig:         s.config.TLS,
+		ReadTimeout:       orTimeout(s.config.ReadTimeout, s.config.Timeout),
+		ReadHeaderTimeout: orTimeout(s.config.ReadHeaderTimeout, s.config.Timeout),
+		WriteTimeout:      orTimeout(s.config.WriteTimeout, s.config.Timeout),
+		IdleTimeout:       orTimeout(s.config.IdleTimeout, s.config.Timeout),
+	}
+	return s.httpServer, nil
+}
+
+// orTimeout returns timeout, or fallback if it is zero.
+func orTimeout(timeout, fallback time.Duration) time.Duration {
+	if timeout == 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// Stop gracefully shuts the server down. It stops accepting requests,
+// closes WebSocket connections with a going-away close frame, and waits
+// for in-flight executions, cancelling their contexts once
+// Config.DrainTimeout has passed. If ctx ends first, executions are
+// cancelled at once and Stop returns ctx's error.
+//
+// Stop may be called before Listen, which then returns
+// http.ErrServerClosed, and from several goroutines, which all wait for
+// the same shutdown.
+func (s *Server) Stop(ctx context.Context) error {
+	l := &s.lifecycle
+	l.mu.Lock()
+	l.init()
+	first := !l.stopping
+	l.stopping = true
+	srv := s.httpServer
+	sessions := l.sessions
+	l.sessions = nil
+	l.mu.Unlock()
+
+	if !first {
+		select {
+		case <-l.done:
+			return l.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	l.err = s.shutdown(ctx, srv, sessions)
+	close(l.done)
+	return l.err
+}
+
+func (s *Server) shutdown(ctx context.Context, srv *http.Server, sessions map[*wsSession]struct{}) error {
+	l := &s.lifecycle
+	shutdownErr := make(chan error, 1)
+	if srv != nil {
+		go func() { shutdownErr <- srv.Shutdown(ctx) }()
+	} else {
+		shutdownErr <- nil
+	}
+	for ws := range sessions {
+		ws.conn.close(wsGoingAway, "Server is shutting down")
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		l.inflight.Wait()
+		close(drained)
+	}()
+	drain := time.NewTimer(s.config.DrainTimeout)
+	defer drain.Stop()
+	select {
+	case <-drained:
+	case <-drain.C:
+	case <-ctx.Done():
+	}
+	l.cancel()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if serr := <-shutdownErr; err == nil {
+		err = serr
+	}
+	return err
+}
+
+// ListenWithGracefulShutdown starts the server and, when one of signals
+// arrives, stops it, returning once in-flight executions have drained.
+// Without signals, it stops on os.Interrupt and SIGTERM.
+func (s *Server) ListenWithGracefulShutdown(signals ...os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	notify, stopNotify := signal.NotifyContext(context.Background(), signals...)
+	defer stopNotify()
+
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- s.Listen() }()
+
+	select {
+	case err := <-listenErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		// Stopped elsewhere: wait for the drain to finish.
+	case <-notify.Done():
+		fmt.Println("[bgql] Shutting down")
+	}
+	stopNotify()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.DrainTimeout+shutdownGrace)
+	defer cancel()
+	return s.Stop(ctx)
+}
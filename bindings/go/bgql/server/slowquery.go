@@ -0,0 +1,250 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// SlowQuery describes an operation that exceeded the slow-query threshold.
+type SlowQuery struct {
+	OperationName string
+	// Fingerprint identifies the shape of the operation: documents that
+	// differ only in formatting, aliases, argument order, or literal
+	// values share a fingerprint.
+	Fingerprint string
+	// Signature is the normalized document the fingerprint is computed from.
+	Signature  string
+	Duration   time.Duration
+	Complexity int
+	// TopResolvers lists the slowest resolvers when ResolverTimings is enabled.
+	TopResolvers []ResolverTiming
+	// VariableSizes maps each variable to the size of its JSON encoding.
+	VariableSizes map[string]int
+	// Variables holds the raw variable values only when UnsafeLogVariables is set.
+	Variables map[string]any
+}
+
+// ResolverTiming is the time spent in one resolver across an operation.
+type ResolverTiming struct {
+	Field    string
+	Calls    int
+	Duration time.Duration
+}
+
+// SlowQueryConfig configures SlowQueryMiddlewareWithConfig.
+type SlowQueryConfig struct {
+	Threshold time.Duration
+	Sink      func(SlowQuery)
+	// ResolverTimings records per-resolver durations to report the top offenders.
+	ResolverTimings bool
+	// TopResolvers limits the number of resolvers reported. Defaults to 5.
+	TopResolvers int
+	// UnsafeLogVariables includes raw variable values in the record.
+	UnsafeLogVariables bool
+}
+
+// SlowQueryMiddleware reports operations that take longer than threshold.
+func SlowQueryMiddleware(threshold time.Duration, sink func(SlowQuery)) Middleware {
+	return SlowQueryMiddlewareWithConfig(SlowQueryConfig{Threshold: threshold, Sink: sink})
+}
+
+// SlowQueryMiddlewareWithConfig reports slow operations with custom configuration.
+func SlowQueryMiddlewareWithConfig(config SlowQueryConfig) Middleware {
+	if config.TopResolvers <= 0 {
+		config.TopResolvers = 5
+	}
+
+	return func(ctx *Context, next func(*Context) *Response) *Response {
+		if config.ResolverTimings {
+			ctx.timings = &resolverTimings{fields: make(map[string]*ResolverTiming)}
+		}
+
+		start := time.Now()
+		resp := next(ctx)
+		duration := time.Since(start)
+
+		if duration < config.Threshold || config.Sink == nil || ctx.GraphQL == nil {
+			return resp
+		}
+
+		req := ctx.GraphQL
+		record := SlowQuery{
+			OperationName: req.OperationName,
+			Duration:      duration,
+			Complexity:    ctx.shared().complexity,
+			VariableSizes: make(map[string]int, len(req.Variables)),
+		}
+		if doc, err := language.Parse(req.Query); err == nil {
+			record.Signature = Signature(doc)
+			record.Fingerprint = fingerprint(record.Signature)
+			if op := doc.Operation(req.OperationName); op != nil {
+				record.OperationName = op.Name
+			}
+		}
+		for name, value := range req.Variables {
+			encoded, _ := json.Marshal(value)
+			record.VariableSizes[name] = len(encoded)
+		}
+		if config.UnsafeLogVariables {
+			record.Variables = req.Variables
+		}
+		if ctx.timings != nil {
+			record.TopResolvers = ctx.timings.top(config.TopResolvers)
+		}
+
+		config.Sink(record)
+		return resp
+	}
+}
+
+// =============================================================================
+// Fingerprinting
+// =============================================================================
+
+// Fingerprint returns a hash identifying the shape of a query, or an error
+// if it does not parse.
+func Fingerprint(query string) (string, error) {
+	doc, err := language.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	return fingerprint(Signature(doc)), nil
+}
+
+func fingerprint(signature string) string {
+	sum := sha256.Sum256([]byte(signature))
+	return hex.EncodeToString(sum[:])
+}
+
+// Signature prints doc in a normalized form: literals are replaced with
+// zero values, aliases are removed, and arguments are sorted by name.
+// Variable references are kept, so their runtime values never appear.
+func Signature(doc *language.Document) string {
+	normalized := &language.Document{}
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *language.OperationDefinition:
+			op := *def
+			op.VariableDefinitions = make([]*language.VariableDefinition, len(def.VariableDefinitions))
+			for i, v := range def.VariableDefinitions {
+				vd := *v
+				if vd.DefaultValue != nil {
+					vd.DefaultValue = hideLiteral(vd.DefaultValue)
+				}
+				vd.Directives = normalizeDirectives(v.Directives)
+				op.VariableDefinitions[i] = &vd
+			}
+			op.Directives = normalizeDirectives(def.Directives)
+			op.SelectionSet = normalizeSelections(def.SelectionSet)
+			normalized.Definitions = append(normalized.Definitions, &op)
+		case *language.FragmentDefinition:
+			frag := *def
+			frag.Directives = normalizeDirectives(def.Directives)
+			frag.SelectionSet = normalizeSelections(def.SelectionSet)
+			normalized.Definitions = append(normalized.Definitions, &frag)
+		}
+	}
+	return language.Print(normalized)
+}
+
+func normalizeSelections(set language.SelectionSet) language.SelectionSet {
+	out := make(language.SelectionSet, len(set))
+	for i, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			f := *sel
+			f.Alias = ""
+			f.Arguments = normalizeArguments(sel.Arguments)
+			f.Directives = normalizeDirectives(sel.Directives)
+			f.SelectionSet = normalizeSelections(sel.SelectionSet)
+			out[i] = &f
+		case *language.FragmentSpread:
+			s := *sel
+			s.Directives = normalizeDirectives(sel.Directives)
+			out[i] = &s
+		case *language.InlineFragment:
+			f := *sel
+			f.Directives = normalizeDirectives(sel.Directives)
+			f.SelectionSet = normalizeSelections(sel.SelectionSet)
+			out[i] = &f
+		}
+	}
+	return out
+}
+
+func normalizeArguments(args language.ArgumentList) language.ArgumentList {
+	out := make(language.ArgumentList, len(args))
+	for i, arg := range args {
+		out[i] = &language.Argument{Name: arg.Name, Value: hideLiteral(arg.Value)}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func normalizeDirectives(directives language.DirectiveList) language.DirectiveList {
+	out := make(language.DirectiveList, len(directives))
+	for i, d := range directives {
+		out[i] = &language.Directive{Name: d.Name, Arguments: normalizeArguments(d.Arguments)}
+	}
+	return out
+}
+
+// hideLiteral replaces a literal with the zero value of its kind.
+func hideLiteral(v language.Value) language.Value {
+	switch v.(type) {
+	case *language.IntValue:
+		return &language.IntValue{Value: "0"}
+	case *language.FloatValue:
+		return &language.FloatValue{Value: "0"}
+	case *language.StringValue:
+		return &language.StringValue{}
+	case *language.ListValue:
+		return &language.ListValue{}
+	case *language.ObjectValue:
+		return &language.ObjectValue{}
+	}
+	return v
+}
+
+// =============================================================================
+// Resolver Timings
+// =============================================================================
+
+// resolverTimings accumulates resolver durations for one request.
+type resolverTimings struct {
+	mu     sync.Mutex
+	fields map[string]*ResolverTiming
+}
+
+func (t *resolverTimings) record(field string, start time.Time) {
+	elapsed := time.Since(start)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := t.fields[field]
+	if entry == nil {
+		entry = &ResolverTiming{Field: field}
+		t.fields[field] = entry
+	}
+	entry.Calls++
+	entry.Duration += elapsed
+}
+
+func (t *resolverTimings) top(n int) []ResolverTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]ResolverTiming, 0, len(t.fields))
+	for _, entry := range t.fields {
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Duration > out[j].Duration })
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
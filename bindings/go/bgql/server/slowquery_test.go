@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	const base = `query Users($first: Int) { users(first: $first, role: "admin") { id name } }`
+	tests := []struct {
+		name  string
+		query string
+		same  bool
+	}{
+		{"formatting", "query Users( $first : Int )\n{\n  users( first:$first,role:\"admin\" ) {\n    id, name\n  }\n}\n# comment", true},
+		{"literals", `query Users($first: Int) { users(first: $first, role: "guest") { id name } }`, true},
+		{"argument order", `query Users($first: Int) { users(role: "admin", first: $first) { id name } }`, true},
+		{"aliases", `query Users($first: Int) { people: users(first: $first, role: "admin") { id fullName: name } }`, true},
+		{"fields", `query Users($first: Int) { users(first: $first, role: "admin") { id } }`, false},
+		{"arguments", `query Users($first: Int) { users(first: $first) { id name } }`, false},
+	}
+	want, err := Fingerprint(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Fingerprint(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == want) != tt.same {
+				t.Errorf("Fingerprint(%q) == Fingerprint(%q) is %v, want %v", tt.query, base, got == want, tt.same)
+			}
+		})
+	}
+	if _, err := Fingerprint("{ users("); err == nil {
+		t.Error("Fingerprint of a syntax error succeeded")
+	}
+}
+
+func TestSlowQueryVariables(t *testing.T) {
+	tests := []struct {
+		name   string
+		unsafe bool
+	}{
+		{"redacted", false},
+		{"unsafe", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []SlowQuery
+			s := NewBuilder().
+				Schema(`type Query { user(token: String!, id: Int): Int }`).
+				Resolver("Query", "user", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+				Build().Unwrap()
+			s.Use(SlowQueryMiddlewareWithConfig(SlowQueryConfig{
+				Sink:               func(q SlowQuery) { records = append(records, q) },
+				UnsafeLogVariables: tt.unsafe,
+			}))
+
+			body, _ := json.Marshal(Request{
+				Query:     `query User($token: String!) { user(token: $token, id: 42) }`,
+				Variables: map[string]any{"token": "s3cret"},
+			})
+			req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			record := records[0]
+			if record.OperationName != "User" || record.VariableSizes["token"] != len(`"s3cret"`) {
+				t.Errorf("record = %+v", record)
+			}
+			if strings.Contains(record.Signature, "42") || strings.Contains(record.Signature, "s3cret") {
+				t.Errorf("signature %q leaks values", record.Signature)
+			}
+			if leaked := record.Variables["token"] == "s3cret"; leaked != tt.unsafe {
+				t.Errorf("variables = %v, want raw values only when unsafe", record.Variables)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// acceptsEventStream reports whether r asks for a text/event-stream
+// response, as GraphQL over Server-Sent Events clients do.
+func acceptsEventStream(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, v := range strings.Split(value, ",") {
+			if mediaType, _, err := mime.ParseMediaType(v); err == nil && mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleSSE executes a GET or POST request over Server-Sent Events,
+// streaming each response as a next event followed by a complete event.
+// The stream ends early, cancelling the resolver context, when the client
+// disconnects.
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	var req *Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req = &Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+		if ext := query.Get("extensions"); ext != "" {
+			if err := json.Unmarshal([]byte(ext), &req.Extensions); err != nil {
+				http.Error(w, "Invalid extensions", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		reqs, batch, status, err := s.decodeRequest(w, r)
+		if err != nil {
+			writeRequestError(w, status, err)
+			return
+		}
+		defer cleanupUploads(r, reqs)
+		if batch {
+			http.Error(w, "Batched requests cannot be streamed", http.StatusBadRequest)
+			return
+		}
+		req = reqs[0]
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := NewContext(r.Context(), r)
+	stream, resp := s.subscribe(ctx, req)
+	for key, values := range ctx.ResponseHeader() {
+		w.Header()[key] = values
+	}
+	if stream == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's read and write timeouts would cut long-lived streams
+	// short; the request body has been read by now.
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	var heartbeat <-chan time.Time
+	if interval := s.config.KeepAlive; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat:
+			// A comment line keeps proxies from closing an idle stream.
+			fmt.Fprint(w, ":\n\n")
+		case resp, ok := <-stream:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				rc.Flush()
+				return
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// SubscriptionFn resolves a subscription field to a stream of events. Each
+// value received is the field's value for one event, completed against the
+// subscription's selection set; an error value is reported as a field
+// error. The stream ends when the channel is closed. ctx is cancelled when
+// the client unsubscribes or disconnects.
+type SubscriptionFn func(ctx *Context, args map[string]any) (<-chan any, error)
+
+// ConnectFn inspects the connection_init payload of a WebSocket
+// connection. Returning an error rejects the connection.
+type ConnectFn func(ctx *Context, payload map[string]any) error
+
+// Subscription registers the event stream of a field of the subscription
+// root type.
+func (b *Builder) Subscription(fieldName string, fn SubscriptionFn) *Builder {
+	b.subscriptions[fieldName] = fn
+	return b
+}
+
+// WebSocketInitFn authenticates a WebSocket connection from its
+// connection_init payload. The context.Context it returns, if not nil,
+// holds values every operation on the connection inherits, such as the
+// authenticated user. Returning an error closes the connection with code
+// 4401 if it is ErrUnauthenticated, or else 4403.
+type WebSocketInitFn func(ctx *Context, initPayload map[string]any) (context.Context, error)
+
+// OnConnect sets a hook run on connection_init, before the WebSocket
+// connection is acknowledged. See OnWebSocketInit to pass values on to the
+// connection's operations.
+func (b *Builder) OnConnect(fn ConnectFn) *Builder {
+	b.onConnect = fn
+	return b
+}
+
+// OnWebSocketInit sets a hook run on connection_init, after OnConnect and
+// before the WebSocket connection is acknowledged. Values it stores with
+// Context.Set are also copied into the Context of each operation on the
+// connection.
+func (b *Builder) OnWebSocketInit(fn WebSocketInitFn) *Builder {
+	b.onWebSocketInit = fn
+	return b
+}
+
+// checkSubscriptions reports subscriptions registered for fields the
+// subscription root type does not define.
+func checkSubscriptions(s *schema.Schema, subscriptions map[string]SubscriptionFn) language.ErrorList {
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	if s.Subscription == nil {
+		return language.ErrorList{language.NewError(nil, "subscription registered but the schema has no subscription type")}
+	}
+	return checkFieldRefs(s, "subscription", map[string]map[string]SubscriptionFn{s.Subscription.Name: subscriptions})
+}
+
+// =============================================================================
+// Execution
+// =============================================================================
+
+// subscribe starts req inside the middleware chain and returns its stream
+// of responses. Queries and mutations yield a single response. When the
+// operation cannot start, the stream is nil and the response holds the
+// errors.
+func (s *Server) subscribe(ctx *Context, req *Request) (<-chan *Response, *Response) {
+	hooks := s.extensionHooks()
+	ctx.GraphQL = req
+	ctx.Loaders = s.newLoaderStore()
+	ctxs := hooks.onRequest(ctx)
+	if len(ctxs) > 0 {
+		ctx = ctxs[len(ctxs)-1]
+	}
+	var stream <-chan *Response
+	resp := s.withMiddleware(ctx, req, func(ctx *Context) *Response {
+		var resp *Response
+		stream, resp = s.doSubscribe(ctx, req)
+		s.presentErrors(ctx, resp)
+		return resp
+	})
+	// The stream is not read until subscribe returns, so hooks may still
+	// add to the response of a query or mutation sent on it.
+	hooks.onResponse(ctx, ctxs, resp)
+	if stream == nil {
+		if resp == nil || len(resp.Errors) == 0 {
+			resp = &Response{Errors: []GraphQLError{{Message: "Subscription could not be started."}}}
+		}
+		ctx.Loaders.ClearAll()
+		return nil, resp
+	}
+	return stream, nil
+}
+
+func (s *Server) doSubscribe(ctx *Context, req *Request) (<-chan *Response, *Response) {
+	e, root, op, resp := s.prepare(ctx, req)
+	if e == nil {
+		return nil, resp
+	}
+	if op.Operation != language.Subscription {
+		e.executeOperation(root, op, resp)
+		ctx.Loaders.ClearAll()
+		out := make(chan *Response, 1)
+		out <- resp
+		close(out)
+		return out, resp
+	}
+
+	groups := e.collectFields(root, op.SelectionSet, nil)
+	if len(groups) != 1 {
+		msg := "Anonymous Subscription must select only one top level field."
+		if op.Name != "" {
+			msg = fmt.Sprintf("Subscription %q must select only one top level field.", op.Name)
+		}
+		resp.Errors = []GraphQLError{validationError(op.Loc, "%s", msg)}
+		return nil, resp
+	}
+	fields := groups[0]
+	path := []any{fields[0].ResponseKey()}
+	def := e.schema.FieldDefinition(root, fields[0].Name)
+	if def == nil {
+		e.errorf(fields, path, "Cannot query field %q on type %q.", fields[0].Name, root.Name)
+		resp.Errors = e.errors
+		return nil, resp
+	}
+	fn := s.subscriptions[def.Name]
+	if fn == nil {
+		e.errorf(fields, path, "No subscription registered for field \"%s.%s\".", root.Name, def.Name)
+		resp.Errors = e.errors
+		return nil, resp
+	}
+	args, err := e.argumentValues(def.Args, fields[0].Arguments)
+	if err != nil {
+		e.errorf(fields, path, "%s", err.Error())
+		resp.Errors = e.errors
+		return nil, resp
+	}
+	events, err := fn(ctx, args)
+	if err != nil {
+		e.fieldError(err, fields, path)
+		resp.Errors = e.errors
+		return nil, resp
+	}
+
+	out := make(chan *Response)
+	go func() {
+		defer close(out)
+		defer ctx.Loaders.ClearAll()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case value, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case out <- e.executeEvent(root, def, fields, value):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, resp
+}
+
+// executeEvent completes one event of a subscription. Each event gets a
+// fresh executor and loaders, so nothing cached for an earlier event is
+// reused.
+func (e *executor) executeEvent(root *schema.Type, def *schema.Field, fields []*language.Field, value any) *Response {
+	ctx := *e.ctx
+	ctx.Loaders = e.server.newLoaderStore()
+	defer ctx.Loaders.ClearAll()
+	ctx.errors = nil
+	ev := &executor{
+		server:    e.server,
+		schema:    e.schema,
+		resolvers: e.resolvers,
+		scalars:   e.scalars,
+		doc:       e.doc,
+		ctx:       &ctx,
+		variables: e.variables,
+		sched:     e.sched,
+
+		fieldMiddlewares: e.fieldMiddlewares,
+		hooks:            e.hooks,
+	}
+	ev.sched.start()
+	defer ev.sched.stop()
+
+	key := fields[0].ResponseKey()
+	path := []any{key}
+	data := newResultMap()
+	resp := &Response{Data: data}
+	if err, isErr := value.(error); isErr {
+		ev.fieldError(err, fields, path)
+		if def.Type.NonNull {
+			resp.Data = nil
+		} else {
+			data.set(key, nil)
+		}
+	} else if completed, ok := ev.completeValue(def.Type, root.Name+"."+def.Name, fields, value, path); ok {
+		data.set(key, completed)
+	} else {
+		resp.Data = nil
+	}
+	resp.Errors = ev.errors
+	e.server.presentErrors(ev.ctx, resp)
+	return resp
+}
+
+// =============================================================================
+// graphql-transport-ws
+// =============================================================================
+
+// wsSubprotocol is the WebSocket subprotocol spoken by the server.
+const wsSubprotocol = "graphql-transport-ws"
+
+// Close codes defined by graphql-transport-ws.
+const (
+	wsInvalidMessage      = 4400
+	wsInitTimeout         = 4408
+	wsSubscriberExists    = 4409
+	wsTooManyInitRequests = 4429
+)
+
+// wsMessage is a graphql-transport-ws protocol message.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsSession is the state of one WebSocket connection.
+type wsSession struct {
+	server  *Server
+	conn    *wsConn
+	request *http.Request
+	ctx     context.Context
+
+	mu    sync.Mutex
+	acked bool
+	ops   map[string]context.CancelFunc
+	wg    sync.WaitGroup
+	// initCtx and initData are what operations inherit from the Builder's
+	// connection_init hooks, and opCtx and opData that with the values of
+	// Config.OnWebSocketInit or OnWebSocketPing added.
+	initCtx  context.Context
+	initData map[string]any
+	opCtx    context.Context
+	opData   map[string]any
+}
+
+// handleWebSocket serves GraphQL operations, including subscriptions, over
+// a graphql-transport-ws WebSocket connection.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.checkWebSocketOrigin(w, r) {
+		return
+	}
+	conn, err := upgradeWebSocket(w, r, wsSubprotocol)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	session := &wsSession{
+		server:  s,
+		conn:    conn,
+		request: r,
+		ctx:     ctx,
+		ops:     make(map[string]context.CancelFunc),
+		initCtx: ctx,
+		opCtx:   ctx,
+	}
+	if !s.trackSession(session) {
+		conn.close(wsGoingAway, "Server is shutting down")
+		cancel()
+		return
+	}
+	defer func() {
+		s.untrackSession(session)
+		cancel()
+		session.wg.Wait()
+		conn.close(1000, "")
+	}()
+
+	if interval := s.config.KeepAlive; interval > 0 {
+		go session.keepAlive(interval)
+	}
+	if timeout := s.config.ConnectionInitTimeout; timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			session.mu.Lock()
+			acked := session.acked
+			session.mu.Unlock()
+			if !acked {
+				conn.close(wsInitTimeout, "Connection initialisation timeout")
+			}
+		})
+		defer timer.Stop()
+	}
+
+	for {
+		data, err := conn.readMessage()
+		if err != nil {
+			return
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			conn.close(wsInvalidMessage, "Invalid message received")
+			return
+		}
+		if !session.handle(&msg) {
+			return
+		}
+	}
+}
+
+// handle processes one client message. It reports false once the
+// connection has been closed.
+func (ws *wsSession) handle(msg *wsMessage) bool {
+	switch msg.Type {
+	case "connection_init":
+		ws.mu.Lock()
+		acked := ws.acked
+		ws.mu.Unlock()
+		if acked {
+			ws.conn.close(wsTooManyInitRequests, "Too many initialisation requests")
+			return false
+		}
+		var payload map[string]any
+		if len(msg.Payload) > 0 {
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				ws.conn.close(wsInvalidMessage, "Invalid message received")
+				return false
+			}
+		}
+		ctx := NewContext(ws.ctx, ws.request)
+		if fn := ws.server.onConnect; fn != nil {
+			if err := fn(ctx, payload); err != nil {
+				ws.reject(err)
+				return false
+			}
+		}
+		opCtx := ws.ctx
+		if fn := ws.server.onWebSocketInit; fn != nil {
+			values, err := fn(ctx, payload)
+			if err != nil {
+				ws.reject(err)
+				return false
+			}
+			if values != nil {
+				opCtx = connectionContext{Context: ws.ctx, values: values}
+			}
+		}
+		var values map[string]any
+		if fn := ws.server.config.OnWebSocketInit; fn != nil {
+			var err error
+			if values, err = fn(opCtx, payload); err != nil {
+				ws.reject(err)
+				return false
+			}
+		}
+		ws.mu.Lock()
+		ws.acked = true
+		ws.initCtx, ws.initData = opCtx, ctx.Data
+		ws.setValues(values)
+		ws.mu.Unlock()
+		ws.send("", "connection_ack", nil)
+	case "ping":
+		if !ws.revalidate(msg.Payload) {
+			return false
+		}
+		ws.send("", "pong", nil)
+	case "pong":
+	case "subscribe":
+		return ws.subscribe(msg)
+	case "complete":
+		ws.mu.Lock()
+		cancel := ws.ops[msg.ID]
+		delete(ws.ops, msg.ID)
+		ws.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	default:
+		ws.conn.close(wsInvalidMessage, "Invalid message received")
+		return false
+	}
+	return true
+}
+
+// subscribe starts the operation of a subscribe message, streaming its
+// responses as next messages until it completes or the client sends
+// complete.
+func (ws *wsSession) subscribe(msg *wsMessage) bool {
+	var req Request
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+		ws.conn.close(wsInvalidMessage, "Invalid message received")
+		return false
+	}
+
+	ws.mu.Lock()
+	if !ws.acked {
+		ws.mu.Unlock()
+		ws.conn.close(wsUnauthorized, "Unauthorized")
+		return false
+	}
+	if _, exists := ws.ops[msg.ID]; exists {
+		ws.mu.Unlock()
+		ws.conn.close(wsSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+		return false
+	}
+	ctx, cancel := context.WithCancel(ws.opCtx)
+	ws.ops[msg.ID] = cancel
+	data := ws.opData
+	ws.mu.Unlock()
+
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		defer cancel()
+
+		opCtx := NewContext(ctx, ws.request)
+		maps.Copy(opCtx.Data, data)
+		stream, resp := ws.server.subscribe(opCtx, &req)
+		if stream == nil {
+			if ws.finish(msg.ID) {
+				ws.send(msg.ID, "error", resp.Errors)
+			}
+			return
+		}
+		for resp := range stream {
+			ws.send(msg.ID, "next", resp)
+		}
+		if ws.finish(msg.ID) {
+			ws.send(msg.ID, "complete", nil)
+		}
+	}()
+	return true
+}
+
+// checkWebSocketOrigin rejects a WebSocket handshake from a page of
+// another origin unless CORS allows that origin: browsers let any page
+// open a WebSocket carrying the user's cookies, with no preflight. A
+// handshake without an Origin, which browsers always send, is not from a
+// browser and is let through. It reports false after rejecting r.
+func (s *Server) checkWebSocketOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if allowed, _ := s.config.CORS.allows(origin); allowed {
+		return true
+	}
+	http.Error(w, "Origin not allowed", http.StatusForbidden)
+	return false
+}
+
+// reject closes the connection after a hook returned err.
+func (ws *wsSession) reject(err error) {
+	ws.conn.close(rejection(err))
+}
+
+// setValues sets the context of later operations to the connection's with
+// values from Config.OnWebSocketInit or OnWebSocketPing. ws.mu must be
+// held.
+func (ws *wsSession) setValues(values map[string]any) {
+	ws.opCtx, ws.opData = withConnectionValues(ws.initCtx, ws.initData, values)
+}
+
+// revalidate runs Config.OnWebSocketPing for a ping with payload. It
+// reports false once it has closed the connection.
+func (ws *wsSession) revalidate(payload json.RawMessage) bool {
+	fn := ws.server.config.OnWebSocketPing
+	ws.mu.Lock()
+	acked, ctx := ws.acked, ws.opCtx
+	ws.mu.Unlock()
+	if fn == nil || !acked {
+		return true
+	}
+	var values map[string]any
+	if len(payload) > 0 && json.Unmarshal(payload, &values) != nil {
+		ws.conn.close(wsInvalidMessage, "Invalid message received")
+		return false
+	}
+	values, err := fn(ctx, values)
+	if err != nil {
+		ws.reject(err)
+		return false
+	}
+	ws.mu.Lock()
+	ws.setValues(values)
+	ws.mu.Unlock()
+	return true
+}
+
+// finish forgets operation id. It reports false when the client already
+// completed the operation, in which case nothing more is sent for it.
+func (ws *wsSession) finish(id string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if _, ok := ws.ops[id]; !ok {
+		return false
+	}
+	delete(ws.ops, id)
+	return true
+}
+
+// connectionContext is the context of a connection's operations: values
+// come from the context returned by OnWebSocketInit, or else the
+// connection's, which also ends it.
+type connectionContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c connectionContext) Value(key any) any {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// keepAlive pings the client every interval until the connection ends.
+func (ws *wsSession) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.ctx.Done():
+			return
+		case <-ticker.C:
+			if ws.send("", "ping", nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+// send writes a protocol message.
+func (ws *wsSession) send(id, typ string, payload any) error {
+	msg := wsMessage{ID: id, Type: typ}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = data
+	}
+	return ws.conn.writeJSON(msg)
+}
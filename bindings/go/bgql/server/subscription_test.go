@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// newWhoamiServer serves a subscription whoami whose single event
+// describes what the operation's context holds.
+func newWhoamiServer(t *testing.T, config Config) *httptest.Server {
+	t.Helper()
+	s := NewBuilder().
+		Config(config).
+		Schema(`type Query { a: Int } type Subscription { whoami: String }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		Subscription("whoami", func(ctx *Context, args map[string]any) (<-chan any, error) {
+			user, _ := sdk.CurrentUserID.Get(ctx)
+			roles, _ := sdk.UserRoles.Get(ctx)
+			ch := make(chan any, 1)
+			ch <- fmt.Sprintf("%s %s %v", ctx.GetString("tenant"), user, roles)
+			close(ch)
+			return ch, nil
+		}).
+		Build().Unwrap()
+	hs := httptest.NewServer(http.HandlerFunc(s.handleGraphQL))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+// whoami runs the whoami subscription on c and returns its event.
+func whoami(c *wsTestConn, id string) string {
+	c.t.Helper()
+	c.send(`{"id":%q,"type":"subscribe","payload":{"query":"subscription { whoami }"}}`, id)
+	msg := c.expect("next")
+	c.expect("complete")
+	var resp struct {
+		Data struct{ Whoami string }
+	}
+	if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+		c.t.Fatal(err)
+	}
+	return resp.Data.Whoami
+}
+
+func TestWebSocketInitRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"unauthenticated", ErrUnauthenticated, wsUnauthorized},
+		{"wrapped unauthenticated", fmt.Errorf("bad token: %w", ErrUnauthenticated), wsUnauthorized},
+		{"forbidden", errors.New("not allowed"), wsForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.OnWebSocketInit = func(ctx context.Context, payload map[string]any) (map[string]any, error) {
+				return nil, tt.err
+			}
+			c := dialTestWebSocket(t, newWhoamiServer(t, config), nil)
+			c.send(`{"type":"connection_init","payload":{"token":"x"}}`)
+			if msg, code := c.next(); code != tt.want {
+				t.Fatalf("got message %q, close code %d, want close code %d", msg.Type, code, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketInitValues(t *testing.T) {
+	config := DefaultConfig()
+	config.OnWebSocketInit = func(ctx context.Context, payload map[string]any) (map[string]any, error) {
+		if payload["token"] != "secret" {
+			return nil, ErrUnauthenticated
+		}
+		return map[string]any{
+			"tenant":        "acme",
+			"CurrentUserID": "u1",
+			"UserRoles":     []any{"admin", "dev"},
+		}, nil
+	}
+	c := dialTestWebSocket(t, newWhoamiServer(t, config), nil)
+	c.send(`{"type":"connection_init","payload":{"token":"secret"}}`)
+	c.expect("connection_ack")
+
+	if got, want := whoami(c, "1"), "acme u1 [admin dev]"; got != want {
+		t.Errorf("whoami = %q, want %q", got, want)
+	}
+}
+
+func TestWebSocketPingRevalidates(t *testing.T) {
+	config := DefaultConfig()
+	config.OnWebSocketInit = func(ctx context.Context, payload map[string]any) (map[string]any, error) {
+		return map[string]any{"CurrentUserID": "u1"}, nil
+	}
+	config.OnWebSocketPing = func(ctx context.Context, payload map[string]any) (map[string]any, error) {
+		if user, _ := sdk.CurrentUserID.Get(ctx); user != "u1" && user != "u2" {
+			return nil, fmt.Errorf("unexpected user %q", user)
+		}
+		switch payload["token"] {
+		case "refreshed":
+			return map[string]any{"CurrentUserID": "u2"}, nil
+		case "expired":
+			return nil, ErrUnauthenticated
+		}
+		return nil, errors.New("no token")
+	}
+	c := dialTestWebSocket(t, newWhoamiServer(t, config), nil)
+	c.send(`{"type":"connection_init"}`)
+	c.expect("connection_ack")
+
+	c.send(`{"type":"ping","payload":{"token":"refreshed"}}`)
+	c.expect("pong")
+	if got := whoami(c, "1"); !strings.Contains(got, "u2") {
+		t.Errorf("whoami after revalidation = %q, want user u2", got)
+	}
+
+	c.send(`{"type":"ping","payload":{"token":"expired"}}`)
+	if msg, code := c.next(); code != wsUnauthorized {
+		t.Fatalf("got message %q, close code %d, want %d", msg.Type, code, wsUnauthorized)
+	}
+}
+
+func TestOnConnectRejected(t *testing.T) {
+	s := NewBuilder().
+		Schema(`type Query { a: Int } type Subscription { a: Int }`).
+		Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+		OnConnect(func(ctx *Context, payload map[string]any) error { return ErrUnauthenticated }).
+		Build().Unwrap()
+	hs := httptest.NewServer(http.HandlerFunc(s.handleGraphQL))
+	defer hs.Close()
+
+	c := dialTestWebSocket(t, hs, nil)
+	c.send(`{"type":"connection_init"}`)
+	if _, code := c.next(); code != wsUnauthorized {
+		t.Fatalf("close code = %d, want %d", code, wsUnauthorized)
+	}
+}
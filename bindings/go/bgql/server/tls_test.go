@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSignedCert returns a certificate for 127.0.0.1 in PEM form.
+func selfSignedCert(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bgql test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// freePort returns a port nothing is listening on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestListenTLS(t *testing.T) {
+	certPEM, keyPEM := selfSignedCert(t)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tls    *tls.Config
+		listen func(s *Server) error
+	}{
+		{"Config.TLS", &tls.Config{Certificates: []tls.Certificate{cert}}, (*Server).Listen},
+		{"ListenTLS", nil, func(s *Server) error { return s.ListenTLS(certFile, keyFile) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Host, config.Port = "127.0.0.1", freePort(t)
+			config.TLS = tt.tls
+			s := NewBuilder().
+				Config(config).
+				Schema(`type Query { a: Int }`).
+				Resolver("Query", "a", func(ctx *Context, p any, a map[string]any) (any, error) { return 1, nil }).
+				Build().Unwrap()
+			done := make(chan error, 1)
+			go func() { done <- tt.listen(s) }()
+
+			roots := x509.NewCertPool()
+			roots.AppendCertsFromPEM(certPEM)
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: roots},
+				ForceAttemptHTTP2: true,
+			}}
+			url := "https://" + net.JoinHostPort(config.Host, strconv.Itoa(config.Port)) + "/graphql"
+
+			var (
+				resp *http.Response
+				err  error
+			)
+			for deadline := time.Now().Add(5 * time.Second); ; {
+				resp, err = client.Post(url, "application/json", strings.NewReader(`{"query":"{ a }"}`))
+				if err == nil || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatalf("POST %s: %v", url, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.ProtoMajor != 2 {
+				t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+			}
+			if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"data":{"a":1}`) {
+				t.Errorf("status %d: %s", resp.StatusCode, body)
+			}
+
+			if err := s.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("listen returned %v, want ErrServerClosed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/ubugeeei/bgql/bindings/go/bgql/server/tracing
+
+go 1.23
+
+require (
+	github.com/ubugeeei/bgql/bindings/go/bgql v0.1.0
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/ubugeeei/bgql/sdk v0.1.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.35.0 // indirect
+	golang.org/x/sync v0.6.0 // indirect
+)
@@ -0,0 +1,154 @@
+// Package tracing creates OpenTelemetry spans for requests and resolvers
+// of a bgql server. It is a separate module so that servers without
+// tracing do not depend on OpenTelemetry.
+package tracing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/server"
+)
+
+// instrumentationName identifies the tracer.
+const instrumentationName = "github.com/ubugeeei/bgql/bindings/go/bgql/server/tracing"
+
+// Span attributes.
+const (
+	OperationNameKey = attribute.Key("graphql.operation.name")
+	OperationTypeKey = attribute.Key("graphql.operation.type")
+	DocumentHashKey  = attribute.Key("graphql.document.hash")
+	FieldPathKey     = attribute.Key("graphql.field.path")
+	FieldNameKey     = attribute.Key("graphql.field.name")
+	ParentTypeKey    = attribute.Key("graphql.field.parent_type")
+)
+
+// Option configures tracing.
+type Option func(*config)
+
+type config struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the tracer provider. The global provider is used
+// by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) { c.provider = provider }
+}
+
+// WithPropagator sets how the incoming trace context, such as a
+// traceparent header, is extracted. The global propagator is used by
+// default.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *config) { c.propagator = propagator }
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{provider: otel.GetTracerProvider(), propagator: otel.GetTextMapPropagator()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Instrument adds the tracing extension to s.
+func Instrument(s *server.Server, opts ...Option) *server.Server {
+	return s.UseExtension(Extension(opts...))
+}
+
+// Extension creates a server span per request, continuing the trace of the
+// incoming HTTP request, and a child span per resolver call. Fields
+// without a registered resolver are not traced. Register it with
+// Builder.Extension, or with Instrument.
+func Extension(opts ...Option) server.Extension {
+	c := newConfig(opts)
+	return &extension{config: c, tracer: c.provider.Tracer(instrumentationName)}
+}
+
+type extension struct {
+	config *config
+	tracer trace.Tracer
+}
+
+// OnRequest starts the request span. Resolvers see it through the request
+// context.
+func (x *extension) OnRequest(ctx *server.Context) *server.Context {
+	parent := ctx.Context
+	if ctx.Request != nil {
+		parent = x.config.propagator.Extract(parent, propagation.HeaderCarrier(ctx.Request.Header))
+	}
+	spanCtx, _ := x.tracer.Start(parent, "graphql", trace.WithSpanKind(trace.SpanKindServer))
+	return ctx.WithContext(spanCtx)
+}
+
+func (x *extension) OnResponse(ctx *server.Context, resp *server.Response) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if req := ctx.GraphQL; req != nil && req.Query != "" {
+		sum := sha256.Sum256([]byte(req.Query))
+		span.SetAttributes(DocumentHashKey.String(hex.EncodeToString(sum[:])))
+	}
+	if op := ctx.Operation(); op != nil {
+		name := string(op.Operation)
+		span.SetAttributes(OperationTypeKey.String(string(op.Operation)))
+		if op.Name != "" {
+			span.SetAttributes(OperationNameKey.String(op.Name))
+			name += " " + op.Name
+		}
+		span.SetName(name)
+	}
+	if resp != nil && len(resp.Errors) > 0 {
+		for _, err := range resp.Errors {
+			span.RecordError(err)
+		}
+		span.SetStatus(codes.Error, resp.Errors[0].Message)
+	}
+}
+
+func (x *extension) OnFieldStart(ctx *server.Context, info server.FieldInfo) *server.Context {
+	if info.Default {
+		return ctx
+	}
+	spanCtx, _ := x.tracer.Start(ctx, info.ParentType+"."+info.Field, trace.WithAttributes(
+		FieldPathKey.String(formatPath(info.Path)),
+		FieldNameKey.String(info.Field),
+		ParentTypeKey.String(info.ParentType),
+	))
+	return ctx.WithContext(spanCtx)
+}
+
+func (x *extension) OnFieldEnd(ctx *server.Context, info server.FieldInfo, value any, err error) {
+	if info.Default {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// formatPath formats a response path as "user.friends.0.name".
+func formatPath(path []any) string {
+	segments := make([]string, len(path))
+	for i, segment := range path {
+		switch s := segment.(type) {
+		case string:
+			segments[i] = s
+		case int:
+			segments[i] = strconv.Itoa(s)
+		}
+	}
+	return strings.Join(segments, ".")
+}
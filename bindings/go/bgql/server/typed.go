@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// TypedResolver registers a typed sdk resolver for a field. The arguments
+// are decoded into TArgs by DecodeArgs, and the parent value is
+// converted to TParent. sdk errors keep their code and extensions.
+//
+// The resolver's info carries the field and parent type names.
+func TypedResolver[TParent, TArgs, TResult any](b *Builder, typeName, fieldName string, fn sdk.ResolverFn[TParent, TArgs, TResult]) *Builder {
+	info := sdk.ResolverInfo{FieldName: fieldName, ParentType: typeName}
+	if b.results[typeName] == nil {
+		b.results[typeName] = make(map[string]reflect.Type)
+	}
+	b.results[typeName][fieldName] = reflect.TypeFor[TResult]()
+	return b.Resolver(typeName, fieldName, func(ctx *Context, parent any, args map[string]any) (any, error) {
+		p, err := convertParent[TParent](parent)
+		if err != nil {
+			return nil, err
+		}
+		a, err := DecodeArgs[TArgs](ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		value, err := fn(ctx, p, a, info)
+		if err != nil {
+			return nil, adaptError(err)
+		}
+		return value, nil
+	})
+}
+
+// convertParent converts the parent value of a field to T: a nil parent,
+// as for root fields, is the zero value, a pointer is dereferenced, and a
+// map is decoded into a struct.
+func convertParent[T any](parent any) (T, error) {
+	var out T
+	if parent == nil {
+		return out, nil
+	}
+	if p, ok := parent.(T); ok {
+		return p, nil
+	}
+	rv := reflect.ValueOf(parent)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		if p, ok := rv.Elem().Interface().(T); ok {
+			return p, nil
+		}
+	}
+	if err := (decoder{}).assign(reflect.ValueOf(&out).Elem(), parent, ""); err != nil {
+		return out, fmt.Errorf("parent value of type %T cannot be used as %s", parent, reflect.TypeFor[T]())
+	}
+	return out, nil
+}
+
+// adaptError converts sdk errors into GraphQLErrors, keeping their code
+// and extensions. Other errors are returned unchanged.
+func adaptError(err error) error {
+	var sdkErr *sdk.SdkError
+	if errors.As(err, &sdkErr) {
+		extensions := make(map[string]any, len(sdkErr.Extensions)+1)
+		for key, value := range sdkErr.Extensions {
+			extensions[key] = value
+		}
+		extensions["code"] = string(sdkErr.Code)
+		return GraphQLError{Message: sdkErr.Message, Extensions: extensions, cause: err}
+	}
+	var gqlErr *sdk.GraphQLError
+	if errors.As(err, &gqlErr) {
+		adapted := GraphQLError{Message: gqlErr.Message, Path: gqlErr.Path, Extensions: gqlErr.Extensions, cause: err}
+		for _, loc := range gqlErr.Locations {
+			adapted.Locations = append(adapted.Locations, Location{Line: loc.Line, Column: loc.Column})
+		}
+		return adapted
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+)
+
+// Upload is a file sent with a multipart request. Resolvers receive it for
+// arguments of the Upload scalar.
+type Upload struct {
+	Filename    string
+	Size        int64
+	ContentType string
+	// File reads the file's contents. It is closed once the request has
+	// been executed.
+	File io.ReadCloser
+}
+
+// UploadScalar accepts the files of multipart requests, following the
+// GraphQL multipart request spec. Uploads cannot be returned by resolvers.
+var UploadScalar = ScalarConfig{
+	Serialize: func(value any) (any, error) {
+		return nil, errors.New("Upload cannot be used as an output type")
+	},
+	ParseValue: func(value any) (any, error) {
+		upload, ok := value.(Upload)
+		if !ok {
+			return nil, fmt.Errorf("Upload cannot represent value: %s; send it as a multipart file", jsonString(value))
+		}
+		return upload, nil
+	},
+	ParseLiteral: func(value language.Value) (any, error) {
+		return nil, errors.New("Upload cannot be written inline; pass it through a variable")
+	},
+}
+
+// multipartMemory is how much of a multipart request is held in memory;
+// larger files are stored in temporary files.
+const multipartMemory = 32 << 20
+
+// decodeMultipartRequest reads the operations and map fields of a
+// multipart upload request and splices the files into the variables at
+// the mapped paths.
+func (s *Server) decodeMultipartRequest(w http.ResponseWriter, r *http.Request) (reqs []*Request, batch bool, status int, err error) {
+	limit := s.config.MaxUploadSize
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, false, http.StatusRequestEntityTooLarge, GraphQLError{
+				Message:    fmt.Sprintf("Request body exceeds the maximum upload size of %d bytes.", limit),
+				Extensions: map[string]any{"code": "PAYLOAD_TOO_LARGE", "maxUploadSize": limit},
+			}
+		}
+		return nil, false, http.StatusBadRequest, errors.New("Invalid multipart request")
+	}
+
+	form := r.MultipartForm
+	if len(form.Value["operations"]) != 1 {
+		return nil, false, http.StatusBadRequest, errors.New(`Missing multipart field "operations"`)
+	}
+	reqs, batch, status, err = decodeJSONRequest([]byte(form.Value["operations"][0]))
+	if err != nil {
+		return nil, batch, status, err
+	}
+
+	var fileMap map[string][]string
+	if len(form.Value["map"]) != 1 || json.Unmarshal([]byte(form.Value["map"][0]), &fileMap) != nil {
+		return nil, batch, http.StatusBadRequest, errors.New(`Missing or invalid multipart field "map"`)
+	}
+	for _, key := range slices.Sorted(maps.Keys(fileMap)) {
+		files := form.File[key]
+		if len(files) == 0 {
+			cleanupUploads(r, reqs)
+			return nil, batch, http.StatusBadRequest, fmt.Errorf("File %q is missing from the request", key)
+		}
+		for _, path := range fileMap[key] {
+			// Each path gets its own reader of the file.
+			file, err := files[0].Open()
+			if err != nil {
+				cleanupUploads(r, reqs)
+				return nil, batch, http.StatusBadRequest, fmt.Errorf("File %q cannot be read", key)
+			}
+			upload := Upload{
+				Filename:    files[0].Filename,
+				Size:        files[0].Size,
+				ContentType: files[0].Header.Get("Content-Type"),
+				File:        file,
+			}
+			if !spliceUpload(reqs, batch, path, upload) {
+				file.Close()
+				cleanupUploads(r, reqs)
+				return nil, batch, http.StatusBadRequest, fmt.Errorf("Invalid path %q for file %q", path, key)
+			}
+		}
+	}
+	return reqs, batch, http.StatusOK, nil
+}
+
+// spliceUpload sets the value at a map path such as "variables.file", or
+// "0.variables.files.1" in a batch, to upload.
+func spliceUpload(reqs []*Request, batch bool, path string, upload Upload) bool {
+	segments := strings.Split(path, ".")
+	req := reqs[0]
+	if batch {
+		i, err := strconv.Atoi(segments[0])
+		if err != nil || i < 0 || i >= len(reqs) {
+			return false
+		}
+		req, segments = reqs[i], segments[1:]
+	}
+	if len(segments) < 2 || segments[0] != "variables" {
+		return false
+	}
+	if req.Variables == nil {
+		req.Variables = make(map[string]any)
+	}
+
+	var container any = req.Variables
+	for i, segment := range segments[1:] {
+		last := i == len(segments)-2
+		switch c := container.(type) {
+		case map[string]any:
+			if last {
+				c[segment] = upload
+				return true
+			}
+			container = c[segment]
+		case []any:
+			n, err := strconv.Atoi(segment)
+			if err != nil || n < 0 || n >= len(c) {
+				return false
+			}
+			if last {
+				c[n] = upload
+				return true
+			}
+			container = c[n]
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// cleanupUploads closes the files spliced into reqs and removes the
+// temporary files of a multipart request.
+func cleanupUploads(r *http.Request, reqs []*Request) {
+	if r.MultipartForm == nil {
+		return
+	}
+	var closeAll func(value any)
+	closeAll = func(value any) {
+		switch v := value.(type) {
+		case Upload:
+			v.File.Close()
+		case map[string]any:
+			for _, item := range v {
+				closeAll(item)
+			}
+		case []any:
+			for _, item := range v {
+				closeAll(item)
+			}
+		}
+	}
+	for _, req := range reqs {
+		closeAll(req.Variables)
+	}
+	r.MultipartForm.RemoveAll()
+}
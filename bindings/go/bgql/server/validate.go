@@ -0,0 +1,264 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// validate checks an operation before it is executed. Any error returned
+// prevents execution entirely, so the response carries no data.
+func (e *executor) validate(root *schema.Type, op *language.OperationDefinition) []GraphQLError {
+	errs := e.checkFragments()
+	if len(errs) > 0 {
+		// Depth and introspection checks assume every spread resolves.
+		return errs
+	}
+	if !e.introspectionAllowed() {
+		errs = append(errs, e.checkIntrospection(op.SelectionSet, make(map[string]bool))...)
+	}
+	if err := e.checkDepth(op); err != nil {
+		errs = append(errs, *err)
+	}
+	return errs
+}
+
+// checkFragments reports spreads of unknown fragments, type conditions
+// naming unknown types, and fragments that spread themselves.
+func (e *executor) checkFragments() []GraphQLError {
+	var errs []GraphQLError
+	checkSet := func(set language.SelectionSet) {
+		walkSelections(set, func(sel language.Selection) {
+			switch sel := sel.(type) {
+			case *language.FragmentSpread:
+				if e.doc.Fragment(sel.Name) == nil {
+					errs = append(errs, validationError(sel.Loc, "Unknown fragment %q.", sel.Name))
+				}
+			case *language.InlineFragment:
+				if sel.TypeCondition != "" && e.schema.Type(sel.TypeCondition) == nil {
+					errs = append(errs, validationError(sel.Loc, "Unknown type %q.", sel.TypeCondition))
+				}
+			}
+		})
+	}
+
+	for _, op := range e.doc.Operations() {
+		checkSet(op.SelectionSet)
+	}
+	for _, frag := range e.doc.Fragments() {
+		if e.schema.Type(frag.TypeCondition) == nil {
+			errs = append(errs, validationError(frag.Loc, "Unknown type %q.", frag.TypeCondition))
+		}
+		checkSet(frag.SelectionSet)
+	}
+	return append(errs, e.checkFragmentCycles()...)
+}
+
+// checkFragmentCycles reports each cycle of fragment spreads once, by a
+// depth-first search over the spreads of every fragment.
+func (e *executor) checkFragmentCycles() []GraphQLError {
+	var errs []GraphQLError
+	done := make(map[string]bool)
+	var path []*language.FragmentSpread
+	onPath := make(map[string]int)
+
+	var visit func(frag *language.FragmentDefinition)
+	visit = func(frag *language.FragmentDefinition) {
+		if done[frag.Name] {
+			return
+		}
+		done[frag.Name] = true
+		onPath[frag.Name] = len(path)
+
+		walkSelections(frag.SelectionSet, func(sel language.Selection) {
+			spread, ok := sel.(*language.FragmentSpread)
+			if !ok {
+				return
+			}
+			start, cyclic := onPath[spread.Name]
+			path = append(path, spread)
+			if cyclic {
+				var via []string
+				for _, s := range path[start : len(path)-1] {
+					via = append(via, strconv.Quote(s.Name))
+				}
+				msg := fmt.Sprintf("Cannot spread fragment %q within itself", spread.Name)
+				if len(via) > 0 {
+					msg += " via " + strings.Join(via, ", ")
+				}
+				errs = append(errs, validationError(path[start].Loc, "%s.", msg))
+			} else if next := e.doc.Fragment(spread.Name); next != nil {
+				visit(next)
+			}
+			path = path[:len(path)-1]
+		})
+
+		delete(onPath, frag.Name)
+	}
+
+	for _, frag := range e.doc.Fragments() {
+		visit(frag)
+	}
+	return errs
+}
+
+// walkSelections calls fn for every selection within set, without
+// following fragment spreads.
+func walkSelections(set language.SelectionSet, fn func(language.Selection)) {
+	for _, sel := range set {
+		fn(sel)
+		switch sel := sel.(type) {
+		case *language.Field:
+			walkSelections(sel.SelectionSet, fn)
+		case *language.InlineFragment:
+			walkSelections(sel.SelectionSet, fn)
+		}
+	}
+}
+
+// checkArguments coerces the arguments of every field selected and of the
+// directives on selections, so invalid arguments are reported before
+// execution rather than as field errors. It needs the coerced variables.
+func (e *executor) checkArguments(t *schema.Type, set language.SelectionSet, visited map[string]bool) []GraphQLError {
+	var errs []GraphQLError
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			errs = append(errs, e.checkDirectiveArguments(sel.Directives)...)
+			def := e.schema.FieldDefinition(t, sel.Name)
+			if def == nil {
+				continue
+			}
+			for _, arg := range sel.Arguments {
+				argDef := def.Arg(arg.Name)
+				if argDef == nil {
+					errs = append(errs, validationError(arg.Loc, "Unknown argument %q on field \"%s.%s\".", arg.Name, t.Name, def.Name))
+					continue
+				}
+				if _, _, err := e.coerceLiteral(argDef.Type, arg.Value, nil); err != nil {
+					errs = append(errs, validationError(arg.Loc, "%s", err.argumentError(arg.Name).Error()))
+				}
+			}
+			for _, argDef := range def.Args {
+				if argDef.Type.NonNull && argDef.DefaultValue == nil && sel.Arguments.ForName(argDef.Name) == nil {
+					errs = append(errs, validationError(sel.Loc, "Field \"%s.%s\" argument %q of type %q is required, but it was not provided.",
+						t.Name, def.Name, argDef.Name, argDef.Type.String()))
+				}
+			}
+			if child := e.schema.Type(def.Type.Name()); child != nil {
+				errs = append(errs, e.checkArguments(child, sel.SelectionSet, visited)...)
+			}
+		case *language.InlineFragment:
+			errs = append(errs, e.checkDirectiveArguments(sel.Directives)...)
+			errs = append(errs, e.checkArguments(e.conditionType(t, sel.TypeCondition), sel.SelectionSet, visited)...)
+		case *language.FragmentSpread:
+			errs = append(errs, e.checkDirectiveArguments(sel.Directives)...)
+			frag := e.doc.Fragment(sel.Name)
+			if frag == nil || visited[sel.Name] {
+				continue
+			}
+			visited[sel.Name] = true
+			errs = append(errs, e.checkArguments(e.conditionType(t, frag.TypeCondition), frag.SelectionSet, visited)...)
+		}
+	}
+	return errs
+}
+
+// checkDirectiveArguments coerces the arguments of the directives the
+// schema declares, such as the condition of @skip and @include.
+func (e *executor) checkDirectiveArguments(directives language.DirectiveList) []GraphQLError {
+	var errs []GraphQLError
+	for _, d := range directives {
+		def := e.schema.Directive(d.Name)
+		if def == nil {
+			continue
+		}
+		for _, arg := range d.Arguments {
+			argDef := def.Arg(arg.Name)
+			if argDef == nil {
+				errs = append(errs, validationError(arg.Loc, "Unknown argument %q on directive \"@%s\".", arg.Name, d.Name))
+				continue
+			}
+			if _, _, err := e.coerceLiteral(argDef.Type, arg.Value, nil); err != nil {
+				errs = append(errs, validationError(arg.Loc, "%s", err.argumentError(arg.Name).Error()))
+			}
+		}
+		for _, argDef := range def.Args {
+			if argDef.Type.NonNull && argDef.DefaultValue == nil && d.Arguments.ForName(argDef.Name) == nil {
+				errs = append(errs, validationError(d.Loc, "Directive \"@%s\" argument %q of type %q is required, but it was not provided.",
+					d.Name, argDef.Name, argDef.Type.String()))
+			}
+		}
+	}
+	return errs
+}
+
+// checkDepth enforces Config.MaxDepth.
+func (e *executor) checkDepth(op *language.OperationDefinition) *GraphQLError {
+	limit := e.server.config.MaxDepth
+	if limit <= 0 {
+		return nil
+	}
+	depth := e.depth(op.SelectionSet, make(map[string]bool))
+	if depth <= limit {
+		return nil
+	}
+	err := validationError(op.Loc, "Query depth %d exceeds the maximum depth of %d.", depth, limit)
+	err.Extensions = map[string]any{
+		"code":     "MAX_DEPTH_EXCEEDED",
+		"depth":    depth,
+		"maxDepth": limit,
+	}
+	return &err
+}
+
+// introspectionAllowed applies Config.IntrospectionPolicy to the request,
+// or else Config.Introspection.
+func (e *executor) introspectionAllowed() bool {
+	if policy := e.server.config.IntrospectionPolicy; policy != nil {
+		return policy(e.ctx)
+	}
+	return e.server.config.Introspection
+}
+
+// checkIntrospection rejects __schema and __type selections, which are
+// only allowed when introspection is enabled for the request. __typename
+// is always allowed.
+func (e *executor) checkIntrospection(set language.SelectionSet, visited map[string]bool) []GraphQLError {
+	var errs []GraphQLError
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *language.Field:
+			if sel.Name == "__schema" || sel.Name == "__type" {
+				err := validationError(sel.Loc,
+					"GraphQL introspection has been disabled, but the requested query contained the field %q.", sel.Name)
+				err.Extensions = map[string]any{"code": "INTROSPECTION_DISABLED"}
+				errs = append(errs, err)
+				continue
+			}
+			errs = append(errs, e.checkIntrospection(sel.SelectionSet, visited)...)
+		case *language.InlineFragment:
+			errs = append(errs, e.checkIntrospection(sel.SelectionSet, visited)...)
+		case *language.FragmentSpread:
+			frag := e.doc.Fragment(sel.Name)
+			if frag == nil || visited[sel.Name] {
+				continue
+			}
+			visited[sel.Name] = true
+			errs = append(errs, e.checkIntrospection(frag.SelectionSet, visited)...)
+		}
+	}
+	return errs
+}
+
+// validationError creates an error located at loc.
+func validationError(loc language.Location, format string, args ...any) GraphQLError {
+	err := GraphQLError{Message: fmt.Sprintf(format, args...)}
+	if loc.Line > 0 {
+		err.Locations = []Location{{Line: loc.Line, Column: loc.Column}}
+	}
+	return err
+}
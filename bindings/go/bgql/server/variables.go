@@ -0,0 +1,369 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ubugeeei/bgql/bindings/go/bgql/language"
+	"github.com/ubugeeei/bgql/bindings/go/bgql/schema"
+)
+
+// coerceVariables coerces the request's variables to the types the
+// operation declares, applying defaults. Variables that are neither
+// provided nor defaulted are left out, so resolvers can tell them apart
+// from an explicit null.
+func (e *executor) coerceVariables(op *language.OperationDefinition, inputs map[string]any) (map[string]any, []GraphQLError) {
+	coerced := make(map[string]any, len(op.VariableDefinitions))
+	var errs []GraphQLError
+	for _, def := range op.VariableDefinitions {
+		name := def.Variable
+		t := e.schema.Type(def.Type.Name())
+		if t == nil || !t.IsInputType() {
+			errs = append(errs, validationError(def.Loc, "Variable \"$%s\" cannot be non-input type %q.", name, def.Type.String()))
+			continue
+		}
+
+		value, provided := inputs[name]
+		switch {
+		case !provided && def.DefaultValue != nil:
+			v, _, err := e.coerceLiteral(def.Type, def.DefaultValue, nil)
+			if err != nil {
+				errs = append(errs, validationError(def.Loc, "Variable \"$%s\" has an invalid default value: %s", name, err.message))
+				continue
+			}
+			coerced[name] = v
+		case def.Type.NonNull && !provided:
+			errs = append(errs, validationError(def.Loc, "Variable \"$%s\" of required type %q was not provided.", name, def.Type.String()))
+		case def.Type.NonNull && value == nil:
+			errs = append(errs, validationError(def.Loc, "Variable \"$%s\" of non-null type %q must not be null.", name, def.Type.String()))
+		case provided:
+			v, err := e.coerceInput(def.Type, value, nil)
+			if err != nil {
+				errs = append(errs, validationError(def.Loc, "Variable \"$%s\" got invalid value %s%s; %s",
+					name, err.value, err.at(name), err.message))
+				continue
+			}
+			coerced[name] = v
+		}
+	}
+	return coerced, errs
+}
+
+// inputError is a coercion failure at a path within an input value.
+type inputError struct {
+	path []any
+	// value is the offending value as JSON, or as GraphQL for literals.
+	value   string
+	message string
+}
+
+func newInputError(path []any, value any, format string, args ...any) *inputError {
+	return &inputError{path: path, value: jsonString(value), message: fmt.Sprintf(format, args...)}
+}
+
+func newLiteralError(path []any, value language.Value, format string, args ...any) *inputError {
+	return &inputError{path: path, value: language.Print(value), message: fmt.Sprintf(format, args...)}
+}
+
+// at formats the path within the input called name, or "" at the top level.
+func (e *inputError) at(name string) string {
+	if len(e.path) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(" at \"" + name)
+	for _, p := range e.path {
+		if i, ok := p.(int); ok {
+			fmt.Fprintf(&sb, "[%d]", i)
+		} else {
+			fmt.Fprintf(&sb, ".%s", p)
+		}
+	}
+	sb.WriteString("\"")
+	return sb.String()
+}
+
+// argumentError reports the failure as an invalid value of argument name.
+func (e *inputError) argumentError(name string) error {
+	return fmt.Errorf("Argument %q has invalid value %s%s: %s", name, e.value, e.at(name), e.message)
+}
+
+// coerceInput coerces a JSON input value to t, following the spec's input
+// coercion rules.
+func (e *executor) coerceInput(t *language.Type, value any, path []any) (any, *inputError) {
+	if value == nil {
+		if t.NonNull {
+			return nil, newInputError(path, value, "Expected non-nullable type %q not to be null.", t.String())
+		}
+		return nil, nil
+	}
+
+	if t.Elem != nil {
+		items, ok := value.([]any)
+		if !ok {
+			// A single value is coerced to a list of one.
+			item, err := e.coerceInput(t.Elem, value, path)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			v, err := e.coerceInput(t.Elem, item, append(path[:len(path):len(path)], i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+
+	named := e.schema.Type(t.NamedType)
+	switch named.Kind {
+	case schema.InputObject:
+		return e.coerceInputObject(named, value, path)
+	case schema.Enum:
+		v, err := e.enumInput(named, value)
+		if err != nil {
+			return nil, newInputError(path, value, "%s", err.Error())
+		}
+		return v, nil
+	}
+
+	v, err := e.parseScalar(named, value)
+	if err != nil {
+		return nil, newInputError(path, value, "%s", err.Error())
+	}
+	return v, nil
+}
+
+// parseScalar parses an input value with the ParseValue function
+// registered for t, or the specified scalar rules.
+func (e *executor) parseScalar(t *schema.Type, value any) (any, error) {
+	if sc, ok := e.scalars[t.Name]; ok && sc.ParseValue != nil {
+		return sc.ParseValue(value)
+	}
+	return coerceScalar(t, value)
+}
+
+func (e *executor) coerceInputObject(t *schema.Type, value any, path []any) (any, *inputError) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, newInputError(path, value, "Expected type %q to be an object.", t.Name)
+	}
+	for name := range fields {
+		if t.InputField(name) == nil {
+			return nil, newInputError(path, value, "Field %q is not defined by type %q.", name, t.Name)
+		}
+	}
+
+	out := make(map[string]any, len(fields))
+	for _, def := range t.InputFields {
+		fieldValue, provided := fields[def.Name]
+		switch {
+		case !provided && def.DefaultValue != nil:
+			v, _, err := e.coerceLiteral(def.Type, def.DefaultValue, nil)
+			if err != nil {
+				return nil, newInputError(path, value, "%s", err.message)
+			}
+			out[def.Name] = v
+		case !provided && def.Type.NonNull:
+			return nil, newInputError(path, value, "Field \"%s.%s\" of required type %q was not provided.", t.Name, def.Name, def.Type.String())
+		case provided:
+			v, err := e.coerceInput(def.Type, fieldValue, append(path[:len(path):len(path)], def.Name))
+			if err != nil {
+				return nil, err
+			}
+			out[def.Name] = v
+		}
+	}
+	return out, nil
+}
+
+// coerceLiteral coerces an argument or default value literal to t.
+// Variables within it are replaced by their already coerced values; the
+// bool result is false when v is a variable that was not provided.
+func (e *executor) coerceLiteral(t *language.Type, v language.Value, path []any) (any, bool, *inputError) {
+	if ref, ok := v.(*language.Variable); ok {
+		value, provided := e.variables[ref.Name]
+		return value, provided, nil
+	}
+	if _, ok := v.(*language.NullValue); ok {
+		if t.NonNull {
+			return nil, false, newLiteralError(path, v, "Expected value of non-null type %q, found null.", t.String())
+		}
+		return nil, true, nil
+	}
+
+	if t.Elem != nil {
+		list, ok := v.(*language.ListValue)
+		if !ok {
+			item, provided, err := e.coerceLiteral(t.Elem, v, path)
+			if err != nil || !provided {
+				return nil, provided, err
+			}
+			return []any{item}, true, nil
+		}
+		items := make([]any, len(list.Values))
+		for i, itemAST := range list.Values {
+			// An unprovided variable in a list is null.
+			item, _, err := e.coerceLiteral(t.Elem, itemAST, append(path[:len(path):len(path)], i))
+			if err != nil {
+				return nil, false, err
+			}
+			items[i] = item
+		}
+		return items, true, nil
+	}
+
+	named := e.schema.Type(t.NamedType)
+	switch named.Kind {
+	case schema.InputObject:
+		obj, ok := v.(*language.ObjectValue)
+		if !ok {
+			return nil, false, newLiteralError(path, v, "Expected value of type %q, found %s.", named.Name, language.Print(v))
+		}
+		given := make(map[string]language.Value, len(obj.Fields))
+		for _, f := range obj.Fields {
+			if named.InputField(f.Name) == nil {
+				return nil, false, newLiteralError(path, v, "Field %q is not defined by type %q.", f.Name, named.Name)
+			}
+			given[f.Name] = f.Value
+		}
+		fields := make(map[string]any, len(obj.Fields))
+		for _, def := range named.InputFields {
+			if fieldAST, ok := given[def.Name]; ok {
+				value, provided, err := e.coerceLiteral(def.Type, fieldAST, append(path[:len(path):len(path)], def.Name))
+				if err != nil {
+					return nil, false, err
+				}
+				// A field given an unprovided variable is absent, not null.
+				if provided {
+					fields[def.Name] = value
+					continue
+				}
+			}
+			switch {
+			case def.DefaultValue != nil:
+				value, _, err := e.coerceLiteral(def.Type, def.DefaultValue, append(path[:len(path):len(path)], def.Name))
+				if err != nil {
+					return nil, false, err
+				}
+				fields[def.Name] = value
+			case def.Type.NonNull:
+				return nil, false, newLiteralError(path, v, "Field \"%s.%s\" of required type %q was not provided.", named.Name, def.Name, def.Type.String())
+			}
+		}
+		return fields, true, nil
+	case schema.Enum:
+		// Enum members are also accepted as strings.
+		value, err := e.enumInput(named, valueFromAST(v, e.variables))
+		if err != nil {
+			return nil, false, newLiteralError(path, v, "%s", err.Error())
+		}
+		return value, true, nil
+	}
+
+	var value any
+	var err error
+	if sc, ok := e.scalars[named.Name]; ok && sc.ParseLiteral != nil {
+		value, err = sc.ParseLiteral(v)
+	} else {
+		value, err = e.parseScalar(named, valueFromAST(v, e.variables))
+	}
+	if err != nil {
+		return nil, false, newLiteralError(path, v, "%s", err.Error())
+	}
+	return value, true, nil
+}
+
+// enumInput returns the internal value of an enum member given by name:
+// the value registered with Builder.EnumValues, or the name itself.
+func (e *executor) enumInput(t *schema.Type, value any) (any, error) {
+	name, _ := value.(string)
+	if t.EnumValue(name) == nil {
+		return nil, fmt.Errorf("Value %s does not exist in %q enum.", jsonString(value), t.Name)
+	}
+	if internal, ok := e.server.enums[t.Name][name]; ok {
+		return internal, nil
+	}
+	return name, nil
+}
+
+// coerceScalar coerces a JSON value to one of the specified scalars.
+// Custom scalars are passed through unchanged.
+func coerceScalar(t *schema.Type, value any) (any, error) {
+	if n, ok := value.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			value = f
+		}
+	}
+	rv := reflect.ValueOf(value)
+
+	switch t.Name {
+	case "Int":
+		var f float64
+		switch {
+		case rv.CanInt():
+			f = float64(rv.Int())
+		case rv.CanUint():
+			f = float64(rv.Uint())
+		case rv.CanFloat():
+			f = rv.Float()
+		default:
+			return nil, fmt.Errorf("Int cannot represent non-integer value: %s", jsonString(value))
+		}
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("Int cannot represent non-integer value: %s", jsonString(value))
+		}
+		if f < math.MinInt32 || f > math.MaxInt32 {
+			return nil, fmt.Errorf("Int cannot represent non 32-bit signed integer value: %s", jsonString(value))
+		}
+		return int(f), nil
+	case "Float":
+		switch {
+		case rv.CanInt():
+			return float64(rv.Int()), nil
+		case rv.CanUint():
+			return float64(rv.Uint()), nil
+		case rv.CanFloat():
+			return rv.Float(), nil
+		}
+		return nil, fmt.Errorf("Float cannot represent non numeric value: %s", jsonString(value))
+	case "String":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("String cannot represent a non string value: %s", jsonString(value))
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %s", jsonString(value))
+	case "ID":
+		switch {
+		case rv.Kind() == reflect.String:
+			return rv.String(), nil
+		case rv.CanInt():
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case rv.CanFloat() && rv.Float() == math.Trunc(rv.Float()):
+			return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+		}
+		return nil, fmt.Errorf("ID cannot represent value: %s", jsonString(value))
+	}
+	return value, nil
+}
+
+// jsonString formats a value as JSON for error messages.
+func jsonString(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal RFC 6455 WebSocket implementation, enough to serve the
+// subscription protocol without third-party dependencies.
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsAcceptGUID is appended to the client key to compute the accept key.
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage is the largest message a client may send.
+const maxWebSocketMessage = 1 << 20
+
+// errWebSocketClosed is returned by readMessage once the peer has closed
+// the connection.
+var errWebSocketClosed = errors.New("websocket: connection closed")
+
+// wsConn is a server-side WebSocket connection. Writes are safe for
+// concurrent use; reads are not.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// isWebSocketUpgrade reports whether r asks to upgrade to a WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") &&
+		headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// headerContains reports whether the comma-separated values of header
+// name include token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the opening handshake, agreeing on
+// subprotocol. On failure an HTTP error has already been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, subprotocol string) (*wsConn, error) {
+	if r.Method != http.MethodGet || !isWebSocketUpgrade(r) {
+		http.Error(w, "Bad WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Bad WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+	if !headerContains(r.Header, "Sec-WebSocket-Protocol", subprotocol) {
+		http.Error(w, "Unsupported WebSocket subprotocol", http.StatusBadRequest)
+		return nil, errors.New("websocket: unsupported subprotocol")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// Deadlines set by the HTTP server would cut long-lived sockets short.
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n" +
+		"Sec-WebSocket-Protocol: " + subprotocol + "\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: rw.Reader}, nil
+}
+
+// readMessage returns the next text or binary message, answering pings
+// and reassembling fragments. It returns errWebSocketClosed after a close
+// frame.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.close(1000, "")
+			return nil, errWebSocketClosed
+		case wsText, wsBinary, wsContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				c.close(1009, "Message too big")
+				return nil, errors.New("websocket: message too big")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			c.close(1002, "Unknown opcode")
+			return nil, errors.New("websocket: unknown opcode")
+		}
+	}
+}
+
+// readFrame reads one frame, unmasking its payload.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		c.close(1002, "Client frames must be masked")
+		return false, 0, nil, errors.New("websocket: unmasked client frame")
+	}
+	if length > maxWebSocketMessage {
+		c.close(1009, "Message too big")
+		return false, 0, nil, errors.New("websocket: message too big")
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unfragmented, unmasked frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// writeJSON sends v as a text message.
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+// close sends a close frame with code and reason, then closes the
+// connection. Later calls do nothing.
+func (c *wsConn) close(code int, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.writeFrame(wsClose, append(payload, reason...))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.conn.Close()
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestConn is the client side of a test WebSocket connection.
+type wsTestConn struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialTestWebSocket opens a graphql-transport-ws connection to hs with
+// header, failing the test unless the server switches protocols.
+func dialTestWebSocket(t *testing.T, hs *httptest.Server, header http.Header) *wsTestConn {
+	t.Helper()
+	resp, c := handshakeTestWebSocket(t, hs, header)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want 101", resp.StatusCode)
+	}
+	return c
+}
+
+// handshakeTestWebSocket sends a WebSocket opening handshake to hs and
+// returns the response.
+func handshakeTestWebSocket(t *testing.T, hs *httptest.Server, header http.Header) (*http.Response, *wsTestConn) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(hs.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, hs.URL+"/graphql", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", wsSubprotocol)
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp, &wsTestConn{t: t, conn: conn, br: br}
+}
+
+// send writes a protocol message in a masked text frame.
+func (c *wsTestConn) send(format string, args ...any) {
+	c.t.Helper()
+	payload := []byte(fmt.Sprintf(format, args...))
+	frame := []byte{0x80 | wsText}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next protocol message, or the close code when the
+// server closes the connection instead.
+func (c *wsTestConn) next() (msg wsMessage, closeCode int) {
+	c.t.Helper()
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			c.t.Fatalf("read: %v", err)
+		}
+		length := int(header[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			length = int(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			io.ReadFull(c.br, ext[:])
+			length = int(binary.BigEndian.Uint64(ext[:]))
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			c.t.Fatalf("read: %v", err)
+		}
+		switch header[0] & 0x0F {
+		case wsClose:
+			return wsMessage{}, int(binary.BigEndian.Uint16(payload))
+		case wsText:
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				c.t.Fatalf("bad message %s: %v", payload, err)
+			}
+			if msg.Type == "ping" {
+				c.send(`{"type":"pong"}`)
+				continue
+			}
+			return msg, 0
+		}
+	}
+}
+
+// expect fails the test unless the next message has type typ.
+func (c *wsTestConn) expect(typ string) wsMessage {
+	c.t.Helper()
+	msg, code := c.next()
+	if msg.Type != typ {
+		c.t.Fatalf("got message %q (close code %d), want %q", msg.Type, code, typ)
+	}
+	return msg
+}
+
+func TestWebSocketOriginCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		cors   CORSConfig
+		origin string
+		want   int
+	}{
+		{"no origin", CORSConfig{}, "", http.StatusSwitchingProtocols},
+		{"same origin", CORSConfig{}, "same", http.StatusSwitchingProtocols},
+		{"cross origin", CORSConfig{}, "https://evil.example", http.StatusForbidden},
+		{"allowed origin", CORSConfig{AllowedOrigins: []string{"https://app.example"}}, "https://app.example", http.StatusSwitchingProtocols},
+		{"wildcard origin", CORSConfig{AllowedOrigins: []string{"https://*.example"}}, "https://app.example", http.StatusSwitchingProtocols},
+		{"any origin", CORSConfig{AllowedOrigins: []string{"*"}}, "https://evil.example", http.StatusSwitchingProtocols},
+		{"dynamic origin", CORSConfig{AllowOrigin: func(o string) bool { return o == "https://app.example" }}, "https://app.example", http.StatusSwitchingProtocols},
+		{"unlisted origin", CORSConfig{AllowedOrigins: []string{"https://app.example"}}, "https://evil.example", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.CORS = tt.cors
+			hs := newWhoamiServer(t, config)
+
+			header := http.Header{}
+			switch tt.origin {
+			case "":
+			case "same":
+				header.Set("Origin", hs.URL)
+			default:
+				header.Set("Origin", tt.origin)
+			}
+			resp, _ := handshakeTestWebSocket(t, hs, header)
+			if resp.StatusCode != tt.want {
+				t.Errorf("handshake status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+// ErrUnauthenticated, returned by a WebSocket connection hook, closes the
+// connection with code 4401 Unauthorized rather than 4403 Forbidden.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Close codes of graphql-transport-ws connections a hook rejected.
+const (
+	wsUnauthorized = 4401
+	wsForbidden    = 4403
+)
+
+// rejection returns the close code and reason of a connection a hook
+// rejected with err.
+func rejection(err error) (int, string) {
+	if errors.Is(err, ErrUnauthenticated) {
+		return wsUnauthorized, "Unauthorized"
+	}
+	return wsForbidden, "Forbidden"
+}
+
+// withConnectionValues returns the context and Context.Data of an
+// operation on a connection whose OnWebSocketInit or OnWebSocketPing hook
+// returned values. data is not modified.
+func withConnectionValues(ctx context.Context, data, values map[string]any) (context.Context, map[string]any) {
+	if len(values) == 0 {
+		return ctx, data
+	}
+	data = maps.Clone(data)
+	if data == nil {
+		data = make(map[string]any, len(values))
+	}
+	maps.Copy(data, values)
+	if id, ok := values["CurrentUserID"].(string); ok {
+		ctx = sdk.CurrentUserID.Set(ctx, id)
+	}
+	if roles, ok := stringList(values["UserRoles"]); ok {
+		ctx = sdk.UserRoles.Set(ctx, roles)
+	}
+	return ctx, data
+}
+
+// stringList converts a []string, or a []any of strings as decoded from
+// JSON.
+func stringList(v any) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		return v, true
+	case []any:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list[i] = s
+		}
+		return list, true
+	}
+	return nil, false
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ubugeeei/bgql/sdk"
+)
+
+func TestRejection(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"unauthenticated", ErrUnauthenticated, wsUnauthorized},
+		{"wrapped unauthenticated", fmt.Errorf("bad token: %w", ErrUnauthenticated), wsUnauthorized},
+		{"forbidden", errors.New("not allowed"), wsForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := rejection(tt.err); code != tt.want {
+				t.Errorf("rejection(%v) = %d, want %d", tt.err, code, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithConnectionValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      map[string]any
+		values    map[string]any
+		wantData  map[string]any
+		wantUser  string
+		wantRoles []string
+	}{
+		{"none", map[string]any{"a": 1}, nil, map[string]any{"a": 1}, "", nil},
+		{"merged", map[string]any{"a": 1, "tenant": "old"}, map[string]any{"tenant": "acme"},
+			map[string]any{"a": 1, "tenant": "acme"}, "", nil},
+		{"sdk keys", nil, map[string]any{"CurrentUserID": "u1", "UserRoles": []any{"admin", "dev"}},
+			map[string]any{"CurrentUserID": "u1", "UserRoles": []any{"admin", "dev"}}, "u1", []string{"admin", "dev"}},
+		{"mistyped sdk keys", nil, map[string]any{"CurrentUserID": 1, "UserRoles": []any{"admin", 2}},
+			map[string]any{"CurrentUserID": 1, "UserRoles": []any{"admin", 2}}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := fmt.Sprint(tt.data)
+			ctx, data := withConnectionValues(context.Background(), tt.data, tt.values)
+			if !reflect.DeepEqual(data, tt.wantData) {
+				t.Errorf("data = %v, want %v", data, tt.wantData)
+			}
+			if fmt.Sprint(tt.data) != before {
+				t.Errorf("data was modified: %v", tt.data)
+			}
+			if user, _ := sdk.CurrentUserID.Get(ctx); user != tt.wantUser {
+				t.Errorf("CurrentUserID = %q, want %q", user, tt.wantUser)
+			}
+			if roles, _ := sdk.UserRoles.Get(ctx); !reflect.DeepEqual(roles, tt.wantRoles) {
+				t.Errorf("UserRoles = %v, want %v", roles, tt.wantRoles)
+			}
+		})
+	}
+}
@@ -50,14 +50,27 @@ func (r *GraphQLResponse[T]) HasErrors() bool {
 	return len(r.Errors) > 0
 }
 
+// Version is the bgql Go SDK version.
+const Version = "0.1.0"
+
+// DefaultUserAgent is the User-Agent sent when ClientConfig.UserAgent is empty.
+const DefaultUserAgent = "bgql-go/" + Version
+
 // ClientConfig configures the GraphQL client.
 type ClientConfig struct {
-	URL          string
-	Timeout      time.Duration
-	MaxRetries   int
-	RetryDelay   time.Duration
-	Headers      http.Header
-	HTTPClient   *http.Client
+	URL        string
+	Timeout    time.Duration
+	MaxRetries int
+	RetryDelay time.Duration
+	Headers    http.Header
+	HTTPClient *http.Client
+
+	// UserAgent overrides DefaultUserAgent.
+	UserAgent string
+	// ClientName and ClientVersion are sent as the
+	// apollographql-client-name/-version headers when set.
+	ClientName    string
+	ClientVersion string
 }
 
 // DefaultConfig returns default client configuration.
@@ -105,7 +118,8 @@ func Execute[TVariables, TData any](
 	}
 
 	if response.HasErrors() {
-		return Err[TData](NewError(ErrExecutionError, response.Errors[0].Message).
+		return Err[TData](NewError(ErrExecutionError, "GraphQL execution failed").
+			WithCause(GraphQLErrors(response.Errors)).
 			WithExtension("graphqlErrors", response.Errors))
 	}
 
@@ -164,9 +178,14 @@ func (c *Client) doRequest(
 	variables any,
 	operationName string,
 ) ([]byte, error) {
+	vars, err := marshalVariables(variables)
+	if err != nil {
+		return nil, NewError(ErrParseError, "Failed to marshal variables").WithCause(err)
+	}
+
 	reqBody := GraphQLRequest{
 		Query:         query,
-		Variables:     variables,
+		Variables:     vars,
 		OperationName: operationName,
 	}
 
@@ -181,6 +200,19 @@ func (c *Client) doRequest(
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+
+	userAgent := c.config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if c.config.ClientName != "" {
+		req.Header.Set("apollographql-client-name", c.config.ClientName)
+	}
+	if c.config.ClientVersion != "" {
+		req.Header.Set("apollographql-client-version", c.config.ClientVersion)
+	}
+
 	for key, values := range c.config.Headers {
 		for _, value := range values {
 			req.Header.Add(key, value)
@@ -0,0 +1,114 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ClientConfig
+		want   map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"User-Agent":                   DefaultUserAgent,
+				"apollographql-client-name":    "",
+				"apollographql-client-version": "",
+			},
+		},
+		{
+			name:   "overridden user agent",
+			config: ClientConfig{UserAgent: "my-app/2.0"},
+			want:   map[string]string{"User-Agent": "my-app/2.0"},
+		},
+		{
+			name:   "client identification",
+			config: ClientConfig{ClientName: "web", ClientVersion: "1.4.0"},
+			want: map[string]string{
+				"User-Agent":                   DefaultUserAgent,
+				"apollographql-client-name":    "web",
+				"apollographql-client-version": "1.4.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(chan http.Header, 1)
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got <- r.Header.Clone()
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data":{"a":1}}`))
+			}))
+			defer hs.Close()
+
+			config := tt.config
+			config.URL = hs.URL
+			if _, err := ExecuteRaw[map[string]int](NewClient(config), context.Background(), "{ a }", nil, ""); err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			header := <-got
+			for name, want := range tt.want {
+				if v := header.Get(name); v != want {
+					t.Errorf("%s = %q, want %q", name, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteGraphQLErrors(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":null,"errors":[` +
+			`{"message":"a failed","path":["a"],"extensions":{"code":"A"}},` +
+			`{"message":"b failed","path":["b",0],"locations":[{"line":1,"column":5}],"extensions":{"code":"B"}}]}`))
+	}))
+	defer hs.Close()
+
+	op := Operation[struct{}, map[string]int]{Query: "{ a b }"}
+	err := Execute(NewClient(ClientConfig{URL: hs.URL}), context.Background(), op, struct{}{}).Error()
+	if err == nil || !strings.Contains(err.Error(), "a failed; b failed") {
+		t.Fatalf("error = %v, want both messages", err)
+	}
+	if !errors.Is(err, NewError(ErrExecutionError, "")) {
+		t.Errorf("error %v is not an execution error", err)
+	}
+
+	var all GraphQLErrors
+	if !errors.As(err, &all) {
+		t.Fatalf("error %v has no GraphQLErrors", err)
+	}
+	tests := []struct {
+		message   string
+		path      string
+		locations string
+		code      string
+	}{
+		{"a failed", "[a]", "[]", "A"},
+		{"b failed", "[b 0]", "[{1 5}]", "B"},
+	}
+	unwrapped := all.Unwrap()
+	if len(unwrapped) != len(tests) {
+		t.Fatalf("got %d errors, want %d", len(unwrapped), len(tests))
+	}
+	for i, tt := range tests {
+		gqlErr := unwrapped[i].(*GraphQLError)
+		if gqlErr.Message != tt.message || fmt.Sprint(gqlErr.Path) != tt.path ||
+			fmt.Sprint(gqlErr.Locations) != tt.locations || gqlErr.Extensions["code"] != tt.code {
+			t.Errorf("error %d = %+v", i, gqlErr)
+		}
+	}
+	var first *GraphQLError
+	if !errors.As(err, &first) || first.Message != "a failed" {
+		t.Errorf("errors.As *GraphQLError = %+v", first)
+	}
+}
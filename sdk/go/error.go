@@ -3,6 +3,7 @@ package sdk
 
 import (
 	"fmt"
+	"strings"
 )
 
 // ErrorCode represents typed error codes for compile-time safety.
@@ -177,3 +178,25 @@ type Location struct {
 func (e *GraphQLError) Error() string {
 	return e.Message
 }
+
+// GraphQLErrors are the GraphQL errors of a response. errors.As finds
+// each as a *GraphQLError.
+type GraphQLErrors []GraphQLError
+
+// Error joins the messages of the errors.
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, gqlErr := range e {
+		messages[i] = gqlErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns each error.
+func (e GraphQLErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = &e[i]
+	}
+	return errs
+}
@@ -0,0 +1,268 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// =============================================================================
+// Nullable
+// =============================================================================
+
+// Nullable is a variable field with three states: absent (the zero value, not
+// sent at all), explicitly null, or set to a value. Use it in typed
+// variable structs to tell the server "set this field to null" apart from
+// "leave this field unchanged".
+type Nullable[T any] struct {
+	value T
+	state nullState
+}
+
+type nullState uint8
+
+const (
+	nullAbsent nullState = iota
+	nullNull
+	nullValue
+)
+
+// NullableOf returns a Nullable holding v.
+func NullableOf[T any](v T) Nullable[T] {
+	return Nullable[T]{value: v, state: nullValue}
+}
+
+// ExplicitNull returns a Nullable that is sent as null.
+func ExplicitNull[T any]() Nullable[T] {
+	return Nullable[T]{state: nullNull}
+}
+
+// IsZero reports whether the field is absent.
+func (n Nullable[T]) IsZero() bool {
+	return n.state == nullAbsent
+}
+
+// IsNull reports whether the field is explicitly null.
+func (n Nullable[T]) IsNull() bool {
+	return n.state == nullNull
+}
+
+// Get returns the value and whether one is set.
+func (n Nullable[T]) Get() (T, bool) {
+	return n.value, n.state == nullValue
+}
+
+// MarshalJSON encodes the value, or null when null or absent. Absent
+// fields are dropped by the typed-variables marshaling pass.
+func (n Nullable[T]) MarshalJSON() ([]byte, error) {
+	if n.state != nullValue {
+		return []byte("null"), nil
+	}
+	v, err := variableValue(reflect.ValueOf(n.value))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes null as an explicit null and anything else as a value.
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*n = ExplicitNull[T]()
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*n = NullableOf(v)
+	return nil
+}
+
+func (n Nullable[T]) absent() bool {
+	return n.state == nullAbsent
+}
+
+// absenter is implemented by Nullable to let the marshaling pass drop absent fields.
+type absenter interface {
+	absent() bool
+}
+
+// =============================================================================
+// Variables
+// =============================================================================
+
+// marshalVariables converts a struct or map of variables into a variables
+// map, following encoding/json field naming and dropping absent Nullable fields
+// at any depth.
+func marshalVariables(variables any) (map[string]any, error) {
+	if variables == nil {
+		return nil, nil
+	}
+	v, err := variableValue(reflect.ValueOf(variables))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("variables must be a struct or map, got %T", variables)
+	}
+	return m, nil
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// variableValue converts rv to a JSON-encodable value, recursing into
+// structs, maps, and slices so nested input objects are handled too.
+func variableValue(rv reflect.Value) (any, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+
+	if rv.Type().Implements(jsonMarshalerType) {
+		data, err := rv.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(data), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return variableValue(rv.Elem())
+	case reflect.Struct:
+		out := make(map[string]any)
+		if err := structFields(rv, out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Key().Kind() != reflect.String {
+			return rv.Interface(), nil
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			if a, ok := iter.Value().Interface().(absenter); ok && a.absent() {
+				continue
+			}
+			v, err := variableValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			out[iter.Key().String()] = v
+		}
+		return out, nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Interface(), nil
+		}
+		fallthrough
+	case reflect.Array:
+		out := make([]any, rv.Len())
+		for i := range out {
+			v, err := variableValue(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return rv.Interface(), nil
+}
+
+func structFields(rv reflect.Value, out map[string]any) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv, ft = fv.Elem(), ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := structFields(fv, out); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if a, ok := fv.Interface().(absenter); ok && a.absent() {
+			continue
+		}
+		if hasOption(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if hasOption(opts, "omitzero") && fv.IsZero() {
+			continue
+		}
+
+		v, err := variableValue(fv)
+		if err != nil {
+			return err
+		}
+		out[name] = v
+	}
+	return nil
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue mirrors encoding/json's omitempty rule.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -1,8 +1,13 @@
 package sdk
 
 import (
+	"container/list"
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/singleflight"
 )
@@ -52,19 +57,48 @@ func WrapResolver[TParent, TArgs, TResult any](
 	}
 }
 
+// ErrDataLoaderClosed is returned by Load after Close has been called.
+var ErrDataLoaderClosed = errors.New("dataloader closed")
+
+// ErrKeyNotFound is returned by LoadAll for keys the batch function did
+// not return a value for.
+var ErrKeyNotFound = errors.New("dataloader: key not found")
+
 // DataLoader provides batching and caching for data fetching.
 type DataLoader[K comparable, V any] struct {
-	batchFn  func(ctx context.Context, keys []K) (map[K]V, error)
-	cache    map[K]V
-	mu       sync.RWMutex
-	group    singleflight.Group
-	maxBatch int
+	batchFn   func(ctx context.Context, keys []K) (map[K]V, error)
+	cache     *loaderCache[V]
+	mu        sync.Mutex
+	group     singleflight.Group
+	maxBatch  int
+	keyFn     func(key any) string
+	scheduler *batchScheduler[K, V]
 }
 
 // DataLoaderConfig configures a DataLoader.
 type DataLoaderConfig struct {
 	MaxBatchSize int
+	// Deprecated: CacheEnabled has no effect; values are cached unless
+	// CacheDisabled is set.
 	CacheEnabled bool
+	// CacheTTL expires cached values this long after they are loaded or
+	// primed. Zero keeps them until cleared.
+	CacheTTL time.Duration
+	// MaxCacheSize evicts the least recently used values once the cache
+	// holds this many. Zero means no limit.
+	MaxCacheSize int
+	// CacheDisabled loads values without caching them, so each Load
+	// fetches its key anew.
+	CacheDisabled bool
+	// CacheKeyFn maps the loader's keys to the identity they are cached
+	// and fetched by, so that keys equal to the batch function, such as
+	// strings differing only in case, share a value. Defaults to the key
+	// itself.
+	CacheKeyFn func(key any) string
+	// BatchTimeout enables batching of concurrent Loads: keys are collected
+	// until the timeout elapses or MaxBatchSize keys are pending, then
+	// fetched with a single batchFn call.
+	BatchTimeout time.Duration
 }
 
 // NewDataLoader creates a new DataLoader.
@@ -72,29 +106,74 @@ func NewDataLoader[K comparable, V any](
 	batchFn func(ctx context.Context, keys []K) (map[K]V, error),
 	config *DataLoaderConfig,
 ) *DataLoader[K, V] {
+	if config == nil {
+		config = &DataLoaderConfig{}
+	}
 	maxBatch := 100
-	if config != nil && config.MaxBatchSize > 0 {
+	if config.MaxBatchSize > 0 {
 		maxBatch = config.MaxBatchSize
 	}
 
-	return &DataLoader[K, V]{
+	l := &DataLoader[K, V]{
 		batchFn:  batchFn,
-		cache:    make(map[K]V),
+		cache:    newLoaderCache[V](config),
 		maxBatch: maxBatch,
+		keyFn:    config.CacheKeyFn,
+	}
+
+	if config.BatchTimeout > 0 {
+		l.scheduler = newBatchScheduler(batchFn, config.BatchTimeout, maxBatch, config.CacheKeyFn)
+		// The scheduler goroutine only references the scheduler, so the
+		// loader can be collected and the goroutine stopped with it.
+		runtime.SetFinalizer(l, func(l *DataLoader[K, V]) {
+			l.scheduler.close()
+		})
+	}
+
+	return l
+}
+
+// Close stops the batch scheduler. Pending and subsequent Loads fail with
+// ErrDataLoaderClosed. It is a no-op when BatchTimeout is not set.
+func (l *DataLoader[K, V]) Close() {
+	if l.scheduler != nil {
+		l.scheduler.close()
 	}
 }
 
+// cacheKey returns the identity of key in the cache.
+func (l *DataLoader[K, V]) cacheKey(key K) any {
+	return cacheKey(l.keyFn, key)
+}
+
 // Load loads a single value by key.
 func (l *DataLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
-	l.mu.RLock()
-	if value, ok := l.cache[key]; ok {
-		l.mu.RUnlock()
+	ck := l.cacheKey(key)
+	l.mu.Lock()
+	if value, ok := l.cache.get(ck); ok {
+		l.mu.Unlock()
+		return value, nil
+	}
+	l.mu.Unlock()
+
+	if l.scheduler != nil {
+		value, err := l.scheduler.load(ctx, key)
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		l.mu.Lock()
+		l.cache.set(ck, value)
+		l.mu.Unlock()
 		return value, nil
 	}
-	l.mu.RUnlock()
 
 	// Use singleflight to deduplicate requests
-	result, err, _ := l.group.Do(keyToString(key), func() (any, error) {
+	flight := keyToString(key)
+	if l.keyFn != nil {
+		flight = l.keyFn(key)
+	}
+	result, err, _ := l.group.Do(flight, func() (any, error) {
 		results, err := l.batchFn(ctx, []K{key})
 		if err != nil {
 			return nil, err
@@ -102,7 +181,7 @@ func (l *DataLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
 
 		l.mu.Lock()
 		for k, v := range results {
-			l.cache[k] = v
+			l.cache.set(l.cacheKey(k), v)
 		}
 		l.mu.Unlock()
 
@@ -117,20 +196,25 @@ func (l *DataLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
 	return result.(V), nil
 }
 
-// LoadMany loads multiple values by keys.
+// LoadMany loads multiple values by keys, fetching those not cached with a
+// single batchFn call. It returns the values by key: keys the batch
+// function omits are absent. LoadAll returns them in the order of keys.
 func (l *DataLoader[K, V]) LoadMany(ctx context.Context, keys []K) (map[K]V, error) {
 	results := make(map[K]V)
 	var missing []K
+	seen := make(map[any]bool)
 
-	l.mu.RLock()
+	l.mu.Lock()
 	for _, key := range keys {
-		if value, ok := l.cache[key]; ok {
+		ck := l.cacheKey(key)
+		if value, ok := l.cache.get(ck); ok {
 			results[key] = value
-		} else {
+		} else if !seen[ck] {
+			seen[ck] = true
 			missing = append(missing, key)
 		}
 	}
-	l.mu.RUnlock()
+	l.mu.Unlock()
 
 	if len(missing) == 0 {
 		return results, nil
@@ -141,32 +225,276 @@ func (l *DataLoader[K, V]) LoadMany(ctx context.Context, keys []K) (map[K]V, err
 		return nil, err
 	}
 
+	byKey := make(map[any]V, len(loaded))
 	l.mu.Lock()
 	for k, v := range loaded {
-		l.cache[k] = v
+		ck := l.cacheKey(k)
+		l.cache.set(ck, v)
+		byKey[ck] = v
 		results[k] = v
 	}
 	l.mu.Unlock()
+	// Keys equal to a fetched one under CacheKeyFn share its value.
+	for _, key := range keys {
+		if _, ok := results[key]; !ok {
+			if v, ok := byKey[l.cacheKey(key)]; ok {
+				results[key] = v
+			}
+		}
+	}
 
 	return results, nil
 }
 
+// LoadAll loads values by keys, like LoadMany, returning the values and
+// errors in the order of keys, duplicates included. The error of a key is
+// nil, the batch function's error, or ErrKeyNotFound if no value was
+// returned for it.
+func (l *DataLoader[K, V]) LoadAll(ctx context.Context, keys []K) ([]V, []error) {
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	found, err := l.LoadMany(ctx, keys)
+	for i, key := range keys {
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if value, ok := found[key]; ok {
+			values[i] = value
+		} else {
+			errs[i] = fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+		}
+	}
+	return values, errs
+}
+
 // Clear clears the cache.
 func (l *DataLoader[K, V]) Clear() {
 	l.mu.Lock()
-	l.cache = make(map[K]V)
+	l.cache.clear()
 	l.mu.Unlock()
 }
 
-// Prime primes the cache with a value.
+// Prime primes the cache with a value. It is a no-op when the cache is
+// disabled.
 func (l *DataLoader[K, V]) Prime(key K, value V) {
 	l.mu.Lock()
-	l.cache[key] = value
+	l.cache.set(l.cacheKey(key), value)
 	l.mu.Unlock()
 }
 
+// =============================================================================
+// Loader Cache
+// =============================================================================
+
+// loaderCache holds the values of a DataLoader by cache key, evicting them
+// by age and recent use. It is guarded by the loader's mutex.
+type loaderCache[V any] struct {
+	ttl      time.Duration
+	capacity int
+	disabled bool
+	order    *list.List // most recently used first
+	entries  map[any]*list.Element
+}
+
+type loaderEntry[V any] struct {
+	key     any
+	value   V
+	expires time.Time
+}
+
+func newLoaderCache[V any](config *DataLoaderConfig) *loaderCache[V] {
+	return &loaderCache[V]{
+		ttl:      config.CacheTTL,
+		capacity: config.MaxCacheSize,
+		disabled: config.CacheDisabled,
+		order:    list.New(),
+		entries:  make(map[any]*list.Element),
+	}
+}
+
+func (c *loaderCache[V]) get(key any) (V, bool) {
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*loaderEntry[V])
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *loaderCache[V]) set(key any, value V) {
+	if c.disabled {
+		return
+	}
+	entry := &loaderEntry[V]{key: key, value: value}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*loaderEntry[V]).key)
+	}
+}
+
+func (c *loaderCache[V]) clear() {
+	c.order.Init()
+	c.entries = make(map[any]*list.Element)
+}
+
+// =============================================================================
+// Batch Scheduler
+// =============================================================================
+
+// batchScheduler collects keys from concurrent Loads and dispatches them
+// to batchFn together.
+type batchScheduler[K comparable, V any] struct {
+	batchFn  func(ctx context.Context, keys []K) (map[K]V, error)
+	timeout  time.Duration
+	maxBatch int
+	keyFn    func(key any) string
+
+	requests  chan batchRequest[K, V]
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type batchRequest[K comparable, V any] struct {
+	ctx   context.Context
+	key   K
+	reply chan batchReply[V]
+}
+
+type batchReply[V any] struct {
+	value V
+	err   error
+}
+
+func newBatchScheduler[K comparable, V any](
+	batchFn func(ctx context.Context, keys []K) (map[K]V, error),
+	timeout time.Duration,
+	maxBatch int,
+	keyFn func(key any) string,
+) *batchScheduler[K, V] {
+	s := &batchScheduler[K, V]{
+		batchFn:  batchFn,
+		timeout:  timeout,
+		maxBatch: maxBatch,
+		keyFn:    keyFn,
+		requests: make(chan batchRequest[K, V]),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *batchScheduler[K, V]) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// load enqueues key and waits for the batch containing it.
+func (s *batchScheduler[K, V]) load(ctx context.Context, key K) (V, error) {
+	var zero V
+	req := batchRequest[K, V]{ctx: ctx, key: key, reply: make(chan batchReply[V], 1)}
+
+	select {
+	case s.requests <- req:
+	case <-s.done:
+		return zero, ErrDataLoaderClosed
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+
+	select {
+	case reply := <-req.reply:
+		return reply.value, reply.err
+	case <-s.done:
+		return zero, ErrDataLoaderClosed
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// run accumulates requests into batches until closed.
+func (s *batchScheduler[K, V]) run() {
+	for {
+		var first batchRequest[K, V]
+		select {
+		case first = <-s.requests:
+		case <-s.done:
+			return
+		}
+
+		// The batch serves many callers, so one caller cancelling must not
+		// fail the others; each caller still stops waiting on its own ctx.
+		ctx := context.WithoutCancel(first.ctx)
+		keys := []K{first.key}
+		waiters := map[any][]chan batchReply[V]{cacheKey(s.keyFn, first.key): {first.reply}}
+
+		timer := time.NewTimer(s.timeout)
+	collect:
+		for len(keys) < s.maxBatch {
+			select {
+			case req := <-s.requests:
+				ck := cacheKey(s.keyFn, req.key)
+				if _, ok := waiters[ck]; !ok {
+					keys = append(keys, req.key)
+				}
+				waiters[ck] = append(waiters[ck], req.reply)
+			case <-timer.C:
+				break collect
+			case <-s.done:
+				timer.Stop()
+				return
+			}
+		}
+		timer.Stop()
+
+		go s.dispatch(ctx, keys, waiters)
+	}
+}
+
+// dispatch calls batchFn once and distributes the results.
+func (s *batchScheduler[K, V]) dispatch(ctx context.Context, keys []K, waiters map[any][]chan batchReply[V]) {
+	results, err := s.batchFn(ctx, keys)
+	byKey := make(map[any]V, len(results))
+	for key, v := range results {
+		byKey[cacheKey(s.keyFn, key)] = v
+	}
+	for ck, replies := range waiters {
+		reply := batchReply[V]{value: byKey[ck], err: err}
+		for _, ch := range replies {
+			ch <- reply
+		}
+	}
+}
+
+// cacheKey returns the identity of key under keyFn, or key itself.
+func cacheKey[K comparable](keyFn func(key any) string, key K) any {
+	if keyFn != nil {
+		return keyFn(key)
+	}
+	return key
+}
+
+// keyToString formats key for singleflight. The Go syntax keeps unequal
+// keys apart, such as 1 and "1" in an interface or struct fields holding
+// spaces, which %v prints alike.
 func keyToString[K any](key K) string {
-	return fmt.Sprintf("%v", key)
+	return fmt.Sprintf("%#v", key)
 }
 
 // FieldResolver wraps a typed resolver with error handling.
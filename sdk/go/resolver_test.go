@@ -0,0 +1,265 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingLoader returns a loader of upper-cased strings and the number of
+// batch function calls it made.
+func countingLoader(config *DataLoaderConfig) (*DataLoader[string, string], *atomic.Int32) {
+	var calls atomic.Int32
+	return NewDataLoader(func(ctx context.Context, keys []string) (map[string]string, error) {
+		calls.Add(1)
+		values := make(map[string]string, len(keys))
+		for _, k := range keys {
+			values[k] = strings.ToUpper(k)
+		}
+		return values, nil
+	}, config), &calls
+}
+
+func TestDataLoaderBatchTimeout(t *testing.T) {
+	var calls atomic.Int32
+	var batchSize atomic.Int32
+	loader := NewDataLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		calls.Add(1)
+		batchSize.Store(int32(len(keys)))
+		values := make(map[int]int, len(keys))
+		for _, k := range keys {
+			values[k] = k * 2
+		}
+		return values, nil
+	}, &DataLoaderConfig{BatchTimeout: 50 * time.Millisecond})
+	defer loader.Close()
+
+	// The goroutines are started first and released together, so that
+	// their Loads fall within the window.
+	start := make(chan struct{})
+	var ready, wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		ready.Add(1)
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			ready.Done()
+			<-start
+			if v, err := loader.Load(context.Background(), key); err != nil || v != key*2 {
+				t.Errorf("Load(%d) = %d, %v, want %d", key, v, err, key*2)
+			}
+		}(i)
+	}
+	ready.Wait()
+	close(start)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("batch function called %d times, want 1", n)
+	}
+	if n := batchSize.Load(); n != 30 {
+		t.Errorf("batch had %d keys, want 30", n)
+	}
+}
+
+func TestDataLoaderClose(t *testing.T) {
+	loader, _ := countingLoader(&DataLoaderConfig{BatchTimeout: time.Millisecond})
+	if _, err := loader.Load(context.Background(), "a"); err != nil {
+		t.Fatalf("Load before Close: %v", err)
+	}
+	loader.Close()
+	loader.Close()
+	if _, err := loader.Load(context.Background(), "b"); !errors.Is(err, ErrDataLoaderClosed) {
+		t.Errorf("Load after Close error = %v, want ErrDataLoaderClosed", err)
+	}
+}
+
+func TestDataLoaderCacheControls(t *testing.T) {
+	type step struct {
+		key       string
+		wantFetch bool
+	}
+	tests := []struct {
+		name   string
+		config DataLoaderConfig
+		prime  string
+		steps  []step
+	}{
+		{"cached", DataLoaderConfig{}, "", []step{{"a", true}, {"a", false}, {"b", true}}},
+		{"ttl", DataLoaderConfig{CacheTTL: 20 * time.Millisecond}, "", []step{{"a", true}, {"a", false}, {"sleep", false}, {"a", true}}},
+		{"lru", DataLoaderConfig{MaxCacheSize: 2}, "", []step{{"a", true}, {"b", true}, {"a", false}, {"c", true}, {"a", false}, {"b", true}}},
+		{"disabled", DataLoaderConfig{CacheDisabled: true}, "a", []step{{"a", true}, {"a", true}}},
+		{"primed", DataLoaderConfig{}, "a", []step{{"a", false}}},
+		{"batched lru", DataLoaderConfig{MaxCacheSize: 1, BatchTimeout: time.Millisecond}, "", []step{{"a", true}, {"a", false}, {"b", true}, {"a", true}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			loader, calls := countingLoader(&tt.config)
+			defer loader.Close()
+			if tt.prime != "" {
+				loader.Prime(tt.prime, strings.ToUpper(tt.prime))
+			}
+			for i, s := range tt.steps {
+				if s.key == "sleep" {
+					time.Sleep(40 * time.Millisecond)
+					continue
+				}
+				before := calls.Load()
+				if v, err := loader.Load(context.Background(), s.key); err != nil || v != strings.ToUpper(s.key) {
+					t.Fatalf("step %d: Load(%q) = %q, %v", i, s.key, v, err)
+				}
+				if fetch := calls.Load() > before; fetch != s.wantFetch {
+					t.Errorf("step %d: Load(%q) fetched = %v, want %v", i, s.key, fetch, s.wantFetch)
+				}
+			}
+		})
+	}
+}
+
+func TestDataLoaderCacheConcurrentAccess(t *testing.T) {
+	loader, _ := countingLoader(&DataLoaderConfig{MaxCacheSize: 8, CacheTTL: time.Millisecond})
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := keys[(g*7+i)%len(keys)]
+				switch i % 10 {
+				case 0:
+					loader.Prime(key, strings.ToUpper(key))
+				case 1:
+					loader.Clear()
+				case 2:
+					if values, errs := loader.LoadAll(context.Background(), []string{key, "z"}); errs[0] != nil || values[1] != "Z" {
+						t.Errorf("LoadAll = %v, %v", values, errs)
+						return
+					}
+				default:
+					if v, err := loader.Load(context.Background(), key); err != nil || v != strings.ToUpper(key) {
+						t.Errorf("Load(%q) = %q, %v", key, v, err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestDataLoaderPrimeUsesCacheKeyFn(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DataLoaderConfig
+	}{
+		{"direct", DataLoaderConfig{CacheKeyFn: func(key any) string { return strings.ToLower(key.(string)) }}},
+		{"batched", DataLoaderConfig{
+			CacheKeyFn:   func(key any) string { return strings.ToLower(key.(string)) },
+			BatchTimeout: time.Millisecond,
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			loader, calls := countingLoader(&tt.config)
+			defer loader.Close()
+			loader.Prime("Alice", "primed")
+
+			for _, key := range []string{"Alice", "alice", "ALICE"} {
+				v, err := loader.Load(context.Background(), key)
+				if err != nil || v != "primed" {
+					t.Errorf("Load(%q) = %q, %v, want the primed value", key, v, err)
+				}
+			}
+			values, errs := loader.LoadAll(context.Background(), []string{"aLiCe"})
+			if errs[0] != nil || values[0] != "primed" {
+				t.Errorf("LoadAll = %q, %v, want the primed value", values[0], errs[0])
+			}
+			if n := calls.Load(); n != 0 {
+				t.Errorf("batch function called %d times, want 0", n)
+			}
+		})
+	}
+}
+
+func TestDataLoaderLoadAll(t *testing.T) {
+	errBatch := errors.New("batch failed")
+	tests := []struct {
+		name       string
+		keys       []string
+		batchErr   error
+		wantValues []string
+		wantErrs   []error
+		wantKeys   []string
+	}{
+		{"ordered", []string{"c", "a", "b"}, nil, []string{"C", "A", "B"}, []error{nil, nil, nil}, []string{"c", "a", "b"}},
+		{"duplicates", []string{"a", "b", "a"}, nil, []string{"A", "B", "A"}, []error{nil, nil, nil}, []string{"a", "b"}},
+		{"missing", []string{"a", "missing", "b", "missing"}, nil, []string{"A", "", "B", ""}, []error{nil, ErrKeyNotFound, nil, ErrKeyNotFound}, []string{"a", "missing", "b"}},
+		{"batch error", []string{"a", "b"}, errBatch, []string{"", ""}, []error{errBatch, errBatch}, []string{"a", "b"}},
+		{"empty", nil, nil, []string{}, []error{}, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var batches [][]string
+			loader := NewDataLoader(func(ctx context.Context, keys []string) (map[string]string, error) {
+				batches = append(batches, keys)
+				if tt.batchErr != nil {
+					return nil, tt.batchErr
+				}
+				values := make(map[string]string, len(keys))
+				for _, k := range keys {
+					if k != "missing" {
+						values[k] = strings.ToUpper(k)
+					}
+				}
+				return values, nil
+			}, nil)
+
+			values, errs := loader.LoadAll(context.Background(), tt.keys)
+			if len(values) != len(tt.wantValues) || len(errs) != len(tt.wantErrs) {
+				t.Fatalf("LoadAll = %q, %v; want %q, %v", values, errs, tt.wantValues, tt.wantErrs)
+			}
+			for i := range tt.wantValues {
+				if values[i] != tt.wantValues[i] {
+					t.Errorf("values[%d] = %q, want %q", i, values[i], tt.wantValues[i])
+				}
+				if !errors.Is(errs[i], tt.wantErrs[i]) || (errs[i] == nil) != (tt.wantErrs[i] == nil) {
+					t.Errorf("errs[%d] = %v, want %v", i, errs[i], tt.wantErrs[i])
+				}
+			}
+			if len(tt.wantKeys) == 0 {
+				if len(batches) != 0 {
+					t.Errorf("batches = %q, want none", batches)
+				}
+				return
+			}
+			if len(batches) != 1 || strings.Join(batches[0], ",") != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("batches = %q, want one of %q", batches, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestDataLoaderLoadAllUsesCache(t *testing.T) {
+	loader, calls := countingLoader(nil)
+	loader.Prime("a", "primed")
+	values, errs := loader.LoadAll(context.Background(), []string{"a", "b"})
+	if values[0] != "primed" || values[1] != "B" || errs[0] != nil || errs[1] != nil {
+		t.Fatalf("LoadAll = %q, %v", values, errs)
+	}
+	values, _ = loader.LoadAll(context.Background(), []string{"b", "a"})
+	if values[0] != "B" || values[1] != "primed" {
+		t.Fatalf("LoadAll = %q", values)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("batch calls = %d, want 1", n)
+	}
+}